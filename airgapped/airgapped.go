@@ -1,11 +1,14 @@
 package airgapped
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
 	"sync"
+	"time"
 
 	vss "github.com/corestario/kyber/share/vss/rabin"
 
@@ -15,6 +18,7 @@ import (
 	"github.com/lidofinance/dc4bc/dkg"
 	"github.com/lidofinance/dc4bc/fsm/fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/types/requests"
@@ -29,8 +33,9 @@ const (
 type Machine struct {
 	sync.Mutex
 
-	dkgInstances map[string]*dkg.DKG
-	qrProcessor  qr.Processor
+	dkgInstances  map[string]*dkg.DKG
+	qrProcessor   qr.Processor
+	fileProcessor *qr.FileProcessor
 
 	encryptionKey []byte
 	pubKey        kyber.Point
@@ -40,6 +45,10 @@ type Machine struct {
 
 	db             *leveldb.DB
 	resultQRFolder string
+
+	reviewer OperationReviewer
+	// now is a clock of the signing policy cooldown
+	now func() time.Time
 }
 
 func NewMachine(dbPath string) (*Machine, error) {
@@ -50,7 +59,9 @@ func NewMachine(dbPath string) (*Machine, error) {
 	am := &Machine{
 		dkgInstances: make(map[string]*dkg.DKG),
 		qrProcessor:  qr.NewCameraProcessor(),
+		now:          time.Now,
 	}
+	am.fileProcessor = qr.NewFileProcessor(&fileSigner{am: am}, verifyHotNodeFileSignature)
 
 	if am.db, err = leveldb.OpenFile(dbPath, nil); err != nil {
 		return nil, fmt.Errorf("failed to open db file %s for keys: %w", dbPath, err)
@@ -91,6 +102,7 @@ func (am *Machine) SetResultQRFolder(resultQRFolder string) {
 }
 
 // InitKeys load keys public and private keys for DKG from LevelDB. If keys does not exist, creates them.
+// Saved states of DKG rounds are restored with the keys
 func (am *Machine) InitKeys() error {
 	err := am.LoadKeysFromDB()
 	if err != nil && err != leveldb.ErrNotFound {
@@ -103,6 +115,9 @@ func (am *Machine) InitKeys() error {
 		return am.SaveKeysToDB()
 	}
 
+	if err = am.loadDKGStates(); err != nil {
+		return fmt.Errorf("failed to load dkg states: %w", err)
+	}
 	return nil
 }
 
@@ -116,6 +131,14 @@ func (am *Machine) SensitiveDataRemoved() bool {
 	return len(am.encryptionKey) == 0
 }
 
+// checkPassword returns an error unless the password matches the encryption key set on the machine
+func (am *Machine) checkPassword(password []byte) error {
+	if am.SensitiveDataRemoved() || subtle.ConstantTimeCompare(password, am.encryptionKey) != 1 {
+		return errors.New("invalid encryption password")
+	}
+	return nil
+}
+
 // DropSensitiveData remove sensitive data from memory
 func (am *Machine) DropSensitiveData() {
 	am.Lock()
@@ -127,12 +150,17 @@ func (am *Machine) DropSensitiveData() {
 	am.encryptionKey = nil
 }
 
+// ReplayOperationsLog rebuilds the state of the DKG round from the operation log. The state is saved after every
+// operation and restored by InitKeys, so the replay is needed only for rounds started before the states were saved
 func (am *Machine) ReplayOperationsLog(dkgIdentifier string) error {
 	operationsLog, err := am.getOperationsLog(dkgIdentifier)
 	if err != nil {
 		return fmt.Errorf("failed to getOperationsLog: %w", err)
 	}
 
+	// the round starts over, a restored state would be handled twice
+	delete(am.dkgInstances, dkgIdentifier)
+
 	for _, operation := range operationsLog {
 		if _, err := am.HandleOperation(operation); err != nil {
 			return fmt.Errorf(
@@ -180,6 +208,10 @@ func (am *Machine) encryptDataForParticipant(dkgIdentifier, to string, data []by
 
 // decryptDataFromParticipant decrypts the data that was sent to us
 func (am *Machine) decryptDataFromParticipant(data []byte) ([]byte, error) {
+	// ecies expects an ephemeral point in front of a ciphertext and panics on shorter data
+	if len(data) < am.baseSuite.PointLen() {
+		return nil, fmt.Errorf("failed to decrypt data: data is too short")
+	}
 	decryptedData, err := ecies.Decrypt(am.baseSuite, am.secKey, data, am.baseSuite.Hash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt data: %w", err)
@@ -212,6 +244,8 @@ func (am *Machine) handleOperation(operation client.Operation) (client.Operation
 		err = am.handleStateDkgDealsAwaitConfirmations(&operation)
 	case dkg_proposal_fsm.StateDkgResponsesAwaitConfirmations:
 		err = am.handleStateDkgResponsesAwaitConfirmations(&operation)
+	case dkg_proposal_fsm.StateDkgJustificationsAwaitConfirmations:
+		err = am.handleStateDkgJustificationsAwaitConfirmations(&operation)
 	case dkg_proposal_fsm.StateDkgMasterKeyAwaitConfirmations:
 		err = am.handleStateDkgMasterKeyAwaitConfirmations(&operation)
 	case signing_proposal_fsm.StateSigningAwaitConfirmations:
@@ -220,6 +254,14 @@ func (am *Machine) handleOperation(operation client.Operation) (client.Operation
 		err = am.handleStateSigningAwaitPartialSigns(&operation)
 	case signing_proposal_fsm.StateSigningPartialSignsCollected:
 		err = am.reconstructThresholdSignature(&operation)
+	case resharing_proposal_fsm.StateResharingProposalAwaitConfirmations:
+		err = am.handleStateResharingProposalAwaitConfirmations(&operation)
+	case resharing_proposal_fsm.StateResharingDealsAwaitConfirmations:
+		err = am.handleStateResharingDealsAwaitConfirmations(&operation)
+	case resharing_proposal_fsm.StateResharingResponsesAwaitConfirmations:
+		err = am.handleStateResharingResponsesAwaitConfirmations(&operation)
+	case resharing_proposal_fsm.StateResharingMasterKeyAwaitConfirmations:
+		err = am.handleStateResharingMasterKeyAwaitConfirmations(&operation)
 	default:
 		err = fmt.Errorf("invalid operation type: %s", operation.Type)
 	}
@@ -233,35 +275,41 @@ func (am *Machine) handleOperation(operation client.Operation) (client.Operation
 		}
 	}
 
+	if err = am.saveDKGState(operation.DKGIdentifier); err != nil {
+		return operation, fmt.Errorf("failed to save dkg state: %w", err)
+	}
+
 	return operation, nil
 }
 
 // HandleQR - gets an operation from a QR code, do necessary things for the operation and returns paths to QR-code images
 func (am *Machine) HandleQR() (string, error) {
-	var (
-		err error
-
-		// input operation
-		operation client.Operation
-		qrData    []byte
-
-		resultOperation client.Operation
-	)
-
-	if qrData, err = am.qrProcessor.ReadQR(); err != nil {
+	qrData, err := am.qrProcessor.ReadQR()
+	if err != nil {
 		return "", fmt.Errorf("failed to read QR: %w", err)
 	}
-	if err = json.Unmarshal(qrData, &operation); err != nil {
-		return "", fmt.Errorf("failed to unmarshal operation: %w", err)
-	}
 
-	if resultOperation, err = am.HandleOperation(operation); err != nil {
-		return "", err
+	return am.handleQRData(qrData)
+}
+
+// HandleQRFromFiles - same as HandleQR, but reads QR codes from image files (e.g. the GIF made by the client)
+// instead of the camera, so it works on a machine without a camera or a display
+func (am *Machine) HandleQRFromFiles(paths []string) (string, error) {
+	processor := qr.NewImageProcessor()
+	processor.SetPaths(paths...)
+	qrData, err := processor.ReadQR()
+	if err != nil {
+		return "", fmt.Errorf("failed to read QR from files: %w", err)
 	}
 
-	operationBz, err := json.Marshal(resultOperation)
+	return am.handleQRData(qrData)
+}
+
+// handleQRData handles an operation read from QR codes and saves the result operation to QR codes
+func (am *Machine) handleQRData(qrData []byte) (string, error) {
+	resultOperation, operationBz, err := am.handleOperationJSON(qrData, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal operation: %w", err)
+		return "", err
 	}
 
 	qrPath := filepath.Join(am.resultQRFolder, fmt.Sprintf("dc4bc_qr_%s-response.gif", resultOperation.ID))
@@ -272,15 +320,79 @@ func (am *Machine) HandleQR() (string, error) {
 	return qrPath, nil
 }
 
+// HandleFile - gets an operation from a signed file, do necessary things for the operation and writes the result
+// operation to a signed file in the same folder. It returns a path to the result file and a pub key of the hot node
+// which signed the operation file. The key of the first approved file is pinned, files signed by another key are refused
+func (am *Machine) HandleFile(path string) (string, []byte, error) {
+	operationData, signerPubKey, err := am.fileProcessor.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read operation file: %w", err)
+	}
+
+	if err = am.checkHotNodePubKey(signerPubKey); err != nil {
+		return "", nil, err
+	}
+
+	resultOperation, operationBz, err := am.handleOperationJSON(operationData, signerPubKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resultPath := filepath.Join(filepath.Dir(path), fmt.Sprintf("dc4bc_file_%s-response.json", resultOperation.ID))
+	if err = am.fileProcessor.WriteFile(resultPath, operationBz); err != nil {
+		return "", nil, fmt.Errorf("failed to write operation file: %w", err)
+	}
+
+	return resultPath, signerPubKey, nil
+}
+
+// handleOperationJSON handles a JSON-encoded operation approved by the reviewer and returns the result operation
+// with its JSON, a rejected operation is returned with a decline or an error response. The key of the hot node
+// which signed the operation is shown to the reviewer and pinned once the operation is approved
+func (am *Machine) handleOperationJSON(operationData []byte, signerPubKey []byte) (client.Operation, []byte, error) {
+	var operation client.Operation
+	if err := json.Unmarshal(operationData, &operation); err != nil {
+		return client.Operation{}, nil, fmt.Errorf("failed to unmarshal operation: %w", err)
+	}
+
+	resultOperation, approved, err := am.reviewOperation(operation, signerPubKey)
+	if err != nil {
+		return client.Operation{}, nil, err
+	}
+	if approved {
+		if signerPubKey != nil {
+			if err = am.pinHotNodePubKey(signerPubKey); err != nil {
+				return client.Operation{}, nil, err
+			}
+		}
+		if resultOperation, err = am.HandleOperation(operation); err != nil {
+			return client.Operation{}, nil, err
+		}
+	}
+
+	operationBz, err := json.Marshal(resultOperation)
+	if err != nil {
+		return client.Operation{}, nil, fmt.Errorf("failed to marshal operation: %w", err)
+	}
+	return resultOperation, operationBz, nil
+}
+
 // writeErrorRequestToOperation writes error to a operation if some bad things happened
 func (am *Machine) writeErrorRequestToOperation(o *client.Operation, handlerError error) error {
 	// each type of request should have a required event even error
 	// maybe should be global?
 	eventToErrorMap := map[fsm.State]fsm.Event{
-		dkg_proposal_fsm.StateDkgCommitsAwaitConfirmations:   dkg_proposal_fsm.EventDKGCommitConfirmationError,
-		dkg_proposal_fsm.StateDkgDealsAwaitConfirmations:     dkg_proposal_fsm.EventDKGDealConfirmationError,
-		dkg_proposal_fsm.StateDkgResponsesAwaitConfirmations: dkg_proposal_fsm.EventDKGResponseConfirmationError,
-		dkg_proposal_fsm.StateDkgMasterKeyAwaitConfirmations: dkg_proposal_fsm.EventDKGMasterKeyConfirmationError,
+		dkg_proposal_fsm.StateDkgCommitsAwaitConfirmations:        dkg_proposal_fsm.EventDKGCommitConfirmationError,
+		dkg_proposal_fsm.StateDkgDealsAwaitConfirmations:          dkg_proposal_fsm.EventDKGDealConfirmationError,
+		dkg_proposal_fsm.StateDkgResponsesAwaitConfirmations:      dkg_proposal_fsm.EventDKGResponseConfirmationError,
+		dkg_proposal_fsm.StateDkgJustificationsAwaitConfirmations: dkg_proposal_fsm.EventDKGJustificationConfirmationError,
+		dkg_proposal_fsm.StateDkgMasterKeyAwaitConfirmations:      dkg_proposal_fsm.EventDKGMasterKeyConfirmationError,
+
+		resharing_proposal_fsm.StateResharingDealsAwaitConfirmations:     resharing_proposal_fsm.EventResharingDealConfirmationError,
+		resharing_proposal_fsm.StateResharingResponsesAwaitConfirmations: resharing_proposal_fsm.EventResharingResponseConfirmationError,
+		resharing_proposal_fsm.StateResharingMasterKeyAwaitConfirmations: resharing_proposal_fsm.EventResharingMasterKeyConfirmationError,
+
+		signing_proposal_fsm.StateSigningAwaitPartialSigns: signing_proposal_fsm.EventSigningPartialSignError,
 	}
 	pid, err := am.getParticipantID(o.DKGIdentifier)
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	prysmBLS "github.com/prysmaticlabs/prysm/shared/bls"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -16,10 +17,12 @@ import (
 	client "github.com/lidofinance/dc4bc/client/types"
 	"github.com/lidofinance/dc4bc/fsm/fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/types/requests"
 	"github.com/lidofinance/dc4bc/fsm/types/responses"
+	"github.com/lidofinance/dc4bc/qr"
 	"github.com/lidofinance/dc4bc/storage"
 )
 
@@ -35,9 +38,13 @@ type Node struct {
 	commits                 []requests.DKGProposalCommitConfirmationRequest
 	deals                   []requests.DKGProposalDealConfirmationRequest
 	responses               []requests.DKGProposalResponseConfirmationRequest
+	justifications          []requests.DKGProposalJustificationConfirmationRequest
 	masterKeys              []requests.DKGProposalMasterKeyConfirmationRequest
 	partialSigns            []requests.SigningProposalPartialSignRequest
 	reconstructedSignatures []client.ReconstructedSignature
+	resharingDeals          []requests.ResharingProposalDealConfirmationRequest
+	resharingResponses      []requests.ResharingProposalResponseConfirmationRequest
+	resharingMasterKeys     []requests.ResharingProposalMasterKeyConfirmationRequest
 }
 
 func (n *Node) storeOperation(t *testing.T, msg storage.Message) {
@@ -60,6 +67,12 @@ func (n *Node) storeOperation(t *testing.T, msg storage.Message) {
 			t.Fatalf("failed to unmarshal fsm req: %v", err)
 		}
 		n.responses = append(n.responses, req)
+	case dkg_proposal_fsm.EventDKGJustificationConfirmationReceived:
+		var req requests.DKGProposalJustificationConfirmationRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			t.Fatalf("failed to unmarshal fsm req: %v", err)
+		}
+		n.justifications = append(n.justifications, req)
 	case dkg_proposal_fsm.EventDKGMasterKeyConfirmationReceived:
 		var req requests.DKGProposalMasterKeyConfirmationRequest
 		if err := json.Unmarshal(msg.Data, &req); err != nil {
@@ -78,6 +91,24 @@ func (n *Node) storeOperation(t *testing.T, msg storage.Message) {
 			t.Fatalf("failed to unmarshal fsm req: %v", err)
 		}
 		n.reconstructedSignatures = append(n.reconstructedSignatures, req)
+	case resharing_proposal_fsm.EventResharingDealConfirmationReceived:
+		var req requests.ResharingProposalDealConfirmationRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			t.Fatalf("failed to unmarshal fsm req: %v", err)
+		}
+		n.resharingDeals = append(n.resharingDeals, req)
+	case resharing_proposal_fsm.EventResharingResponseConfirmationReceived:
+		var req requests.ResharingProposalResponseConfirmationRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			t.Fatalf("failed to unmarshal fsm req: %v", err)
+		}
+		n.resharingResponses = append(n.resharingResponses, req)
+	case resharing_proposal_fsm.EventResharingMasterKeyConfirmationReceived:
+		var req requests.ResharingProposalMasterKeyConfirmationRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			t.Fatalf("failed to unmarshal fsm req: %v", err)
+		}
+		n.resharingMasterKeys = append(n.resharingMasterKeys, req)
 	default:
 		t.Fatalf("invalid event: %s", msg.Event)
 	}
@@ -278,7 +309,7 @@ func TestAirgappedAllSteps(t *testing.T) {
 		defer wg.Done()
 
 		payload := responses.SigningPartialSignsParticipantInvitationsResponse{
-			SrcPayload: msgToSign,
+			SrcPayloads: [][]byte{msgToSign},
 		}
 
 		op := createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "", payload)
@@ -301,11 +332,11 @@ func TestAirgappedAllSteps(t *testing.T) {
 			p := responses.SigningProcessParticipantEntry{
 				ParticipantId: req.ParticipantId,
 				Username:      fmt.Sprintf("Participant#%d", req.ParticipantId),
-				PartialSign:   req.PartialSign,
+				PartialSigns:  req.PartialSigns,
 			}
 			payload.Participants = append(payload.Participants, &p)
 		}
-		payload.SrcPayload = msgToSign
+		payload.SrcPayloads = [][]byte{msgToSign}
 		op := createOperation(t, string(signing_proposal_fsm.StateSigningPartialSignsCollected), "", payload)
 
 		operation, err := n.Machine.HandleOperation(op)
@@ -540,7 +571,7 @@ func TestAirgappedMachine_Replay(t *testing.T) {
 		defer wg.Done()
 
 		payload := responses.SigningPartialSignsParticipantInvitationsResponse{
-			SrcPayload: msgToSign,
+			SrcPayloads: [][]byte{msgToSign},
 		}
 
 		op := createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "", payload)
@@ -563,11 +594,11 @@ func TestAirgappedMachine_Replay(t *testing.T) {
 			p := responses.SigningProcessParticipantEntry{
 				ParticipantId: req.ParticipantId,
 				Username:      fmt.Sprintf("Participant#%d", req.ParticipantId),
-				PartialSign:   req.PartialSign,
+				PartialSigns:  req.PartialSigns,
 			}
 			payload.Participants = append(payload.Participants, &p)
 		}
-		payload.SrcPayload = msgToSign
+		payload.SrcPayloads = [][]byte{msgToSign}
 		op := createOperation(t, string(signing_proposal_fsm.StateSigningPartialSignsCollected), "", payload)
 
 		operation, err := n.Machine.HandleOperation(op)
@@ -597,6 +628,83 @@ func TestAirgappedMachine_Replay(t *testing.T) {
 	fmt.Println("DKG succeeded, signature recovered and verified")
 }
 
+// restartNodes closes the machines and opens them again with the same databases, the state of the round is
+// restored from the databases only
+func restartNodes(t *testing.T, tr *Transport, testDir string) {
+	for _, n := range tr.nodes {
+		if err := n.Machine.db.Close(); err != nil {
+			t.Fatalf("%s: failed to close db: %v", n.Participant, err)
+		}
+		n.Machine = newTestNode(t, testDir, n.ParticipantID).Machine
+	}
+}
+
+func TestAirgappedMachine_RestoreState(t *testing.T) {
+	testDir := "/tmp/airgapped_restore_state_test"
+	defer os.RemoveAll(testDir)
+
+	tr := &Transport{}
+	for i := 0; i < 4; i++ {
+		tr.nodes = append(tr.nodes, newTestNode(t, testDir, i))
+	}
+
+	// the machines are restarted in the middle of the round and continue it without the operation log replay
+	runTestDKGDeals(t, tr, 3)
+	restartNodes(t, tr, testDir)
+	runTestDKGResponses(t, tr)
+	restartNodes(t, tr, testDir)
+	runTestDKGMasterKey(t, tr)
+
+	masterKey := tr.nodes[0].masterKeys[0].MasterKey
+	for _, n := range tr.nodes {
+		if len(n.masterKeys) != len(tr.nodes) {
+			t.Fatalf("%s: expected %d master keys, got %d", n.Participant, len(tr.nodes), len(n.masterKeys))
+		}
+		for _, req := range n.masterKeys {
+			if !bytes.Equal(masterKey, req.MasterKey) {
+				t.Fatalf("master keys is not equal!")
+			}
+		}
+	}
+
+	restartNodes(t, tr, testDir)
+
+	msgToSign := []byte("i am a message")
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		payload := responses.SigningPartialSignsParticipantInvitationsResponse{
+			SrcPayloads: [][]byte{msgToSign},
+		}
+		handleAndBroadcast(t, tr, n, createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "", payload))
+	})
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		payload := responses.SigningProcessParticipantResponse{
+			SrcPayloads: [][]byte{msgToSign},
+		}
+		for _, req := range n.partialSigns {
+			payload.Participants = append(payload.Participants, &responses.SigningProcessParticipantEntry{
+				ParticipantId: req.ParticipantId,
+				Username:      fmt.Sprintf("Participant#%d", req.ParticipantId),
+				PartialSigns:  req.PartialSigns,
+			})
+		}
+		handleAndBroadcast(t, tr, n, createOperation(t, string(signing_proposal_fsm.StateSigningPartialSignsCollected), "", payload))
+	})
+
+	for _, n := range tr.nodes {
+		if len(n.reconstructedSignatures) == 0 {
+			t.Fatalf("%s: signature is not reconstructed", n.Participant)
+		}
+		for _, signature := range n.reconstructedSignatures {
+			if err := n.Machine.VerifySign(msgToSign, signature.Signature, DKGIdentifier); err != nil {
+				t.Fatalf("%s: signature is not verified: %v", n.Participant, err)
+			}
+		}
+	}
+	testKyberPrysm(t, masterKey, tr.nodes[0].reconstructedSignatures[0].Signature, msgToSign)
+}
+
 func testKyberPrysm(t *testing.T, pubkey, signature, msg []byte) {
 	prysmSig, err := prysmBLS.SignatureFromBytes(signature)
 	if err != nil {
@@ -620,3 +728,44 @@ func runStep(transport *Transport, cb func(n *Node, wg *sync.WaitGroup)) {
 	}
 	wg.Wait()
 }
+
+func TestAirgappedHandleQRFromFiles(t *testing.T) {
+	var (
+		req     = require.New(t)
+		testDir = "/tmp/airgapped_qr_files_test"
+	)
+	defer os.RemoveAll(testDir)
+
+	n := newTestNode(t, testDir, 0)
+	n.Machine.SetQRProcessorChunkSize(256)
+	n.Machine.SetResultQRFolder(testDir)
+	dkgPubKey, err := n.Machine.pubKey.MarshalBinary()
+	req.NoError(err)
+
+	op := createOperation(t, string(signature_proposal_fsm.StateAwaitParticipantsConfirmations), "",
+		responses.SignatureProposalParticipantInvitationsResponse{{
+			ParticipantId: n.ParticipantID,
+			Username:      n.Participant,
+			Threshold:     1,
+			DkgPubKey:     dkgPubKey,
+		}})
+	opBz, err := json.Marshal(op)
+	req.NoError(err)
+
+	// the client side of the QR path without a camera
+	processor := qr.NewImageProcessor()
+	processor.SetChunkSize(256)
+	requestPath := filepath.Join(testDir, "dc4bc_qr_"+op.ID+"-request.gif")
+	req.NoError(processor.WriteQR(requestPath, opBz))
+
+	resultPath, err := n.Machine.HandleQRFromFiles([]string{requestPath})
+	req.NoError(err)
+
+	processor.SetPaths(resultPath)
+	resultBz, err := processor.ReadQR()
+	req.NoError(err)
+	var resultOperation client.Operation
+	req.NoError(json.Unmarshal(resultBz, &resultOperation))
+	req.Equal(op.ID, resultOperation.ID)
+	req.Len(resultOperation.ResultMsgs, 1)
+}
@@ -1,16 +1,23 @@
 package airgapped
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
+
 	"github.com/corestario/kyber/pairing"
 
 	"github.com/corestario/kyber/sign/bls"
 	"github.com/corestario/kyber/sign/tbls"
 	client "github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/dkg"
+	"github.com/lidofinance/dc4bc/eth2"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/types/requests"
 	"github.com/lidofinance/dc4bc/fsm/types/responses"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 // handleStateSigningAwaitConfirmations returns a confirmation of participation to create a threshold signature for a data
@@ -54,11 +61,6 @@ func (am *Machine) handleStateSigningAwaitPartialSigns(o *client.Operation) erro
 		return fmt.Errorf("failed to unmarshal payload: %w", err)
 	}
 
-	partialSign, err := am.createPartialSign(payload.SrcPayload, o.DKGIdentifier)
-	if err != nil {
-		return fmt.Errorf("failed to create partialSign for msg: %w", err)
-	}
-
 	participantID, err := am.getParticipantID(o.DKGIdentifier)
 	if err != nil {
 		return fmt.Errorf("failed to get paricipant id: %w", err)
@@ -66,9 +68,45 @@ func (am *Machine) handleStateSigningAwaitPartialSigns(o *client.Operation) erro
 	req := requests.SigningProposalPartialSignRequest{
 		SigningId:     payload.SigningId,
 		ParticipantId: participantID,
-		PartialSign:   partialSign,
 		CreatedAt:     o.CreatedAt,
 	}
+
+	srcPayloads, eth2Requests := payload.SrcPayloads, payload.Eth2Requests
+	if err = am.checkSigningPolicy(o.DKGIdentifier, payload.SigningId, srcPayloads, eth2Requests); err != nil {
+		return err
+	}
+	slashingProtection, err := am.checkSlashingProtection(o.DKGIdentifier, srcPayloads, eth2Requests)
+	if err != nil {
+		return fmt.Errorf("slashing protection: %w", err)
+	}
+
+	// a batch is signed at once, so every payload of it needs a single round trip
+	req.PartialSigns = make([][]byte, 0, len(srcPayloads))
+	for i, srcPayload := range srcPayloads {
+		if i < len(eth2Requests) {
+			if err = verifyEth2SigningRoot(srcPayload, eth2Requests[i]); err != nil {
+				return fmt.Errorf("invalid payload #%d: %w", i, err)
+			}
+		}
+		partialSign, err := am.createPartialSign(srcPayload, o.DKGIdentifier)
+		if err != nil {
+			return fmt.Errorf("failed to create partialSign for msg #%d: %w", i, err)
+		}
+		req.PartialSigns = append(req.PartialSigns, partialSign)
+	}
+
+	// both histories are written at once, so they can't get out of sync if the write fails
+	batch := new(leveldb.Batch)
+	if err = am.recordSigning(batch, o.DKGIdentifier, payload.SigningId, srcPayloads, eth2Requests); err != nil {
+		return fmt.Errorf("failed to record signing: %w", err)
+	}
+	if err = recordSlashingProtection(batch, slashingProtection); err != nil {
+		return fmt.Errorf("failed to record slashing protection: %w", err)
+	}
+	if err = am.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("failed to save signing and slashing protection histories: %w", err)
+	}
+
 	reqBz, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("failed to generate fsm request: %w", err)
@@ -90,34 +128,85 @@ func (am *Machine) reconstructThresholdSignature(o *client.Operation) error {
 		return fmt.Errorf("failed to unmarshal payload: %w", err)
 	}
 
-	partialSignatures := make([][]byte, 0, len(payload.Participants))
-	for _, participant := range payload.Participants {
-		partialSignatures = append(partialSignatures, participant.PartialSign)
-	}
-
 	dkgInstance, ok := am.dkgInstances[o.DKGIdentifier]
 	if !ok {
 		return fmt.Errorf("dkg instance with identifier %s does not exist", o.DKGIdentifier)
 	}
 
-	reconstructedSignature, err := am.recoverFullSign(payload.SrcPayload, partialSignatures, dkgInstance.Threshold,
-		dkgInstance.N, o.DKGIdentifier)
+	blsKeyring, err := am.loadBLSKeyring(o.DKGIdentifier)
 	if err != nil {
-		return fmt.Errorf("failed to reconsruct full signature for msg: %w", err)
+		return fmt.Errorf("failed to load blsKeyring: %w", err)
+	}
+
+	// every signature of a batch is broadcasted in a separate message with the same signing ID
+	signaturesBz := make([][]byte, 0, len(payload.SrcPayloads))
+	for i, srcPayload := range payload.SrcPayloads {
+		msgName := "msg"
+		if len(payload.SrcPayloads) > 1 {
+			msgName = fmt.Sprintf("msg #%d", i)
+		}
+
+		// invalid partial signs are left out, any threshold of valid ones is enough to reconstruct the signature
+		partialSignatures := make([][]byte, 0, len(payload.Participants))
+		invalidSigners := make([]string, 0)
+		for _, participant := range payload.Participants {
+			var partialSign []byte
+			if i < len(participant.PartialSigns) {
+				partialSign = participant.PartialSigns[i]
+			}
+			index := dkgInstance.GetIndexByParticipant(participant.Username)
+			err = dkg.VerifyPartialSign(am.baseSuite.(pairing.Suite), blsKeyring.PubPoly, index, srcPayload, partialSign)
+			if err != nil {
+				log.Printf("invalid partial sign from %s: %v", participant.Username, err)
+				invalidSigners = append(invalidSigners, participant.Username)
+				continue
+			}
+			partialSignatures = append(partialSignatures, partialSign)
+		}
+		if len(partialSignatures) < dkgInstance.Threshold {
+			return fmt.Errorf("not enough valid partial signs to reconstruct full signature of %s, invalid partial signs from: %s",
+				msgName, strings.Join(invalidSigners, ", "))
+		}
+
+		reconstructedSignature, err := am.recoverFullSign(srcPayload, partialSignatures, dkgInstance.Threshold,
+			dkgInstance.N, o.DKGIdentifier)
+		if err != nil {
+			return fmt.Errorf("failed to reconsruct full signature for %s: %w", msgName, err)
+		}
+
+		response := client.ReconstructedSignature{
+			SigningID:  payload.SigningId,
+			SrcPayload: srcPayload,
+			Signature:  reconstructedSignature,
+			DKGRoundID: o.DKGIdentifier,
+		}
+		respBz, err := json.Marshal(response)
+		if err != nil {
+			return fmt.Errorf("failed to generate reconstructed signature response: %w", err)
+		}
+		signaturesBz = append(signaturesBz, respBz)
+	}
+
+	o.Event = client.SignatureReconstructed
+	for _, respBz := range signaturesBz {
+		o.ResultMsgs = append(o.ResultMsgs, createMessage(*o, respBz))
 	}
+	return nil
+}
 
-	response := client.ReconstructedSignature{
-		SigningID:  payload.SigningId,
-		SrcPayload: payload.SrcPayload,
-		Signature:  reconstructedSignature,
-		DKGRoundID: o.DKGIdentifier,
+// verifyEth2SigningRoot computes the signing root of a validator operation here, so the hot node can't substitute it
+func verifyEth2SigningRoot(srcPayload []byte, eth2Request *eth2.SigningRequest) error {
+	if eth2Request == nil {
+		return nil
 	}
-	respBz, err := json.Marshal(response)
+
+	signingRoot, err := eth2Request.SigningRoot()
 	if err != nil {
-		return fmt.Errorf("failed to generate reconstructed signature response: %w", err)
+		return fmt.Errorf("failed to compute signing root of Eth2 operation: %w", err)
+	}
+	if !bytes.Equal(srcPayload, signingRoot[:]) {
+		return fmt.Errorf("data to sign is not the signing root of Eth2 operation")
 	}
-	o.Event = client.SignatureReconstructed
-	o.ResultMsgs = append(o.ResultMsgs, createMessage(*o, respBz))
 	return nil
 }
 
@@ -0,0 +1,153 @@
+package airgapped
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	client "github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/eth2"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/types/responses"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAirgappedReconstruct_InvalidPartialSigns(t *testing.T) {
+	var (
+		req       = require.New(t)
+		testDir   = "/tmp/airgapped_invalid_partial_signs_test"
+		msgToSign = []byte("i am a message")
+	)
+	defer os.RemoveAll(testDir)
+
+	tr := &Transport{}
+	for i := 0; i < 3; i++ {
+		tr.nodes = append(tr.nodes, newTestNode(t, testDir, i))
+	}
+	runTestDKG(t, tr, 2)
+
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		payload := responses.SigningPartialSignsParticipantInvitationsResponse{SrcPayloads: [][]byte{msgToSign}}
+		handleAndBroadcast(t, tr, n, createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "", payload))
+	})
+
+	n := tr.nodes[0]
+	req.Len(n.partialSigns, 3)
+	partialSigns := make(map[int][]byte)
+	for _, partialSign := range n.partialSigns {
+		partialSigns[partialSign.ParticipantId] = partialSign.PartialSigns[0]
+	}
+
+	reconstruct := func(invalid map[int][]byte) (client.Operation, error) {
+		payload := responses.SigningProcessParticipantResponse{SrcPayloads: [][]byte{msgToSign}}
+		for participantId, partialSign := range partialSigns {
+			if invalidSign, ok := invalid[participantId]; ok {
+				partialSign = invalidSign
+			}
+			payload.Participants = append(payload.Participants, &responses.SigningProcessParticipantEntry{
+				ParticipantId: participantId,
+				Username:      fmt.Sprintf("Participant#%d", participantId),
+				PartialSigns:  [][]byte{partialSign},
+			})
+		}
+		op := createOperation(t, string(signing_proposal_fsm.StateSigningPartialSignsCollected), "", payload)
+		return op, n.Machine.reconstructThresholdSignature(&op)
+	}
+
+	// a corrupted partial sign and a partial sign made with someone else's share are left out
+	for _, invalidSign := range [][]byte{[]byte("corrupted partial sign"), partialSigns[0]} {
+		op, err := reconstruct(map[int][]byte{1: invalidSign})
+		req.NoError(err)
+		req.Len(op.ResultMsgs, 1)
+
+		var signature client.ReconstructedSignature
+		req.NoError(json.Unmarshal(op.ResultMsgs[0].Data, &signature))
+		req.NoError(n.Machine.VerifySign(msgToSign, signature.Signature, DKGIdentifier))
+	}
+
+	_, err := reconstruct(map[int][]byte{1: partialSigns[0], 2: []byte("corrupted partial sign")})
+	req.Error(err)
+	req.Contains(err.Error(), "Participant#1")
+	req.Contains(err.Error(), "Participant#2")
+}
+
+func TestAirgappedPartialSign_Eth2Request(t *testing.T) {
+	var (
+		req     = require.New(t)
+		testDir = "/tmp/airgapped_eth2_request_test"
+	)
+	defer os.RemoveAll(testDir)
+
+	tr := &Transport{}
+	for i := 0; i < 3; i++ {
+		tr.nodes = append(tr.nodes, newTestNode(t, testDir, i))
+	}
+	runTestDKG(t, tr, 2)
+
+	eth2Request := &eth2.SigningRequest{
+		Type:          eth2.OperationVoluntaryExit,
+		ForkVersion:   eth2.Version{0x03, 0x00, 0x00, 0x00},
+		VoluntaryExit: &eth2.VoluntaryExit{Epoch: 194048, ValidatorIndex: 12345},
+	}
+	signingRoot, err := eth2Request.SigningRoot()
+	req.NoError(err)
+
+	n := tr.nodes[0]
+	op := createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "",
+		responses.SigningPartialSignsParticipantInvitationsResponse{SrcPayloads: [][]byte{signingRoot[:]}, Eth2Requests: []*eth2.SigningRequest{eth2Request}})
+	req.NoError(n.Machine.handleStateSigningAwaitPartialSigns(&op))
+	req.Len(op.ResultMsgs, 1)
+
+	// the hot node substitutes the data to sign
+	op = createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "",
+		responses.SigningPartialSignsParticipantInvitationsResponse{SrcPayloads: [][]byte{[]byte("another message")}, Eth2Requests: []*eth2.SigningRequest{eth2Request}})
+	req.Error(n.Machine.handleStateSigningAwaitPartialSigns(&op))
+	req.Empty(op.ResultMsgs)
+}
+
+func TestAirgappedBatchSigning(t *testing.T) {
+	var (
+		req         = require.New(t)
+		testDir     = "/tmp/airgapped_batch_signing_test"
+		srcPayloads = [][]byte{[]byte("first message"), []byte("second message"), []byte("third message")}
+	)
+	defer os.RemoveAll(testDir)
+
+	tr := &Transport{}
+	for i := 0; i < 3; i++ {
+		tr.nodes = append(tr.nodes, newTestNode(t, testDir, i))
+	}
+	runTestDKG(t, tr, 2)
+
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		payload := responses.SigningPartialSignsParticipantInvitationsResponse{SigningId: "batch", SrcPayloads: srcPayloads}
+		handleAndBroadcast(t, tr, n, createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "", payload))
+	})
+
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		payload := responses.SigningProcessParticipantResponse{SigningId: "batch", SrcPayloads: srcPayloads}
+		for _, partialSign := range n.partialSigns {
+			req.Len(partialSign.PartialSigns, len(srcPayloads))
+			payload.Participants = append(payload.Participants, &responses.SigningProcessParticipantEntry{
+				ParticipantId: partialSign.ParticipantId,
+				Username:      fmt.Sprintf("Participant#%d", partialSign.ParticipantId),
+				PartialSigns:  partialSign.PartialSigns,
+			})
+		}
+		handleAndBroadcast(t, tr, n, createOperation(t, string(signing_proposal_fsm.StateSigningPartialSignsCollected), "", payload))
+	})
+
+	for _, n := range tr.nodes {
+		// every node reconstructs a signature for each payload of the batch
+		req.Len(n.reconstructedSignatures, len(tr.nodes)*len(srcPayloads))
+		for _, signature := range n.reconstructedSignatures {
+			req.Equal("batch", signature.SigningID)
+			req.NoError(n.Machine.VerifySign(signature.SrcPayload, signature.Signature, DKGIdentifier))
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/corestario/kyber"
 	dkgPedersen "github.com/corestario/kyber/share/dkg/pedersen"
+	vss "github.com/corestario/kyber/share/vss/pedersen"
 	client "github.com/lidofinance/dc4bc/client/types"
 	"github.com/lidofinance/dc4bc/dkg"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
@@ -62,14 +63,7 @@ func (am *Machine) handleStateAwaitParticipantsConfirmations(o *client.Operation
 		return fmt.Errorf("dkg instance %s already exists", o.DKGIdentifier)
 	}
 
-	// Here we create a new seeded suite for the new DKG round with seed =
-	// sha256.Sum256(baseSeed + DKGIdentifier). We need this to avoid identical
-	// DKG rounds.
-	var (
-		dkgSeed = sha256.Sum256(append([]byte(o.DKGIdentifier), am.baseSeed...))
-		suite   = bls.NewBLS12381Suite(dkgSeed[:])
-	)
-	dkgInstance := dkg.Init(suite, am.pubKey, am.secKey)
+	dkgInstance := dkg.Init(am.dkgSuite(o.DKGIdentifier), am.pubKey, am.secKey)
 	dkgInstance.Threshold = payload[0].Threshold //same for everyone
 	dkgInstance.N = len(payload)
 	am.dkgInstances[o.DKGIdentifier] = dkgInstance
@@ -87,6 +81,13 @@ func (am *Machine) handleStateAwaitParticipantsConfirmations(o *client.Operation
 	return nil
 }
 
+// dkgSuite returns a seeded suite for the DKG round with seed = sha256.Sum256(baseSeed + DKGIdentifier).
+// We need this to avoid identical DKG rounds, and the same suite restores a saved state of the round
+func (am *Machine) dkgSuite(dkgIdentifier string) vss.Suite {
+	dkgSeed := sha256.Sum256(append([]byte(dkgIdentifier), am.baseSeed...))
+	return bls.NewBLS12381Suite(dkgSeed[:])
+}
+
 func (am *Machine) GetPubKey() kyber.Point {
 	return am.pubKey
 }
@@ -237,13 +238,16 @@ func (am *Machine) handleStateDkgResponsesAwaitConfirmations(o *client.Operation
 	}
 
 	for _, entry := range payload {
+		// a deal which can't be read is stored empty, so the dealer gets a complaint
 		decryptedDealBz, err := am.decryptDataFromParticipant(entry.DkgDeal)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt deal: %w", err)
+			dkgInstance.StoreDeal(entry.Username, nil)
+			continue
 		}
 		var deal dkgPedersen.Deal
 		if err = json.Unmarshal(decryptedDealBz, &deal); err != nil {
-			return fmt.Errorf("failed to unmarshal deal")
+			dkgInstance.StoreDeal(entry.Username, nil)
+			continue
 		}
 		dkgInstance.StoreDeal(entry.Username, &deal)
 	}
@@ -263,6 +267,7 @@ func (am *Machine) handleStateDkgResponsesAwaitConfirmations(o *client.Operation
 	req := requests.DKGProposalResponseConfirmationRequest{
 		ParticipantId: dkgInstance.ParticipantID,
 		Response:      responsesBz,
+		Complaints:    dkgInstance.Complaints(processedResponses),
 		CreatedAt:     o.CreatedAt,
 	}
 
@@ -276,6 +281,65 @@ func (am *Machine) handleStateDkgResponsesAwaitConfirmations(o *client.Operation
 	return nil
 }
 
+// handleStateDkgJustificationsAwaitConfirmations takes broadcasted responses with complaints as payload and
+// returns justifications revealing the deals the participant was complained about
+func (am *Machine) handleStateDkgJustificationsAwaitConfirmations(o *client.Operation) error {
+	var (
+		payload responses.DKGProposalResponseParticipantResponse
+		err     error
+	)
+
+	dkgInstance, ok := am.dkgInstances[o.DKGIdentifier]
+	if !ok {
+		return fmt.Errorf("dkg instance with identifier %s does not exist", o.DKGIdentifier)
+	}
+
+	if err = json.Unmarshal(o.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	var accused bool
+	for _, entry := range payload {
+		var entryResponses []*dkgPedersen.Response
+		if err = json.Unmarshal(entry.DkgResponse, &entryResponses); err != nil {
+			return fmt.Errorf("failed to unmarshal responses: %w", err)
+		}
+		dkgInstance.StoreResponses(entry.Username, entryResponses)
+		accused = accused || dkgInstance.IsAccused(entryResponses)
+	}
+
+	justifications, err := dkgInstance.JustifyDeal()
+	if err != nil {
+		return fmt.Errorf("failed to justify deal: %w", err)
+	}
+
+	am.dkgInstances[o.DKGIdentifier] = dkgInstance
+
+	// only the accused dealers answer complaints, the others have nothing to send
+	if !accused {
+		return nil
+	}
+
+	justificationsBz, err := dkg.EncodeJustifications(justifications)
+	if err != nil {
+		return fmt.Errorf("failed to encode justifications: %w", err)
+	}
+
+	req := requests.DKGProposalJustificationConfirmationRequest{
+		ParticipantId: dkgInstance.ParticipantID,
+		Justification: justificationsBz,
+		CreatedAt:     o.CreatedAt,
+	}
+	reqBz, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to generate fsm request: %w", err)
+	}
+
+	o.Event = dkg_proposal_fsm.EventDKGJustificationConfirmationReceived
+	o.ResultMsgs = append(o.ResultMsgs, createMessage(*o, reqBz))
+	return nil
+}
+
 // handleStateDkgMasterKeyAwaitConfirmations takes broadcasted responses from the previous step, process them,
 // reconstructs a distributed DKG public key to broadcast and saves a private part of the key
 func (am *Machine) handleStateDkgMasterKeyAwaitConfirmations(o *client.Operation) error {
@@ -293,12 +357,39 @@ func (am *Machine) handleStateDkgMasterKeyAwaitConfirmations(o *client.Operation
 		return fmt.Errorf("failed to unmarshal payload: %w", err)
 	}
 
+	// every accused dealer either answered complaints or was disqualified by the FSM
+	var (
+		accused  = make(map[int]bool)
+		answered = make(map[int]bool)
+	)
 	for _, entry := range payload {
 		var entryResponses []*dkgPedersen.Response
 		if err = json.Unmarshal(entry.DkgResponse, &entryResponses); err != nil {
 			return fmt.Errorf("failed to unmarshal responses: %w", err)
 		}
 		dkgInstance.StoreResponses(entry.Username, entryResponses)
+		for _, dealerId := range dkgInstance.Complaints(entryResponses) {
+			accused[dealerId] = true
+		}
+
+		if entry.Disqualified {
+			answered[entry.ParticipantId] = true
+		}
+		if len(entry.DkgJustification) == 0 {
+			continue
+		}
+		answered[entry.ParticipantId] = true
+		justifications, err := dkg.DecodeJustifications(am.baseSuite, entry.DkgJustification)
+		if err != nil {
+			return fmt.Errorf("failed to decode justifications: %w", err)
+		}
+		dkgInstance.StoreJustifications(entry.Username, justifications)
+	}
+
+	for dealerId := range accused {
+		if !answered[dealerId] {
+			return fmt.Errorf("complaints against participant %d were not announced, so it had no chance to justify its deal", dealerId)
+		}
 	}
 
 	if err = dkgInstance.ProcessResponses(); err != nil {
@@ -326,9 +417,15 @@ func (am *Machine) handleStateDkgMasterKeyAwaitConfirmations(o *client.Operation
 		return fmt.Errorf("failed to save BLSKeyring: %w", err)
 	}
 
+	pubPolyBz, err := dkg.PubPolyBytes(blsKeyring.PubPoly)
+	if err != nil {
+		return fmt.Errorf("failed to marshal public polynomial: %w", err)
+	}
+
 	req := requests.DKGProposalMasterKeyConfirmationRequest{
 		ParticipantId: dkgInstance.ParticipantID,
 		MasterKey:     masterPubKeyBz,
+		PubPoly:       pubPolyBz,
 		CreatedAt:     o.CreatedAt,
 	}
 	reqBz, err := json.Marshal(req)
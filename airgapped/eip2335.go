@@ -0,0 +1,363 @@
+package airgapped
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/corestario/kyber"
+	"github.com/corestario/kyber/pairing"
+	"github.com/corestario/kyber/share"
+	"github.com/google/uuid"
+	"github.com/lidofinance/dc4bc/dkg"
+	"github.com/syndtr/goleveldb/leveldb"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+// EIP-2335 keystore (https://eips.ethereum.org/EIPS/eip-2335) parameters
+const (
+	eip2335Version   = 4
+	eip2335DKLen     = 32
+	eip2335SaltSize  = 32
+	eip2335SecretLen = 32
+
+	eip2335KDFScrypt     = "scrypt"
+	eip2335KDFPBKDF2     = "pbkdf2"
+	eip2335ChecksumSHA   = "sha256"
+	eip2335CipherAES     = "aes-128-ctr"
+	eip2335PBKDF2HMACSHA = "hmac-sha256"
+)
+
+// eip2335ScryptN is a scrypt cost parameter recommended by EIP-2335, a variable to speed up tests
+var eip2335ScryptN = 262144
+
+var ErrEIP2335WrongPassphrase = errors.New("wrong keystore passphrase")
+
+type eip2335Module struct {
+	Function string          `json:"function"`
+	Params   json.RawMessage `json:"params"`
+	Message  string          `json:"message"`
+}
+
+type eip2335Crypto struct {
+	KDF      eip2335Module `json:"kdf"`
+	Checksum eip2335Module `json:"checksum"`
+	Cipher   eip2335Module `json:"cipher"`
+}
+
+type eip2335ScryptParams struct {
+	DKLen int    `json:"dklen"`
+	N     int    `json:"n"`
+	P     int    `json:"p"`
+	R     int    `json:"r"`
+	Salt  string `json:"salt"`
+}
+
+type eip2335PBKDF2Params struct {
+	DKLen int    `json:"dklen"`
+	C     int    `json:"c"`
+	PRF   string `json:"prf"`
+	Salt  string `json:"salt"`
+}
+
+type eip2335CipherParams struct {
+	IV string `json:"iv"`
+}
+
+// BLSShareInfo is a public part of a threshold BLS share, which is needed to restore the share
+// on an airgapped machine, but is not a part of the EIP-2335 format. Other tools ignore it
+type BLSShareInfo struct {
+	DKGIdentifier string   `json:"dkg_id"`
+	Index         int      `json:"index"`
+	Commitments   []string `json:"commitments"`
+}
+
+// EIP2335Keystore is an EIP-2335 JSON keystore of a private BLS share
+type EIP2335Keystore struct {
+	Crypto      eip2335Crypto `json:"crypto"`
+	Description string        `json:"description"`
+	Pubkey      string        `json:"pubkey"`
+	Path        string        `json:"path"`
+	UUID        string        `json:"uuid"`
+	Version     int           `json:"version"`
+	Share       *BLSShareInfo `json:"dc4bc_share,omitempty"`
+}
+
+// eip2335Password processes a passphrase as required by EIP-2335: NFKD normalization
+// and removal of the C0, C1 and Delete control codes
+func eip2335Password(passphrase string) []byte {
+	var buf bytes.Buffer
+	for _, r := range norm.NFKD.String(passphrase) {
+		if r <= 0x1f || (r >= 0x7f && r <= 0x9f) {
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.Bytes()
+}
+
+func eip2335Checksum(decryptionKey []byte, cipherMessage []byte) []byte {
+	checksum := sha256.Sum256(append(append([]byte{}, decryptionKey[16:32]...), cipherMessage...))
+	return checksum[:]
+}
+
+func eip2335AESCTR(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCTR(block, iv).XORKeyStream(out, data)
+	return out, nil
+}
+
+func newEIP2335Module(function string, params interface{}, message []byte) (eip2335Module, error) {
+	paramsBz, err := json.Marshal(params)
+	if err != nil {
+		return eip2335Module{}, fmt.Errorf("failed to marshal %s params: %w", function, err)
+	}
+	return eip2335Module{Function: function, Params: paramsBz, Message: hex.EncodeToString(message)}, nil
+}
+
+// encryptEIP2335 seals a secret into an EIP-2335 keystore with scrypt and AES-128-CTR
+func encryptEIP2335(secret, pubkey []byte, passphrase, description string) (*EIP2335Keystore, error) {
+	salt := make([]byte, eip2335SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	scryptParams := eip2335ScryptParams{DKLen: eip2335DKLen, N: eip2335ScryptN, P: 1, R: 8, Salt: hex.EncodeToString(salt)}
+	decryptionKey, err := scrypt.Key(eip2335Password(passphrase), salt, scryptParams.N, scryptParams.R,
+		scryptParams.P, scryptParams.DKLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	cipherMessage, err := eip2335AESCTR(decryptionKey[:16], iv, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	var keystoreCrypto eip2335Crypto
+	if keystoreCrypto.KDF, err = newEIP2335Module(eip2335KDFScrypt, scryptParams, nil); err != nil {
+		return nil, err
+	}
+	if keystoreCrypto.Checksum, err = newEIP2335Module(eip2335ChecksumSHA, struct{}{},
+		eip2335Checksum(decryptionKey, cipherMessage)); err != nil {
+		return nil, err
+	}
+	if keystoreCrypto.Cipher, err = newEIP2335Module(eip2335CipherAES,
+		eip2335CipherParams{IV: hex.EncodeToString(iv)}, cipherMessage); err != nil {
+		return nil, err
+	}
+
+	return &EIP2335Keystore{
+		Crypto:      keystoreCrypto,
+		Description: description,
+		Pubkey:      hex.EncodeToString(pubkey),
+		Path:        "",
+		UUID:        uuid.New().String(),
+		Version:     eip2335Version,
+	}, nil
+}
+
+// decryptEIP2335 returns a secret sealed into an EIP-2335 keystore. Both scrypt and PBKDF2 keystores are supported
+func decryptEIP2335(keystore *EIP2335Keystore, passphrase string) ([]byte, error) {
+	if keystore.Version != eip2335Version {
+		return nil, fmt.Errorf("unsupported keystore version %d", keystore.Version)
+	}
+
+	var (
+		decryptionKey []byte
+		err           error
+	)
+	kdf := keystore.Crypto.KDF
+	switch kdf.Function {
+	case eip2335KDFScrypt:
+		var params eip2335ScryptParams
+		if err = json.Unmarshal(kdf.Params, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal scrypt params: %w", err)
+		}
+		salt, err := hex.DecodeString(params.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode salt: %w", err)
+		}
+		if decryptionKey, err = scrypt.Key(eip2335Password(passphrase), salt, params.N, params.R, params.P,
+			params.DKLen); err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+	case eip2335KDFPBKDF2:
+		var params eip2335PBKDF2Params
+		if err = json.Unmarshal(kdf.Params, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pbkdf2 params: %w", err)
+		}
+		if params.PRF != eip2335PBKDF2HMACSHA {
+			return nil, fmt.Errorf("unsupported pbkdf2 prf %s", params.PRF)
+		}
+		salt, err := hex.DecodeString(params.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode salt: %w", err)
+		}
+		decryptionKey = pbkdf2.Key(eip2335Password(passphrase), salt, params.C, params.DKLen, sha256.New)
+	default:
+		return nil, fmt.Errorf("unsupported kdf %s", kdf.Function)
+	}
+	if len(decryptionKey) != eip2335DKLen {
+		return nil, fmt.Errorf("invalid derived key length %d", len(decryptionKey))
+	}
+
+	if keystore.Crypto.Checksum.Function != eip2335ChecksumSHA {
+		return nil, fmt.Errorf("unsupported checksum %s", keystore.Crypto.Checksum.Function)
+	}
+	if keystore.Crypto.Cipher.Function != eip2335CipherAES {
+		return nil, fmt.Errorf("unsupported cipher %s", keystore.Crypto.Cipher.Function)
+	}
+
+	cipherMessage, err := hex.DecodeString(keystore.Crypto.Cipher.Message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cipher message: %w", err)
+	}
+	checksum, err := hex.DecodeString(keystore.Crypto.Checksum.Message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode checksum: %w", err)
+	}
+	if !bytes.Equal(checksum, eip2335Checksum(decryptionKey, cipherMessage)) {
+		return nil, ErrEIP2335WrongPassphrase
+	}
+
+	var cipherParams eip2335CipherParams
+	if err = json.Unmarshal(keystore.Crypto.Cipher.Params, &cipherParams); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cipher params: %w", err)
+	}
+	iv, err := hex.DecodeString(cipherParams.IV)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode iv: %w", err)
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid iv length %d", len(iv))
+	}
+
+	return eip2335AESCTR(decryptionKey[:16], iv, cipherMessage)
+}
+
+// ExportBLSKeyring exports the private BLS share of a given DKG round with its index and commitments
+// as an EIP-2335 JSON keystore protected by a passphrase
+func (am *Machine) ExportBLSKeyring(dkgIdentifier, passphrase string) ([]byte, error) {
+	blsKeyring, err := am.loadBLSKeyring(dkgIdentifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blsKeyring: %w", err)
+	}
+
+	secret, err := blsKeyring.Share.V.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private share: %w", err)
+	}
+	pubkey, err := am.baseSuite.(pairing.Suite).G1().Point().Mul(blsKeyring.Share.V, nil).MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal share pubkey: %w", err)
+	}
+
+	_, commitments := blsKeyring.PubPoly.Info()
+	shareInfo := &BLSShareInfo{
+		DKGIdentifier: dkgIdentifier,
+		Index:         blsKeyring.Share.I,
+		Commitments:   make([]string, 0, len(commitments)),
+	}
+	for _, commitment := range commitments {
+		commitmentBz, err := commitment.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal commitment: %w", err)
+		}
+		shareInfo.Commitments = append(shareInfo.Commitments, hex.EncodeToString(commitmentBz))
+	}
+
+	description := fmt.Sprintf("dc4bc threshold BLS share #%d of DKG round %s", blsKeyring.Share.I, dkgIdentifier)
+	keystore, err := encryptEIP2335(secret, pubkey, passphrase, description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt private share: %w", err)
+	}
+	keystore.Share = shareInfo
+
+	return json.MarshalIndent(keystore, "", "  ")
+}
+
+// ImportBLSKeyring restores a private BLS share exported by ExportBLSKeyring and returns its DKG round identifier.
+// An existing share of the same DKG round is never overwritten
+func (am *Machine) ImportBLSKeyring(data []byte, passphrase string) (string, error) {
+	var keystore EIP2335Keystore
+	if err := json.Unmarshal(data, &keystore); err != nil {
+		return "", fmt.Errorf("failed to unmarshal keystore: %w", err)
+	}
+	if keystore.Share == nil {
+		return "", errors.New("keystore does not contain a dc4bc share index and commitments")
+	}
+	dkgIdentifier := keystore.Share.DKGIdentifier
+
+	if _, err := am.db.Get([]byte(makeBLSKeyKeyringDBKey(dkgIdentifier)), nil); err == nil {
+		return "", fmt.Errorf("bls keyring for dkg round %s already exists", dkgIdentifier)
+	} else if err != leveldb.ErrNotFound {
+		return "", fmt.Errorf("failed to check bls keyring: %w", err)
+	}
+
+	secret, err := decryptEIP2335(&keystore, passphrase)
+	if err != nil {
+		return "", err
+	}
+	if len(secret) != eip2335SecretLen {
+		return "", fmt.Errorf("invalid secret length %d", len(secret))
+	}
+
+	suite := am.baseSuite.(pairing.Suite)
+	priShare := &share.PriShare{I: keystore.Share.Index, V: suite.G1().Scalar().SetBytes(secret)}
+	pubkey, err := suite.G1().Point().Mul(priShare.V, nil).MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal share pubkey: %w", err)
+	}
+	if hex.EncodeToString(pubkey) != keystore.Pubkey {
+		return "", errors.New("private share does not match keystore pubkey")
+	}
+
+	commitments := make([]kyber.Point, 0, len(keystore.Share.Commitments))
+	for _, commitmentHex := range keystore.Share.Commitments {
+		commitmentBz, err := hex.DecodeString(commitmentHex)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode commitment: %w", err)
+		}
+		commitment := suite.G1().Point()
+		if err = commitment.UnmarshalBinary(commitmentBz); err != nil {
+			return "", fmt.Errorf("failed to unmarshal commitment: %w", err)
+		}
+		commitments = append(commitments, commitment)
+	}
+	if len(commitments) == 0 {
+		return "", errors.New("keystore does not contain commitments")
+	}
+
+	pubPoly := share.NewPubPoly(suite.G1(), nil, commitments)
+	if !pubPoly.Check(priShare) {
+		return "", errors.New("private share does not match commitments")
+	}
+
+	blsKeyring := &dkg.BLSKeyring{
+		PubPoly: pubPoly,
+		Share:   priShare,
+	}
+	if err = am.saveBLSKeyring(dkgIdentifier, blsKeyring); err != nil {
+		return "", fmt.Errorf("failed to save BLSKeyring: %w", err)
+	}
+
+	return dkgIdentifier, nil
+}
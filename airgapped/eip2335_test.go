@@ -0,0 +1,121 @@
+package airgapped
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/corestario/kyber/pairing"
+	"github.com/corestario/kyber/share"
+	"github.com/lidofinance/dc4bc/dkg"
+	"github.com/stretchr/testify/require"
+)
+
+// a scrypt test vector from EIP-2335
+const eip2335TestKeystore = `{
+    "crypto": {
+        "kdf": {
+            "function": "scrypt",
+            "params": {
+                "dklen": 32,
+                "n": 262144,
+                "p": 1,
+                "r": 8,
+                "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"
+            },
+            "message": ""
+        },
+        "checksum": {
+            "function": "sha256",
+            "params": {},
+            "message": "d2217fe5f3e9a1e34581ef8a78f7c9928e436d36dacc5e846690a5581e8ea484"
+        },
+        "cipher": {
+            "function": "aes-128-ctr",
+            "params": {
+                "iv": "264daa3f303d7259501c93d997d84fe6"
+            },
+            "message": "06ae90d55fe0a6e9c5c3bc5b170827b2e5cce3929ed3f116c2811e6366dfe20f"
+        }
+    },
+    "description": "This is a test keystore that uses scrypt to secure the secret.",
+    "pubkey": "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07",
+    "path": "m/12381/60/3141592653/589793238",
+    "uuid": "1d85ae20-35c5-4611-98e8-aa14a633906f",
+    "version": 4
+}`
+
+func TestDecryptEIP2335_TestVector(t *testing.T) {
+	req := require.New(t)
+
+	var keystore EIP2335Keystore
+	req.NoError(json.Unmarshal([]byte(eip2335TestKeystore), &keystore))
+
+	_, err := decryptEIP2335(&keystore, "testpassword")
+	req.Equal(ErrEIP2335WrongPassphrase, err)
+
+	secret, err := decryptEIP2335(&keystore, "\U0001d531\U0001d522\U0001d530\U0001d531\U0001d52d\U0001d51e\U0001d530\U0001d530\U0001d534\U0001d52c\U0001d52f\U0001d521\U0001f511")
+	req.NoError(err)
+	req.Equal("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f", hex.EncodeToString(secret))
+}
+
+func TestMachine_ExportImportBLSKeyring(t *testing.T) {
+	var (
+		req          = require.New(t)
+		srcDBPath    = "/tmp/dc4bc_test_export_bls_keyring"
+		dstDBPath    = "/tmp/dc4bc_test_import_bls_keyring"
+		dkgID        = "dkg_id"
+		passphrase   = "passphrase"
+		oldScryptN   = eip2335ScryptN
+		threshold, n = 2, 3
+	)
+	defer os.RemoveAll(srcDBPath)
+	defer os.RemoveAll(dstDBPath)
+
+	// a cheap KDF is enough for the test
+	eip2335ScryptN = 1 << 10
+	defer func() { eip2335ScryptN = oldScryptN }()
+
+	src, err := NewMachine(srcDBPath)
+	req.NoError(err)
+	src.SetEncryptionKey([]byte("src_password"))
+	req.NoError(src.InitKeys())
+
+	dst, err := NewMachine(dstDBPath)
+	req.NoError(err)
+	dst.SetEncryptionKey([]byte("dst_password"))
+	req.NoError(dst.InitKeys())
+
+	suite := src.baseSuite.(pairing.Suite)
+	priPoly := share.NewPriPoly(suite.G1(), threshold, nil, suite.RandomStream())
+	blsKeyring := &dkg.BLSKeyring{
+		PubPoly: priPoly.Commit(nil),
+		Share:   priPoly.Shares(n)[1],
+	}
+	req.NoError(src.saveBLSKeyring(dkgID, blsKeyring))
+
+	keystoreBz, err := src.ExportBLSKeyring(dkgID, passphrase)
+	req.NoError(err)
+
+	_, err = dst.ImportBLSKeyring(keystoreBz, "wrong_passphrase")
+	req.Equal(ErrEIP2335WrongPassphrase, err)
+
+	importedDKGID, err := dst.ImportBLSKeyring(keystoreBz, passphrase)
+	req.NoError(err)
+	req.Equal(dkgID, importedDKGID)
+
+	importedKeyring, err := dst.loadBLSKeyring(dkgID)
+	req.NoError(err)
+	req.Equal(blsKeyring.Share.I, importedKeyring.Share.I)
+	req.True(blsKeyring.Share.V.Equal(importedKeyring.Share.V))
+	_, commitments := blsKeyring.PubPoly.Info()
+	_, importedCommitments := importedKeyring.PubPoly.Info()
+	req.Len(importedCommitments, len(commitments))
+	for i := range commitments {
+		req.True(commitments[i].Equal(importedCommitments[i]))
+	}
+
+	_, err = dst.ImportBLSKeyring(keystoreBz, passphrase)
+	req.Error(err)
+}
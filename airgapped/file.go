@@ -0,0 +1,40 @@
+package airgapped
+
+import (
+	"crypto/ed25519"
+	"errors"
+
+	"github.com/corestario/kyber/pairing"
+	"github.com/corestario/kyber/sign/bls"
+)
+
+// fileSigner signs operation files with the DKG key of the machine, so the hot node can check
+// that a file was made by the machine registered in the DKG round
+type fileSigner struct {
+	am *Machine
+}
+
+func (s *fileSigner) PubKey() ([]byte, error) {
+	if s.am.pubKey == nil {
+		return nil, errors.New("keys are not loaded")
+	}
+	return s.am.pubKey.MarshalBinary()
+}
+
+func (s *fileSigner) Sign(msg []byte) ([]byte, error) {
+	if s.am.secKey == nil {
+		return nil, errors.New("keys are not loaded")
+	}
+	return bls.Sign(s.am.baseSuite.(pairing.Suite), s.am.secKey, msg)
+}
+
+// verifyHotNodeFileSignature checks a signature of an operation file made with the ed25519 key of the hot node
+func verifyHotNodeFileSignature(pubKey, msg, signature []byte) error {
+	if len(pubKey) != ed25519.PublicKeySize {
+		return errors.New("invalid pub key length")
+	}
+	if !ed25519.Verify(pubKey, msg, signature) {
+		return errors.New("signature is not valid")
+	}
+	return nil
+}
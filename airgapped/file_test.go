@@ -0,0 +1,119 @@
+package airgapped
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/corestario/kyber/pairing"
+	"github.com/corestario/kyber/sign/bls"
+	client "github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/types/responses"
+	"github.com/lidofinance/dc4bc/qr"
+	"github.com/stretchr/testify/require"
+)
+
+type testHotNodeSigner struct {
+	priv ed25519.PrivateKey
+}
+
+func (s *testHotNodeSigner) PubKey() ([]byte, error) {
+	return s.priv.Public().(ed25519.PublicKey), nil
+}
+
+func (s *testHotNodeSigner) Sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(s.priv, msg), nil
+}
+
+func TestAirgappedHandleFile(t *testing.T) {
+	var (
+		req     = require.New(t)
+		testDir = "/tmp/airgapped_handle_file_test"
+	)
+	defer os.RemoveAll(testDir)
+
+	n := newTestNode(t, testDir, 0)
+	dkgPubKey, err := n.Machine.pubKey.MarshalBinary()
+	req.NoError(err)
+
+	hotNodePubKey, hotNodePrivKey, err := ed25519.GenerateKey(nil)
+	req.NoError(err)
+	verifyMachineSignature := func(pubKey, msg, signature []byte) error {
+		suite := n.Machine.baseSuite.(pairing.Suite)
+		point := suite.G1().Point()
+		if err := point.UnmarshalBinary(pubKey); err != nil {
+			return err
+		}
+		return bls.Verify(suite, point, msg, signature)
+	}
+	hotNodeFileProcessor := qr.NewFileProcessor(&testHotNodeSigner{priv: hotNodePrivKey}, verifyMachineSignature)
+
+	op := createOperation(t, string(signature_proposal_fsm.StateAwaitParticipantsConfirmations), "",
+		responses.SignatureProposalParticipantInvitationsResponse{{
+			ParticipantId: n.ParticipantID,
+			Username:      n.Participant,
+			Threshold:     1,
+			DkgPubKey:     dkgPubKey,
+		}})
+	opBz, err := json.Marshal(op)
+	req.NoError(err)
+	requestPath := filepath.Join(testDir, "dc4bc_file_"+op.ID+"-request.json")
+	req.NoError(hotNodeFileProcessor.WriteFile(requestPath, opBz))
+
+	resultPath, signerPubKey, err := n.Machine.HandleFile(requestPath)
+	req.NoError(err)
+	req.Equal([]byte(hotNodePubKey), signerPubKey)
+	req.Equal(filepath.Join(testDir, "dc4bc_file_"+op.ID+"-response.json"), resultPath)
+
+	// the result file is signed with the DKG key of the machine
+	resultBz, machinePubKey, err := hotNodeFileProcessor.ReadFile(resultPath)
+	req.NoError(err)
+	req.Equal(dkgPubKey, machinePubKey)
+
+	var resultOperation client.Operation
+	req.NoError(json.Unmarshal(resultBz, &resultOperation))
+	req.Equal(op.ID, resultOperation.ID)
+	req.Len(resultOperation.ResultMsgs, 1)
+
+	// the key of the first file is pinned, a file signed by another hot node is refused
+	pinnedPubKey, err := n.Machine.GetHotNodePubKey()
+	req.NoError(err)
+	req.Equal([]byte(hotNodePubKey), pinnedPubKey)
+
+	_, otherPrivKey, err := ed25519.GenerateKey(nil)
+	req.NoError(err)
+	otherFileProcessor := qr.NewFileProcessor(&testHotNodeSigner{priv: otherPrivKey}, verifyMachineSignature)
+	otherPath := filepath.Join(testDir, "dc4bc_file_other-request.json")
+	req.NoError(otherFileProcessor.WriteFile(otherPath, opBz))
+
+	var reviewed bool
+	n.Machine.SetOperationReviewer(func(string) (bool, error) {
+		reviewed = true
+		return true, nil
+	})
+	_, _, err = n.Machine.HandleFile(otherPath)
+	req.Error(err)
+	req.False(reviewed)
+
+	// the signer key is shown to the reviewer
+	req.Error(n.Machine.ResetHotNodePubKey([]byte("wrong password")))
+	req.NoError(n.Machine.ResetHotNodePubKey(n.Machine.encryptionKey))
+	var description string
+	n.Machine.SetOperationReviewer(func(d string) (bool, error) {
+		description = d
+		return false, nil
+	})
+	_, _, err = n.Machine.HandleFile(otherPath)
+	req.NoError(err)
+	req.Contains(description, base64.StdEncoding.EncodeToString(otherPrivKey.Public().(ed25519.PublicKey)))
+	req.Contains(description, "NOT PINNED")
+
+	// a rejected operation doesn't pin the key
+	pinnedPubKey, err = n.Machine.GetHotNodePubKey()
+	req.NoError(err)
+	req.Nil(pinnedPubKey)
+}
@@ -0,0 +1,80 @@
+package airgapped
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+const hotNodePubKeyDBKey = "hot_node_public_key"
+
+// GetHotNodePubKey returns the pinned key of the hot node which signs operation files, it's nil until
+// the first operation file is approved
+func (am *Machine) GetHotNodePubKey() ([]byte, error) {
+	pubKey, err := am.db.Get([]byte(hotNodePubKeyDBKey), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hot node pub key from db: %w", err)
+	}
+	return pubKey, nil
+}
+
+// checkHotNodePubKey checks that an operation file is signed by the pinned hot node key,
+// a file signed by another key is refused before the operation is even shown to the operator
+func (am *Machine) checkHotNodePubKey(signerPubKey []byte) error {
+	pinnedPubKey, err := am.GetHotNodePubKey()
+	if err != nil {
+		return err
+	}
+	if pinnedPubKey != nil && !bytes.Equal(pinnedPubKey, signerPubKey) {
+		return fmt.Errorf("operation file is signed by the key %s, but the pinned hot node key is %s",
+			base64.StdEncoding.EncodeToString(signerPubKey), base64.StdEncoding.EncodeToString(pinnedPubKey))
+	}
+	return nil
+}
+
+// pinHotNodePubKey pins the key which signed the first approved operation file
+func (am *Machine) pinHotNodePubKey(signerPubKey []byte) error {
+	pinnedPubKey, err := am.GetHotNodePubKey()
+	if err != nil {
+		return err
+	}
+	if pinnedPubKey != nil {
+		return nil
+	}
+	if err = am.db.Put([]byte(hotNodePubKeyDBKey), signerPubKey, nil); err != nil {
+		return fmt.Errorf("failed to put hot node pub key: %w", err)
+	}
+	return nil
+}
+
+// ResetHotNodePubKey drops the pinned hot node key, e.g. after the hot node key was changed,
+// the key of the next approved operation file is pinned. The encryption password is required to reset it
+func (am *Machine) ResetHotNodePubKey(password []byte) error {
+	if err := am.checkPassword(password); err != nil {
+		return err
+	}
+	if err := am.db.Delete([]byte(hotNodePubKeyDBKey), nil); err != nil {
+		return fmt.Errorf("failed to delete hot node pub key: %w", err)
+	}
+	return nil
+}
+
+// describeSigner returns a line of the operation review with the key of the hot node which signed the file
+func (am *Machine) describeSigner(signerPubKey []byte) (string, error) {
+	pinnedPubKey, err := am.GetHotNodePubKey()
+	if err != nil {
+		return "", err
+	}
+	signer := base64.StdEncoding.EncodeToString(signerPubKey)
+	if pinnedPubKey == nil {
+		return fmt.Sprintf("Signed by the hot node key: %s (NOT PINNED, it's pinned once the operation is approved, "+
+			"make sure it matches the output of `dc4bc_cli get_pubkey` on your client node)\n", signer), nil
+	}
+	return fmt.Sprintf("Signed by the hot node key: %s (pinned)\n", signer), nil
+}
@@ -0,0 +1,184 @@
+package airgapped
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/lidofinance/dc4bc/dkg"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	"github.com/stretchr/testify/require"
+)
+
+// breakDeal replaces the deal sent by the dealer to the receiver with garbage
+func breakDeal(tr *Transport, dealer, receiver int) {
+	for i, deal := range tr.nodes[receiver].deals {
+		if deal.ParticipantId == dealer {
+			tr.nodes[receiver].deals[i].Deal = []byte("broken deal")
+		}
+	}
+}
+
+func TestAirgappedJustifications(t *testing.T) {
+	var (
+		req        = require.New(t)
+		testDir    = "/tmp/airgapped_justifications_test"
+		nodesCount = 4
+		threshold  = 2
+		// the deal of an honest dealer is broken on the way and justified later,
+		// the faulty dealer doesn't answer the complaint against its deal
+		honestDealer, honestComplainer = 1, 2
+		faultyDealer, faultyComplainer = 3, 0
+	)
+	defer os.RemoveAll(testDir)
+
+	tr := &Transport{}
+	for i := 0; i < nodesCount; i++ {
+		tr.nodes = append(tr.nodes, newTestNode(t, testDir, i))
+	}
+
+	runTestDKGDeals(t, tr, threshold)
+	breakDeal(tr, honestDealer, honestComplainer)
+	breakDeal(tr, faultyDealer, faultyComplainer)
+	runTestDKGResponses(t, tr)
+	// the complaints and the justifications are kept over restarts
+	restartNodes(t, tr, testDir)
+
+	for _, r := range tr.nodes[0].responses {
+		switch r.ParticipantId {
+		case honestComplainer:
+			req.Equal([]int{honestDealer}, r.Complaints)
+		case faultyComplainer:
+			req.Equal([]int{faultyDealer}, r.Complaints)
+		default:
+			req.Empty(r.Complaints)
+		}
+	}
+
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		handleAndBroadcast(t, tr, n, createOperation(t, string(dkg_proposal_fsm.StateDkgJustificationsAwaitConfirmations), "", responsesPayload(n)))
+	})
+
+	// only the accused dealers answer complaints
+	for _, n := range tr.nodes {
+		req.Len(n.justifications, 2)
+		for i, j := range n.justifications {
+			justifications, err := dkg.DecodeJustifications(n.Machine.baseSuite, j.Justification)
+			req.NoError(err)
+			req.Contains([]int{honestDealer, faultyDealer}, j.ParticipantId)
+			req.Len(justifications, 1)
+			if j.ParticipantId == faultyDealer {
+				n.justifications[i].Justification = []byte("[]")
+			}
+		}
+	}
+
+	restartNodes(t, tr, testDir)
+	runTestDKGMasterKey(t, tr)
+
+	var honestMasterKey []byte
+	for _, masterKey := range tr.nodes[0].masterKeys {
+		if masterKey.ParticipantId != faultyDealer {
+			honestMasterKey = masterKey.MasterKey
+		}
+	}
+	for _, n := range tr.nodes {
+		req.Len(n.masterKeys, nodesCount)
+		// the faulty dealer justified its deal for itself only, so it disagrees with the others
+		if n.ParticipantID == faultyDealer {
+			continue
+		}
+		for _, masterKey := range n.masterKeys {
+			if masterKey.ParticipantId != faultyDealer {
+				req.Equal(honestMasterKey, masterKey.MasterKey)
+			}
+		}
+		req.ElementsMatch([]int{0, 1, 2}, n.Machine.dkgInstances[DKGIdentifier].QUAL())
+
+		keyring, err := n.Machine.loadBLSKeyring(DKGIdentifier)
+		req.NoError(err)
+		req.True(keyring.PubPoly.Check(keyring.Share), "%s: share doesn't match the key", n.Participant)
+	}
+}
+
+func TestAirgappedJustifications_Disqualified(t *testing.T) {
+	var (
+		req        = require.New(t)
+		testDir    = "/tmp/airgapped_justifications_disqualified_test"
+		nodesCount = 4
+		threshold  = 2
+		// the faulty dealer doesn't answer the complaint in time and is disqualified by the FSM
+		faultyDealer, faultyComplainer = 3, 0
+	)
+	defer os.RemoveAll(testDir)
+
+	tr := &Transport{}
+	for i := 0; i < nodesCount; i++ {
+		tr.nodes = append(tr.nodes, newTestNode(t, testDir, i))
+	}
+
+	runTestDKGDeals(t, tr, threshold)
+	breakDeal(tr, faultyDealer, faultyComplainer)
+	runTestDKGResponses(t, tr)
+
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		handleAndBroadcast(t, tr, n, createOperation(t, string(dkg_proposal_fsm.StateDkgJustificationsAwaitConfirmations), "", responsesPayload(n)))
+	})
+
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+
+		payload := responsesPayload(n)
+		for _, entry := range payload {
+			if entry.ParticipantId == faultyDealer {
+				entry.DkgJustification = nil
+				entry.Disqualified = true
+			}
+		}
+		handleAndBroadcast(t, tr, n, createOperation(t, string(dkg_proposal_fsm.StateDkgMasterKeyAwaitConfirmations), "", payload))
+	})
+
+	var honestMasterKey []byte
+	for _, masterKey := range tr.nodes[0].masterKeys {
+		if masterKey.ParticipantId != faultyDealer {
+			honestMasterKey = masterKey.MasterKey
+		}
+	}
+	// the faulty dealer keeps its own deal, the others agree on the key without it
+	for _, n := range tr.nodes {
+		req.Len(n.masterKeys, nodesCount)
+		if n.ParticipantID == faultyDealer {
+			continue
+		}
+		for _, masterKey := range n.masterKeys {
+			if masterKey.ParticipantId != faultyDealer {
+				req.Equal(honestMasterKey, masterKey.MasterKey)
+			}
+		}
+		req.ElementsMatch([]int{0, 1, 2}, n.Machine.dkgInstances[DKGIdentifier].QUAL())
+	}
+}
+
+func TestAirgappedMasterKey_UnannouncedComplaints(t *testing.T) {
+	testDir := "/tmp/airgapped_unannounced_complaints_test"
+	defer os.RemoveAll(testDir)
+
+	tr := &Transport{}
+	for i := 0; i < 3; i++ {
+		tr.nodes = append(tr.nodes, newTestNode(t, testDir, i))
+	}
+
+	runTestDKGDeals(t, tr, 2)
+	breakDeal(tr, 1, 2)
+	runTestDKGResponses(t, tr)
+
+	// the justification phase is skipped, as if the complaint was hidden from the FSM
+	for _, n := range tr.nodes {
+		operation, err := n.Machine.HandleOperation(createOperation(t, string(dkg_proposal_fsm.StateDkgMasterKeyAwaitConfirmations), "", responsesPayload(n)))
+		require.NoError(t, err)
+		require.Len(t, operation.ResultMsgs, 1)
+		require.Equal(t, string(dkg_proposal_fsm.EventDKGMasterKeyConfirmationError), operation.ResultMsgs[0].Event)
+	}
+}
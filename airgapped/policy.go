@@ -0,0 +1,363 @@
+package airgapped
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lidofinance/dc4bc/eth2"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+const (
+	signingPolicyDBKey     = "signing_policy"
+	signingHistoryDBPrefix = "signing_history_"
+
+	// PayloadTypeRaw is a type of payloads signed without an Eth2 operation
+	PayloadTypeRaw = "raw"
+)
+
+// Duration is a time.Duration written as a string in JSON, e.g. "1h30m"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// RoundSigningPolicy limits signings of a DKG round. Zero values of the fields mean no limit
+type RoundSigningPolicy struct {
+	// AllowedTypes are allowed payload types: Eth2 operation types or "raw" for other payloads
+	AllowedTypes []string `json:"allowed_types,omitempty"`
+	// AllowedPrefixes are non-empty hex prefixes of allowed raw payloads, a raw payload is allowed if it matches
+	// a prefix or the "raw" type is allowed
+	AllowedPrefixes []string `json:"allowed_prefixes,omitempty"`
+	// MaxSignatures caps the number of payloads signed with the round key, every payload of a batch is counted
+	MaxSignatures int `json:"max_signatures,omitempty"`
+	// ForbidDoubleSigning forbids signing voluntary exits of the same validator and epoch in different signings.
+	// Blocks and attestations are always checked by the slashing protection, see checkSlashingProtection
+	ForbidDoubleSigning bool `json:"forbid_double_signing,omitempty"`
+	// Cooldown is a minimal time between signings of the round on this machine
+	Cooldown Duration `json:"cooldown,omitempty"`
+}
+
+// SigningPolicy holds the rules checked before the machine makes a partial signature.
+// Rounds which are not listed follow the default rules, without the default rules they are not limited
+type SigningPolicy struct {
+	Default *RoundSigningPolicy            `json:"default,omitempty"`
+	Rounds  map[string]*RoundSigningPolicy `json:"rounds,omitempty"`
+}
+
+func (p *SigningPolicy) Validate() error {
+	policies := []*RoundSigningPolicy{p.Default}
+	for _, policy := range p.Rounds {
+		policies = append(policies, policy)
+	}
+	for _, policy := range policies {
+		if policy == nil {
+			continue
+		}
+		for _, payloadType := range policy.AllowedTypes {
+			switch eth2.OperationType(payloadType) {
+			case PayloadTypeRaw, eth2.OperationVoluntaryExit, eth2.OperationDeposit, eth2.OperationBLSToExecutionChange,
+				eth2.OperationAttestation, eth2.OperationBlock:
+			default:
+				return fmt.Errorf("unknown payload type %q", payloadType)
+			}
+		}
+		for _, prefix := range policy.AllowedPrefixes {
+			prefixBz, err := hex.DecodeString(strings.TrimPrefix(prefix, "0x"))
+			if err != nil {
+				return fmt.Errorf("invalid payload prefix %q: %w", prefix, err)
+			}
+			if len(prefixBz) == 0 {
+				return fmt.Errorf("payload prefix %q is empty", prefix)
+			}
+		}
+		if policy.MaxSignatures < 0 {
+			return errors.New("max signatures cannot be a negative number")
+		}
+		if policy.Cooldown < 0 {
+			return errors.New("cooldown cannot be negative")
+		}
+	}
+	return nil
+}
+
+func (p *SigningPolicy) roundPolicy(dkgIdentifier string) *RoundSigningPolicy {
+	if policy, ok := p.Rounds[dkgIdentifier]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+// signingHistory is a record of signings made with the round key, it's checked against the policy
+type signingHistory struct {
+	Signatures    int
+	LastSigningAt time.Time
+	// Signings holds a hash of payloads of every signing, keyed by the signing ID
+	Signings map[string][]byte
+	// SignedEpochs holds IDs of signings of Eth2 operations, keyed by signedEpochKey
+	SignedEpochs map[string]string
+}
+
+// SetSigningPolicy replaces the signing policy, the encryption password is required to change it
+func (am *Machine) SetSigningPolicy(password []byte, policyBz []byte) error {
+	if err := am.checkPassword(password); err != nil {
+		return err
+	}
+
+	var policy SigningPolicy
+	decoder := json.NewDecoder(bytes.NewReader(policyBz))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		return fmt.Errorf("failed to unmarshal signing policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid signing policy: %w", err)
+	}
+
+	salt, err := am.db.Get([]byte(saltDBKey), nil)
+	if err != nil {
+		return fmt.Errorf("failed to read salt from db: %w", err)
+	}
+	policyBz, err = json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signing policy: %w", err)
+	}
+	// the policy is encrypted, so it can't be changed without the password
+	encryptedPolicy, err := encrypt(am.encryptionKey, salt, policyBz)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt signing policy: %w", err)
+	}
+	if err = am.db.Put([]byte(signingPolicyDBKey), encryptedPolicy, nil); err != nil {
+		return fmt.Errorf("failed to put signing policy into db: %w", err)
+	}
+	return nil
+}
+
+// GetSigningPolicy returns the signing policy, or nil if it's not set
+func (am *Machine) GetSigningPolicy() (*SigningPolicy, error) {
+	encryptedPolicy, err := am.db.Get([]byte(signingPolicyDBKey), nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get signing policy from db: %w", err)
+	}
+
+	salt, err := am.db.Get([]byte(saltDBKey), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read salt from db: %w", err)
+	}
+	policyBz, err := decrypt(am.encryptionKey, salt, encryptedPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt signing policy: %w", err)
+	}
+
+	var policy SigningPolicy
+	if err = json.Unmarshal(policyBz, &policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal signing policy: %w", err)
+	}
+	return &policy, nil
+}
+
+func (am *Machine) getSigningHistory(dkgIdentifier string) (*signingHistory, error) {
+	history := &signingHistory{
+		Signings:     make(map[string][]byte),
+		SignedEpochs: make(map[string]string),
+	}
+	historyBz, err := am.db.Get([]byte(signingHistoryDBPrefix+dkgIdentifier), nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return history, nil
+		}
+		return nil, fmt.Errorf("failed to get signing history from db: %w", err)
+	}
+	if err = json.Unmarshal(historyBz, history); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal signing history: %w", err)
+	}
+	return history, nil
+}
+
+func putSigningHistory(batch *leveldb.Batch, dkgIdentifier string, history *signingHistory) error {
+	historyBz, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signing history: %w", err)
+	}
+	batch.Put([]byte(signingHistoryDBPrefix+dkgIdentifier), historyBz)
+	return nil
+}
+
+// payloadsHash returns a hash of all the payloads of a signing
+func payloadsHash(srcPayloads [][]byte) []byte {
+	h := sha256.New()
+	for _, payload := range srcPayloads {
+		payloadHash := sha256.Sum256(payload)
+		h.Write(payloadHash[:])
+	}
+	return h.Sum(nil)
+}
+
+// signedEpochKey returns a key of the voluntary exit of the validator for the epoch, or an empty string
+// for other operations, which are covered by the slashing protection
+func signedEpochKey(pubkey eth2.BLSPubkey, request *eth2.SigningRequest) string {
+	if request == nil || request.VoluntaryExit == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s:%d:%d", request.Type, pubkey.String(), request.VoluntaryExit.ValidatorIndex,
+		request.VoluntaryExit.Epoch)
+}
+
+// signedEpochKeys returns keys of the Eth2 operations of the signing, see signedEpochKey
+func (am *Machine) signedEpochKeys(dkgIdentifier string, eth2Requests []*eth2.SigningRequest) ([]string, error) {
+	keys := make([]string, len(eth2Requests))
+	var (
+		pubkey       eth2.BLSPubkey
+		pubkeyLoaded bool
+		err          error
+	)
+	for i, request := range eth2Requests {
+		if request == nil || request.VoluntaryExit == nil {
+			continue
+		}
+		if !pubkeyLoaded {
+			if pubkey, err = am.roundValidatorPubkey(dkgIdentifier); err != nil {
+				return nil, err
+			}
+			pubkeyLoaded = true
+		}
+		keys[i] = signedEpochKey(pubkey, request)
+	}
+	return keys, nil
+}
+
+// checkSigningPolicy checks that the payloads of the signing may be signed with the round key.
+// The same signing can be made again, e.g. when the operation is replayed, but not for other payloads
+func (am *Machine) checkSigningPolicy(dkgIdentifier, signingID string, srcPayloads [][]byte,
+	eth2Requests []*eth2.SigningRequest) error {
+	policy, err := am.GetSigningPolicy()
+	if err != nil {
+		return fmt.Errorf("failed to get signing policy: %w", err)
+	}
+	if policy == nil {
+		return nil
+	}
+	roundPolicy := policy.roundPolicy(dkgIdentifier)
+	if roundPolicy == nil {
+		return nil
+	}
+
+	history, err := am.getSigningHistory(dkgIdentifier)
+	if err != nil {
+		return err
+	}
+	if hash, ok := history.Signings[signingID]; ok {
+		if !bytes.Equal(hash, payloadsHash(srcPayloads)) {
+			return fmt.Errorf("signing policy violation: signing %s was already made for other payloads", signingID)
+		}
+		return nil
+	}
+
+	epochKeys, err := am.signedEpochKeys(dkgIdentifier, eth2Requests)
+	if err != nil {
+		return err
+	}
+	for i, payload := range srcPayloads {
+		var request *eth2.SigningRequest
+		var key string
+		if i < len(eth2Requests) {
+			request, key = eth2Requests[i], epochKeys[i]
+		}
+		if !roundPolicy.allows(payload, request) {
+			return fmt.Errorf("signing policy violation: payload #%d is not allowed in round %s", i, dkgIdentifier)
+		}
+		if roundPolicy.ForbidDoubleSigning && key != "" {
+			if otherSigningID, ok := history.SignedEpochs[key]; ok {
+				return fmt.Errorf("signing policy violation: %s was already signed in signing %s", key, otherSigningID)
+			}
+		}
+	}
+
+	if roundPolicy.MaxSignatures > 0 && history.Signatures+len(srcPayloads) > roundPolicy.MaxSignatures {
+		return fmt.Errorf("signing policy violation: round %s is limited to %d signatures, %d are already made",
+			dkgIdentifier, roundPolicy.MaxSignatures, history.Signatures)
+	}
+	if cooldown := time.Duration(roundPolicy.Cooldown); cooldown > 0 && !history.LastSigningAt.IsZero() {
+		if elapsed := am.now().Sub(history.LastSigningAt); elapsed < cooldown {
+			return fmt.Errorf("signing policy violation: the next signing of round %s is allowed in %s",
+				dkgIdentifier, (cooldown - elapsed).Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// allows checks that the payload matches the allowed types or prefixes
+func (p *RoundSigningPolicy) allows(payload []byte, request *eth2.SigningRequest) bool {
+	if len(p.AllowedTypes) == 0 && len(p.AllowedPrefixes) == 0 {
+		return true
+	}
+
+	payloadType := PayloadTypeRaw
+	if request != nil {
+		payloadType = string(request.Type)
+	}
+	for _, allowedType := range p.AllowedTypes {
+		if allowedType == payloadType {
+			return true
+		}
+	}
+	if request != nil {
+		return false
+	}
+	for _, prefix := range p.AllowedPrefixes {
+		prefixBz, _ := hex.DecodeString(strings.TrimPrefix(prefix, "0x"))
+		if bytes.HasPrefix(payload, prefixBz) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordSigning adds the signing to the history of the round, the history is written with the batch
+func (am *Machine) recordSigning(batch *leveldb.Batch, dkgIdentifier, signingID string, srcPayloads [][]byte,
+	eth2Requests []*eth2.SigningRequest) error {
+	history, err := am.getSigningHistory(dkgIdentifier)
+	if err != nil {
+		return err
+	}
+	if _, ok := history.Signings[signingID]; ok {
+		return nil
+	}
+
+	epochKeys, err := am.signedEpochKeys(dkgIdentifier, eth2Requests)
+	if err != nil {
+		return err
+	}
+	history.Signings[signingID] = payloadsHash(srcPayloads)
+	history.Signatures += len(srcPayloads)
+	history.LastSigningAt = am.now()
+	for _, key := range epochKeys {
+		if key != "" {
+			history.SignedEpochs[key] = signingID
+		}
+	}
+	return putSigningHistory(batch, dkgIdentifier, history)
+}
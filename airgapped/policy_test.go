@@ -0,0 +1,91 @@
+package airgapped
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lidofinance/dc4bc/eth2"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/types/responses"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAirgappedSigningPolicy(t *testing.T) {
+	var (
+		req     = require.New(t)
+		testDir = "/tmp/airgapped_signing_policy_test"
+	)
+	defer os.RemoveAll(testDir)
+
+	tr := &Transport{}
+	for i := 0; i < 3; i++ {
+		tr.nodes = append(tr.nodes, newTestNode(t, testDir, i))
+	}
+	runTestDKG(t, tr, 2)
+
+	n := tr.nodes[0]
+	now := time.Now()
+	n.Machine.now = func() time.Time { return now }
+
+	policy := []byte(`{
+		"rounds": {
+			"` + DKGIdentifier + `": {
+				"allowed_types": ["voluntary_exit"],
+				"max_signatures": 2,
+				"forbid_double_signing": true,
+				"cooldown": "1h"
+			}
+		}
+	}`)
+	req.Error(n.Machine.SetSigningPolicy([]byte("wrong password"), policy))
+	req.Error(n.Machine.SetSigningPolicy(n.Machine.encryptionKey, []byte(`{"default": {"allowed_types": ["sync_committee_message"]}}`)))
+	req.Error(n.Machine.SetSigningPolicy(n.Machine.encryptionKey, []byte(`{"default": {"allowed_prefixes": ["0x"]}}`)))
+	req.NoError(n.Machine.SetSigningPolicy(n.Machine.encryptionKey, policy))
+
+	sign := func(signingID string, validatorIndex, epoch uint64) error {
+		eth2Request := &eth2.SigningRequest{
+			Type:          eth2.OperationVoluntaryExit,
+			ForkVersion:   eth2.Version{0x03, 0x00, 0x00, 0x00},
+			VoluntaryExit: &eth2.VoluntaryExit{Epoch: epoch, ValidatorIndex: validatorIndex},
+		}
+		signingRoot, err := eth2Request.SigningRoot()
+		req.NoError(err)
+		op := createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "",
+			responses.SigningPartialSignsParticipantInvitationsResponse{
+				SigningId:    signingID,
+				SrcPayloads:  [][]byte{signingRoot[:]},
+				Eth2Requests: []*eth2.SigningRequest{eth2Request},
+			})
+		return n.Machine.handleStateSigningAwaitPartialSigns(&op)
+	}
+
+	// a raw payload isn't allowed in the round, the violation comes back as an error operation
+	op := createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "",
+		responses.SigningPartialSignsParticipantInvitationsResponse{SigningId: "raw", SrcPayloads: [][]byte{[]byte("i am a message")}})
+	resultOperation, err := n.Machine.HandleOperation(op)
+	req.NoError(err)
+	req.Equal(signing_proposal_fsm.EventSigningPartialSignError, resultOperation.Event)
+
+	req.NoError(sign("first", 12345, 100))
+	// the same signing is made again, but it can't be reused for other payloads
+	req.NoError(sign("first", 12345, 100))
+	req.Error(sign("first", 12345, 200))
+
+	// cooldown
+	req.Error(sign("second", 12345, 200))
+	now = now.Add(2 * time.Hour)
+
+	// the epoch is already signed for the validator, but not for another one
+	req.Error(sign("second", 12345, 100))
+	req.NoError(sign("second", 54321, 100))
+
+	// max signatures
+	now = now.Add(2 * time.Hour)
+	req.Error(sign("third", 12345, 300))
+
+	// other rounds are not limited without the default rules
+	signingPolicy, err := n.Machine.GetSigningPolicy()
+	req.NoError(err)
+	req.Nil(signingPolicy.roundPolicy("another_dkg_identifier"))
+}
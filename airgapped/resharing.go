@@ -0,0 +1,419 @@
+package airgapped
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/corestario/kyber"
+	"github.com/corestario/kyber/encrypt/ecies"
+	dkgPedersen "github.com/corestario/kyber/share/dkg/pedersen"
+	client "github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/dkg"
+	"github.com/lidofinance/dc4bc/fsm/fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/types/requests"
+	"github.com/lidofinance/dc4bc/fsm/types/responses"
+)
+
+// findParticipantID returns the id of our DKG public key in the list, or -1 if we are not in the list
+func (am *Machine) findParticipantID(entries []*responses.ResharingProposalParticipantEntry) (int, error) {
+	for _, entry := range entries {
+		pubKey := am.baseSuite.Point()
+		if err := pubKey.UnmarshalBinary(entry.DkgPubKey); err != nil {
+			return -1, fmt.Errorf("failed to unmarshal dkg pubkey: %w", err)
+		}
+		if am.pubKey.Equal(pubKey) {
+			return entry.ParticipantId, nil
+		}
+	}
+	return -1, nil
+}
+
+func marshalPoints(points []kyber.Point) ([]byte, error) {
+	marshaledPoints := make([][]byte, 0, len(points))
+	for _, point := range points {
+		pointBz, err := point.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal point: %w", err)
+		}
+		marshaledPoints = append(marshaledPoints, pointBz)
+	}
+	return json.Marshal(marshaledPoints)
+}
+
+func (am *Machine) unmarshalPoints(data []byte) ([]kyber.Point, error) {
+	var pointsBz [][]byte
+	if err := json.Unmarshal(data, &pointsBz); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal points: %w", err)
+	}
+	points := make([]kyber.Point, 0, len(pointsBz))
+	for _, pointBz := range pointsBz {
+		point := am.baseSuite.Point()
+		if err := point.UnmarshalBinary(pointBz); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal point: %w", err)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// storeResharingParticipants puts both the current and the new participants of the round into the DKG instance.
+// A machine which joins the round with the resharing gets a new DKG instance
+func (am *Machine) storeResharingParticipants(dkgIdentifier string, payload responses.ResharingProposalParticipantsResponse) (*dkg.DKG, error) {
+	dkgInstance, ok := am.dkgInstances[dkgIdentifier]
+	if !ok {
+		dkgInstance = dkg.Init(am.dkgSuite(dkgIdentifier), am.pubKey, am.secKey)
+		dkgInstance.ParticipantID = -1
+	}
+
+	dkgInstance.ResetParticipants()
+
+	for _, entry := range payload.OldParticipants {
+		pubKey := am.baseSuite.Point()
+		if err := pubKey.UnmarshalBinary(entry.DkgPubKey); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pubkey: %w", err)
+		}
+		dkgInstance.StorePubKey(entry.Username, entry.ParticipantId, pubKey)
+	}
+
+	for _, entry := range payload.NewParticipants {
+		pubKey := am.baseSuite.Point()
+		if err := pubKey.UnmarshalBinary(entry.DkgPubKey); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pubkey: %w", err)
+		}
+		dkgInstance.StoreNewPubKey(entry.Username, entry.ParticipantId, pubKey)
+	}
+
+	am.dkgInstances[dkgIdentifier] = dkgInstance
+
+	return dkgInstance, nil
+}
+
+// resharingSeed returns a seed for the resharing polynomial. It must differ from the seed of the DKG round,
+// but has to be the same when the operation log is replayed
+func (am *Machine) resharingSeed(o *client.Operation) []byte {
+	seed := sha256.Sum256(append([]byte(o.ID), am.baseSeed...))
+	return seed[:]
+}
+
+// handleStateResharingProposalAwaitConfirmations takes the current and the new participants of the round as payload
+// and returns an approval of the resharing by the current share holder
+func (am *Machine) handleStateResharingProposalAwaitConfirmations(o *client.Operation) error {
+	return am.answerResharingProposal(o, resharing_proposal_fsm.EventResharingProposalConfirm)
+}
+
+func (am *Machine) answerResharingProposal(o *client.Operation, event fsm.Event) error {
+	var payload responses.ResharingProposalConfirmationResponse
+	if err := json.Unmarshal(o.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	oldID, err := am.findParticipantID(payload.OldParticipants)
+	if err != nil {
+		return fmt.Errorf("failed to determine participant id: %w", err)
+	}
+	if oldID < 0 {
+		return fmt.Errorf("failed to determine participant id for DKG #%s", o.DKGIdentifier)
+	}
+
+	req := requests.ResharingProposalConfirmationRequest{
+		ParticipantId: oldID,
+		CreatedAt:     o.CreatedAt,
+	}
+	reqBz, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to generate fsm request: %w", err)
+	}
+
+	o.Event = event
+	o.ResultMsgs = append(o.ResultMsgs, createMessage(*o, reqBz))
+	return nil
+}
+
+// handleStateResharingDealsAwaitConfirmations takes the current and the new participants of the round as payload.
+// If we are a dealer, it returns commits, the public polynomial of the round key and deals for all
+// new participants to broadcast. Each deal is encrypted with a public key of its recipient
+func (am *Machine) handleStateResharingDealsAwaitConfirmations(o *client.Operation) error {
+	var (
+		payload responses.ResharingProposalParticipantsResponse
+		err     error
+	)
+
+	if err = json.Unmarshal(o.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	oldID, err := am.findParticipantID(payload.OldParticipants)
+	if err != nil {
+		return fmt.Errorf("failed to determine participant id: %w", err)
+	}
+	newID, err := am.findParticipantID(payload.NewParticipants)
+	if err != nil {
+		return fmt.Errorf("failed to determine participant id: %w", err)
+	}
+
+	var isDealer bool
+	for _, dealerID := range payload.Dealers {
+		if oldID >= 0 && dealerID == oldID {
+			isDealer = true
+		}
+	}
+
+	// receivers which do not deal need public coefficients of the round key from dealers,
+	// so they init the resharing on the next step
+	if !isDealer {
+		if newID >= 0 {
+			_, err = am.storeResharingParticipants(o.DKGIdentifier, payload)
+		}
+		return err
+	}
+
+	dkgInstance, err := am.storeResharingParticipants(o.DKGIdentifier, payload)
+	if err != nil {
+		return fmt.Errorf("failed to store participants: %w", err)
+	}
+
+	blsKeyring, err := am.loadBLSKeyring(o.DKGIdentifier)
+	if err != nil {
+		return fmt.Errorf("failed to load BLSKeyring: %w", err)
+	}
+
+	err = dkgInstance.InitResharingInstance(blsKeyring, nil, payload.OldThreshold, payload.NewThreshold, am.resharingSeed(o))
+	if err != nil {
+		return fmt.Errorf("failed to init resharing instance: %w", err)
+	}
+
+	commitsBz, err := marshalPoints(dkgInstance.GetCommits())
+	if err != nil {
+		return fmt.Errorf("failed to marshal commits: %w", err)
+	}
+
+	_, publicCoeffs := blsKeyring.PubPoly.Info()
+	pubPolyBz, err := marshalPoints(publicCoeffs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal public polynomial: %w", err)
+	}
+
+	deals, err := dkgInstance.GetDeals()
+	if err != nil {
+		return fmt.Errorf("failed to get deals: %w", err)
+	}
+
+	// deals variable is a map, so every key is an index of a new participant we should send a deal
+	encryptedDeals := make(map[int][]byte)
+	for index, deal := range deals {
+		dealBz, err := json.Marshal(deal)
+		if err != nil {
+			return fmt.Errorf("failed to marshal deal: %w", err)
+		}
+		pk := dkgInstance.GetNewPKByIndex(index)
+		if pk == nil {
+			return fmt.Errorf("failed to get pk for new participant %d", index)
+		}
+		encryptedDeal, err := ecies.Encrypt(am.baseSuite, pk, dealBz, am.baseSuite.Hash)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt deal: %w", err)
+		}
+		encryptedDeals[index] = encryptedDeal
+	}
+
+	req := requests.ResharingProposalDealConfirmationRequest{
+		ParticipantId: oldID,
+		Commit:        commitsBz,
+		PubPoly:       pubPolyBz,
+		Deals:         encryptedDeals,
+		CreatedAt:     o.CreatedAt,
+	}
+	reqBz, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to generate fsm request: %w", err)
+	}
+
+	o.Event = resharing_proposal_fsm.EventResharingDealConfirmationReceived
+	o.ResultMsgs = append(o.ResultMsgs, createMessage(*o, reqBz))
+	return nil
+}
+
+// handleStateResharingResponsesAwaitConfirmations takes broadcasted dealers commits and deals as payload.
+// If we are a new participant, it decrypts and processes deals sent to us and returns responses to broadcast
+func (am *Machine) handleStateResharingResponsesAwaitConfirmations(o *client.Operation) error {
+	var (
+		payload responses.ResharingProposalDealsResponse
+		err     error
+	)
+
+	if err = json.Unmarshal(o.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	newID, err := am.findParticipantID(payload.NewParticipants)
+	if err != nil {
+		return fmt.Errorf("failed to determine participant id: %w", err)
+	}
+	if newID < 0 {
+		return nil
+	}
+
+	dkgInstance, ok := am.dkgInstances[o.DKGIdentifier]
+	if !ok {
+		return fmt.Errorf("dkg instance with identifier %s does not exist", o.DKGIdentifier)
+	}
+
+	if len(payload.Deals) == 0 {
+		return fmt.Errorf("no deals received")
+	}
+
+	// all dealers must agree on the public polynomial of the round key
+	pubPolyBz := payload.Deals[0].DkgPubPoly
+	for _, entry := range payload.Deals {
+		if !bytes.Equal(entry.DkgPubPoly, pubPolyBz) {
+			return fmt.Errorf("public polynomial from %s differs from others", entry.Username)
+		}
+	}
+
+	if !dkgInstance.IsResharing() {
+		publicCoeffs, err := am.unmarshalPoints(pubPolyBz)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal public polynomial: %w", err)
+		}
+		err = dkgInstance.InitResharingInstance(nil, publicCoeffs, payload.OldThreshold, payload.NewThreshold, am.resharingSeed(o))
+		if err != nil {
+			return fmt.Errorf("failed to init resharing instance: %w", err)
+		}
+	}
+
+	for _, entry := range payload.Deals {
+		dkgCommits, err := am.unmarshalPoints(entry.DkgCommit)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal commits: %w", err)
+		}
+		dkgInstance.StoreCommits(entry.Username, dkgCommits)
+
+		encryptedDeal, ok := entry.DkgDeals[newID]
+		if !ok {
+			// a dealer processes its own deal itself
+			continue
+		}
+		decryptedDealBz, err := am.decryptDataFromParticipant(encryptedDeal)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt deal: %w", err)
+		}
+		var deal dkgPedersen.Deal
+		if err = json.Unmarshal(decryptedDealBz, &deal); err != nil {
+			return fmt.Errorf("failed to unmarshal deal")
+		}
+		dkgInstance.StoreDeal(entry.Username, &deal)
+	}
+
+	processedResponses, err := dkgInstance.ProcessDeals()
+	if err != nil {
+		return fmt.Errorf("failed to process deals: %w", err)
+	}
+
+	responsesBz, err := json.Marshal(processedResponses)
+	if err != nil {
+		return fmt.Errorf("failed to marshal responses")
+	}
+
+	req := requests.ResharingProposalResponseConfirmationRequest{
+		ParticipantId: newID,
+		Response:      responsesBz,
+		CreatedAt:     o.CreatedAt,
+	}
+	reqBz, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to generate fsm request: %w", err)
+	}
+
+	o.Event = resharing_proposal_fsm.EventResharingResponseConfirmationReceived
+	o.ResultMsgs = append(o.ResultMsgs, createMessage(*o, reqBz))
+	return nil
+}
+
+// handleStateResharingMasterKeyAwaitConfirmations takes broadcasted responses of the new participants, process them,
+// saves a new private part of the round key and returns the master key to broadcast.
+// The master key must stay the same as before the resharing
+func (am *Machine) handleStateResharingMasterKeyAwaitConfirmations(o *client.Operation) error {
+	var (
+		payload responses.ResharingProposalResponsesResponse
+		err     error
+	)
+
+	if err = json.Unmarshal(o.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	dkgInstance, ok := am.dkgInstances[o.DKGIdentifier]
+	if !ok || !dkgInstance.IsResharing() {
+		return nil
+	}
+
+	newID := -1
+	for _, entry := range payload {
+		pubKey := dkgInstance.GetNewPKByIndex(entry.ParticipantId)
+		if pubKey != nil && pubKey.Equal(am.pubKey) {
+			newID = entry.ParticipantId
+		}
+	}
+	// we were only a dealer, there is nothing to receive
+	if newID < 0 {
+		return nil
+	}
+
+	for _, entry := range payload {
+		var entryResponses []*dkgPedersen.Response
+		if err = json.Unmarshal(entry.DkgResponse, &entryResponses); err != nil {
+			return fmt.Errorf("failed to unmarshal responses: %w", err)
+		}
+		dkgInstance.StoreResponses(entry.Username, entryResponses)
+	}
+
+	if err = dkgInstance.SwitchToNewParticipants(); err != nil {
+		return fmt.Errorf("failed to switch to new participants: %w", err)
+	}
+
+	if err = dkgInstance.ProcessResponses(); err != nil {
+		return fmt.Errorf("failed to process responses: %w", err)
+	}
+
+	pubKey, err := dkgInstance.GetDistributedPublicKey()
+	if err != nil {
+		return fmt.Errorf("failed to get master pub key: %w", err)
+	}
+
+	masterPubKeyBz, err := pubKey.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to marshal master pub key: %w", err)
+	}
+
+	blsKeyring, err := dkgInstance.GetBLSKeyring()
+	if err != nil {
+		return fmt.Errorf("failed to get BLSKeyring: %w", err)
+	}
+
+	if err = am.saveBLSKeyring(o.DKGIdentifier, blsKeyring); err != nil {
+		return fmt.Errorf("failed to save BLSKeyring: %w", err)
+	}
+
+	pubPolyBz, err := dkg.PubPolyBytes(blsKeyring.PubPoly)
+	if err != nil {
+		return fmt.Errorf("failed to marshal public polynomial: %w", err)
+	}
+
+	req := requests.ResharingProposalMasterKeyConfirmationRequest{
+		ParticipantId: dkgInstance.ParticipantID,
+		MasterKey:     masterPubKeyBz,
+		PubPoly:       pubPolyBz,
+		CreatedAt:     o.CreatedAt,
+	}
+	reqBz, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to generate fsm request: %w", err)
+	}
+
+	o.Event = resharing_proposal_fsm.EventResharingMasterKeyConfirmationReceived
+	o.ResultMsgs = append(o.ResultMsgs, createMessage(*o, reqBz))
+	return nil
+}
@@ -0,0 +1,323 @@
+package airgapped
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	client "github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/types/requests"
+	"github.com/lidofinance/dc4bc/fsm/types/responses"
+)
+
+func newTestNode(t *testing.T, testDir string, i int) *Node {
+	am, err := NewMachine(fmt.Sprintf("%s/%s-%d", testDir, testDB, i))
+	if err != nil {
+		t.Fatalf("failed to create airgapped machine: %v", err)
+	}
+	am.SetEncryptionKey([]byte(fmt.Sprintf(testDB+"%d", i)))
+	if err = am.InitKeys(); err != nil {
+		t.Fatalf(err.Error())
+	}
+	return &Node{
+		ParticipantID: i,
+		Participant:   fmt.Sprintf("Participant#%d", i),
+		Machine:       am,
+	}
+}
+
+func handleAndBroadcast(t *testing.T, tr *Transport, n *Node, op client.Operation) {
+	operation, err := n.Machine.HandleOperation(op)
+	if err != nil {
+		t.Fatalf("%s: failed to handle operation %s: %v", n.Participant, op.Type, err)
+	}
+	for _, msg := range operation.ResultMsgs {
+		tr.BroadcastMessage(t, msg)
+	}
+}
+
+func participantEntry(t *testing.T, n *Node, id int) *responses.ResharingProposalParticipantEntry {
+	pubKey, err := n.Machine.pubKey.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: failed to marshal pubkey: %v", n.Participant, err)
+	}
+	return &responses.ResharingProposalParticipantEntry{
+		ParticipantId: id,
+		Username:      n.Participant,
+		DkgPubKey:     pubKey,
+	}
+}
+
+func runTestDKG(t *testing.T, tr *Transport, threshold int) {
+	runTestDKGDeals(t, tr, threshold)
+	runTestDKGResponses(t, tr)
+	runTestDKGMasterKey(t, tr)
+}
+
+// runTestDKGDeals runs a DKG round until every participant has the deals sent to it
+func runTestDKGDeals(t *testing.T, tr *Transport, threshold int) {
+	var initReq responses.SignatureProposalParticipantInvitationsResponse
+	var getCommitsRequest responses.DKGProposalPubKeysParticipantResponse
+	for _, n := range tr.nodes {
+		pubKey, err := n.Machine.pubKey.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal dkg pubkey: %v", err)
+		}
+		initReq = append(initReq, &responses.SignatureProposalParticipantInvitationEntry{
+			ParticipantId: n.ParticipantID,
+			Username:      n.Participant,
+			Threshold:     threshold,
+			DkgPubKey:     pubKey,
+		})
+		getCommitsRequest = append(getCommitsRequest, &responses.DKGProposalPubKeysParticipantEntry{
+			ParticipantId: n.ParticipantID,
+			Username:      n.Participant,
+			DkgPubKey:     pubKey,
+		})
+	}
+
+	op := createOperation(t, string(signature_proposal_fsm.StateAwaitParticipantsConfirmations), "", initReq)
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		handleAndBroadcast(t, &Transport{}, n, op)
+	})
+
+	op = createOperation(t, string(dkg_proposal_fsm.StateDkgCommitsAwaitConfirmations), "", getCommitsRequest)
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		handleAndBroadcast(t, tr, n, op)
+	})
+
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+
+		var payload responses.DKGProposalCommitParticipantResponse
+		for _, req := range n.commits {
+			payload = append(payload, &responses.DKGProposalCommitParticipantEntry{
+				ParticipantId: req.ParticipantId,
+				Username:      fmt.Sprintf("Participant#%d", req.ParticipantId),
+				DkgCommit:     req.Commit,
+			})
+		}
+		handleAndBroadcast(t, tr, n, createOperation(t, string(dkg_proposal_fsm.StateDkgDealsAwaitConfirmations), "", payload))
+	})
+}
+
+func runTestDKGResponses(t *testing.T, tr *Transport) {
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+
+		var payload responses.DKGProposalDealParticipantResponse
+		for _, req := range n.deals {
+			payload = append(payload, &responses.DKGProposalDealParticipantEntry{
+				ParticipantId: req.ParticipantId,
+				Username:      fmt.Sprintf("Participant#%d", req.ParticipantId),
+				DkgDeal:       req.Deal,
+			})
+		}
+		handleAndBroadcast(t, tr, n, createOperation(t, string(dkg_proposal_fsm.StateDkgResponsesAwaitConfirmations), "", payload))
+	})
+}
+
+func runTestDKGMasterKey(t *testing.T, tr *Transport) {
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		handleAndBroadcast(t, tr, n, createOperation(t, string(dkg_proposal_fsm.StateDkgMasterKeyAwaitConfirmations), "", responsesPayload(n)))
+	})
+}
+
+// responsesPayload returns the broadcasted responses with the justifications if the round had them
+func responsesPayload(n *Node) responses.DKGProposalResponseParticipantResponse {
+	var payload responses.DKGProposalResponseParticipantResponse
+	for _, req := range n.responses {
+		entry := &responses.DKGProposalResponseParticipantEntry{
+			ParticipantId: req.ParticipantId,
+			Username:      fmt.Sprintf("Participant#%d", req.ParticipantId),
+			DkgResponse:   req.Response,
+		}
+		for _, j := range n.justifications {
+			if j.ParticipantId == req.ParticipantId {
+				entry.DkgJustification = j.Justification
+			}
+		}
+		payload = append(payload, entry)
+	}
+	return payload
+}
+
+func TestAirgappedResharing(t *testing.T) {
+	testDir := "/tmp/airgapped_resharing_test"
+	defer os.RemoveAll(testDir)
+
+	var (
+		oldThreshold = 2
+		newThreshold = 3
+		nodes        []*Node
+	)
+	for i := 0; i < 5; i++ {
+		nodes = append(nodes, newTestNode(t, testDir, i))
+	}
+
+	// the first four nodes run the DKG, the last one joins later
+	tr := &Transport{nodes: nodes[:4]}
+	runTestDKG(t, tr, oldThreshold)
+
+	masterKey := nodes[0].masterKeys[0].MasterKey
+	for _, n := range tr.nodes {
+		for _, req := range n.masterKeys {
+			if !bytes.Equal(masterKey, req.MasterKey) {
+				t.Fatalf("master keys is not equal!")
+			}
+		}
+	}
+
+	// Participant#0 leaves, Participant#2 receives a new share without dealing, Participant#4 joins
+	var (
+		dealers     = []int{0, 1, 3}
+		newNodes    = []*Node{nodes[1], nodes[2], nodes[3], nodes[4]}
+		usernameMap = make(map[int]string)
+	)
+	participantsPayload := responses.ResharingProposalParticipantsResponse{
+		OldThreshold: oldThreshold,
+		NewThreshold: newThreshold,
+		Dealers:      dealers,
+	}
+	for id, n := range nodes[:4] {
+		participantsPayload.OldParticipants = append(participantsPayload.OldParticipants, participantEntry(t, n, id))
+	}
+	for id, n := range newNodes {
+		participantsPayload.NewParticipants = append(participantsPayload.NewParticipants, participantEntry(t, n, id))
+		usernameMap[id] = n.Participant
+	}
+
+	tr = &Transport{nodes: nodes}
+
+	// current share holders approve the resharing proposed by Participant#0
+	op := createOperation(t, string(resharing_proposal_fsm.StateResharingProposalAwaitConfirmations), "",
+		responses.ResharingProposalConfirmationResponse{
+			ResharingProposalParticipantsResponse: participantsPayload,
+			InitiatorId:                           0,
+		})
+	for id, n := range nodes[1:4] {
+		operation, err := n.Machine.HandleOperation(op)
+		if err != nil {
+			t.Fatalf("%s: failed to handle operation %s: %v", n.Participant, op.Type, err)
+		}
+		if len(operation.ResultMsgs) != 1 || operation.ResultMsgs[0].Event != string(resharing_proposal_fsm.EventResharingProposalConfirm) {
+			t.Fatalf("%s: expected resharing approval, got %+v", n.Participant, operation.ResultMsgs)
+		}
+		var req requests.ResharingProposalConfirmationRequest
+		if err = json.Unmarshal(operation.ResultMsgs[0].Data, &req); err != nil {
+			t.Fatalf("failed to unmarshal approval: %v", err)
+		}
+		if req.ParticipantId != id+1 {
+			t.Fatalf("%s: expected participant id %d, got %d", n.Participant, id+1, req.ParticipantId)
+		}
+	}
+
+	op = createOperation(t, string(resharing_proposal_fsm.StateResharingDealsAwaitConfirmations), "", participantsPayload)
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		handleAndBroadcast(t, tr, n, op)
+	})
+
+	if len(nodes[4].resharingDeals) != len(dealers) {
+		t.Fatalf("expected %d resharing deals, got %d", len(dealers), len(nodes[4].resharingDeals))
+	}
+
+	dealsPayload := responses.ResharingProposalDealsResponse{
+		ResharingProposalParticipantsResponse: participantsPayload,
+	}
+	for _, req := range nodes[4].resharingDeals {
+		dealsPayload.Deals = append(dealsPayload.Deals, &responses.ResharingProposalDealEntry{
+			ParticipantId: req.ParticipantId,
+			Username:      fmt.Sprintf("Participant#%d", req.ParticipantId),
+			DkgCommit:     req.Commit,
+			DkgPubPoly:    req.PubPoly,
+			DkgDeals:      req.Deals,
+		})
+	}
+	op = createOperation(t, string(resharing_proposal_fsm.StateResharingResponsesAwaitConfirmations), "", dealsPayload)
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		handleAndBroadcast(t, tr, n, op)
+	})
+
+	if len(nodes[4].resharingResponses) != len(newNodes) {
+		t.Fatalf("expected %d resharing responses, got %d", len(newNodes), len(nodes[4].resharingResponses))
+	}
+
+	var responsesPayload responses.ResharingProposalResponsesResponse
+	for _, req := range nodes[4].resharingResponses {
+		responsesPayload = append(responsesPayload, &responses.ResharingProposalResponseEntry{
+			ParticipantId: req.ParticipantId,
+			Username:      usernameMap[req.ParticipantId],
+			DkgResponse:   req.Response,
+		})
+	}
+	// the machines are restarted in the middle of the resharing, the leaving dealer included
+	restartNodes(t, tr, testDir)
+	op = createOperation(t, string(resharing_proposal_fsm.StateResharingMasterKeyAwaitConfirmations), "", responsesPayload)
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		handleAndBroadcast(t, tr, n, op)
+	})
+
+	// the master key must survive the resharing
+	if len(nodes[4].resharingMasterKeys) != len(newNodes) {
+		t.Fatalf("expected %d master keys, got %d", len(newNodes), len(nodes[4].resharingMasterKeys))
+	}
+	for _, req := range nodes[4].resharingMasterKeys {
+		if !bytes.Equal(masterKey, req.MasterKey) {
+			t.Fatalf("master key is changed after resharing!")
+		}
+	}
+
+	msgToSign := []byte("i am a message")
+
+	// sign with the new participants only, a threshold of them is enough
+	tr = &Transport{nodes: newNodes}
+	signers := &Transport{nodes: newNodes[1:]}
+	op = createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "",
+		responses.SigningPartialSignsParticipantInvitationsResponse{SrcPayloads: [][]byte{msgToSign}})
+	runStep(signers, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+		handleAndBroadcast(t, tr, n, op)
+	})
+
+	runStep(tr, func(n *Node, wg *sync.WaitGroup) {
+		defer wg.Done()
+
+		payload := responses.SigningProcessParticipantResponse{
+			SrcPayloads: [][]byte{msgToSign},
+		}
+		for _, req := range n.partialSigns {
+			payload.Participants = append(payload.Participants, &responses.SigningProcessParticipantEntry{
+				ParticipantId: req.ParticipantId,
+				Username:      usernameMap[req.ParticipantId],
+				PartialSigns:  req.PartialSigns,
+			})
+		}
+		handleAndBroadcast(t, tr, n, createOperation(t, string(signing_proposal_fsm.StateSigningPartialSignsCollected), "", payload))
+	})
+
+	for _, n := range tr.nodes {
+		if len(n.reconstructedSignatures) == 0 {
+			t.Fatalf("%s: signature is not reconstructed", n.Participant)
+		}
+		for _, signature := range n.reconstructedSignatures {
+			if err := n.Machine.VerifySign(msgToSign, signature.Signature, DKGIdentifier); err != nil {
+				t.Fatal("signature is not verified!")
+			}
+		}
+	}
+
+	testKyberPrysm(t, masterKey, tr.nodes[0].reconstructedSignatures[0].Signature, msgToSign)
+}
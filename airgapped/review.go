@@ -0,0 +1,263 @@
+package airgapped
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	client "github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/eth2"
+	"github.com/lidofinance/dc4bc/fsm/fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/types/requests"
+	"github.com/lidofinance/dc4bc/fsm/types/responses"
+)
+
+// ErrOperationRejected is written to the response of an operation rejected by the operator
+var ErrOperationRejected = errors.New("operation is rejected by the operator")
+
+// OperationReviewer shows a human-readable description of an operation to the operator and returns
+// whether the operator approved it
+type OperationReviewer func(description string) (bool, error)
+
+// SetOperationReviewer sets a reviewer asked before an operation read from QR codes or a file is handled,
+// without a reviewer every operation is handled right away
+func (am *Machine) SetOperationReviewer(reviewer OperationReviewer) {
+	am.reviewer = reviewer
+}
+
+// reviewOperation asks the reviewer to approve the operation, a rejected operation gets a decline or an error
+// response instead of being handled. The signer key of an operation file is shown along with the operation
+func (am *Machine) reviewOperation(o client.Operation, signerPubKey []byte) (client.Operation, bool, error) {
+	if am.reviewer == nil {
+		return o, true, nil
+	}
+
+	description, err := am.DescribeOperation(o)
+	if err != nil {
+		return o, false, fmt.Errorf("failed to describe operation: %w", err)
+	}
+	if signerPubKey != nil {
+		signer, err := am.describeSigner(signerPubKey)
+		if err != nil {
+			return o, false, fmt.Errorf("failed to describe operation signer: %w", err)
+		}
+		description = signer + description
+	}
+	approved, err := am.reviewer(description)
+	if err != nil {
+		return o, false, fmt.Errorf("failed to review operation: %w", err)
+	}
+	if approved {
+		return o, true, nil
+	}
+
+	if err = am.rejectOperation(&o); err != nil {
+		return o, false, fmt.Errorf("failed to reject operation: %w", err)
+	}
+	return o, false, nil
+}
+
+// rejectOperation writes a response to the rejected operation. Invitations to a DKG round, to a signing
+// or to a resharing are declined, other operations get an error
+func (am *Machine) rejectOperation(o *client.Operation) error {
+	switch fsm.State(o.Type) {
+	case signature_proposal_fsm.StateAwaitParticipantsConfirmations:
+		var payload responses.SignatureProposalParticipantInvitationsResponse
+		if err := json.Unmarshal(o.Payload, &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		pid := -1
+		for _, r := range payload {
+			pubKey := am.baseSuite.Point()
+			if err := pubKey.UnmarshalBinary(r.DkgPubKey); err == nil && am.pubKey.Equal(pubKey) {
+				pid = r.ParticipantId
+				break
+			}
+		}
+		if pid < 0 {
+			return fmt.Errorf("failed to determine participant id for DKG #%s", o.DKGIdentifier)
+		}
+		return am.writeRejection(o, signature_proposal_fsm.EventDeclineProposal, requests.SignatureProposalParticipantRequest{
+			ParticipantId: pid,
+			CreatedAt:     o.CreatedAt,
+		})
+	case signing_proposal_fsm.StateSigningAwaitConfirmations:
+		var payload responses.SigningProposalParticipantInvitationsResponse
+		if err := json.Unmarshal(o.Payload, &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		pid, err := am.getParticipantID(o.DKGIdentifier)
+		if err != nil {
+			return fmt.Errorf("failed to get paricipant id: %w", err)
+		}
+		return am.writeRejection(o, signing_proposal_fsm.EventDeclineSigningConfirmation, requests.SigningProposalParticipantRequest{
+			SigningId:     payload.SigningId,
+			ParticipantId: pid,
+			CreatedAt:     o.CreatedAt,
+		})
+	case resharing_proposal_fsm.StateResharingProposalAwaitConfirmations:
+		return am.answerResharingProposal(o, resharing_proposal_fsm.EventResharingProposalDecline)
+	default:
+		return am.writeErrorRequestToOperation(o, ErrOperationRejected)
+	}
+}
+
+func (am *Machine) writeRejection(o *client.Operation, event fsm.Event, req interface{}) error {
+	reqBz, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to generate fsm request: %w", err)
+	}
+	o.Event = event
+	o.ResultMsgs = append(o.ResultMsgs, createMessage(*o, reqBz))
+	return nil
+}
+
+// DescribeOperation returns a human-readable description of the operation: the DKG round, the participants,
+// the threshold and the payload. Payloads to sign are shown with the validator operations they belong to
+func (am *Machine) DescribeOperation(o client.Operation) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Operation: %s\n", o.ID)
+	fmt.Fprintf(&sb, "Type: %s\n", o.Type)
+	fmt.Fprintf(&sb, "DKG round: %s\n", o.DKGIdentifier)
+	if o.To != "" {
+		fmt.Fprintf(&sb, "Recipient: %s\n", o.To)
+	}
+	if dkgInstance, ok := am.dkgInstances[o.DKGIdentifier]; ok {
+		fmt.Fprintf(&sb, "Threshold: %d of %d\n", dkgInstance.Threshold, dkgInstance.N)
+	}
+
+	var err error
+	switch fsm.State(o.Type) {
+	case signature_proposal_fsm.StateAwaitParticipantsConfirmations:
+		err = describeInvitation(&sb, o.Payload)
+	case dkg_proposal_fsm.StateDkgCommitsAwaitConfirmations:
+		var payload responses.DKGProposalPubKeysParticipantResponse
+		if err = json.Unmarshal(o.Payload, &payload); err == nil {
+			sb.WriteString("Participants:\n")
+			for _, entry := range payload {
+				fmt.Fprintf(&sb, "  #%d %s\n", entry.ParticipantId, entry.Username)
+			}
+		}
+	case dkg_proposal_fsm.StateDkgDealsAwaitConfirmations,
+		dkg_proposal_fsm.StateDkgResponsesAwaitConfirmations,
+		dkg_proposal_fsm.StateDkgJustificationsAwaitConfirmations,
+		dkg_proposal_fsm.StateDkgMasterKeyAwaitConfirmations,
+		resharing_proposal_fsm.StateResharingMasterKeyAwaitConfirmations:
+		var payload []struct {
+			ParticipantId int
+			Username      string
+		}
+		if err = json.Unmarshal(o.Payload, &payload); err == nil {
+			fmt.Fprintf(&sb, "Messages from %d participants:\n", len(payload))
+			for _, entry := range payload {
+				fmt.Fprintf(&sb, "  #%d %s\n", entry.ParticipantId, entry.Username)
+			}
+		}
+	case resharing_proposal_fsm.StateResharingProposalAwaitConfirmations,
+		resharing_proposal_fsm.StateResharingDealsAwaitConfirmations,
+		resharing_proposal_fsm.StateResharingResponsesAwaitConfirmations:
+		var payload responses.ResharingProposalParticipantsResponse
+		if err = json.Unmarshal(o.Payload, &payload); err == nil {
+			describeResharing(&sb, payload)
+		}
+	case signing_proposal_fsm.StateSigningAwaitConfirmations:
+		var payload responses.SigningProposalParticipantInvitationsResponse
+		if err = json.Unmarshal(o.Payload, &payload); err == nil {
+			fmt.Fprintf(&sb, "Signing: %s\n", payload.SigningId)
+			describePayloads(&sb, payload.SrcPayloads, payload.Eth2Requests)
+		}
+	case signing_proposal_fsm.StateSigningAwaitPartialSigns:
+		var payload responses.SigningPartialSignsParticipantInvitationsResponse
+		if err = json.Unmarshal(o.Payload, &payload); err == nil {
+			fmt.Fprintf(&sb, "Signing: %s\n", payload.SigningId)
+			describePayloads(&sb, payload.SrcPayloads, payload.Eth2Requests)
+		}
+	case signing_proposal_fsm.StateSigningPartialSignsCollected:
+		var payload responses.SigningProcessParticipantResponse
+		if err = json.Unmarshal(o.Payload, &payload); err == nil {
+			fmt.Fprintf(&sb, "Signing: %s\n", payload.SigningId)
+			fmt.Fprintf(&sb, "Partial signatures from %d participants\n", len(payload.Participants))
+			describePayloads(&sb, payload.SrcPayloads, nil)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+func describeInvitation(sb *strings.Builder, payloadBz []byte) error {
+	var payload responses.SignatureProposalParticipantInvitationsResponse
+	if err := json.Unmarshal(payloadBz, &payload); err != nil {
+		return err
+	}
+	if len(payload) > 0 {
+		fmt.Fprintf(sb, "Threshold: %d of %d\n", payload[0].Threshold, len(payload))
+	}
+	sb.WriteString("Participants:\n")
+	for _, entry := range payload {
+		fmt.Fprintf(sb, "  #%d %s\n", entry.ParticipantId, entry.Username)
+	}
+	return nil
+}
+
+func describeResharing(sb *strings.Builder, payload responses.ResharingProposalParticipantsResponse) {
+	fmt.Fprintf(sb, "Threshold: %d of %d -> %d of %d\n", payload.OldThreshold, len(payload.OldParticipants),
+		payload.NewThreshold, len(payload.NewParticipants))
+	sb.WriteString("Current participants:\n")
+	for _, entry := range payload.OldParticipants {
+		fmt.Fprintf(sb, "  #%d %s\n", entry.ParticipantId, entry.Username)
+	}
+	sb.WriteString("New participants:\n")
+	for _, entry := range payload.NewParticipants {
+		fmt.Fprintf(sb, "  #%d %s\n", entry.ParticipantId, entry.Username)
+	}
+	fmt.Fprintf(sb, "Dealers: %v\n", payload.Dealers)
+}
+
+// describePayloads shows the payloads of a signing, a single payload is shown without the batch header
+func describePayloads(sb *strings.Builder, srcPayloads [][]byte, eth2Requests []*eth2.SigningRequest) {
+	if len(srcPayloads) == 1 {
+		var request *eth2.SigningRequest
+		if len(eth2Requests) > 0 {
+			request = eth2Requests[0]
+		}
+		describePayload(sb, srcPayloads[0], request)
+		return
+	}
+
+	fmt.Fprintf(sb, "Batch of %d payloads:\n", len(srcPayloads))
+	for i, payload := range srcPayloads {
+		fmt.Fprintf(sb, "#%d ", i)
+		var request *eth2.SigningRequest
+		if i < len(eth2Requests) {
+			request = eth2Requests[i]
+		}
+		describePayload(sb, payload, request)
+	}
+}
+
+// describePayload shows a payload to sign, a validator operation is shown instead of its signing root
+func describePayload(sb *strings.Builder, payload []byte, request *eth2.SigningRequest) {
+	fmt.Fprintf(sb, "Payload (hex): %s\n", hex.EncodeToString(payload))
+	if request != nil {
+		sb.WriteString(request.String())
+		sb.WriteString("\n")
+		return
+	}
+	if utf8.Valid(payload) && strings.IndexFunc(string(payload), isNotPrintable) < 0 {
+		fmt.Fprintf(sb, "Payload (text): %s\n", payload)
+	}
+}
+
+func isNotPrintable(r rune) bool {
+	return !unicode.IsPrint(r) && !unicode.IsSpace(r)
+}
@@ -0,0 +1,99 @@
+package airgapped
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	client "github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/eth2"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/types/requests"
+	"github.com/lidofinance/dc4bc/fsm/types/responses"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAirgappedOperationReview(t *testing.T) {
+	var (
+		req     = require.New(t)
+		testDir = "/tmp/airgapped_operation_review_test"
+	)
+	defer os.RemoveAll(testDir)
+
+	tr := &Transport{}
+	for i := 0; i < 3; i++ {
+		tr.nodes = append(tr.nodes, newTestNode(t, testDir, i))
+	}
+	runTestDKG(t, tr, 2)
+
+	var (
+		n           = tr.nodes[1]
+		approve     bool
+		description string
+	)
+	n.Machine.SetOperationReviewer(func(d string) (bool, error) {
+		description = d
+		return approve, nil
+	})
+	handle := func(op client.Operation) client.Operation {
+		opBz, err := json.Marshal(op)
+		req.NoError(err)
+		resultOperation, _, err := n.Machine.handleOperationJSON(opBz, nil)
+		req.NoError(err)
+		req.Len(resultOperation.ResultMsgs, 1)
+		return resultOperation
+	}
+
+	eth2Request := &eth2.SigningRequest{
+		Type:          eth2.OperationVoluntaryExit,
+		ForkVersion:   eth2.Version{0x03, 0x00, 0x00, 0x00},
+		VoluntaryExit: &eth2.VoluntaryExit{Epoch: 194048, ValidatorIndex: 12345},
+	}
+	signingRoot, err := eth2Request.SigningRoot()
+	req.NoError(err)
+
+	// a rejected signing invitation is declined
+	op := handle(createOperation(t, string(signing_proposal_fsm.StateSigningAwaitConfirmations), "",
+		responses.SigningProposalParticipantInvitationsResponse{
+			SigningId:    "signing_id",
+			SrcPayloads:  [][]byte{signingRoot[:]},
+			Eth2Requests: []*eth2.SigningRequest{eth2Request},
+		}))
+	req.Contains(description, DKGIdentifier)
+	req.Contains(description, "Threshold: 2 of 3")
+	req.Contains(description, "Voluntary exit")
+	req.Contains(description, "Validator index: 12345")
+	req.Equal(signing_proposal_fsm.EventDeclineSigningConfirmation, op.Event)
+	var declineReq requests.SigningProposalParticipantRequest
+	req.NoError(json.Unmarshal(op.ResultMsgs[0].Data, &declineReq))
+	req.Equal("signing_id", declineReq.SigningId)
+	req.Equal(1, declineReq.ParticipantId)
+
+	// a rejected payload to sign gets an error instead of a partial signature
+	partialSignsPayload := responses.SigningPartialSignsParticipantInvitationsResponse{
+		SigningId:   "signing_id",
+		SrcPayloads: [][]byte{[]byte("i am a message")},
+	}
+	op = handle(createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "", partialSignsPayload))
+	req.Contains(description, "Payload (text): i am a message")
+	req.Equal(signing_proposal_fsm.EventSigningPartialSignError, op.Event)
+
+	approve = true
+	op = handle(createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "", partialSignsPayload))
+	req.Equal(signing_proposal_fsm.EventSigningPartialSignReceived, op.Event)
+
+	// a rejected invitation to a DKG round is declined without starting the round
+	approve = false
+	pubKey, err := n.Machine.pubKey.MarshalBinary()
+	req.NoError(err)
+	invitation := createOperation(t, string(signature_proposal_fsm.StateAwaitParticipantsConfirmations), "",
+		responses.SignatureProposalParticipantInvitationsResponse{
+			{ParticipantId: 0, Username: "Participant#0", Threshold: 2, DkgPubKey: pubKey},
+		})
+	invitation.DKGIdentifier = "another_dkg_identifier"
+	op = handle(invitation)
+	req.Contains(description, "Participant#0")
+	req.Equal(signature_proposal_fsm.EventDeclineProposal, op.Event)
+	req.NotContains(n.Machine.dkgInstances, invitation.DKGIdentifier)
+}
@@ -0,0 +1,253 @@
+package airgapped
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lidofinance/dc4bc/eth2"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	validatorHistoryDBPrefix   = "validator_history_"
+	genesisValidatorsRootDBKey = "genesis_validators_root"
+)
+
+// slashingProtectionUpdate is a validator history with the blocks and attestations of a signing added,
+// it's saved only after the partial signatures are made
+type slashingProtectionUpdate struct {
+	history               *eth2.ValidatorHistory
+	genesisValidatorsRoot eth2.Root
+}
+
+// roundValidatorPubkey returns the master public key of the round, it's the validator key of the signatures
+func (am *Machine) roundValidatorPubkey(dkgIdentifier string) (eth2.BLSPubkey, error) {
+	var pubkey eth2.BLSPubkey
+	blsKeyring, err := am.loadBLSKeyring(dkgIdentifier)
+	if err != nil {
+		return pubkey, fmt.Errorf("failed to load blsKeyring: %w", err)
+	}
+	pubkeyBz, err := blsKeyring.PubPoly.Commit().MarshalBinary()
+	if err != nil {
+		return pubkey, fmt.Errorf("failed to marshal master pubkey: %w", err)
+	}
+	if len(pubkeyBz) != len(pubkey) {
+		return pubkey, fmt.Errorf("unexpected master pubkey length %d", len(pubkeyBz))
+	}
+	copy(pubkey[:], pubkeyBz)
+	return pubkey, nil
+}
+
+func (am *Machine) getValidatorHistory(pubkey eth2.BLSPubkey) (*eth2.ValidatorHistory, error) {
+	history := &eth2.ValidatorHistory{Pubkey: pubkey}
+	historyBz, err := am.db.Get([]byte(validatorHistoryDBPrefix+pubkey.String()), nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return history, nil
+		}
+		return nil, fmt.Errorf("failed to get validator history from db: %w", err)
+	}
+	if err = json.Unmarshal(historyBz, history); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal validator history: %w", err)
+	}
+	return history, nil
+}
+
+// getGenesisValidatorsRoot returns the genesis validators root of the chain protected by the history,
+// or nil if nothing was signed or imported yet
+func (am *Machine) getGenesisValidatorsRoot() (*eth2.Root, error) {
+	rootBz, err := am.db.Get([]byte(genesisValidatorsRootDBKey), nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get genesis validators root from db: %w", err)
+	}
+	var root eth2.Root
+	if err = root.UnmarshalText(rootBz); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal genesis validators root: %w", err)
+	}
+	return &root, nil
+}
+
+// checkSlashingProtection checks the blocks and attestations of the signing against the history of the round
+// validator, the same block or attestation can be signed again, e.g. when the operation is replayed.
+// A raw payload may be a signing root of a block or an attestation, so raw payloads are refused once the round
+// validator has a history. It returns nil if there is nothing to protect in the signing
+func (am *Machine) checkSlashingProtection(dkgIdentifier string, srcPayloads [][]byte,
+	eth2Requests []*eth2.SigningRequest) (*slashingProtectionUpdate, error) {
+	var (
+		update  *slashingProtectionUpdate
+		history *eth2.ValidatorHistory
+	)
+	loadHistory := func() error {
+		if history != nil {
+			return nil
+		}
+		pubkey, err := am.roundValidatorPubkey(dkgIdentifier)
+		if err != nil {
+			return err
+		}
+		history, err = am.getValidatorHistory(pubkey)
+		return err
+	}
+
+	for i := range srcPayloads {
+		if i < len(eth2Requests) && eth2Requests[i] != nil {
+			continue
+		}
+		if err := loadHistory(); err != nil {
+			return nil, err
+		}
+		if len(history.SignedBlocks) > 0 || len(history.SignedAttestations) > 0 {
+			return nil, fmt.Errorf("payload #%d is a raw payload, but the round validator has a slashing protection "+
+				"history, blocks and attestations must be signed as Eth2 requests", i)
+		}
+	}
+
+	for i, request := range eth2Requests {
+		if request == nil || (request.Type != eth2.OperationBlock && request.Type != eth2.OperationAttestation) {
+			continue
+		}
+		signingRoot, err := request.SigningRoot()
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute signing root of payload #%d: %w", i, err)
+		}
+
+		if update == nil {
+			if err = loadHistory(); err != nil {
+				return nil, err
+			}
+			genesisValidatorsRoot, err := am.getGenesisValidatorsRoot()
+			if err != nil {
+				return nil, err
+			}
+			if genesisValidatorsRoot == nil {
+				genesisValidatorsRoot = &request.GenesisValidatorsRoot
+			}
+			update = &slashingProtectionUpdate{history: history, genesisValidatorsRoot: *genesisValidatorsRoot}
+		}
+		if request.GenesisValidatorsRoot != update.genesisValidatorsRoot {
+			return nil, fmt.Errorf("payload #%d is signed for genesis validators root %s, the slashing protection is kept for %s",
+				i, request.GenesisValidatorsRoot, update.genesisValidatorsRoot)
+		}
+
+		// payloads of a batch are added one by one, so they can't conflict with each other either
+		if err = update.history.Check(request, signingRoot); err != nil {
+			return nil, fmt.Errorf("payload #%d: %w", i, err)
+		}
+		update.history.Add(request, signingRoot)
+	}
+	return update, nil
+}
+
+// recordSlashingProtection adds the validator history updated by checkSlashingProtection to the batch
+func recordSlashingProtection(batch *leveldb.Batch, update *slashingProtectionUpdate) error {
+	if update == nil {
+		return nil
+	}
+	return putValidatorHistories(batch, update.genesisValidatorsRoot, []*eth2.ValidatorHistory{update.history})
+}
+
+func putValidatorHistories(batch *leveldb.Batch, genesisValidatorsRoot eth2.Root,
+	histories []*eth2.ValidatorHistory) error {
+	rootBz, _ := genesisValidatorsRoot.MarshalText()
+	batch.Put([]byte(genesisValidatorsRootDBKey), rootBz)
+	for _, history := range histories {
+		historyBz, err := json.Marshal(history)
+		if err != nil {
+			return fmt.Errorf("failed to marshal validator history: %w", err)
+		}
+		batch.Put([]byte(validatorHistoryDBPrefix+history.Pubkey.String()), historyBz)
+	}
+	return nil
+}
+
+func (am *Machine) saveValidatorHistories(genesisValidatorsRoot eth2.Root, histories []*eth2.ValidatorHistory) error {
+	batch := new(leveldb.Batch)
+	if err := putValidatorHistories(batch, genesisValidatorsRoot, histories); err != nil {
+		return err
+	}
+	if err := am.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("failed to put validator histories into db: %w", err)
+	}
+	return nil
+}
+
+// ExportSlashingProtection returns the blocks and attestations signed on the machine
+// in the EIP-3076 interchange format
+func (am *Machine) ExportSlashingProtection() ([]byte, error) {
+	interchange := eth2.Interchange{
+		Metadata: eth2.InterchangeMetadata{InterchangeFormatVersion: eth2.InterchangeFormatVersion},
+		Data:     make([]*eth2.ValidatorHistory, 0),
+	}
+	genesisValidatorsRoot, err := am.getGenesisValidatorsRoot()
+	if err != nil {
+		return nil, err
+	}
+	if genesisValidatorsRoot != nil {
+		interchange.Metadata.GenesisValidatorsRoot = *genesisValidatorsRoot
+	}
+
+	iter := am.db.NewIterator(util.BytesPrefix([]byte(validatorHistoryDBPrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		history := &eth2.ValidatorHistory{}
+		if err = json.Unmarshal(iter.Value(), history); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal validator history: %w", err)
+		}
+		if history.SignedBlocks == nil {
+			history.SignedBlocks = make([]eth2.SignedBlock, 0)
+		}
+		if history.SignedAttestations == nil {
+			history.SignedAttestations = make([]eth2.SignedAttestation, 0)
+		}
+		interchange.Data = append(interchange.Data, history)
+	}
+	if err = iter.Error(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over validator histories: %w", err)
+	}
+
+	return json.MarshalIndent(interchange, "", "  ")
+}
+
+// ImportSlashingProtection merges a history in the EIP-3076 interchange format into the slashing protection
+// of the machine, e.g. the history of the validator signed elsewhere before
+func (am *Machine) ImportSlashingProtection(data []byte) error {
+	var interchange eth2.Interchange
+	if err := json.Unmarshal(data, &interchange); err != nil {
+		return fmt.Errorf("failed to unmarshal interchange: %w", err)
+	}
+	if err := interchange.Validate(); err != nil {
+		return fmt.Errorf("invalid interchange: %w", err)
+	}
+
+	genesisValidatorsRoot, err := am.getGenesisValidatorsRoot()
+	if err != nil {
+		return err
+	}
+	if genesisValidatorsRoot != nil && *genesisValidatorsRoot != interchange.Metadata.GenesisValidatorsRoot {
+		return fmt.Errorf("interchange is made for genesis validators root %s, the slashing protection is kept for %s",
+			interchange.Metadata.GenesisValidatorsRoot, *genesisValidatorsRoot)
+	}
+
+	histories := make(map[eth2.BLSPubkey]*eth2.ValidatorHistory)
+	for _, imported := range interchange.Data {
+		history, ok := histories[imported.Pubkey]
+		if !ok {
+			if history, err = am.getValidatorHistory(imported.Pubkey); err != nil {
+				return err
+			}
+			histories[imported.Pubkey] = history
+		}
+		history.Merge(imported)
+	}
+
+	merged := make([]*eth2.ValidatorHistory, 0, len(histories))
+	for _, history := range histories {
+		merged = append(merged, history)
+	}
+	return am.saveValidatorHistories(interchange.Metadata.GenesisValidatorsRoot, merged)
+}
@@ -0,0 +1,112 @@
+package airgapped
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/lidofinance/dc4bc/eth2"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/types/responses"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAirgappedSlashingProtection(t *testing.T) {
+	var (
+		req     = require.New(t)
+		testDir = "/tmp/airgapped_slashing_protection_test"
+	)
+	defer os.RemoveAll(testDir)
+
+	tr := &Transport{}
+	for i := 0; i < 3; i++ {
+		tr.nodes = append(tr.nodes, newTestNode(t, testDir, i))
+	}
+	runTestDKG(t, tr, 2)
+
+	gvr := eth2.Root{0x01}
+	block := func(slot uint64, bodyRoot byte) *eth2.SigningRequest {
+		return &eth2.SigningRequest{
+			Type:                  eth2.OperationBlock,
+			ForkVersion:           eth2.Version{0x03, 0x00, 0x00, 0x00},
+			GenesisValidatorsRoot: gvr,
+			BlockHeader:           &eth2.BeaconBlockHeader{Slot: slot, BodyRoot: eth2.Root{bodyRoot}},
+		}
+	}
+	attestation := func(source, target uint64) *eth2.SigningRequest {
+		return &eth2.SigningRequest{
+			Type:                  eth2.OperationAttestation,
+			ForkVersion:           eth2.Version{0x03, 0x00, 0x00, 0x00},
+			GenesisValidatorsRoot: gvr,
+			AttestationData: &eth2.AttestationData{
+				Source: eth2.Checkpoint{Epoch: source},
+				Target: eth2.Checkpoint{Epoch: target},
+			},
+		}
+	}
+	sign := func(n *Node, signingID string, eth2Requests ...*eth2.SigningRequest) error {
+		payload := responses.SigningPartialSignsParticipantInvitationsResponse{SigningId: signingID}
+		for _, eth2Request := range eth2Requests {
+			signingRoot, err := eth2Request.SigningRoot()
+			req.NoError(err)
+			payload.SrcPayloads = append(payload.SrcPayloads, signingRoot[:])
+			payload.Eth2Requests = append(payload.Eth2Requests, eth2Request)
+		}
+		op := createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "", payload)
+		return n.Machine.handleStateSigningAwaitPartialSigns(&op)
+	}
+
+	n := tr.nodes[0]
+	req.NoError(sign(n, "first", block(100, 1), attestation(10, 11)))
+	// the same signing is made again, e.g. when the operation log is replayed
+	req.NoError(sign(n, "first", block(100, 1), attestation(10, 11)))
+	// the same block in another signing is fine, another block at the slot isn't
+	req.NoError(sign(n, "second", block(100, 1)))
+	err := sign(n, "third", block(100, 2))
+	req.Error(err)
+	req.True(errors.Is(err, eth2.ErrSlashable))
+	req.Error(sign(n, "third", attestation(9, 12)))
+	// payloads of a batch can't conflict with each other
+	req.Error(sign(n, "third", block(101, 1), block(101, 2)))
+	// the protection is kept for a single chain
+	otherChainBlock := block(102, 1)
+	otherChainBlock.GenesisValidatorsRoot = eth2.Root{0x02}
+	req.Error(sign(n, "third", otherChainBlock))
+
+	// the refused signing didn't record anything
+	req.NoError(sign(n, "fourth", block(101, 2)))
+
+	// a raw payload may be a signing root of a conflicting block, it's refused once there is a history
+	conflictingRoot, err := block(100, 3).SigningRoot()
+	req.NoError(err)
+	op := createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "",
+		responses.SigningPartialSignsParticipantInvitationsResponse{SigningId: "raw", SrcPayloads: [][]byte{conflictingRoot[:]}})
+	req.Error(n.Machine.handleStateSigningAwaitPartialSigns(&op))
+	// a round without a history still signs raw payloads
+	op = createOperation(t, string(signing_proposal_fsm.StateSigningAwaitPartialSigns), "",
+		responses.SigningPartialSignsParticipantInvitationsResponse{SigningId: "raw", SrcPayloads: [][]byte{[]byte("i am a message")}})
+	req.NoError(tr.nodes[1].Machine.handleStateSigningAwaitPartialSigns(&op))
+
+	// the history moves to another machine in the interchange format
+	interchangeBz, err := n.Machine.ExportSlashingProtection()
+	req.NoError(err)
+	var interchange eth2.Interchange
+	req.NoError(json.Unmarshal(interchangeBz, &interchange))
+	req.Equal(eth2.InterchangeFormatVersion, interchange.Metadata.InterchangeFormatVersion)
+	req.Equal(gvr, interchange.Metadata.GenesisValidatorsRoot)
+	req.Len(interchange.Data, 1)
+	req.Len(interchange.Data[0].SignedBlocks, 2)
+	req.Len(interchange.Data[0].SignedAttestations, 1)
+
+	other := tr.nodes[1]
+	req.NoError(other.Machine.ImportSlashingProtection(interchangeBz))
+	req.Error(sign(other, "third", block(100, 2)))
+	req.NoError(sign(other, "fifth", block(100, 1), attestation(11, 12)))
+
+	// an interchange of another chain is refused
+	interchange.Metadata.GenesisValidatorsRoot = eth2.Root{0x02}
+	interchangeBz, err = json.Marshal(interchange)
+	req.NoError(err)
+	req.Error(other.Machine.ImportSlashingProtection(interchangeBz))
+}
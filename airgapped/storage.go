@@ -6,11 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	bls12381 "github.com/corestario/kyber/pairing/bls12381"
 
 	client "github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/dkg"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 const (
@@ -19,6 +22,7 @@ const (
 	saltDBKey          = "salt_key"
 	baseSeedKey        = "base_seed_key"
 	operationsLogDBKey = "operations_log"
+	dkgStatePrefix     = "dkg_state_"
 )
 
 type RoundOperationLog map[string][]client.Operation
@@ -240,3 +244,61 @@ func (am *Machine) SaveKeysToDB() error {
 
 	return nil
 }
+
+// saveDKGState saves the encrypted state of the round's DKG instance, so a restarted machine continues the round
+// without replaying the operation log
+func (am *Machine) saveDKGState(dkgIdentifier string) error {
+	dkgInstance, ok := am.dkgInstances[dkgIdentifier]
+	if !ok {
+		return nil
+	}
+
+	salt, err := am.db.Get([]byte(saltDBKey), nil)
+	if err != nil {
+		return fmt.Errorf("failed to read salt from db: %w", err)
+	}
+
+	state, err := dkgInstance.MarshalState()
+	if err != nil {
+		return fmt.Errorf("failed to marshal dkg state: %w", err)
+	}
+
+	encryptedState, err := encrypt(am.encryptionKey, salt, state)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt dkg state: %w", err)
+	}
+	if err = am.db.Put([]byte(dkgStatePrefix+dkgIdentifier), encryptedState, nil); err != nil {
+		return fmt.Errorf("failed to put dkg state into db: %w", err)
+	}
+	return nil
+}
+
+// loadDKGStates restores DKG instances of the rounds from the saved states, an instance which is already
+// in memory is kept as is
+func (am *Machine) loadDKGStates() error {
+	salt, err := am.db.Get([]byte(saltDBKey), nil)
+	if err != nil {
+		return fmt.Errorf("failed to read salt from db: %w", err)
+	}
+
+	iter := am.db.NewIterator(util.BytesPrefix([]byte(dkgStatePrefix)), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		dkgIdentifier := strings.TrimPrefix(string(iter.Key()), dkgStatePrefix)
+		if _, ok := am.dkgInstances[dkgIdentifier]; ok {
+			continue
+		}
+
+		state, err := decrypt(am.encryptionKey, salt, iter.Value())
+		if err != nil {
+			return fmt.Errorf("failed to decrypt dkg state of %s: %w", dkgIdentifier, err)
+		}
+		dkgInstance, err := dkg.RestoreState(am.dkgSuite(dkgIdentifier), am.pubKey, am.secKey, state)
+		if err != nil {
+			return fmt.Errorf("failed to restore dkg state of %s: %w", dkgIdentifier, err)
+		}
+		am.dkgInstances[dkgIdentifier] = dkgInstance
+	}
+	return iter.Error()
+}
@@ -25,13 +25,16 @@ import (
 
 	"github.com/lidofinance/dc4bc/fsm/fsm"
 	dpf "github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	rpf "github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/qr"
 	"github.com/lidofinance/dc4bc/storage"
 )
 
 const (
-	pollingPeriod = time.Second
-	QrCodesDir    = "/tmp"
+	timeoutCheckPeriod = time.Minute
+	QrCodesDir         = "/tmp"
+	// maxClockSkew is how far the sender timestamp of a message without a log time can be behind the round clock
+	maxClockSkew = time.Minute
 )
 
 type Client interface {
@@ -41,6 +44,8 @@ type Client interface {
 	GetUsername() string
 	SendMessage(message storage.Message) error
 	ProcessMessage(message storage.Message) error
+	SetAllowLegacyMessages(allow bool)
+	SetHTTPServerConfig(config HTTPServerConfig)
 	GetOperations() (map[string]*types.Operation, error)
 	GetOperationQRPath(operationID string) (string, error)
 	StartHTTPServer(listenAddr string) error
@@ -54,8 +59,18 @@ type BaseClient struct {
 	ctx         context.Context
 	state       State
 	storage     storage.Storage
-	keyStore    KeyStore
+	keyPair     *KeyPair
 	qrProcessor qr.Processor
+	// fileProcessor transfers operations to the airgapped machine on removable media
+	fileProcessor *qr.FileProcessor
+	// timeouts which were already sent to the append-only log, to avoid sending them twice
+	sentTimeouts map[string]bool
+	// offsetReset is signaled when the offset is changed by hand to resubscribe from it
+	offsetReset chan struct{}
+	// allowLegacyMessages enables messages signed without an envelope, see storage.Message.Bytes
+	allowLegacyMessages bool
+	// httpConfig secures the HTTP API
+	httpConfig HTTPServerConfig
 }
 
 func NewClient(
@@ -64,23 +79,28 @@ func NewClient(
 	state State,
 	storage storage.Storage,
 	keyStore KeyStore,
+	keyStorePassword string,
 	qrProcessor qr.Processor,
 ) (Client, error) {
-	keyPair, err := keyStore.LoadKeys(userName, "")
+	keyPair, err := keyStore.LoadKeys(userName, keyStorePassword)
 	if err != nil {
 		return nil, fmt.Errorf("failed to LoadKeys: %w", err)
 	}
 
-	return &BaseClient{
-		ctx:         ctx,
-		Logger:      newLogger(userName),
-		userName:    userName,
-		pubKey:      keyPair.Pub,
-		state:       state,
-		storage:     storage,
-		keyStore:    keyStore,
-		qrProcessor: qrProcessor,
-	}, nil
+	c := &BaseClient{
+		ctx:          ctx,
+		Logger:       newLogger(userName),
+		userName:     userName,
+		pubKey:       keyPair.Pub,
+		state:        state,
+		storage:      storage,
+		keyPair:      keyPair,
+		qrProcessor:  qrProcessor,
+		sentTimeouts: make(map[string]bool),
+		offsetReset:  make(chan struct{}, 1),
+	}
+	c.fileProcessor = qr.NewFileProcessor(&keyPairSigner{keyPair: keyPair}, verifyAirgappedFileSignature)
+	return c, nil
 }
 
 func (c *BaseClient) GetLogger() *logger {
@@ -95,36 +115,74 @@ func (c *BaseClient) GetPubKey() ed25519.PublicKey {
 	return c.pubKey
 }
 
+// SetAllowLegacyMessages enables messages whose signature does not cover the envelope fields.
+// It's needed only to replay an append-only log written by older clients
+func (c *BaseClient) SetAllowLegacyMessages(allow bool) {
+	c.allowLegacyMessages = allow
+}
+
+// SetHTTPServerConfig sets TLS, authentication and the audit log of the HTTP API, it must be called
+// before StartHTTPServer. Without credentials the server is started only if authentication is disabled explicitly
+func (c *BaseClient) SetHTTPServerConfig(config HTTPServerConfig) {
+	c.httpConfig = config
+}
+
 // Poll is a main client loop, which gets new messages from an append-only log and processes them
 func (c *BaseClient) Poll() error {
-	tk := time.NewTicker(pollingPeriod)
+	timeoutTk := time.NewTicker(timeoutCheckPeriod)
+	defer timeoutTk.Stop()
+	for {
+		offset, err := c.state.LoadOffset()
+		if err != nil {
+			return fmt.Errorf("failed to LoadOffset: %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(c.ctx)
+		messages, err := c.storage.Subscribe(ctx, offset)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("failed to Subscribe: %w", err)
+		}
+
+		resubscribe, err := c.consume(messages, timeoutTk.C)
+		cancel()
+		if !resubscribe {
+			return err
+		}
+		c.Logger.Log("Offset was changed, resubscribing to the append-only log")
+	}
+}
+
+// consume processes messages of a subscription until it's closed or the offset is changed by hand
+func (c *BaseClient) consume(messages <-chan storage.Message, timeouts <-chan time.Time) (resubscribe bool, err error) {
 	for {
 		select {
-		case <-tk.C:
-			offset, err := c.state.LoadOffset()
-			if err != nil {
-				panic(err)
+		case <-timeouts:
+			if err := c.sendTimeouts(time.Now()); err != nil {
+				c.Logger.Log("Failed to send timeouts: %v", err)
 			}
-
-			messages, err := c.storage.GetMessages(offset)
-			if err != nil {
-				return fmt.Errorf("failed to GetMessages: %w", err)
+		case <-c.offsetReset:
+			return true, nil
+		case message, ok := <-messages:
+			if !ok {
+				if c.ctx.Err() != nil {
+					log.Println("Context closed, stop polling...")
+					return false, nil
+				}
+				return false, errors.New("subscription to the append-only log is closed")
 			}
-
-			for _, message := range messages {
-				if message.RecipientAddr == "" || message.RecipientAddr == c.GetUsername() {
-					c.Logger.Log("Handling message with offset %d, type %s", message.Offset, message.Event)
-					if err := c.ProcessMessage(message); err != nil {
-						c.Logger.Log("Failed to process message with offset %d: %v", message.Offset, err)
-					} else {
-						c.Logger.Log("Successfully processed message with offset %d, type %s",
-							message.Offset, message.Event)
-					}
+			if message.RecipientAddr == "" || message.RecipientAddr == c.GetUsername() {
+				c.Logger.Log("Handling message with offset %d, type %s", message.Offset, message.Event)
+				if err := c.ProcessMessage(message); err != nil {
+					c.Logger.Log("Failed to process message with offset %d: %v", message.Offset, err)
+				} else {
+					c.Logger.Log("Successfully processed message with offset %d, type %s",
+						message.Offset, message.Event)
 				}
 			}
 		case <-c.ctx.Done():
 			log.Println("Context closed, stop polling...")
-			return nil
+			return false, nil
 		}
 	}
 }
@@ -151,6 +209,79 @@ func (c *BaseClient) processSignature(message storage.Message) error {
 	return c.state.SaveSignature(signature)
 }
 
+// processSigningStart saves the data of a new signing, an entry is saved for each payload of a batch
+func (c *BaseClient) processSigningStart(message storage.Message) error {
+	var request requests.SigningProposalStartRequest
+	if err := json.Unmarshal(message.Data, &request); err != nil {
+		return fmt.Errorf("failed to unmarshal signing start request: %w", err)
+	}
+
+	for _, srcPayload := range request.SrcPayloads {
+		err := c.state.SaveSignature(types.ReconstructedSignature{
+			SigningID:  request.SigningID,
+			SrcPayload: srcPayload,
+			Username:   message.SenderAddr,
+			DKGRoundID: message.DkgRoundID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// messageTime returns the time of FSM transitions made by the message, it's the time the storage appended the message at.
+// The sender timestamp is used only if the storage doesn't assign it, then the timestamp can't go back in the round.
+// The time depends only on the log and the round clock, which is built from the log, so a replay of the log
+// makes the same transitions as the live run.
+// A legacy message has no signed timestamp, lines written by older clients have no log time either,
+// so such a message is timed by the creation time set in its signed data, as older clients did
+func (c *BaseClient) messageTime(message storage.Message) (time.Time, error) {
+	roundClock, err := c.state.LoadRoundClock(message.DkgRoundID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to LoadRoundClock: %w", err)
+	}
+
+	messageTime := message.LogTime
+	if messageTime.IsZero() {
+		if message.Version == storage.MessageVersionLegacy {
+			return legacyMessageTime(message, roundClock)
+		}
+		if message.Timestamp.IsZero() {
+			return time.Time{}, errors.New("message timestamp is not set")
+		}
+		if message.Timestamp.Before(roundClock.Add(-maxClockSkew)) {
+			return time.Time{}, fmt.Errorf("message timestamp %s is behind the round clock %s",
+				message.Timestamp, roundClock)
+		}
+		messageTime = message.Timestamp
+	}
+
+	// clocks of participants and storage nodes differ a bit, so the round clock only goes forward
+	if messageTime.Before(roundClock) {
+		messageTime = roundClock
+	}
+	return messageTime.UTC(), nil
+}
+
+// legacyMessageTime returns the creation time of the request in the data of a legacy message,
+// the round clock only goes forward with it as well
+func legacyMessageTime(message storage.Message, roundClock time.Time) (time.Time, error) {
+	var data struct {
+		CreatedAt time.Time
+	}
+	if err := json.Unmarshal(message.Data, &data); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal legacy message data: %w", err)
+	}
+	if data.CreatedAt.IsZero() {
+		return time.Time{}, errors.New("legacy message has neither a log time nor a creation time")
+	}
+	if data.CreatedAt.Before(roundClock) {
+		return roundClock.UTC(), nil
+	}
+	return data.CreatedAt.UTC(), nil
+}
+
 func (c *BaseClient) ProcessMessage(message storage.Message) error {
 	// save broadcasted reconstructed signature
 	if fsm.Event(message.Event) == types.SignatureReconstructed {
@@ -166,8 +297,8 @@ func (c *BaseClient) ProcessMessage(message storage.Message) error {
 	// save signing data to the same storage as we save signatures
 	// This allows easy to view signing data by CLI-command
 	if fsm.Event(message.Event) == sipf.EventSigningStart {
-		if err := c.processSignature(message); err != nil {
-			return fmt.Errorf("failed to process signature: %w", err)
+		if err := c.processSigningStart(message); err != nil {
+			return fmt.Errorf("failed to process signing start: %w", err)
 		}
 	}
 	fsmInstance, err := c.getFSMInstance(message.DkgRoundID)
@@ -182,12 +313,44 @@ func (c *BaseClient) ProcessMessage(message storage.Message) error {
 		}
 	}
 
-	fsmReq, err := types.FSMRequestFromMessage(message)
+	messageTime, err := c.messageTime(message)
+	if err != nil {
+		return fmt.Errorf("failed to get message time: %w", err)
+	}
+
+	fsmReq, err := types.FSMRequestFromMessage(message, messageTime)
 	if err != nil {
 		return fmt.Errorf("failed to get FSMRequestFromMessage: %v", err)
 	}
 
-	resp, fsmDump, err := fsmInstance.Do(fsm.Event(message.Event), fsmReq)
+	resharingGeneration, err := fsmInstance.ResharingGeneration()
+	if err != nil {
+		return fmt.Errorf("failed to get ResharingGeneration: %w", err)
+	}
+	seenKey, tracked := seenMessageKey(message, fsmReq, resharingGeneration)
+	if tracked {
+		if err := c.checkSeenMessage(seenKey, message); err != nil {
+			return err
+		}
+	}
+
+	// switch FSM state by hand due to implementation specifics
+	if fsm.Event(message.Event) == rpf.EventResharingProposalStart {
+		if fsmInstance, err = c.requestResharing(fsmInstance, messageTime); err != nil {
+			return fmt.Errorf("failed to request resharing: %w", err)
+		}
+	}
+
+	var (
+		resp    *fsm.Response
+		fsmDump []byte
+	)
+	// every signing session has its own state, so signing messages are routed by the signing ID
+	if signingID, ok := types.SigningIDFromRequest(fsmReq); ok {
+		resp, fsmDump, err = fsmInstance.DoSigning(signingID, fsm.Event(message.Event), fsmReq)
+	} else {
+		resp, fsmDump, err = fsmInstance.Do(fsm.Event(message.Event), fsmReq)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to Do operation in FSM: %w", err)
 	}
@@ -201,7 +364,7 @@ func (c *BaseClient) ProcessMessage(message storage.Message) error {
 			return fmt.Errorf("failed get state_machines from dump: %w", err)
 		}
 		resp, fsmDump, err = fsmInstance.Do(dpf.EventDKGInitProcess, requests.DefaultRequest{
-			CreatedAt: time.Now(),
+			CreatedAt: messageTime,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to Do operation in FSM: %w", err)
@@ -213,7 +376,21 @@ func (c *BaseClient) ProcessMessage(message storage.Message) error {
 			return fmt.Errorf("failed get state_machines from dump: %w", err)
 		}
 		resp, fsmDump, err = fsmInstance.Do(sipf.EventSigningInit, requests.DefaultRequest{
-			CreatedAt: time.Now(),
+			CreatedAt: messageTime,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to Do operation in FSM: %w", err)
+		}
+	}
+
+	// a canceled resharing is rolled back, so the round is signed by the current share holders again
+	if isResharingCanceled(resp.State) {
+		fsmInstance, err = state_machines.FromDump(fsmDump)
+		if err != nil {
+			return fmt.Errorf("failed get state_machines from dump: %w", err)
+		}
+		resp, fsmDump, err = fsmInstance.Do(rpf.EventResharingRollback, requests.DefaultRequest{
+			CreatedAt: messageTime,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to Do operation in FSM: %w", err)
@@ -228,15 +405,25 @@ func (c *BaseClient) ProcessMessage(message storage.Message) error {
 		dpf.StateDkgCommitsAwaitConfirmations,
 		dpf.StateDkgDealsAwaitConfirmations,
 		dpf.StateDkgResponsesAwaitConfirmations,
+		dpf.StateDkgJustificationsAwaitConfirmations,
 		dpf.StateDkgMasterKeyAwaitConfirmations,
 		sipf.StateSigningAwaitPartialSigns,
 		sipf.StateSigningPartialSignsCollected,
-		sipf.StateSigningAwaitConfirmations:
+		sipf.StateSigningAwaitConfirmations,
+		rpf.StateResharingProposalAwaitConfirmations,
+		rpf.StateResharingDealsAwaitConfirmations,
+		rpf.StateResharingResponsesAwaitConfirmations,
+		rpf.StateResharingMasterKeyAwaitConfirmations:
 		if resp.Data != nil {
 
+			// resharing operations are only for participants who deal or receive a new share
+			if !c.isResharingParticipant(resp.Data) {
+				break
+			}
+
 			// if we are initiator of signing, then we don't need to confirm our participation
 			if data, ok := resp.Data.(responses.SigningProposalParticipantInvitationsResponse); ok {
-				initiator, err := fsmInstance.SigningQuorumGetParticipant(data.InitiatorId)
+				initiator, err := fsmInstance.SigningQuorumGetParticipant(data.SigningId, data.InitiatorId)
 				if err != nil {
 					return fmt.Errorf("failed to get SigningQuorumParticipant: %w", err)
 				}
@@ -262,26 +449,18 @@ func (c *BaseClient) ProcessMessage(message storage.Message) error {
 		c.Logger.Log("State %s does not require an operation", resp.State)
 	}
 
-	// switch FSM state by hand due to implementation specifics
-	if resp.State == sipf.StateSigningPartialSignsCollected {
-		fsmInstance, err = state_machines.FromDump(fsmDump)
-		if err != nil {
-			return fmt.Errorf("failed get state_machines from dump: %w", err)
-		}
-		resp, fsmDump, err = fsmInstance.Do(sipf.EventSigningRestart, requests.DefaultRequest{
-			CreatedAt: time.Now(),
-		})
-		if err != nil {
-			return fmt.Errorf("failed to Do operation in FSM: %w", err)
-		}
-	}
-
 	if operation != nil {
 		if err := c.state.PutOperation(operation); err != nil {
 			return fmt.Errorf("failed to PutOperation: %w", err)
 		}
 	}
 
+	if tracked {
+		if err := c.saveSeenMessage(seenKey, message); err != nil {
+			return fmt.Errorf("failed to saveSeenMessage: %w", err)
+		}
+	}
+
 	if err := c.state.SaveOffset(message.Offset + 1); err != nil {
 		return fmt.Errorf("failed to SaveOffset: %w", err)
 	}
@@ -290,9 +469,108 @@ func (c *BaseClient) ProcessMessage(message storage.Message) error {
 		return fmt.Errorf("failed to SaveFSM: %w", err)
 	}
 
+	if err := c.state.SaveRoundClock(message.DkgRoundID, messageTime); err != nil {
+		return fmt.Errorf("failed to SaveRoundClock: %w", err)
+	}
+
 	return nil
 }
 
+// requestResharing hands an idle round over to the resharing machine
+func (c *BaseClient) requestResharing(fsmInstance *state_machines.FSMInstance, timestamp time.Time) (*state_machines.FSMInstance, error) {
+	state, err := fsmInstance.State()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get FSM state: %w", err)
+	}
+	// a round which was saved with a canceled resharing is rolled back before a new one
+	if isResharingCanceled(state) {
+		_, fsmDump, err := fsmInstance.Do(rpf.EventResharingRollback, requests.DefaultRequest{
+			CreatedAt: timestamp,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to Do operation in FSM: %w", err)
+		}
+		if fsmInstance, err = state_machines.FromDump(fsmDump); err != nil {
+			return nil, fmt.Errorf("failed get state_machines from dump: %w", err)
+		}
+		state = sipf.StateSigningIdle
+	}
+	if state != sipf.StateSigningIdle {
+		return fsmInstance, nil
+	}
+	_, fsmDump, err := fsmInstance.Do(sipf.EventSigningResharingRequest, requests.DefaultRequest{
+		CreatedAt: timestamp,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to Do operation in FSM: %w", err)
+	}
+	return state_machines.FromDump(fsmDump)
+}
+
+func isResharingCanceled(state fsm.State) bool {
+	switch state {
+	case rpf.StateResharingProposalAwaitCanceledByParticipant,
+		rpf.StateResharingProposalAwaitCanceledByTimeout,
+		rpf.StateResharingDealsAwaitCanceledByError,
+		rpf.StateResharingDealsAwaitCanceledByTimeout,
+		rpf.StateResharingResponsesAwaitCanceledByError,
+		rpf.StateResharingResponsesAwaitCanceledByTimeout,
+		rpf.StateResharingMasterKeyAwaitCanceledByError,
+		rpf.StateResharingMasterKeyAwaitCanceledByTimeout:
+		return true
+	}
+	return false
+}
+
+// isResharingParticipant checks whether the client deals or receives a share in the resharing
+// described by the FSM response, any other response is considered to be addressed to everyone
+func (c *BaseClient) isResharingParticipant(data interface{}) bool {
+	var participants responses.ResharingProposalParticipantsResponse
+	switch d := data.(type) {
+	case responses.ResharingProposalConfirmationResponse:
+		// the proposal is approved by current share holders, the initiator has approved it already
+		for _, entry := range d.OldParticipants {
+			if entry.Username == c.GetUsername() {
+				return entry.ParticipantId != d.InitiatorId
+			}
+		}
+		return false
+	case responses.ResharingProposalParticipantsResponse:
+		participants = d
+	case responses.ResharingProposalDealsResponse:
+		participants = d.ResharingProposalParticipantsResponse
+	case responses.ResharingProposalResponsesResponse:
+		for _, entry := range d {
+			if entry.Username == c.GetUsername() {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+
+	for _, entry := range participants.NewParticipants {
+		if entry.Username == c.GetUsername() {
+			return true
+		}
+	}
+	if _, ok := data.(responses.ResharingProposalDealsResponse); ok {
+		return false
+	}
+	for _, entry := range participants.OldParticipants {
+		if entry.Username != c.GetUsername() {
+			continue
+		}
+		for _, id := range participants.Dealers {
+			if id == entry.ParticipantId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (c *BaseClient) GetOperations() (map[string]*types.Operation, error) {
 	return c.state.GetOperations()
 }
@@ -356,6 +634,8 @@ func (c *BaseClient) handleProcessedOperation(operation types.Operation) error {
 
 	for i, message := range operation.ResultMsgs {
 		message.SenderAddr = c.GetUsername()
+		message.Timestamp = time.Now().UTC()
+		message.Version = storage.CurrentMessageVersion
 
 		sig, err := c.signMessage(message.Bytes())
 		if err != nil {
@@ -366,8 +646,11 @@ func (c *BaseClient) handleProcessedOperation(operation types.Operation) error {
 		operation.ResultMsgs[i] = message
 	}
 
-	if _, err := c.storage.SendBatch(operation.ResultMsgs...); err != nil {
-		return fmt.Errorf("failed to post messages: %w", err)
+	// an operation may have nothing to send, e.g. justifications of a dealer without complaints
+	if len(operation.ResultMsgs) > 0 {
+		if _, err := c.storage.SendBatch(operation.ResultMsgs...); err != nil {
+			return fmt.Errorf("failed to post messages: %w", err)
+		}
 	}
 
 	if err := c.state.DeleteOperation(operation.ID); err != nil {
@@ -403,15 +686,20 @@ func (c *BaseClient) getFSMInstance(dkgRoundID string) (*state_machines.FSMInsta
 }
 
 func (c *BaseClient) signMessage(message []byte) ([]byte, error) {
-	keyPair, err := c.keyStore.LoadKeys(c.userName, "")
-	if err != nil {
-		return nil, fmt.Errorf("failed to LoadKeys: %w", err)
-	}
-
-	return ed25519.Sign(keyPair.Priv, message), nil
+	return ed25519.Sign(c.keyPair.Priv, message), nil
 }
 
 func (c *BaseClient) verifyMessage(fsmInstance *state_machines.FSMInstance, message storage.Message) error {
+	switch message.Version {
+	case storage.MessageVersionEnvelope:
+	case storage.MessageVersionLegacy:
+		if !c.allowLegacyMessages {
+			return errors.New("legacy messages are not allowed")
+		}
+	default:
+		return fmt.Errorf("unknown message version %d", message.Version)
+	}
+
 	senderPubKey, err := fsmInstance.GetPubKeyByUsername(message.SenderAddr)
 	if err != nil {
 		return fmt.Errorf("failed to GetPubKeyByUsername: %w", err)
@@ -15,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/lidofinance/dc4bc/client"
 	"github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/fsm/fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines"
 	spf "github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/types/requests"
@@ -25,6 +26,8 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+const keyStorePassword = "keystore_password"
+
 func TestClient_ProcessMessage(t *testing.T) {
 	var (
 		ctx  = context.Background()
@@ -41,7 +44,7 @@ func TestClient_ProcessMessage(t *testing.T) {
 	qrProcessor := qrMocks.NewMockProcessor(ctrl)
 
 	testClientKeyPair := client.NewKeyPair()
-	keyStore.EXPECT().LoadKeys(userName, "").Times(1).Return(testClientKeyPair, nil)
+	keyStore.EXPECT().LoadKeys(userName, keyStorePassword).Times(1).Return(testClientKeyPair, nil)
 
 	clt, err := client.NewClient(
 		ctx,
@@ -49,6 +52,7 @@ func TestClient_ProcessMessage(t *testing.T) {
 		state,
 		stg,
 		keyStore,
+		keyStorePassword,
 		qrProcessor,
 	)
 	req.NoError(err)
@@ -96,18 +100,225 @@ func TestClient_ProcessMessage(t *testing.T) {
 			Event:      string(spf.EventInitProposal),
 			Data:       messageDataBz,
 			SenderAddr: senderAddr,
+			Timestamp:  time.Now(),
+			Version:    storage.CurrentMessageVersion,
 		}
 		message.Signature = ed25519.Sign(senderKeyPair.Priv, message.Bytes())
 
+		state.EXPECT().LoadRoundClock(dkgRoundID).Times(1).Return(time.Time{}, nil)
 		state.EXPECT().SaveOffset(gomock.Any()).Times(1).Return(nil)
 		state.EXPECT().SaveFSM(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+		state.EXPECT().SaveRoundClock(dkgRoundID, gomock.Any()).Times(1).Return(nil)
 		state.EXPECT().PutOperation(gomock.Any()).Times(1).Return(nil)
 
 		err = clt.ProcessMessage(message)
 		req.NoError(err)
+
+		// replaying the same message gives the same FSM dump
+		var dumps [][]byte
+		for i := 0; i < 2; i++ {
+			fsm, err := state_machines.Create(dkgRoundID)
+			req.NoError(err)
+			state.EXPECT().LoadFSM(dkgRoundID).Times(1).Return(fsm, true, nil)
+			state.EXPECT().LoadRoundClock(dkgRoundID).Times(1).Return(time.Time{}, nil)
+			state.EXPECT().SaveOffset(gomock.Any()).Times(1).Return(nil)
+			state.EXPECT().PutOperation(gomock.Any()).Times(1).Return(nil)
+			state.EXPECT().SaveFSM(dkgRoundID, gomock.Any()).Times(1).DoAndReturn(func(_ string, dump []byte) error {
+				dumps = append(dumps, dump)
+				return nil
+			})
+			state.EXPECT().SaveRoundClock(dkgRoundID, gomock.Any()).Times(1).Return(nil)
+
+			err = clt.ProcessMessage(message)
+			req.NoError(err)
+			time.Sleep(time.Millisecond)
+		}
+		req.Equal(dumps[0], dumps[1])
+
+		message.Timestamp = time.Time{}
+		state.EXPECT().LoadFSM(dkgRoundID).Times(1).Return(fsm, true, nil)
+		state.EXPECT().LoadRoundClock(dkgRoundID).Times(1).Return(time.Time{}, nil)
+		err = clt.ProcessMessage(message)
+		req.Error(err)
+
+		// a sender timestamp can't be behind the round clock
+		message.Timestamp = time.Now().Add(-time.Hour)
+		message.Signature = ed25519.Sign(senderKeyPair.Priv, message.Bytes())
+		state.EXPECT().LoadFSM(dkgRoundID).Times(1).Return(fsm, true, nil)
+		state.EXPECT().LoadRoundClock(dkgRoundID).Times(1).Return(time.Now(), nil)
+		err = clt.ProcessMessage(message)
+		req.Error(err)
+
+		// the log time is used instead of the sender timestamp
+		logTime := time.Now().Add(-time.Hour).UTC().Round(0)
+		message.LogTime = logTime
+		fsm, err = state_machines.Create(dkgRoundID)
+		req.NoError(err)
+		state.EXPECT().LoadFSM(dkgRoundID).Times(1).Return(fsm, true, nil)
+		state.EXPECT().LoadRoundClock(dkgRoundID).Times(1).Return(time.Time{}, nil)
+		state.EXPECT().SaveOffset(gomock.Any()).Times(1).Return(nil)
+		state.EXPECT().PutOperation(gomock.Any()).Times(1).Return(nil)
+		state.EXPECT().SaveFSM(dkgRoundID, gomock.Any()).Times(1).Return(nil)
+		state.EXPECT().SaveRoundClock(dkgRoundID, logTime).Times(1).Return(nil)
+		err = clt.ProcessMessage(message)
+		req.NoError(err)
 	})
 }
 
+func TestClient_ProcessMessage_Equivocation(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		req         = require.New(t)
+		ctrl        = gomock.NewController(t)
+		stateDbPath = "/tmp/dc4bc_test_equivocation"
+	)
+	defer ctrl.Finish()
+	defer os.RemoveAll(stateDbPath)
+
+	userName := "user_name"
+	dkgRoundID := "dkg_round_id"
+	state, err := client.NewLevelDBState(stateDbPath)
+	req.NoError(err)
+	keyStore := clientMocks.NewMockKeyStore(ctrl)
+	stg := storageMocks.NewMockStorage(ctrl)
+	qrProcessor := qrMocks.NewMockProcessor(ctrl)
+
+	keyStore.EXPECT().LoadKeys(userName, keyStorePassword).Times(1).Return(client.NewKeyPair(), nil)
+
+	clt, err := client.NewClient(ctx, userName, state, stg, keyStore, keyStorePassword, qrProcessor)
+	req.NoError(err)
+
+	senderKeyPair := client.NewKeyPair()
+	senderAddr := senderKeyPair.GetAddr()
+	participants := []*requests.SignatureProposalParticipantsEntry{
+		{Username: senderAddr, PubKey: senderKeyPair.Pub, DkgPubKey: make([]byte, 128)},
+		{Username: userName, PubKey: client.NewKeyPair().Pub, DkgPubKey: make([]byte, 128)},
+	}
+	newMessage := func(offset uint64, event fsm.Event, data interface{}) storage.Message {
+		dataBz, err := json.Marshal(data)
+		req.NoError(err)
+		message := storage.Message{
+			ID:         uuid.New().String(),
+			DkgRoundID: dkgRoundID,
+			Offset:     offset,
+			Event:      string(event),
+			Data:       dataBz,
+			SenderAddr: senderAddr,
+			Timestamp:  time.Now(),
+			Version:    storage.CurrentMessageVersion,
+		}
+		message.Signature = ed25519.Sign(senderKeyPair.Priv, message.Bytes())
+		return message
+	}
+
+	req.NoError(clt.ProcessMessage(newMessage(0, spf.EventInitProposal, requests.SignatureProposalParticipantsListRequest{
+		Participants:     participants,
+		CreatedAt:        time.Now(),
+		SigningThreshold: 2,
+	})))
+
+	confirmation := requests.SignatureProposalParticipantRequest{ParticipantId: 0, CreatedAt: time.Now()}
+	message := newMessage(1, spf.EventConfirmSignatureProposal, confirmation)
+	req.NoError(clt.ProcessMessage(message))
+
+	// the same message read again from the same offset is processed as usual
+	err = clt.ProcessMessage(message)
+	req.False(errors.Is(err, client.ErrDuplicateMessage))
+	req.False(errors.Is(err, client.ErrEquivocation))
+
+	duplicate := newMessage(2, spf.EventConfirmSignatureProposal, confirmation)
+	err = clt.ProcessMessage(duplicate)
+	req.True(errors.Is(err, client.ErrDuplicateMessage))
+
+	evidence, err := state.GetEvidence(dkgRoundID)
+	req.NoError(err)
+	req.Empty(evidence)
+
+	confirmation.CreatedAt = confirmation.CreatedAt.Add(time.Second)
+	conflicting := newMessage(3, spf.EventConfirmSignatureProposal, confirmation)
+	err = clt.ProcessMessage(conflicting)
+	req.True(errors.Is(err, client.ErrEquivocation))
+
+	evidence, err = state.GetEvidence(dkgRoundID)
+	req.NoError(err)
+	req.Len(evidence, 1)
+	req.Equal(senderAddr, evidence[0].Sender)
+	req.Equal(message.Offset, evidence[0].First.Offset)
+	req.Equal(conflicting.Offset, evidence[0].Second.Offset)
+}
+
+func TestClient_ProcessMessage_Legacy(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		req         = require.New(t)
+		ctrl        = gomock.NewController(t)
+		stateDbPath = "/tmp/dc4bc_test_legacy"
+	)
+	defer ctrl.Finish()
+	defer os.RemoveAll(stateDbPath)
+
+	userName := "user_name"
+	dkgRoundID := "dkg_round_id"
+	state, err := client.NewLevelDBState(stateDbPath)
+	req.NoError(err)
+	keyStore := clientMocks.NewMockKeyStore(ctrl)
+	stg := storageMocks.NewMockStorage(ctrl)
+	qrProcessor := qrMocks.NewMockProcessor(ctrl)
+
+	keyStore.EXPECT().LoadKeys(userName, keyStorePassword).Times(1).Return(client.NewKeyPair(), nil)
+
+	clt, err := client.NewClient(ctx, userName, state, stg, keyStore, keyStorePassword, qrProcessor)
+	req.NoError(err)
+	clt.SetAllowLegacyMessages(true)
+
+	senderKeyPair := client.NewKeyPair()
+	senderAddr := senderKeyPair.GetAddr()
+	newMessage := func(offset uint64, event fsm.Event, data interface{}) storage.Message {
+		dataBz, err := json.Marshal(data)
+		req.NoError(err)
+		// older clients signed the data only and didn't set a timestamp
+		return storage.Message{
+			ID:         uuid.New().String(),
+			DkgRoundID: dkgRoundID,
+			Offset:     offset,
+			Event:      string(event),
+			Data:       dataBz,
+			Signature:  ed25519.Sign(senderKeyPair.Priv, dataBz),
+			SenderAddr: senderAddr,
+		}
+	}
+
+	participants := []*requests.SignatureProposalParticipantsEntry{
+		{Username: senderAddr, PubKey: senderKeyPair.Pub, DkgPubKey: make([]byte, 128)},
+		{Username: userName, PubKey: client.NewKeyPair().Pub, DkgPubKey: make([]byte, 128)},
+	}
+	// a legacy message without a creation time can't be timed
+	req.Error(clt.ProcessMessage(newMessage(0, spf.EventInitProposal, requests.SignatureProposalParticipantsListRequest{
+		Participants:     participants,
+		SigningThreshold: 2,
+	})))
+
+	// lines written by older clients have no log time, they are timed by the creation time of their data
+	createdAt := time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)
+	initMessage := newMessage(0, spf.EventInitProposal, requests.SignatureProposalParticipantsListRequest{
+		Participants:     participants,
+		CreatedAt:        createdAt,
+		SigningThreshold: 2,
+	})
+	req.NoError(clt.ProcessMessage(initMessage))
+	roundClock, err := state.LoadRoundClock(dkgRoundID)
+	req.NoError(err)
+	req.True(createdAt.Equal(roundClock))
+
+	// the round clock doesn't go back
+	confirmation := newMessage(1, spf.EventConfirmSignatureProposal,
+		requests.SignatureProposalParticipantRequest{ParticipantId: 0, CreatedAt: createdAt.Add(-time.Hour)})
+	req.NoError(clt.ProcessMessage(confirmation))
+	roundClock, err = state.LoadRoundClock(dkgRoundID)
+	req.NoError(err)
+	req.True(createdAt.Equal(roundClock))
+}
+
 func TestClient_GetOperationsList(t *testing.T) {
 	var (
 		ctx  = context.Background()
@@ -120,7 +331,7 @@ func TestClient_GetOperationsList(t *testing.T) {
 
 	keyStore := clientMocks.NewMockKeyStore(ctrl)
 	testClientKeyPair := client.NewKeyPair()
-	keyStore.EXPECT().LoadKeys(userName, "").Times(1).Return(testClientKeyPair, nil)
+	keyStore.EXPECT().LoadKeys(userName, keyStorePassword).Times(1).Return(testClientKeyPair, nil)
 
 	state := clientMocks.NewMockState(ctrl)
 	stg := storageMocks.NewMockStorage(ctrl)
@@ -132,6 +343,7 @@ func TestClient_GetOperationsList(t *testing.T) {
 		state,
 		stg,
 		keyStore,
+		keyStorePassword,
 		qrProcessor,
 	)
 	req.NoError(err)
@@ -167,7 +379,7 @@ func TestClient_GetOperationQRPath(t *testing.T) {
 
 	keyStore := clientMocks.NewMockKeyStore(ctrl)
 	testClientKeyPair := client.NewKeyPair()
-	keyStore.EXPECT().LoadKeys(userName, "").Times(1).Return(testClientKeyPair, nil)
+	keyStore.EXPECT().LoadKeys(userName, keyStorePassword).Times(1).Return(testClientKeyPair, nil)
 
 	state := clientMocks.NewMockState(ctrl)
 	stg := storageMocks.NewMockStorage(ctrl)
@@ -179,6 +391,7 @@ func TestClient_GetOperationQRPath(t *testing.T) {
 		state,
 		stg,
 		keyStore,
+		keyStorePassword,
 		qrProcessor,
 	)
 	req.NoError(err)
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/fsm/fsm"
+	dpf "github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	rpf "github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
+	spf "github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
+	sipf "github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
+	"github.com/lidofinance/dc4bc/storage"
+)
+
+var (
+	ErrDuplicateMessage = errors.New("duplicate message")
+	ErrEquivocation     = errors.New("equivocation")
+)
+
+// untrackedEvents are not checked for duplicates: a timeout may be legitimately sent again after a restart
+// of the client, the FSM ignores all of them except the first one anyway. A round init proposal is not signed
+// by a known participant, so it can't be an evidence
+var untrackedEvents = map[fsm.Event]bool{
+	spf.EventInitProposal:                          true,
+	spf.EventSignatureProposalTimeout:              true,
+	dpf.EventDKGCommitsConfirmationTimeout:         true,
+	dpf.EventDKGDealsConfirmationTimeout:           true,
+	dpf.EventDKGResponsesConfirmationTimeout:       true,
+	dpf.EventDKGJustificationsConfirmationTimeout:  true,
+	dpf.EventDKGMasterKeyConfirmationTimeout:       true,
+	rpf.EventResharingProposalTimeout:              true,
+	rpf.EventResharingDealsConfirmationTimeout:     true,
+	rpf.EventResharingResponsesConfirmationTimeout: true,
+	rpf.EventResharingMasterKeyConfirmationTimeout: true,
+	sipf.EventSigningConfirmationsTimeout:          true,
+	sipf.EventSigningPartialSignsTimeout:           true,
+}
+
+// resharingEvents are sent once per resharing, a round can be reshared again after a resharing is done or rolled back
+var resharingEvents = map[fsm.Event]bool{
+	rpf.EventResharingProposalStart:                 true,
+	rpf.EventResharingProposalConfirm:               true,
+	rpf.EventResharingProposalDecline:               true,
+	rpf.EventResharingDealConfirmationReceived:      true,
+	rpf.EventResharingDealConfirmationError:         true,
+	rpf.EventResharingResponseConfirmationReceived:  true,
+	rpf.EventResharingResponseConfirmationError:     true,
+	rpf.EventResharingMasterKeyConfirmationReceived: true,
+	rpf.EventResharingMasterKeyConfirmationError:    true,
+}
+
+// seenMessageKey identifies a step of a DKG round where a participant sends exactly one message
+// of the event: deals are sent to every recipient, every signing session and every resharing has its own steps
+func seenMessageKey(message storage.Message, fsmReq interface{}, resharingGeneration int) (string, bool) {
+	if untrackedEvents[fsm.Event(message.Event)] {
+		return "", false
+	}
+	signingID, _ := types.SigningIDFromRequest(fsmReq)
+	if !resharingEvents[fsm.Event(message.Event)] {
+		resharingGeneration = 0
+	}
+	return fmt.Sprintf("%q %q %q %q %d", message.SenderAddr, message.Event, message.RecipientAddr, signingID,
+		resharingGeneration), true
+}
+
+func payloadHash(message storage.Message) []byte {
+	hash := sha256.Sum256(message.Data)
+	return hash[:]
+}
+
+// checkSeenMessage rejects a message if the sender has already sent a message for the same step.
+// A message with the same payload is a duplicate, a message with another payload is an equivocation,
+// both messages are saved as an evidence then. The same message read again from the same offset
+// (e.g. after the offset was reset by hand) is not a duplicate
+func (c *BaseClient) checkSeenMessage(key string, message storage.Message) error {
+	seen, err := c.state.GetSeenMessage(message.DkgRoundID, key)
+	if err != nil {
+		return fmt.Errorf("failed to GetSeenMessage: %w", err)
+	}
+	if seen == nil || seen.Message.Offset == message.Offset {
+		return nil
+	}
+
+	if bytes.Equal(seen.PayloadHash, payloadHash(message)) {
+		return fmt.Errorf("%w: %s from %s was already processed at offset %d",
+			ErrDuplicateMessage, message.Event, message.SenderAddr, seen.Message.Offset)
+	}
+
+	evidence := types.Evidence{
+		DKGRoundID: message.DkgRoundID,
+		Sender:     message.SenderAddr,
+		Event:      fsm.Event(message.Event),
+		First:      seen.Message,
+		Second:     message,
+		DetectedAt: time.Now(),
+	}
+	if err := c.state.SaveEvidence(evidence); err != nil {
+		return fmt.Errorf("failed to SaveEvidence: %w", err)
+	}
+	c.Logger.Log("Participant %s sent conflicting %s messages at offsets %d and %d",
+		message.SenderAddr, message.Event, seen.Message.Offset, message.Offset)
+
+	return fmt.Errorf("%w: %s from %s conflicts with the message at offset %d",
+		ErrEquivocation, message.Event, message.SenderAddr, seen.Message.Offset)
+}
+
+// saveSeenMessage remembers a message processed by the FSM
+func (c *BaseClient) saveSeenMessage(key string, message storage.Message) error {
+	return c.state.SaveSeenMessage(message.DkgRoundID, key, types.SeenMessage{
+		PayloadHash: payloadHash(message),
+		Message:     message,
+	})
+}
+
+// GetEvidence returns equivocation evidence collected in the given DKG round
+func (c *BaseClient) GetEvidence(dkgID string) ([]types.Evidence, error) {
+	return c.state.GetEvidence(dkgID)
+}
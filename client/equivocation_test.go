@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+
+	rpf "github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
+	spf "github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/types/requests"
+	"github.com/lidofinance/dc4bc/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeenMessageKey(t *testing.T) {
+	req := require.New(t)
+
+	confirmation := storage.Message{
+		SenderAddr: "sender",
+		Event:      string(rpf.EventResharingProposalConfirm),
+	}
+	fsmReq := requests.ResharingProposalConfirmationRequest{ParticipantId: 1}
+
+	// a resharing started again after a rollback has its own steps
+	first, tracked := seenMessageKey(confirmation, fsmReq, 1)
+	req.True(tracked)
+	second, tracked := seenMessageKey(confirmation, fsmReq, 2)
+	req.True(tracked)
+	req.NotEqual(first, second)
+
+	// a round is confirmed once whatever resharings follow
+	confirmation.Event = string(spf.EventConfirmSignatureProposal)
+	first, _ = seenMessageKey(confirmation, requests.SignatureProposalParticipantRequest{}, 1)
+	second, _ = seenMessageKey(confirmation, requests.SignatureProposalParticipantRequest{}, 2)
+	req.Equal(first, second)
+
+	_, tracked = seenMessageKey(storage.Message{Event: string(rpf.EventResharingDealsConfirmationTimeout)}, nil, 1)
+	req.False(tracked)
+}
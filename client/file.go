@@ -0,0 +1,87 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+
+	"github.com/corestario/kyber/pairing"
+	bls12381 "github.com/corestario/kyber/pairing/bls12381"
+	"github.com/corestario/kyber/sign/bls"
+
+	"github.com/lidofinance/dc4bc/client/types"
+)
+
+// keyPairSigner signs operation files with the hot node key, the airgapped machine shows the key to an operator
+type keyPairSigner struct {
+	keyPair *KeyPair
+}
+
+func (s *keyPairSigner) PubKey() ([]byte, error) {
+	return s.keyPair.Pub, nil
+}
+
+func (s *keyPairSigner) Sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(s.keyPair.Priv, msg), nil
+}
+
+// verifyAirgappedFileSignature checks a signature of an operation file made with the DKG key of the airgapped machine
+func verifyAirgappedFileSignature(pubKey, msg, signature []byte) error {
+	suite := bls12381.NewBLS12381Suite(nil).(pairing.Suite)
+	point := suite.G1().Point()
+	if err := point.UnmarshalBinary(pubKey); err != nil {
+		return fmt.Errorf("failed to unmarshal pub key: %w", err)
+	}
+	return bls.Verify(suite, point, msg, signature)
+}
+
+// GetOperationFile returns a content of the signed file with the operation to transfer it to the airgapped machine
+func (c *BaseClient) GetOperationFile(operationID string) ([]byte, error) {
+	operationJSON, err := c.getOperationJSON(operationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operation in JSON: %w", err)
+	}
+
+	return c.fileProcessor.EncodeFile(operationJSON)
+}
+
+// handleProcessedOperationFile handles a signed file with an operation processed by the airgapped machine
+func (c *BaseClient) handleProcessedOperationFile(fileData []byte) error {
+	operationJSON, signerPubKey, err := c.fileProcessor.DecodeFile(fileData)
+	if err != nil {
+		return fmt.Errorf("failed to decode operation file: %w", err)
+	}
+
+	var operation types.Operation
+	if err = json.Unmarshal(operationJSON, &operation); err != nil {
+		return fmt.Errorf("failed to unmarshal operation: %w", err)
+	}
+
+	if err = c.checkAirgappedPubKey(operation.DKGIdentifier, signerPubKey); err != nil {
+		return err
+	}
+
+	return c.handleProcessedOperation(operation)
+}
+
+// checkAirgappedPubKey checks that an operation file was signed by the airgapped machine of this participant.
+// The key is known from the DKG proposal or, for a new share holder, from the resharing proposal,
+// a file of a round where the key is unknown is refused
+func (c *BaseClient) checkAirgappedPubKey(dkgID string, pubKey []byte) error {
+	fsmInstance, err := c.getFSMInstance(dkgID)
+	if err != nil {
+		return fmt.Errorf("failed to get FSM instance: %w", err)
+	}
+
+	dkgPubKey, err := fsmInstance.GetDkgPubKeyByUsername(c.GetUsername())
+	if err != nil || len(dkgPubKey) == 0 {
+		return fmt.Errorf("DKG pub key of %s is unknown in round %s, the operation file signer can't be checked",
+			c.GetUsername(), dkgID)
+	}
+
+	if !bytes.Equal(dkgPubKey, pubKey) {
+		return fmt.Errorf("operation file is not signed by the airgapped machine of %s", c.GetUsername())
+	}
+	return nil
+}
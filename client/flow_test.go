@@ -169,7 +169,7 @@ func TestFullFlow(t *testing.T) {
 		}
 
 		keyPair := NewKeyPair()
-		if err := keyStore.PutKeys(userName, keyPair); err != nil {
+		if err := keyStore.PutKeys(userName, "very_strong_password", keyPair); err != nil {
 			t.Fatalf("Failed to PutKeys: %v\n", err)
 		}
 
@@ -184,11 +184,14 @@ func TestFullFlow(t *testing.T) {
 			state,
 			stg,
 			keyStore,
+			"very_strong_password",
 			qr.NewCameraProcessor(),
 		)
 		if err != nil {
 			t.Fatalf("node %d failed to init client: %v\n", nodeID, err)
 		}
+		// the test calls the API with plain HTTP requests
+		clt.SetHTTPServerConfig(HTTPServerConfig{NoAuth: true})
 		airgappedMachine.SetEncryptionKey([]byte("very_strong_password")) //just for testing
 		if err = airgappedMachine.InitKeys(); err != nil {
 			t.Errorf(err.Error())
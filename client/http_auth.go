@@ -0,0 +1,350 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role is a set of HTTP API endpoints a caller is allowed to use
+type Role string
+
+const (
+	// RoleReader can read operations, signatures and FSM states
+	RoleReader Role = "reader"
+	// RoleOperator can also start DKG rounds and signings, send messages and handle processed operations
+	RoleOperator Role = "operator"
+)
+
+func (r Role) allows(required Role) bool {
+	switch r {
+	case RoleOperator:
+		return true
+	case RoleReader:
+		return required == RoleReader
+	default:
+		return false
+	}
+}
+
+// httpMaxRequestSize bounds the body of an API request, an operation file of a batch signing is far smaller
+const httpMaxRequestSize = 32 << 20
+
+// HTTPServerConfig configures TLS, authentication and the audit log of the HTTP API
+type HTTPServerConfig struct {
+	// ListenAddr is the address the API is served on, bearer tokens are sent in the clear without TLS,
+	// so they are allowed without it on a loopback address only
+	ListenAddr  string
+	TLSCertFile string
+	TLSKeyFile  string
+	// ClientCAFile enables mTLS, client certificates signed by the CA are authenticated by their common names
+	ClientCAFile string
+	// Credentials grant roles to bearer tokens and client certificates
+	Credentials *HTTPCredentials
+	// AuditLogPath is a file which mutating calls are appended to, without it they are written to the client log
+	AuditLogPath string
+	// NoAuth serves the API without authentication, anyone who can reach it gets the operator role
+	NoAuth bool
+}
+
+func (cfg *HTTPServerConfig) Validate() error {
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return errors.New("both TLS certificate and key must be set")
+	}
+	if cfg.ClientCAFile != "" && cfg.TLSCertFile == "" {
+		return errors.New("client certificates can be verified only over TLS")
+	}
+	if cfg.NoAuth {
+		return nil
+	}
+	if cfg.Credentials == nil || (len(cfg.Credentials.Tokens) == 0 && len(cfg.Credentials.ClientCertificates) == 0) {
+		return errors.New("no credentials are configured, set tokens or client certificates or disable authentication explicitly")
+	}
+	if len(cfg.Credentials.ClientCertificates) > 0 && cfg.ClientCAFile == "" {
+		return errors.New("client certificates are configured without a client CA")
+	}
+	if len(cfg.Credentials.Tokens) > 0 && cfg.TLSCertFile == "" && cfg.ListenAddr != "" && !isLoopbackAddr(cfg.ListenAddr) {
+		return fmt.Errorf("bearer tokens are sent in the clear without TLS, serve the API on a loopback address "+
+			"or set a TLS certificate to listen on %s", cfg.ListenAddr)
+	}
+	return cfg.Credentials.Validate()
+}
+
+// isLoopbackAddr reports whether the listen address accepts local connections only,
+// an address without a host listens on all the interfaces
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (cfg *HTTPServerConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+	caBz, err := ioutil.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caBz) {
+		return nil, errors.New("failed to parse client CA file")
+	}
+	// clients without a certificate can still authenticate with a token
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	tlsConfig.ClientCAs = clientCAs
+	return tlsConfig, nil
+}
+
+// HTTPCredentials are the bearer tokens and the client certificates allowed to use the HTTP API
+type HTTPCredentials struct {
+	Tokens             []TokenCredential       `json:"tokens"`
+	ClientCertificates []CertificateCredential `json:"client_certificates"`
+}
+
+type TokenCredential struct {
+	// Name identifies the token holder in the audit log
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  Role   `json:"role"`
+}
+
+type CertificateCredential struct {
+	CommonName string `json:"common_name"`
+	Role       Role   `json:"role"`
+}
+
+// minTokenLength is the minimal length of a bearer token, shorter tokens are too easy to guess
+const minTokenLength = 16
+
+func (c *HTTPCredentials) Validate() error {
+	names := make(map[string]bool)
+	tokens := make(map[string]bool)
+	for _, token := range c.Tokens {
+		if token.Name == "" {
+			return errors.New("token name is empty")
+		}
+		if names[token.Name] {
+			return fmt.Errorf("duplicate token name %s", token.Name)
+		}
+		names[token.Name] = true
+		if len(token.Token) < minTokenLength {
+			return fmt.Errorf("token %s is shorter than %d characters", token.Name, minTokenLength)
+		}
+		if tokens[token.Token] {
+			return fmt.Errorf("token %s is used more than once", token.Name)
+		}
+		tokens[token.Token] = true
+		if token.Role != RoleReader && token.Role != RoleOperator {
+			return fmt.Errorf("unknown role %q of token %s", token.Role, token.Name)
+		}
+	}
+	for _, cert := range c.ClientCertificates {
+		if cert.CommonName == "" {
+			return errors.New("client certificate common name is empty")
+		}
+		if cert.Role != RoleReader && cert.Role != RoleOperator {
+			return fmt.Errorf("unknown role %q of client certificate %s", cert.Role, cert.CommonName)
+		}
+	}
+	return nil
+}
+
+// LoadHTTPCredentials reads the credentials of the HTTP API from a JSON file
+func LoadHTTPCredentials(path string) (*HTTPCredentials, error) {
+	credentialsBz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	var credentials HTTPCredentials
+	if err = json.Unmarshal(credentialsBz, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal credentials: %w", err)
+	}
+	if err = credentials.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid credentials: %w", err)
+	}
+	return &credentials, nil
+}
+
+// httpAuth authenticates the callers of the HTTP API, checks their roles and audits mutating calls
+type httpAuth struct {
+	noAuth bool
+	// tokens are keyed by their hashes, so a lookup doesn't leak the tokens through timing
+	tokens map[[sha256.Size]byte]TokenCredential
+	certs  map[string]Role
+
+	auditMu   sync.Mutex
+	auditFile *os.File
+	logger    *logger
+}
+
+func newHTTPAuth(cfg HTTPServerConfig, logger *logger) (*httpAuth, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	a := &httpAuth{
+		noAuth: cfg.NoAuth,
+		tokens: make(map[[sha256.Size]byte]TokenCredential),
+		certs:  make(map[string]Role),
+		logger: logger,
+	}
+	if cfg.Credentials != nil {
+		for _, token := range cfg.Credentials.Tokens {
+			a.tokens[sha256.Sum256([]byte(token.Token))] = token
+		}
+		for _, cert := range cfg.Credentials.ClientCertificates {
+			a.certs[cert.CommonName] = cert.Role
+		}
+	}
+	if cfg.AuditLogPath != "" {
+		auditFile, err := os.OpenFile(cfg.AuditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		a.auditFile = auditFile
+	}
+	return a, nil
+}
+
+func (a *httpAuth) Close() error {
+	if a.auditFile == nil {
+		return nil
+	}
+	return a.auditFile.Close()
+}
+
+// authenticate returns the name and the role of the caller, an unauthenticated caller has no role
+func (a *httpAuth) authenticate(r *http.Request) (string, Role) {
+	if a.noAuth {
+		return "anonymous", RoleOperator
+	}
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		if token, ok := a.tokens[sha256.Sum256([]byte(strings.TrimPrefix(header, "Bearer ")))]; ok {
+			return "token:" + token.Name, token.Role
+		}
+		return "", ""
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		commonName := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if role, ok := a.certs[commonName]; ok {
+			return "cert:" + commonName, role
+		}
+	}
+	return "", ""
+}
+
+// auditEntry is a line of the audit log
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	Caller     string    `json:"caller"`
+	Role       Role      `json:"role"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Query      string    `json:"query,omitempty"`
+	BodySHA256 string    `json:"body_sha256,omitempty"`
+	Status     int       `json:"status"`
+}
+
+func (a *httpAuth) audit(entry auditEntry) {
+	entryBz, err := json.Marshal(entry)
+	if err != nil {
+		a.logger.Log("failed to marshal audit entry: %v", err)
+		return
+	}
+
+	a.auditMu.Lock()
+	defer a.auditMu.Unlock()
+	if a.auditFile == nil {
+		a.logger.Log("audit: %s", entryBz)
+		return
+	}
+	if _, err = a.auditFile.Write(append(entryBz, '\n')); err != nil {
+		a.logger.Log("failed to write audit entry %s: %v", entryBz, err)
+	}
+}
+
+// statusRecorder keeps the status code of a response for the audit log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// wrap allows only callers with the required role to call the handler, calls of operator endpoints are audited
+// whether they're allowed or not. The body is read only after the caller is authenticated, and its size is bounded
+func (a *httpAuth) wrap(required Role, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, role := a.authenticate(r)
+
+		var entry *auditEntry
+		if required == RoleOperator {
+			entry = &auditEntry{
+				Time:       time.Now().UTC(),
+				Caller:     caller,
+				Role:       role,
+				RemoteAddr: r.RemoteAddr,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Query:      r.URL.RawQuery,
+			}
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				entry.Status = recorder.status
+				a.audit(*entry)
+			}()
+			w = recorder
+		}
+
+		switch {
+		case role == "":
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			errorResponse(w, http.StatusUnauthorized, "authentication is required")
+			return
+		case !role.allows(required):
+			errorResponse(w, http.StatusForbidden, fmt.Sprintf("%s role is required", required))
+			return
+		}
+
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, httpMaxRequestSize)
+			if entry != nil {
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					errorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to read body: %v", err))
+					return
+				}
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+				if len(body) > 0 {
+					bodyHash := sha256.Sum256(body)
+					entry.BodySHA256 = hex.EncodeToString(bodyHash[:])
+				}
+			}
+		}
+		handler(w, r)
+	}
+}
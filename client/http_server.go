@@ -12,7 +12,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/eth2"
 	"github.com/lidofinance/dc4bc/fsm/fsm"
+	rpf "github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
 	spf "github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
 	sif "github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/types/requests"
@@ -60,33 +62,58 @@ func successResponse(w http.ResponseWriter, response interface{}) {
 }
 
 func (c *BaseClient) StartHTTPServer(listenAddr string) error {
+	c.httpConfig.ListenAddr = listenAddr
+	auth, err := newHTTPAuth(c.httpConfig, c.Logger)
+	if err != nil {
+		return fmt.Errorf("failed to init HTTP authentication: %w", err)
+	}
+	defer auth.Close()
+
 	mux := http.NewServeMux()
+	// readers can only read the state, the endpoints which change it require the operator role
+	handle := func(path string, role Role, handler http.HandlerFunc) {
+		mux.HandleFunc(path, auth.wrap(role, handler))
+	}
 
-	mux.HandleFunc("/getUsername", c.getUsernameHandler)
-	mux.HandleFunc("/getPubKey", c.getPubkeyHandler)
+	handle("/getUsername", RoleReader, c.getUsernameHandler)
+	handle("/getPubKey", RoleReader, c.getPubkeyHandler)
 
-	mux.HandleFunc("/sendMessage", c.sendMessageHandler)
-	mux.HandleFunc("/getOperations", c.getOperationsHandler)
-	mux.HandleFunc("/getOperationQRPath", c.getOperationQRPathHandler)
+	handle("/sendMessage", RoleOperator, c.sendMessageHandler)
+	handle("/getOperations", RoleReader, c.getOperationsHandler)
+	handle("/getOperationQRPath", RoleReader, c.getOperationQRPathHandler)
 
-	mux.HandleFunc("/getSignatures", c.getSignaturesHandler)
-	mux.HandleFunc("/getSignatureByID", c.getSignatureByIDHandler)
+	handle("/getSignatures", RoleReader, c.getSignaturesHandler)
+	handle("/getSignatureByID", RoleReader, c.getSignatureByIDHandler)
+	handle("/getEvidence", RoleReader, c.getEvidenceHandler)
 
-	mux.HandleFunc("/getOperationQR", c.getOperationQRToBodyHandler)
-	mux.HandleFunc("/handleProcessedOperationJSON", c.handleJSONOperationHandler)
-	mux.HandleFunc("/getOperation", c.getOperationHandler)
+	handle("/getOperationQR", RoleReader, c.getOperationQRToBodyHandler)
+	handle("/handleProcessedOperationJSON", RoleOperator, c.handleJSONOperationHandler)
+	handle("/getOperation", RoleReader, c.getOperationHandler)
+	handle("/getOperationFile", RoleReader, c.getOperationFileHandler)
+	handle("/handleProcessedOperationFile", RoleOperator, c.handleOperationFileHandler)
 
-	mux.HandleFunc("/startDKG", c.startDKGHandler)
-	mux.HandleFunc("/proposeSignMessage", c.proposeSignDataHandler)
+	handle("/startDKG", RoleOperator, c.startDKGHandler)
+	handle("/proposeSignMessage", RoleOperator, c.proposeSignDataHandler)
+	handle("/proposeSignEth2Operation", RoleOperator, c.proposeSignEth2OperationHandler)
+	handle("/proposeSignBatch", RoleOperator, c.proposeSignBatchHandler)
+	handle("/startResharing", RoleOperator, c.startResharingHandler)
 
-	mux.HandleFunc("/saveOffset", c.saveOffsetHandler)
-	mux.HandleFunc("/getOffset", c.getOffsetHandler)
+	handle("/saveOffset", RoleOperator, c.saveOffsetHandler)
+	handle("/getOffset", RoleReader, c.getOffsetHandler)
 
-	mux.HandleFunc("/getFSMDump", c.getFSMDumpHandler)
-	mux.HandleFunc("/getFSMList", c.getFSMList)
+	handle("/getFSMDump", RoleReader, c.getFSMDumpHandler)
+	handle("/getFSMList", RoleReader, c.getFSMList)
 
-	c.Logger.Log("HTTP server started on address: %s", listenAddr)
-	return http.ListenAndServe(listenAddr, mux)
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	if c.httpConfig.TLSCertFile == "" {
+		c.Logger.Log("HTTP server started on address: %s", listenAddr)
+		return server.ListenAndServe()
+	}
+	if server.TLSConfig, err = c.httpConfig.tlsConfig(); err != nil {
+		return fmt.Errorf("failed to init TLS config: %w", err)
+	}
+	c.Logger.Log("HTTPS server started on address: %s", listenAddr)
+	return server.ListenAndServeTLS(c.httpConfig.TLSCertFile, c.httpConfig.TLSKeyFile)
 }
 
 func (c *BaseClient) getFSMDumpHandler(w http.ResponseWriter, r *http.Request) {
@@ -178,6 +205,10 @@ func (c *BaseClient) saveOffsetHandler(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to save offset: %v", err))
 		return
 	}
+	select {
+	case c.offsetReset <- struct{}{}:
+	default:
+	}
 	successResponse(w, "ok")
 }
 
@@ -252,6 +283,21 @@ func (c *BaseClient) getSignatureByIDHandler(w http.ResponseWriter, r *http.Requ
 	successResponse(w, signature)
 }
 
+func (c *BaseClient) getEvidenceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, http.StatusBadRequest, "Wrong HTTP method")
+		return
+	}
+
+	evidence, err := c.GetEvidence(r.URL.Query().Get("dkgID"))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to get evidence: %v", err))
+		return
+	}
+
+	successResponse(w, evidence)
+}
+
 func (c *BaseClient) getOperationQRPathHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		errorResponse(w, http.StatusBadRequest, "Wrong HTTP method")
@@ -284,6 +330,22 @@ func (c *BaseClient) getOperationHandler(w http.ResponseWriter, r *http.Request)
 	successResponse(w, operation)
 }
 
+func (c *BaseClient) getOperationFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		errorResponse(w, http.StatusBadRequest, "Wrong HTTP method")
+		return
+	}
+	operationID := r.URL.Query().Get("operationID")
+
+	fileData, err := c.GetOperationFile(operationID)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to get operation file: %v", err))
+		return
+	}
+
+	successResponse(w, fileData)
+}
+
 func (c *BaseClient) getOperationQRToBodyHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		errorResponse(w, http.StatusBadRequest, "Wrong HTTP method")
@@ -351,7 +413,143 @@ func (c *BaseClient) proposeSignDataHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	fsmInstance, err := c.getFSMInstance(hex.EncodeToString(req["dkgID"]))
+	err = c.proposeSigning(hex.EncodeToString(req["dkgID"]), requests.SigningProposalStartRequest{SrcPayloads: [][]byte{req["data"]}})
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	successResponse(w, "ok")
+}
+
+func (c *BaseClient) proposeSignEth2OperationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorResponse(w, http.StatusBadRequest, "Wrong HTTP method")
+		return
+	}
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to read body: %v", err))
+		return
+	}
+	defer r.Body.Close()
+
+	var req types.Eth2SigningProposal
+	if err = json.Unmarshal(reqBody, &req); err != nil {
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to umarshal request: %v", err))
+		return
+	}
+
+	signingRoot, err := req.SigningRequest.SigningRoot()
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to compute signing root: %v", err))
+		return
+	}
+	err = c.proposeSigning(req.DKGID, requests.SigningProposalStartRequest{
+		SrcPayloads:  [][]byte{signingRoot[:]},
+		Eth2Requests: []*eth2.SigningRequest{&req.SigningRequest},
+	})
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	successResponse(w, "ok")
+}
+
+func (c *BaseClient) proposeSignBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorResponse(w, http.StatusBadRequest, "Wrong HTTP method")
+		return
+	}
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to read body: %v", err))
+		return
+	}
+	defer r.Body.Close()
+
+	var req types.BatchSigningProposal
+	if err = json.Unmarshal(reqBody, &req); err != nil {
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to umarshal request: %v", err))
+		return
+	}
+
+	messageData := requests.SigningProposalStartRequest{SrcPayloads: req.SrcPayloads}
+	// payloads of Eth2 operations are their signing roots
+	if len(req.Eth2Requests) > 0 {
+		if len(req.SrcPayloads) > 0 {
+			errorResponse(w, http.StatusBadRequest, "payloads are computed from Eth2 operations and can't be set")
+			return
+		}
+		for i, eth2Request := range req.Eth2Requests {
+			signingRoot, err := eth2Request.SigningRoot()
+			if err != nil {
+				errorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to compute signing root of operation #%d: %v", i, err))
+				return
+			}
+			messageData.SrcPayloads = append(messageData.SrcPayloads, signingRoot[:])
+			messageData.Eth2Requests = append(messageData.Eth2Requests, eth2Request)
+		}
+	}
+	if len(messageData.SrcPayloads) == 0 {
+		errorResponse(w, http.StatusBadRequest, "batch is empty")
+		return
+	}
+
+	if err = c.proposeSigning(req.DKGID, messageData); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	successResponse(w, "ok")
+}
+
+// proposeSigning sends a message to start signing of the data from the request in the DKG round
+func (c *BaseClient) proposeSigning(dkgID string, messageDataSign requests.SigningProposalStartRequest) error {
+	fsmInstance, err := c.getFSMInstance(dkgID)
+	if err != nil {
+		return fmt.Errorf("failed to get FSM instance: %w", err)
+	}
+	participantID, err := fsmInstance.GetIDByUsername(c.GetUsername())
+	if err != nil {
+		return fmt.Errorf("failed to get participantID: %w", err)
+	}
+
+	messageDataSign.SigningID = uuid.New().String()
+	messageDataSign.ParticipantId = participantID
+	messageDataSign.CreatedAt = time.Now()
+	messageDataSignBz, err := json.Marshal(messageDataSign)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SigningProposalStartRequest: %w", err)
+	}
+
+	message, err := c.buildMessage(dkgID, sif.EventSigningStart, messageDataSignBz)
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+	if err = c.SendMessage(*message); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+func (c *BaseClient) startResharingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorResponse(w, http.StatusBadRequest, "Wrong HTTP method")
+		return
+	}
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to read body: %v", err))
+		return
+	}
+	defer r.Body.Close()
+
+	var req types.ResharingProposal
+	if err = json.Unmarshal(reqBody, &req); err != nil {
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to umarshal request: %v", err))
+		return
+	}
+
+	fsmInstance, err := c.getFSMInstance(req.DKGID)
 	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to get FSM instance: %v", err))
 		return
@@ -362,19 +560,16 @@ func (c *BaseClient) proposeSignDataHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	messageDataSign := requests.SigningProposalStartRequest{
-		SigningID:     uuid.New().String(),
-		ParticipantId: participantID,
-		SrcPayload:    req["data"],
-		CreatedAt:     time.Now(),
-	}
-	messageDataSignBz, err := json.Marshal(messageDataSign)
+	messageData := req.ResharingProposalStartRequest
+	messageData.ParticipantId = participantID
+	messageData.CreatedAt = time.Now()
+	messageDataBz, err := json.Marshal(messageData)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to marshal SigningProposalStartRequest: %v", err))
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to marshal ResharingProposalStartRequest: %v", err))
 		return
 	}
 
-	message, err := c.buildMessage(hex.EncodeToString(req["dkgID"]), sif.EventSigningStart, messageDataSignBz)
+	message, err := c.buildMessage(req.DKGID, rpf.EventResharingProposalStart, messageDataBz)
 	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to build message: %v", err))
 		return
@@ -412,6 +607,26 @@ func (c *BaseClient) handleJSONOperationHandler(w http.ResponseWriter, r *http.R
 	successResponse(w, "ok")
 }
 
+func (c *BaseClient) handleOperationFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		errorResponse(w, http.StatusBadRequest, "Wrong HTTP method")
+		return
+	}
+	fileData, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to read body: %v", err))
+		return
+	}
+	defer r.Body.Close()
+
+	if err = c.handleProcessedOperationFile(fileData); err != nil {
+		errorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to handle processed operation file: %v", err))
+		return
+	}
+
+	successResponse(w, "ok")
+}
+
 func (c *BaseClient) buildMessage(dkgRoundID string, event fsm.Event, data []byte) (*storage.Message, error) {
 	message := storage.Message{
 		ID:         uuid.New().String(),
@@ -419,6 +634,8 @@ func (c *BaseClient) buildMessage(dkgRoundID string, event fsm.Event, data []byt
 		Event:      string(event),
 		Data:       data,
 		SenderAddr: c.GetUsername(),
+		Timestamp:  time.Now().UTC(),
+		Version:    storage.CurrentMessageVersion,
 	}
 	signature, err := c.signMessage(message.Bytes())
 	if err != nil {
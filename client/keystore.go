@@ -1,29 +1,56 @@
 package client
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/syndtr/goleveldb/leveldb"
+	"golang.org/x/crypto/scrypt"
 )
 
 const (
 	secretsKey = "secrets"
+
+	keyStoreSaltSize = 32
+
+	// scrypt parameters, the same as the airgapped machine uses for its keys
+	scryptN      = 1 << 16
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
 )
 
+var ErrWrongPassword = errors.New("wrong keystore password")
+
 type KeyStore interface {
-	PutKeys(username string, keyPair *KeyPair) error
+	PutKeys(username, password string, keyPair *KeyPair) error
 	LoadKeys(userName, password string) (*KeyPair, error)
+	ChangePassword(userName, oldPassword, newPassword string) error
 }
 
-// LevelDBKeyStore is a temporary solution for keeping hot node keys.
-// The target state is an encrypted storage with password authentication.
+// LevelDBKeyStore keeps hot node keys in LevelDB. A private key is sealed with AES-GCM
+// under a key derived from the user's password with scrypt, a public key is kept as is
 type LevelDBKeyStore struct {
 	keystoreDb *leveldb.DB
 }
 
+// encryptedKeyPair is a keystore entry. Entries written by the older unencrypted keystore
+// have Priv set instead of Salt and EncryptedPriv, they can be encrypted with ChangePassword
+type encryptedKeyPair struct {
+	Pub           ed25519.PublicKey
+	Priv          ed25519.PrivateKey `json:",omitempty"`
+	Salt          []byte             `json:",omitempty"`
+	EncryptedPriv []byte             `json:",omitempty"`
+}
+
 func NewLevelDBKeyStore(username, keystorePath string) (KeyStore, error) {
 	db, err := leveldb.OpenFile(keystorePath, nil)
 	if err != nil {
@@ -35,57 +62,103 @@ func NewLevelDBKeyStore(username, keystorePath string) (KeyStore, error) {
 	}
 
 	if _, err := keystore.keystoreDb.Get([]byte(secretsKey), nil); err != nil {
-		if err := keystore.initJsonKey(secretsKey, map[string]*KeyPair{}); err != nil {
-			return nil, fmt.Errorf("failed to init %s storage: %w", operationsKey, err)
+		if err := keystore.initJsonKey(secretsKey, map[string]*encryptedKeyPair{}); err != nil {
+			return nil, fmt.Errorf("failed to init %s storage: %w", secretsKey, err)
 		}
 	}
 
 	return keystore, nil
 }
 
-func (s *LevelDBKeyStore) PutKeys(username string, keyPair *KeyPair) error {
-	bz, err := s.keystoreDb.Get([]byte(secretsKey), nil)
+func (s *LevelDBKeyStore) PutKeys(username, password string, keyPair *KeyPair) error {
+	keyPairs, err := s.loadEntries()
 	if err != nil {
-		return fmt.Errorf("failed to read keystore: %w", err)
+		return err
 	}
 
-	var keyPairs = map[string]*KeyPair{}
-	if err := json.Unmarshal(bz, &keyPairs); err != nil {
-		return fmt.Errorf("failed to unmarshak key pairs: %w", err)
+	entry, err := sealKeyPair(username, password, keyPair)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt key pair: %w", err)
 	}
+	keyPairs[username] = entry
 
-	keyPairs[username] = keyPair
+	return s.saveEntries(keyPairs)
+}
 
-	keyPairsBz, err := json.Marshal(keyPairs)
+func (s *LevelDBKeyStore) LoadKeys(userName, password string) (*KeyPair, error) {
+	keyPairs, err := s.loadEntries()
 	if err != nil {
-		return fmt.Errorf("failed to marshal key pair: %w", err)
+		return nil, err
 	}
 
-	err = s.keystoreDb.Put([]byte(secretsKey), keyPairsBz, nil)
+	entry, ok := keyPairs[userName]
+	if !ok {
+		return nil, fmt.Errorf("no key pair found for user %s", userName)
+	}
+	if entry.EncryptedPriv == nil {
+		return nil, fmt.Errorf("key pair for user %s is not encrypted, set a password for it first", userName)
+	}
+
+	return openKeyPair(userName, password, entry)
+}
+
+// ChangePassword re-encrypts the user's key pair with a key derived from the new password
+// and a fresh salt. An unencrypted entry is accepted with an empty old password
+func (s *LevelDBKeyStore) ChangePassword(userName, oldPassword, newPassword string) error {
+	keyPairs, err := s.loadEntries()
 	if err != nil {
-		return fmt.Errorf("failed to put key pairs: %w", err)
+		return err
 	}
 
-	return nil
+	entry, ok := keyPairs[userName]
+	if !ok {
+		return fmt.Errorf("no key pair found for user %s", userName)
+	}
+
+	var keyPair *KeyPair
+	if entry.EncryptedPriv == nil {
+		if oldPassword != "" {
+			return ErrWrongPassword
+		}
+		keyPair = &KeyPair{Pub: entry.Pub, Priv: entry.Priv}
+	} else {
+		if keyPair, err = openKeyPair(userName, oldPassword, entry); err != nil {
+			return err
+		}
+	}
+
+	if keyPairs[userName], err = sealKeyPair(userName, newPassword, keyPair); err != nil {
+		return fmt.Errorf("failed to encrypt key pair: %w", err)
+	}
+
+	return s.saveEntries(keyPairs)
 }
 
-func (s *LevelDBKeyStore) LoadKeys(userName, password string) (*KeyPair, error) {
+func (s *LevelDBKeyStore) loadEntries() (map[string]*encryptedKeyPair, error) {
 	bz, err := s.keystoreDb.Get([]byte(secretsKey), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read keystore: %w", err)
 	}
 
-	var keyPairs = map[string]*KeyPair{}
+	var keyPairs = map[string]*encryptedKeyPair{}
 	if err := json.Unmarshal(bz, &keyPairs); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal key pairs: %w", err)
 	}
 
-	keyPair, ok := keyPairs[userName]
-	if !ok {
-		return nil, fmt.Errorf("no key pair found for user %s", userName)
+	return keyPairs, nil
+}
+
+func (s *LevelDBKeyStore) saveEntries(keyPairs map[string]*encryptedKeyPair) error {
+	keyPairsBz, err := json.Marshal(keyPairs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key pairs: %w", err)
 	}
 
-	return keyPair, nil
+	if err = s.keystoreDb.Put([]byte(secretsKey), keyPairsBz, nil); err != nil {
+		return fmt.Errorf("failed to put key pairs: %w", err)
+	}
+
+	return nil
 }
 
 func (s *LevelDBKeyStore) initJsonKey(key string, data interface{}) error {
@@ -103,6 +176,74 @@ func (s *LevelDBKeyStore) initJsonKey(key string, data interface{}) error {
 	return nil
 }
 
+// keyPairAD binds a sealed private key to its owner and public key,
+// so entries cannot be swapped between users
+func keyPairAD(userName string, pub ed25519.PublicKey) []byte {
+	return append([]byte(userName+":"), pub...)
+}
+
+func newKeyStoreAEAD(password string, salt []byte) (cipher.AEAD, error) {
+	derivedKey, err := scrypt.Key([]byte(password), salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	c, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(c)
+}
+
+func sealKeyPair(userName, password string, keyPair *KeyPair) (*encryptedKeyPair, error) {
+	salt := make([]byte, keyStoreSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newKeyStoreAEAD(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return &encryptedKeyPair{
+		Pub:           keyPair.Pub,
+		Salt:          salt,
+		EncryptedPriv: gcm.Seal(nonce, nonce, keyPair.Priv, keyPairAD(userName, keyPair.Pub)),
+	}, nil
+}
+
+func openKeyPair(userName, password string, entry *encryptedKeyPair) (*KeyPair, error) {
+	gcm, err := newKeyStoreAEAD(password, entry.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(entry.EncryptedPriv) < nonceSize {
+		return nil, fmt.Errorf("invalid encrypted key length")
+	}
+
+	nonce, ciphertext := entry.EncryptedPriv[:nonceSize], entry.EncryptedPriv[nonceSize:]
+	priv, err := gcm.Open(nil, nonce, ciphertext, keyPairAD(userName, entry.Pub))
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+
+	keyPair := &KeyPair{Pub: entry.Pub, Priv: priv}
+	if len(priv) != ed25519.PrivateKeySize || !bytes.Equal(keyPair.Priv.Public().(ed25519.PublicKey), keyPair.Pub) {
+		return nil, fmt.Errorf("private key does not match public key for user %s", userName)
+	}
+
+	return keyPair, nil
+}
+
 type KeyPair struct {
 	Pub  ed25519.PublicKey
 	Priv ed25519.PrivateKey
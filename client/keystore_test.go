@@ -0,0 +1,57 @@
+package client_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/lidofinance/dc4bc/client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelDBKeyStore_PutLoadKeys(t *testing.T) {
+	var (
+		req    = require.New(t)
+		dbPath = "/tmp/dc4bc_test_KeyStore_PutLoadKeys"
+	)
+	defer os.RemoveAll(dbPath)
+
+	keyStore, err := client.NewLevelDBKeyStore("user", dbPath)
+	req.NoError(err)
+
+	keyPair := client.NewKeyPair()
+	req.NoError(keyStore.PutKeys("user", "password", keyPair))
+
+	loadedKeyPair, err := keyStore.LoadKeys("user", "password")
+	req.NoError(err)
+	req.Equal(keyPair, loadedKeyPair)
+
+	_, err = keyStore.LoadKeys("user", "wrong_password")
+	req.Equal(client.ErrWrongPassword, err)
+
+	_, err = keyStore.LoadKeys("another_user", "password")
+	req.Error(err)
+}
+
+func TestLevelDBKeyStore_ChangePassword(t *testing.T) {
+	var (
+		req    = require.New(t)
+		dbPath = "/tmp/dc4bc_test_KeyStore_ChangePassword"
+	)
+	defer os.RemoveAll(dbPath)
+
+	keyStore, err := client.NewLevelDBKeyStore("user", dbPath)
+	req.NoError(err)
+
+	keyPair := client.NewKeyPair()
+	req.NoError(keyStore.PutKeys("user", "old_password", keyPair))
+
+	req.Equal(client.ErrWrongPassword, keyStore.ChangePassword("user", "wrong_password", "new_password"))
+	req.NoError(keyStore.ChangePassword("user", "old_password", "new_password"))
+
+	_, err = keyStore.LoadKeys("user", "old_password")
+	req.Equal(client.ErrWrongPassword, err)
+
+	loadedKeyPair, err := keyStore.LoadKeys("user", "new_password")
+	req.NoError(err)
+	req.Equal(keyPair, loadedKeyPair)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/lidofinance/dc4bc/client/types"
 
@@ -19,6 +20,9 @@ const (
 	operationsKey       = "operations"
 	fsmStateKey         = "fsm_state"
 	signaturesKeyPrefix = "signatures"
+	seenKeyPrefix       = "seen"
+	evidenceKeyPrefix   = "evidence"
+	roundClockKeyPrefix = "round_clock"
 )
 
 // State is the client's state (it keeps the offset, the FSM state and
@@ -39,6 +43,15 @@ type State interface {
 	SaveSignature(signature types.ReconstructedSignature) error
 	GetSignatureByID(dkgID, signatureID string) ([]types.ReconstructedSignature, error)
 	GetSignatures(dkgID string) (map[string][]types.ReconstructedSignature, error)
+
+	GetSeenMessage(dkgID, key string) (*types.SeenMessage, error)
+	SaveSeenMessage(dkgID, key string, message types.SeenMessage) error
+
+	SaveEvidence(evidence types.Evidence) error
+	GetEvidence(dkgID string) ([]types.Evidence, error)
+
+	SaveRoundClock(dkgID string, clock time.Time) error
+	LoadRoundClock(dkgID string) (time.Time, error)
 }
 
 type LevelDBState struct {
@@ -353,3 +366,135 @@ func (s *LevelDBState) SaveSignature(signature types.ReconstructedSignature) err
 
 	return nil
 }
+
+// makeSeenKey returns a key of a seen message, every message is kept under its own key with the round prefix,
+// so saving a message doesn't rewrite the messages seen before
+func makeSeenKey(dkgID, key string) []byte {
+	return []byte(fmt.Sprintf("%s_%s_%s", seenKeyPrefix, dkgID, key))
+}
+
+// GetSeenMessage returns a message processed in the DKG round under the given key, nil if there is no such message
+func (s *LevelDBState) GetSeenMessage(dkgID, key string) (*types.SeenMessage, error) {
+	bz, err := s.stateDb.Get(makeSeenKey(dkgID, key), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get seen message for dkgID %s: %w", dkgID, err)
+	}
+
+	var message types.SeenMessage
+	if err := json.Unmarshal(bz, &message); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal seen message: %w", err)
+	}
+
+	return &message, nil
+}
+
+// SaveSeenMessage saves a processed message of the DKG round under the given key
+func (s *LevelDBState) SaveSeenMessage(dkgID, key string, message types.SeenMessage) error {
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal seen message: %w", err)
+	}
+
+	if err := s.stateDb.Put(makeSeenKey(dkgID, key), messageJSON, nil); err != nil {
+		return fmt.Errorf("failed to save seen message: %w", err)
+	}
+
+	return nil
+}
+
+func makeEvidenceKey(dkgID string) []byte {
+	return []byte(fmt.Sprintf("%s_%s", evidenceKeyPrefix, dkgID))
+}
+
+func (s *LevelDBState) getEvidence(dkgID string) ([]types.Evidence, error) {
+	bz, err := s.stateDb.Get(makeEvidenceKey(dkgID), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get evidence for dkgID %s: %w", dkgID, err)
+	}
+
+	var evidence []types.Evidence
+	if err := json.Unmarshal(bz, &evidence); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal evidence: %w", err)
+	}
+
+	return evidence, nil
+}
+
+// GetEvidence returns all equivocation evidence collected in the DKG round
+func (s *LevelDBState) GetEvidence(dkgID string) ([]types.Evidence, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.getEvidence(dkgID)
+}
+
+// SaveEvidence saves an equivocation evidence, an evidence for the same pair of messages is saved once
+func (s *LevelDBState) SaveEvidence(evidence types.Evidence) error {
+	s.Lock()
+	defer s.Unlock()
+
+	evidenceList, err := s.getEvidence(evidence.DKGRoundID)
+	if err != nil {
+		return fmt.Errorf("failed to getEvidence: %w", err)
+	}
+
+	for _, e := range evidenceList {
+		if e.First.Offset == evidence.First.Offset && e.Second.Offset == evidence.Second.Offset {
+			return nil
+		}
+	}
+	evidenceList = append(evidenceList, evidence)
+
+	evidenceJSON, err := json.Marshal(evidenceList)
+	if err != nil {
+		return fmt.Errorf("failed to marshal evidence: %w", err)
+	}
+
+	if err := s.stateDb.Put(makeEvidenceKey(evidence.DKGRoundID), evidenceJSON, nil); err != nil {
+		return fmt.Errorf("failed to save evidence: %w", err)
+	}
+
+	return nil
+}
+
+func makeRoundClockKey(dkgID string) []byte {
+	return []byte(fmt.Sprintf("%s_%s", roundClockKeyPrefix, dkgID))
+}
+
+// SaveRoundClock saves the time of the last FSM transition of the DKG round
+func (s *LevelDBState) SaveRoundClock(dkgID string, clock time.Time) error {
+	clockBz, err := clock.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to marshal round clock: %w", err)
+	}
+
+	if err := s.stateDb.Put(makeRoundClockKey(dkgID), clockBz, nil); err != nil {
+		return fmt.Errorf("failed to save round clock: %w", err)
+	}
+
+	return nil
+}
+
+// LoadRoundClock returns the time of the last FSM transition of the DKG round, zero time if there was none
+func (s *LevelDBState) LoadRoundClock(dkgID string) (time.Time, error) {
+	var clock time.Time
+	bz, err := s.stateDb.Get(makeRoundClockKey(dkgID), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return clock, nil
+		}
+		return clock, fmt.Errorf("failed to get round clock for dkgID %s: %w", dkgID, err)
+	}
+
+	if err := clock.UnmarshalBinary(bz); err != nil {
+		return clock, fmt.Errorf("failed to unmarshal round clock: %w", err)
+	}
+
+	return clock, nil
+}
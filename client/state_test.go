@@ -8,6 +8,7 @@ import (
 	"github.com/lidofinance/dc4bc/client/types"
 
 	"github.com/lidofinance/dc4bc/client"
+	"github.com/lidofinance/dc4bc/storage"
 	"github.com/stretchr/testify/require"
 )
 
@@ -30,6 +31,28 @@ func TestLevelDBState_SaveOffset(t *testing.T) {
 	req.Equal(offset, loadedOffset)
 }
 
+func TestLevelDBState_RoundClock(t *testing.T) {
+	var (
+		req    = require.New(t)
+		dbPath = "/tmp/dc4bc_test_RoundClock"
+	)
+	defer os.RemoveAll(dbPath)
+
+	stg, err := client.NewLevelDBState(dbPath)
+	req.NoError(err)
+
+	clock, err := stg.LoadRoundClock("dkg_round_id")
+	req.NoError(err)
+	req.True(clock.IsZero())
+
+	now := time.Now().UTC()
+	req.NoError(stg.SaveRoundClock("dkg_round_id", now))
+
+	clock, err = stg.LoadRoundClock("dkg_round_id")
+	req.NoError(err)
+	req.True(now.Equal(clock))
+}
+
 func TestLevelDBState_PutOperation(t *testing.T) {
 	var (
 		req    = require.New(t)
@@ -115,3 +138,44 @@ func TestLevelDBState_DeleteOperation(t *testing.T) {
 	_, err = stg.GetOperationByID(operation.ID)
 	req.Error(err)
 }
+
+func TestLevelDBState_SeenMessagesAndEvidence(t *testing.T) {
+	var (
+		req    = require.New(t)
+		dbPath = "/tmp/dc4bc_test_SeenMessages"
+		dkgID  = "dkg_id"
+	)
+	defer os.RemoveAll(dbPath)
+
+	stg, err := client.NewLevelDBState(dbPath)
+	req.NoError(err)
+
+	seen, err := stg.GetSeenMessage(dkgID, "key")
+	req.NoError(err)
+	req.Nil(seen)
+
+	message := storage.Message{ID: "message_1", DkgRoundID: dkgID, Offset: 1, Data: []byte("data")}
+	err = stg.SaveSeenMessage(dkgID, "key", types.SeenMessage{PayloadHash: []byte("hash"), Message: message})
+	req.NoError(err)
+
+	seen, err = stg.GetSeenMessage(dkgID, "key")
+	req.NoError(err)
+	req.Equal([]byte("hash"), seen.PayloadHash)
+	req.Equal(message.ID, seen.Message.ID)
+
+	seen, err = stg.GetSeenMessage("another_dkg_id", "key")
+	req.NoError(err)
+	req.Nil(seen)
+
+	second := message
+	second.ID, second.Offset = "message_2", 2
+	evidence := types.Evidence{DKGRoundID: dkgID, First: message, Second: second}
+	req.NoError(stg.SaveEvidence(evidence))
+	// the same evidence is saved once
+	req.NoError(stg.SaveEvidence(evidence))
+
+	evidenceList, err := stg.GetEvidence(dkgID)
+	req.NoError(err)
+	req.Len(evidenceList, 1)
+	req.Equal(second.ID, evidenceList[0].Second.ID)
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lidofinance/dc4bc/fsm/state_machines"
+	"github.com/lidofinance/dc4bc/fsm/types/requests"
+)
+
+// sendTimeouts broadcasts a timeout message for every round stage and signing session which deadline has passed.
+// Rounds are not cancelled locally: every client switches to the cancelled state when it reads the first
+// timeout message from the append-only log, so all of them are cancelled at the same offset
+func (c *BaseClient) sendTimeouts(now time.Time) error {
+	fsmInstances, err := c.state.GetAllFSM()
+	if err != nil {
+		return fmt.Errorf("failed to get all FSM instances: %w", err)
+	}
+
+	for dkgRoundID, fsmInstance := range fsmInstances {
+		// messages from users out of the round are not accepted by other participants
+		if _, err := fsmInstance.GetPubKeyByUsername(c.GetUsername()); err != nil {
+			continue
+		}
+		timeouts, err := fsmInstance.ExpiredTimeouts(now)
+		if err != nil {
+			return fmt.Errorf("failed to get expired timeouts for DKG round %s: %w", dkgRoundID, err)
+		}
+		for _, timeout := range timeouts {
+			if err := c.sendTimeout(dkgRoundID, fsmInstance, timeout, now); err != nil {
+				return fmt.Errorf("failed to send timeout for DKG round %s: %w", dkgRoundID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *BaseClient) sendTimeout(dkgRoundID string, fsmInstance *state_machines.FSMInstance, timeout state_machines.Timeout,
+	now time.Time) error {
+	state, err := fsmInstance.State()
+	if err != nil {
+		return fmt.Errorf("failed to get FSM state: %w", err)
+	}
+	if timeout.SigningId != "" {
+		if state, err = fsmInstance.SigningState(timeout.SigningId); err != nil {
+			return fmt.Errorf("failed to get signing state: %w", err)
+		}
+	}
+
+	// the key includes the state, so a next stage of the same round gets its own timeout
+	key := fmt.Sprintf("%s/%s/%s", dkgRoundID, timeout.SigningId, state)
+	if c.sentTimeouts[key] {
+		return nil
+	}
+
+	var req interface{} = requests.DefaultRequest{CreatedAt: now}
+	if timeout.SigningId != "" {
+		req = requests.SigningProposalTimeoutRequest{
+			SigningId: timeout.SigningId,
+			CreatedAt: now,
+		}
+	}
+	reqBz, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal timeout request: %w", err)
+	}
+
+	message, err := c.buildMessage(dkgRoundID, timeout.Event, reqBz)
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+	if err = c.SendMessage(*message); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	c.sentTimeouts[key] = true
+	c.Logger.Log("Timeout %s sent for DKG round %s in state %s", timeout.Event, dkgRoundID, state)
+
+	return nil
+}
@@ -8,8 +8,10 @@ import (
 
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
 
+	"github.com/lidofinance/dc4bc/eth2"
 	"github.com/lidofinance/dc4bc/fsm/fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/types/requests"
 	"github.com/lidofinance/dc4bc/storage"
@@ -30,6 +32,23 @@ type ReconstructedSignature struct {
 	DKGRoundID string
 }
 
+// SeenMessage is a processed message kept to reject its duplicates and detect equivocation
+type SeenMessage struct {
+	PayloadHash []byte
+	Message     storage.Message
+}
+
+// Evidence is a proof of equivocation: two conflicting messages signed by the same participant
+// for the same step of a DKG round
+type Evidence struct {
+	DKGRoundID string
+	Sender     string
+	Event      fsm.Event
+	First      storage.Message
+	Second     storage.Message
+	DetectedAt time.Time
+}
+
 // Operation is the type for any Operation that might be required for
 // both DKG and signing process (e.g.,
 type Operation struct {
@@ -59,63 +78,162 @@ func (o *Operation) Check(o2 *Operation) error {
 	return nil
 }
 
-// FSMRequestFromMessage converts a message data to a necessary FSM struct
-func FSMRequestFromMessage(message storage.Message) (interface{}, error) {
+// ResharingProposal is a request to reshare the key of the DKG round with the given ID
+type ResharingProposal struct {
+	DKGID string
+	requests.ResharingProposalStartRequest
+}
+
+// Eth2SigningProposal is a request to sign the Eth2 validator operation with the key of the DKG round with the given ID
+type Eth2SigningProposal struct {
+	DKGID          string
+	SigningRequest eth2.SigningRequest
+}
+
+// BatchSigningProposal is a request to sign a batch of payloads in one signing of the DKG round with the given ID.
+// If Eth2Requests are set, their signing roots are signed instead of SrcPayloads
+type BatchSigningProposal struct {
+	DKGID        string
+	SrcPayloads  [][]byte
+	Eth2Requests []*eth2.SigningRequest
+}
+
+// FSMRequestFromMessage converts a message data to a necessary FSM struct,
+// the time of the request is the time of the message in the log instead of the one set in the data
+func FSMRequestFromMessage(message storage.Message, createdAt time.Time) (interface{}, error) {
 	var resolvedValue interface{}
 	switch fsm.Event(message.Event) {
-	case signature_proposal_fsm.EventConfirmSignatureProposal:
+	case signature_proposal_fsm.EventConfirmSignatureProposal,
+		signature_proposal_fsm.EventDeclineProposal:
 		var req requests.SignatureProposalParticipantRequest
 		if err := json.Unmarshal(message.Data, &req); err != nil {
 			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
 		}
+		req.CreatedAt = createdAt
 		resolvedValue = req
 	case signature_proposal_fsm.EventInitProposal:
 		var req requests.SignatureProposalParticipantsListRequest
 		if err := json.Unmarshal(message.Data, &req); err != nil {
 			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
 		}
+		req.CreatedAt = createdAt
 		resolvedValue = req
 	case dkg_proposal_fsm.EventDKGCommitConfirmationReceived:
 		var req requests.DKGProposalCommitConfirmationRequest
 		if err := json.Unmarshal(message.Data, &req); err != nil {
 			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
 		}
+		req.CreatedAt = createdAt
 		resolvedValue = req
 	case dkg_proposal_fsm.EventDKGDealConfirmationReceived:
 		var req requests.DKGProposalDealConfirmationRequest
 		if err := json.Unmarshal(message.Data, &req); err != nil {
 			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
 		}
+		req.CreatedAt = createdAt
 		resolvedValue = req
 	case dkg_proposal_fsm.EventDKGResponseConfirmationReceived:
 		var req requests.DKGProposalResponseConfirmationRequest
 		if err := json.Unmarshal(message.Data, &req); err != nil {
 			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
 		}
+		req.CreatedAt = createdAt
+		resolvedValue = req
+	case dkg_proposal_fsm.EventDKGJustificationConfirmationReceived:
+		var req requests.DKGProposalJustificationConfirmationRequest
+		if err := json.Unmarshal(message.Data, &req); err != nil {
+			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
+		}
+		req.CreatedAt = createdAt
 		resolvedValue = req
 	case dkg_proposal_fsm.EventDKGMasterKeyConfirmationReceived:
 		var req requests.DKGProposalMasterKeyConfirmationRequest
 		if err := json.Unmarshal(message.Data, &req); err != nil {
 			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
 		}
+		req.CreatedAt = createdAt
 		resolvedValue = req
 	case signing_proposal_fsm.EventSigningPartialSignReceived:
 		var req requests.SigningProposalPartialSignRequest
 		if err := json.Unmarshal(message.Data, &req); err != nil {
 			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
 		}
+		req.CreatedAt = createdAt
 		resolvedValue = req
-	case signing_proposal_fsm.EventConfirmSigningConfirmation:
+	case signing_proposal_fsm.EventConfirmSigningConfirmation,
+		signing_proposal_fsm.EventDeclineSigningConfirmation:
 		var req requests.SigningProposalParticipantRequest
 		if err := json.Unmarshal(message.Data, &req); err != nil {
 			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
 		}
+		req.CreatedAt = createdAt
 		resolvedValue = req
 	case signing_proposal_fsm.EventSigningStart:
 		var req requests.SigningProposalStartRequest
 		if err := json.Unmarshal(message.Data, &req); err != nil {
 			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
 		}
+		req.CreatedAt = createdAt
+		resolvedValue = req
+	case resharing_proposal_fsm.EventResharingProposalStart:
+		var req requests.ResharingProposalStartRequest
+		if err := json.Unmarshal(message.Data, &req); err != nil {
+			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
+		}
+		req.CreatedAt = createdAt
+		resolvedValue = req
+	case resharing_proposal_fsm.EventResharingProposalConfirm,
+		resharing_proposal_fsm.EventResharingProposalDecline:
+		var req requests.ResharingProposalConfirmationRequest
+		if err := json.Unmarshal(message.Data, &req); err != nil {
+			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
+		}
+		req.CreatedAt = createdAt
+		resolvedValue = req
+	case resharing_proposal_fsm.EventResharingDealConfirmationReceived:
+		var req requests.ResharingProposalDealConfirmationRequest
+		if err := json.Unmarshal(message.Data, &req); err != nil {
+			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
+		}
+		req.CreatedAt = createdAt
+		resolvedValue = req
+	case resharing_proposal_fsm.EventResharingResponseConfirmationReceived:
+		var req requests.ResharingProposalResponseConfirmationRequest
+		if err := json.Unmarshal(message.Data, &req); err != nil {
+			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
+		}
+		req.CreatedAt = createdAt
+		resolvedValue = req
+	case resharing_proposal_fsm.EventResharingMasterKeyConfirmationReceived:
+		var req requests.ResharingProposalMasterKeyConfirmationRequest
+		if err := json.Unmarshal(message.Data, &req); err != nil {
+			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
+		}
+		req.CreatedAt = createdAt
+		resolvedValue = req
+	case signature_proposal_fsm.EventSignatureProposalTimeout,
+		dkg_proposal_fsm.EventDKGCommitsConfirmationTimeout,
+		dkg_proposal_fsm.EventDKGDealsConfirmationTimeout,
+		dkg_proposal_fsm.EventDKGResponsesConfirmationTimeout,
+		dkg_proposal_fsm.EventDKGJustificationsConfirmationTimeout,
+		dkg_proposal_fsm.EventDKGMasterKeyConfirmationTimeout,
+		resharing_proposal_fsm.EventResharingProposalTimeout,
+		resharing_proposal_fsm.EventResharingDealsConfirmationTimeout,
+		resharing_proposal_fsm.EventResharingResponsesConfirmationTimeout,
+		resharing_proposal_fsm.EventResharingMasterKeyConfirmationTimeout:
+		var req requests.DefaultRequest
+		if err := json.Unmarshal(message.Data, &req); err != nil {
+			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
+		}
+		req.CreatedAt = createdAt
+		resolvedValue = req
+	case signing_proposal_fsm.EventSigningConfirmationsTimeout,
+		signing_proposal_fsm.EventSigningPartialSignsTimeout:
+		var req requests.SigningProposalTimeoutRequest
+		if err := json.Unmarshal(message.Data, &req); err != nil {
+			return fmt.Errorf("failed to unmarshal fsm req: %v", err), nil
+		}
+		req.CreatedAt = createdAt
 		resolvedValue = req
 	default:
 		return nil, fmt.Errorf("invalid event: %s", message.Event)
@@ -123,3 +241,19 @@ func FSMRequestFromMessage(message storage.Message) (interface{}, error) {
 
 	return resolvedValue, nil
 }
+
+// SigningIDFromRequest returns an ID of the signing session the FSM request belongs to
+func SigningIDFromRequest(req interface{}) (string, bool) {
+	switch r := req.(type) {
+	case requests.SigningProposalStartRequest:
+		return r.SigningID, true
+	case requests.SigningProposalParticipantRequest:
+		return r.SigningId, true
+	case requests.SigningProposalPartialSignRequest:
+		return r.SigningId, true
+	case requests.SigningProposalTimeoutRequest:
+		return r.SigningId, true
+	default:
+		return "", false
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/syndtr/goleveldb/leveldb"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
@@ -65,6 +67,14 @@ func NewPrompt(machine *airgapped.Machine) (*prompt, error) {
 		commandHandler: p.readQRCommand,
 		description:    "Reads QR chunks from camera, handle a decoded operation and returns paths to qr chunks of operation's result",
 	})
+	p.addCommand("read_qr_files", &promptCommand{
+		commandHandler: p.readQRFilesCommand,
+		description:    "Reads QR chunks from image files (GIF, PNG, JPEG) instead of camera, handle a decoded operation and returns paths to qr chunks of operation's result",
+	})
+	p.addCommand("read_file", &promptCommand{
+		commandHandler: p.readFileCommand,
+		description:    "Reads an operation from a signed file (e.g. on a USB drive), handle it and saves a result operation to a signed file next to it",
+	})
 	p.addCommand("help", &promptCommand{
 		commandHandler: p.helpCommand,
 		description:    "shows available commands",
@@ -79,7 +89,7 @@ func NewPrompt(machine *airgapped.Machine) (*prompt, error) {
 	})
 	p.addCommand("replay_operations_log", &promptCommand{
 		commandHandler: p.replayOperationLogCommand,
-		description:    "replays the operation log for a given dkg round",
+		description:    "replays the operation log for a given dkg round (only for rounds without a saved state)",
 	})
 	p.addCommand("drop_operations_log", &promptCommand{
 		commandHandler: p.dropOperationLogCommand,
@@ -93,6 +103,34 @@ func NewPrompt(machine *airgapped.Machine) (*prompt, error) {
 		commandHandler: p.verifySignCommand,
 		description:    "verifies a BLS signature of a message",
 	})
+	p.addCommand("export_bls_keystore", &promptCommand{
+		commandHandler: p.exportBLSKeystoreCommand,
+		description:    "exports a private BLS share of a finished dkg round as an EIP-2335 keystore file",
+	})
+	p.addCommand("set_signing_policy", &promptCommand{
+		commandHandler: p.setSigningPolicyCommand,
+		description:    "replaces the signing policy with the one from a JSON file, requires the encryption password",
+	})
+	p.addCommand("show_signing_policy", &promptCommand{
+		commandHandler: p.showSigningPolicyCommand,
+		description:    "shows the signing policy",
+	})
+	p.addCommand("export_slashing_protection", &promptCommand{
+		commandHandler: p.exportSlashingProtectionCommand,
+		description:    "exports signed blocks and attestations to an EIP-3076 slashing protection interchange file",
+	})
+	p.addCommand("import_slashing_protection", &promptCommand{
+		commandHandler: p.importSlashingProtectionCommand,
+		description:    "imports signed blocks and attestations from an EIP-3076 slashing protection interchange file",
+	})
+	p.addCommand("import_bls_keystore", &promptCommand{
+		commandHandler: p.importBLSKeystoreCommand,
+		description:    "imports a private BLS share from an EIP-2335 keystore file made by export_bls_keystore",
+	})
+	p.addCommand("reset_hot_node_pubkey", &promptCommand{
+		commandHandler: p.resetHotNodePubKeyCommand,
+		description:    "drops the pinned pub key of the client node which signs operation files, requires the encryption password",
+	})
 	p.addCommand("change_configuration", &promptCommand{
 		commandHandler: p.changeConfigurationCommand,
 		description:    "changes a configuration variables (frames delay, chunk size, etc...)",
@@ -150,6 +188,64 @@ func (p *prompt) readQRCommand() error {
 	return nil
 }
 
+func (p *prompt) readQRFilesCommand() error {
+	p.print("> Enter paths to the QR image files separated by spaces: ")
+	paths, err := p.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read paths: %w", err)
+	}
+
+	qrPath, err := p.airgapped.HandleQRFromFiles(strings.Fields(paths))
+	if err != nil {
+		return err
+	}
+
+	p.println("An operation in the read QR code handled successfully, a result operation saved by chunks in following qr codes:")
+	p.printf("Operation's chunk: %s\n", qrPath)
+	return nil
+}
+
+func (p *prompt) readFileCommand() error {
+	p.print("> Enter a path to the operation file: ")
+	filePath, err := p.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read file path: %w", err)
+	}
+
+	resultPath, signerPubKey, err := p.airgapped.HandleFile(strings.Trim(filePath, "\n"))
+	if err != nil {
+		return err
+	}
+
+	p.printf("The operation file was signed by the pinned client pub key: %s\n",
+		base64.StdEncoding.EncodeToString(signerPubKey))
+	p.println("An operation in the file handled successfully, a result operation saved to the file:")
+	p.printf("Operation's file: %s\n", resultPath)
+	return nil
+}
+
+// reviewOperation prints an operation read by a command and asks the operator to approve it,
+// a rejected operation is answered with a decline or an error
+func (p *prompt) reviewOperation(description string) (bool, error) {
+	p.println("-----------------------------------------------------")
+	p.println(description)
+	p.println("-----------------------------------------------------")
+	for {
+		p.print("> Approve the operation? (yes/no): ")
+		answer, err := p.reader.ReadString('\n')
+		if err != nil {
+			return false, fmt.Errorf("failed to read answer: %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			p.println("The operation is rejected, the result operation holds a rejection")
+			return false, nil
+		}
+	}
+}
+
 func (p *prompt) showDKGPubKeyCommand() error {
 	pubkey := p.airgapped.GetPubKey()
 	pubkeyBz, err := pubkey.MarshalBinary()
@@ -306,6 +402,178 @@ func (p *prompt) verifySignCommand() error {
 	return nil
 }
 
+func (p *prompt) exportBLSKeystoreCommand() error {
+	p.print("> Enter the DKGRoundIdentifier: ")
+	dkgRoundIdentifier, err := p.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read dkgRoundIdentifier: %w", err)
+	}
+
+	p.print("> Enter a path to save the keystore file: ")
+	keystorePath, err := p.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read keystore path: %w", err)
+	}
+
+	p.print("> Enter a keystore passphrase: ")
+	passphrase, err := terminal.ReadPassword(syscall.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	p.println()
+	p.print("> Confirm the keystore passphrase: ")
+	confirmedPassphrase, err := terminal.ReadPassword(syscall.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	p.println()
+	if !bytes.Equal(passphrase, confirmedPassphrase) {
+		return fmt.Errorf("passphrases do not match")
+	}
+
+	keystore, err := p.airgapped.ExportBLSKeyring(strings.Trim(dkgRoundIdentifier, "\n"), string(passphrase))
+	if err != nil {
+		return fmt.Errorf("failed to export BLS keyring: %w", err)
+	}
+	keystorePath = strings.Trim(keystorePath, "\n")
+	if err = ioutil.WriteFile(keystorePath, keystore, 0600); err != nil {
+		return fmt.Errorf("failed to write keystore file: %w", err)
+	}
+	p.printf("Keystore was saved to %s\n", keystorePath)
+	return nil
+}
+
+func (p *prompt) setSigningPolicyCommand() error {
+	p.print("> Enter a path to the signing policy file: ")
+	policyPath, err := p.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read policy path: %w", err)
+	}
+	policy, err := ioutil.ReadFile(strings.Trim(policyPath, "\n"))
+	if err != nil {
+		return fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	p.print("> Enter encryption password: ")
+	password, err := terminal.ReadPassword(syscall.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	p.println()
+
+	if err = p.airgapped.SetSigningPolicy(password, policy); err != nil {
+		return fmt.Errorf("failed to set signing policy: %w", err)
+	}
+	p.println("Signing policy was updated")
+	return p.showSigningPolicyCommand()
+}
+
+func (p *prompt) resetHotNodePubKeyCommand() error {
+	pubKey, err := p.airgapped.GetHotNodePubKey()
+	if err != nil {
+		return fmt.Errorf("failed to get hot node pub key: %w", err)
+	}
+	if pubKey == nil {
+		p.println("The client pub key is not pinned yet")
+		return nil
+	}
+	p.printf("The pinned client pub key: %s\n", base64.StdEncoding.EncodeToString(pubKey))
+
+	p.print("> Enter encryption password: ")
+	password, err := terminal.ReadPassword(syscall.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	p.println()
+
+	if err = p.airgapped.ResetHotNodePubKey(password); err != nil {
+		return fmt.Errorf("failed to reset hot node pub key: %w", err)
+	}
+	p.println("The client pub key was dropped, the key of the next approved operation file will be pinned")
+	return nil
+}
+
+func (p *prompt) showSigningPolicyCommand() error {
+	policy, err := p.airgapped.GetSigningPolicy()
+	if err != nil {
+		return fmt.Errorf("failed to get signing policy: %w", err)
+	}
+	if policy == nil {
+		p.println("Signing policy is not set, any payload can be signed")
+		return nil
+	}
+	policyBz, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal signing policy: %w", err)
+	}
+	p.println(string(policyBz))
+	return nil
+}
+
+func (p *prompt) exportSlashingProtectionCommand() error {
+	p.print("> Enter a path to save the interchange file: ")
+	interchangePath, err := p.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read interchange path: %w", err)
+	}
+
+	interchange, err := p.airgapped.ExportSlashingProtection()
+	if err != nil {
+		return fmt.Errorf("failed to export slashing protection: %w", err)
+	}
+	interchangePath = strings.Trim(interchangePath, "\n")
+	if err = ioutil.WriteFile(interchangePath, interchange, 0600); err != nil {
+		return fmt.Errorf("failed to write interchange file: %w", err)
+	}
+	p.printf("Slashing protection was saved to %s\n", interchangePath)
+	return nil
+}
+
+func (p *prompt) importSlashingProtectionCommand() error {
+	p.print("> Enter a path to the interchange file: ")
+	interchangePath, err := p.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read interchange path: %w", err)
+	}
+
+	interchange, err := ioutil.ReadFile(strings.Trim(interchangePath, "\n"))
+	if err != nil {
+		return fmt.Errorf("failed to read interchange file: %w", err)
+	}
+	if err = p.airgapped.ImportSlashingProtection(interchange); err != nil {
+		return fmt.Errorf("failed to import slashing protection: %w", err)
+	}
+	p.println("Slashing protection was imported")
+	return nil
+}
+
+func (p *prompt) importBLSKeystoreCommand() error {
+	p.print("> Enter a path to the keystore file: ")
+	keystorePath, err := p.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read keystore path: %w", err)
+	}
+
+	keystore, err := ioutil.ReadFile(strings.Trim(keystorePath, "\n"))
+	if err != nil {
+		return fmt.Errorf("failed to read keystore file: %w", err)
+	}
+
+	p.print("> Enter the keystore passphrase: ")
+	passphrase, err := terminal.ReadPassword(syscall.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	p.println()
+
+	dkgRoundIdentifier, err := p.airgapped.ImportBLSKeyring(keystore, string(passphrase))
+	if err != nil {
+		return fmt.Errorf("failed to import BLS keyring: %w", err)
+	}
+	p.printf("BLS share of DKG round %s was imported\n", dkgRoundIdentifier)
+	return nil
+}
+
 func (p *prompt) enterEncryptionPasswordIfNeeded() error {
 	p.airgapped.Lock()
 	defer p.airgapped.Unlock()
@@ -485,6 +753,7 @@ func main() {
 		log.Fatalf(err.Error())
 	}
 	defer p.Close()
+	air.SetOperationReviewer(p.reviewOperation)
 
 	go func() {
 		for range c {
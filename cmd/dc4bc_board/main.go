@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/lidofinance/dc4bc/storage"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagListenAddr      = "listen_addr"
+	flagDataFile        = "data_file"
+	flagLockFile        = "lock_file"
+	flagTLSCert         = "tls_cert"
+	flagTLSKey          = "tls_key"
+	flagAppendTokenFile = "append_token_file"
+)
+
+func init() {
+	rootCmd.PersistentFlags().String(flagListenAddr, "localhost:9090", "Listen Address")
+	rootCmd.PersistentFlags().String(flagDataFile, "./dc4bc_board_storage", "Path to the append-only data file")
+	rootCmd.PersistentFlags().String(flagLockFile, "./dc4bc_board_storage_lock", "Path to the lock file of the data file")
+	rootCmd.PersistentFlags().String(flagTLSCert, "", "Path to the TLS certificate, the board is served over HTTPS with it")
+	rootCmd.PersistentFlags().String(flagTLSKey, "", "Path to the TLS private key")
+	rootCmd.PersistentFlags().String(flagAppendTokenFile, "", "Path to the file with a token required to send messages")
+}
+
+func startBoardCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "start",
+		Short: "starts the append-only bulletin board server",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+			dataFile, err := cmd.Flags().GetString(flagDataFile)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+			lockFile, err := cmd.Flags().GetString(flagLockFile)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+			tlsCert, err := cmd.Flags().GetString(flagTLSCert)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+			tlsKey, err := cmd.Flags().GetString(flagTLSKey)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+			if (tlsCert == "") != (tlsKey == "") {
+				return fmt.Errorf("both TLS certificate and key must be set")
+			}
+			appendTokenFile, err := cmd.Flags().GetString(flagAppendTokenFile)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+
+			var appendToken string
+			if appendTokenFile != "" {
+				tokenBz, err := ioutil.ReadFile(appendTokenFile)
+				if err != nil {
+					return fmt.Errorf("failed to read append token file: %w", err)
+				}
+				if appendToken = strings.TrimSpace(string(tokenBz)); appendToken == "" {
+					return fmt.Errorf("append token file is empty")
+				}
+				if tlsCert == "" {
+					log.Println("WARNING: the append token is sent in plain text, set a TLS certificate")
+				}
+			}
+
+			stg, err := storage.NewFileStorage(dataFile, lockFile)
+			if err != nil {
+				return fmt.Errorf("failed to init storage: %w", err)
+			}
+			board := storage.NewBoardServer(stg)
+			board.SetAppendToken(appendToken)
+
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sigs
+
+				log.Println("Received signal, stopping board...")
+				if err := stg.Close(); err != nil {
+					log.Printf("failed to close storage: %v\n", err)
+				}
+				os.Exit(0)
+			}()
+
+			log.Printf("Board started on address: %s", listenAddr)
+			if tlsCert != "" {
+				return board.ListenAndServeTLS(listenAddr, tlsCert, tlsKey)
+			}
+			return board.ListenAndServe(listenAddr)
+		},
+	}
+}
+
+var rootCmd = &cobra.Command{
+	Use:   "dc4bc_board",
+	Short: "dc4bc append-only bulletin board server, an alternative to Kafka",
+}
+
+func main() {
+	rootCmd.AddCommand(
+		startBoardCommand(),
+	)
+	if err := rootCmd.Execute(); err != nil {
+		log.Fatalf("Failed to execute root command: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagTLSCA     = "tls_ca"
+	flagTLSCert   = "tls_cert"
+	flagTLSKey    = "tls_key"
+	flagToken     = "token"
+	flagTokenFile = "token_file"
+)
+
+var (
+	// apiClient calls the HTTP API of the client node, it's configured by the flags before a command is run
+	apiClient = http.DefaultClient
+	apiScheme = "http"
+	apiToken  string
+)
+
+func init() {
+	rootCmd.PersistentFlags().String(flagTLSCA, "", "Path to the CA of the node's TLS certificate, the node is called over HTTPS with it")
+	rootCmd.PersistentFlags().String(flagTLSCert, "", "Path to the client certificate for mTLS authentication")
+	rootCmd.PersistentFlags().String(flagTLSKey, "", "Path to the private key of the client certificate")
+	rootCmd.PersistentFlags().String(flagToken, "", "Bearer token of the node's HTTP API")
+	rootCmd.PersistentFlags().String(flagTokenFile, "", "Path to a file with the bearer token of the node's HTTP API")
+	rootCmd.PersistentPreRunE = initAPIClient
+}
+
+func initAPIClient(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	caPath, _ := flags.GetString(flagTLSCA)
+	certPath, _ := flags.GetString(flagTLSCert)
+	keyPath, _ := flags.GetString(flagTLSKey)
+	token, _ := flags.GetString(flagToken)
+	tokenPath, _ := flags.GetString(flagTokenFile)
+
+	if token != "" && tokenPath != "" {
+		return errors.New("either token or token file can be set")
+	}
+	if tokenPath != "" {
+		tokenBz, err := ioutil.ReadFile(tokenPath)
+		if err != nil {
+			return fmt.Errorf("failed to read token file: %w", err)
+		}
+		token = strings.TrimSpace(string(tokenBz))
+	}
+	apiToken = token
+
+	if caPath == "" && certPath == "" && keyPath == "" {
+		return nil
+	}
+	if caPath == "" {
+		return errors.New("a client certificate can be used only over TLS, set the CA of the node")
+	}
+	caBz, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		return fmt.Errorf("failed to read CA file: %w", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caBz) {
+		return errors.New("failed to parse CA file")
+	}
+	tlsConfig := &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}
+	if certPath != "" || keyPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	apiClient = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	apiScheme = "https"
+	return nil
+}
+
+// apiURL returns the base URL of the node's HTTP API
+func apiURL(host string) string {
+	return fmt.Sprintf("%s://%s", apiScheme, host)
+}
+
+func apiGet(url string) (*http.Response, error) {
+	return apiRequest(http.MethodGet, url, "", nil)
+}
+
+func apiPost(url string, contentType string, body io.Reader) (*http.Response, error) {
+	return apiRequest(http.MethodPost, url, contentType, body)
+}
+
+func apiRequest(method, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if apiToken != "" {
+		req.Header.Set("Authorization", "Bearer "+apiToken)
+	}
+	return apiClient.Do(req)
+}
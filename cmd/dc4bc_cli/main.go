@@ -10,7 +10,6 @@ import (
 	"github.com/lidofinance/dc4bc/fsm/state_machines"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -22,7 +21,12 @@ import (
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/types/responses"
 
+	"github.com/corestario/kyber/pairing"
+	bls12381 "github.com/corestario/kyber/pairing/bls12381"
+	"github.com/corestario/kyber/sign/bls"
 	"github.com/lidofinance/dc4bc/client"
+	"github.com/lidofinance/dc4bc/client/types"
+	"github.com/lidofinance/dc4bc/eth2"
 	"github.com/lidofinance/dc4bc/fsm/types/requests"
 	"github.com/lidofinance/dc4bc/qr"
 	"github.com/spf13/cobra"
@@ -33,6 +37,10 @@ const (
 	flagFramesDelay   = "frames_delay"
 	flagChunkSize     = "chunk_size"
 	flagQRCodesFolder = "qr_codes_folder"
+
+	flagWithdrawalCredentials = "withdrawal_credentials"
+	flagDepositAmount         = "amount"
+	flagForkVersion           = "fork_version"
 )
 
 func init() {
@@ -52,13 +60,22 @@ func main() {
 		getOperationsCommand(),
 		getOperationQRPathCommand(),
 		readOperationFromCameraCommand(),
+		getOperationFileCommand(),
+		readOperationFileCommand(),
 		startDKGCommand(),
 		proposeSignMessageCommand(),
+		startResharingCommand(),
+		proposeSignEth2OperationCommand(),
+		proposeDepositCommand(),
+		proposeSignBatchCommand(),
+		proposeSignEth2OperationsCommand(),
+		getDepositDataCommand(),
 		getUsernameCommand(),
 		getPubKeyCommand(),
 		getHashOfStartDKGCommand(),
 		getSignaturesCommand(),
 		getSignatureCommand(),
+		getEvidenceCommand(),
 		saveOffsetCommand(),
 		getOffsetCommand(),
 		getFSMStatusCommand(),
@@ -71,7 +88,7 @@ func main() {
 }
 
 func getOperationsRequest(host string) (*OperationsResponse, error) {
-	resp, err := http.Get(fmt.Sprintf("http://%s/getOperations", host))
+	resp, err := apiGet(fmt.Sprintf("%s/getOperations", apiURL(host)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get operations: %w", err)
 	}
@@ -120,8 +137,22 @@ func getOperationsCommand() *cobra.Command {
 					if err := json.Unmarshal(operation.Payload, &payload); err != nil {
 						return fmt.Errorf("failed to unmarshal operation payload")
 					}
-					msgHash := md5.Sum(payload.SrcPayload)
-					fmt.Printf("Hash of the data to sign - %s\n", hex.EncodeToString(msgHash[:]))
+					if len(payload.SrcPayloads) > 1 {
+						fmt.Printf("Batch of %d payloads to sign:\n", len(payload.SrcPayloads))
+						for i, srcPayload := range payload.SrcPayloads {
+							msgHash := md5.Sum(srcPayload)
+							fmt.Printf("\tHash of the data #%d to sign - %s\n", i, hex.EncodeToString(msgHash[:]))
+							if i < len(payload.Eth2Requests) && payload.Eth2Requests[i] != nil {
+								fmt.Printf("\tEth2 operation #%d to sign:\n%s\n", i, payload.Eth2Requests[i])
+							}
+						}
+					} else if len(payload.SrcPayloads) == 1 {
+						msgHash := md5.Sum(payload.SrcPayloads[0])
+						fmt.Printf("Hash of the data to sign - %s\n", hex.EncodeToString(msgHash[:]))
+						if len(payload.Eth2Requests) > 0 && payload.Eth2Requests[0] != nil {
+							fmt.Printf("Eth2 operation to sign:\n%s\n", payload.Eth2Requests[0])
+						}
+					}
 					fmt.Printf("Signing ID: %s\n", payload.SigningId)
 				}
 				fmt.Println("-----------------------------------------------------")
@@ -132,7 +163,7 @@ func getOperationsCommand() *cobra.Command {
 }
 
 func getSignaturesRequest(host string, dkgID string) (*SignaturesResponse, error) {
-	resp, err := http.Get(fmt.Sprintf("http://%s/getSignatures?dkgID=%s", host, dkgID))
+	resp, err := apiGet(fmt.Sprintf("%s/getSignatures?dkgID=%s", apiURL(host), dkgID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get signatures: %w", err)
 	}
@@ -171,6 +202,8 @@ func getSignaturesCommand() *cobra.Command {
 				for _, participantSig := range signature {
 					fmt.Printf("\tDKG round ID: %s\n", participantSig.DKGRoundID)
 					fmt.Printf("\tParticipant: %s\n", participantSig.Username)
+					msgHash := md5.Sum(participantSig.SrcPayload)
+					fmt.Printf("\tHash of the data: %s\n", hex.EncodeToString(msgHash[:]))
 					fmt.Printf("\tReconstructed signature for the data: %s\n", base64.StdEncoding.EncodeToString(participantSig.Signature))
 					fmt.Println()
 				}
@@ -180,8 +213,55 @@ func getSignaturesCommand() *cobra.Command {
 	}
 }
 
+func getEvidenceRequest(host string, dkgID string) (*EvidenceResponse, error) {
+	resp, err := apiGet(fmt.Sprintf("%s/getEvidence?dkgID=%s", apiURL(host), dkgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get evidence: %w", err)
+	}
+	defer resp.Body.Close()
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	var response EvidenceResponse
+	if err = json.Unmarshal(responseBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	return &response, nil
+}
+
+func getEvidenceCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get_evidence [dkgID]",
+		Args:  cobra.ExactArgs(1),
+		Short: "returns pairs of conflicting messages sent by the same participant in the given DKG round",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+			evidence, err := getEvidenceRequest(listenAddr, args[0])
+			if err != nil {
+				return fmt.Errorf("failed to get evidence: %w", err)
+			}
+			if evidence.ErrorMessage != "" {
+				return fmt.Errorf("failed to get evidence: %s", evidence.ErrorMessage)
+			}
+			for _, e := range evidence.Result {
+				fmt.Printf("Participant: %s\n", e.Sender)
+				fmt.Printf("Event: %s\n", e.Event)
+				fmt.Printf("Conflicting messages at offsets: %d, %d\n", e.First.Offset, e.Second.Offset)
+				fmt.Printf("Detected at: %s\n", e.DetectedAt.Format(time.RFC3339))
+				fmt.Println("-----------------------------------------------------")
+			}
+			return nil
+		},
+	}
+}
+
 func getSignatureRequest(host string, dkgID, dataHash string) (*SignatureResponse, error) {
-	resp, err := http.Get(fmt.Sprintf("http://%s/getSignatureByID?dkgID=%s&id=%s", host, dkgID, dataHash))
+	resp, err := apiGet(fmt.Sprintf("%s/getSignatureByID?dkgID=%s&id=%s", apiURL(host), dkgID, dataHash))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get signatures: %w", err)
 	}
@@ -251,7 +331,7 @@ func getSignatureDataCommand() *cobra.Command {
 }
 
 func getOperationRequest(host string, operationID string) (*OperationResponse, error) {
-	resp, err := http.Get(fmt.Sprintf("http://%s/getOperation?operationID=%s", host, operationID))
+	resp, err := apiGet(fmt.Sprintf("%s/getOperation?operationID=%s", apiURL(host), operationID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get operation: %w", err)
 	}
@@ -318,8 +398,57 @@ func getOperationQRPathCommand() *cobra.Command {
 	}
 }
 
+func getOperationFileRequest(host string, operationID string) (*OperationResponse, error) {
+	resp, err := apiGet(fmt.Sprintf("%s/getOperationFile?operationID=%s", apiURL(host), operationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operation file: %w", err)
+	}
+	defer resp.Body.Close()
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	var response OperationResponse
+	if err = json.Unmarshal(responseBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	return &response, nil
+}
+
+func getOperationFileCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get_operation_file [operationID] [folder]",
+		Args:  cobra.ExactArgs(2),
+		Short: "saves the operation to a signed file to transfer it on removable media instead of QR codes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+
+			operationID := args[0]
+			operationFile, err := getOperationFileRequest(listenAddr, operationID)
+			if err != nil {
+				return fmt.Errorf("failed to get operation file: %w", err)
+			}
+			if operationFile.ErrorMessage != "" {
+				return fmt.Errorf("failed to get operation file: %s", operationFile.ErrorMessage)
+			}
+
+			filePath := filepath.Join(args[1], fmt.Sprintf("dc4bc_file_%s-request.json", operationID))
+			if err = ioutil.WriteFile(filePath, operationFile.Result, 0600); err != nil {
+				return fmt.Errorf("failed to save operation file: %w", err)
+			}
+
+			fmt.Printf("Operation file was saved to: %s\n", filePath)
+			return nil
+		},
+	}
+}
+
 func rawGetRequest(url string) (*client.Response, error) {
-	resp, err := http.Get(url)
+	resp, err := apiGet(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get operations for node %w", err)
 	}
@@ -346,7 +475,7 @@ func getPubKeyCommand() *cobra.Command {
 				return fmt.Errorf("failed to read configuration: %v", err)
 			}
 
-			resp, err := rawGetRequest(fmt.Sprintf("http://%s//getPubKey", listenAddr))
+			resp, err := rawGetRequest(fmt.Sprintf("%s//getPubKey", apiURL(listenAddr)))
 			if err != nil {
 				return fmt.Errorf("failed to get client's pubkey: %w", err)
 			}
@@ -379,7 +508,7 @@ func saveOffsetCommand() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to create request: %w", err)
 			}
-			resp, err := rawPostRequest(fmt.Sprintf("http://%s/saveOffset", listenAddr), "application/json", data)
+			resp, err := rawPostRequest(fmt.Sprintf("%s/saveOffset", apiURL(listenAddr)), "application/json", data)
 			if err != nil {
 				return fmt.Errorf("failed to save offset: %w", err)
 			}
@@ -402,7 +531,7 @@ func getOffsetCommand() *cobra.Command {
 				return fmt.Errorf("failed to read configuration: %v", err)
 			}
 
-			resp, err := rawGetRequest(fmt.Sprintf("http://%s//getOffset", listenAddr))
+			resp, err := rawGetRequest(fmt.Sprintf("%s//getOffset", apiURL(listenAddr)))
 			if err != nil {
 				return fmt.Errorf("failed to get offset: %w", err)
 			}
@@ -425,7 +554,7 @@ func getUsernameCommand() *cobra.Command {
 				return fmt.Errorf("failed to read configuration: %v", err)
 			}
 
-			resp, err := rawGetRequest(fmt.Sprintf("http://%s//getUsername", listenAddr))
+			resp, err := rawGetRequest(fmt.Sprintf("%s//getUsername", apiURL(listenAddr)))
 			if err != nil {
 				return fmt.Errorf("failed to get client's username: %w", err)
 			}
@@ -439,7 +568,7 @@ func getUsernameCommand() *cobra.Command {
 }
 
 func rawPostRequest(url string, contentType string, data []byte) (*client.Response, error) {
-	resp, err := http.Post(url,
+	resp, err := apiPost(url,
 		contentType, bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
@@ -459,8 +588,8 @@ func rawPostRequest(url string, contentType string, data []byte) (*client.Respon
 
 func readOperationFromCameraCommand() *cobra.Command {
 	return &cobra.Command{
-		Use:   "read_qr",
-		Short: "opens the camera and reads QR codes which should contain a processed operation",
+		Use:   "read_qr [image_path...]",
+		Short: "opens the camera and reads QR codes which should contain a processed operation, QR codes are read from the image files instead if they are given",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
 			if err != nil {
@@ -468,11 +597,16 @@ func readOperationFromCameraCommand() *cobra.Command {
 			}
 
 			processor := qr.NewCameraProcessor()
+			if len(args) > 0 {
+				imageProcessor := qr.NewImageProcessor()
+				imageProcessor.SetPaths(args...)
+				processor = imageProcessor
+			}
 			data, err := processor.ReadQR()
 			if err != nil {
 				return fmt.Errorf("failed to read data from QR: %w", err)
 			}
-			resp, err := rawPostRequest(fmt.Sprintf("http://%s/handleProcessedOperationJSON", listenAddr),
+			resp, err := rawPostRequest(fmt.Sprintf("%s/handleProcessedOperationJSON", apiURL(listenAddr)),
 				"application/json", data)
 			if err != nil {
 				return fmt.Errorf("failed to handle processed operation: %w", err)
@@ -485,6 +619,34 @@ func readOperationFromCameraCommand() *cobra.Command {
 	}
 }
 
+func readOperationFileCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "read_file [file_path]",
+		Args:  cobra.ExactArgs(1),
+		Short: "reads a signed file which should contain an operation processed by the airgapped machine",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+
+			fileData, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+			resp, err := rawPostRequest(fmt.Sprintf("%s/handleProcessedOperationFile", apiURL(listenAddr)),
+				"application/json", fileData)
+			if err != nil {
+				return fmt.Errorf("failed to handle processed operation file: %w", err)
+			}
+			if resp.ErrorMessage != "" {
+				return fmt.Errorf("failed to handle processed operation file: %v", resp.ErrorMessage)
+			}
+			return nil
+		},
+	}
+}
+
 func startDKGCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "start_dkg [proposing_file]",
@@ -515,7 +677,7 @@ func startDKGCommand() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to marshal SignatureProposalParticipantsListRequest: %v", err)
 			}
-			resp, err := rawPostRequest(fmt.Sprintf("http://%s/startDKG", listenAddr),
+			resp, err := rawPostRequest(fmt.Sprintf("%s/startDKG", apiURL(listenAddr)),
 				"application/json", messageDataBz)
 			if err != nil {
 				return fmt.Errorf("failed to make HTTP request to start DKG: %w", err)
@@ -596,7 +758,7 @@ func proposeSignMessageCommand() *cobra.Command {
 				return fmt.Errorf("failed to marshal SigningProposalStartRequest: %v", err)
 			}
 
-			resp, err := rawPostRequest(fmt.Sprintf("http://%s/proposeSignMessage", listenAddr),
+			resp, err := rawPostRequest(fmt.Sprintf("%s/proposeSignMessage", apiURL(listenAddr)),
 				"application/json", messageDataBz)
 			if err != nil {
 				return fmt.Errorf("failed to make HTTP request to propose message to sign: %w", err)
@@ -609,8 +771,340 @@ func proposeSignMessageCommand() *cobra.Command {
 	}
 }
 
+func proposeSignEth2OperationCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sign_eth2_operation [dkg_id] [file_path]",
+		Args:  cobra.ExactArgs(2),
+		Short: "sends a propose message to sign the Eth2 validator operation described in the JSON file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+
+			operationFileData, err := ioutil.ReadFile(args[1])
+			if err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+			var req types.Eth2SigningProposal
+			if err = json.Unmarshal(operationFileData, &req.SigningRequest); err != nil {
+				return fmt.Errorf("failed to unmarshal operation file: %w", err)
+			}
+			signingRoot, err := req.SigningRequest.SigningRoot()
+			if err != nil {
+				return fmt.Errorf("invalid operation: %w", err)
+			}
+			req.DKGID = args[0]
+
+			messageDataBz, err := json.Marshal(req)
+			if err != nil {
+				return fmt.Errorf("failed to marshal Eth2SigningProposal: %v", err)
+			}
+			resp, err := rawPostRequest(fmt.Sprintf("%s/proposeSignEth2Operation", apiURL(listenAddr)),
+				"application/json", messageDataBz)
+			if err != nil {
+				return fmt.Errorf("failed to make HTTP request to propose operation to sign: %w", err)
+			}
+			if resp.ErrorMessage != "" {
+				return fmt.Errorf("failed to make HTTP request to propose operation to sign: %v", resp.ErrorMessage)
+			}
+			fmt.Printf("Signing root: %s\n", signingRoot)
+			return nil
+		},
+	}
+}
+
+// postBatchSigningProposal sends a request to sign the batch in one signing of the DKG round
+func postBatchSigningProposal(listenAddr string, req types.BatchSigningProposal) error {
+	messageDataBz, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal BatchSigningProposal: %v", err)
+	}
+	resp, err := rawPostRequest(fmt.Sprintf("%s/proposeSignBatch", apiURL(listenAddr)),
+		"application/json", messageDataBz)
+	if err != nil {
+		return fmt.Errorf("failed to make HTTP request to propose batch to sign: %w", err)
+	}
+	if resp.ErrorMessage != "" {
+		return fmt.Errorf("failed to make HTTP request to propose batch to sign: %v", resp.ErrorMessage)
+	}
+	return nil
+}
+
+func proposeSignBatchCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sign_data_batch [dkg_id] [file_path...]",
+		Args:  cobra.MinimumNArgs(2),
+		Short: "sends a propose message to sign the data of all the files in one signing",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+
+			req := types.BatchSigningProposal{DKGID: args[0]}
+			for _, filePath := range args[1:] {
+				data, err := ioutil.ReadFile(filePath)
+				if err != nil {
+					return fmt.Errorf("failed to read file %s: %w", filePath, err)
+				}
+				req.SrcPayloads = append(req.SrcPayloads, data)
+			}
+			return postBatchSigningProposal(listenAddr, req)
+		},
+	}
+}
+
+func proposeSignEth2OperationsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sign_eth2_operations [dkg_id] [file_path]",
+		Args:  cobra.ExactArgs(2),
+		Short: "sends a propose message to sign all the Eth2 validator operations from the JSON array in the file in one signing",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+
+			operationsFileData, err := ioutil.ReadFile(args[1])
+			if err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+			req := types.BatchSigningProposal{DKGID: args[0]}
+			if err = json.Unmarshal(operationsFileData, &req.Eth2Requests); err != nil {
+				return fmt.Errorf("failed to unmarshal operations file: %w", err)
+			}
+			if len(req.Eth2Requests) == 0 {
+				return fmt.Errorf("no operations in the file")
+			}
+			for i, eth2Request := range req.Eth2Requests {
+				signingRoot, err := eth2Request.SigningRoot()
+				if err != nil {
+					return fmt.Errorf("invalid operation #%d: %w", i, err)
+				}
+				fmt.Printf("Signing root #%d: %s\n", i, signingRoot)
+			}
+			return postBatchSigningProposal(listenAddr, req)
+		},
+	}
+}
+
+// withDepositFlags adds flags of the deposit parameters to the command
+func withDepositFlags(cmd *cobra.Command) *cobra.Command {
+	cmd.Flags().String(flagWithdrawalCredentials, "", "Withdrawal credentials of the validator (hex)")
+	cmd.Flags().Uint64(flagDepositAmount, 32000000000, "Deposit amount in Gwei")
+	cmd.Flags().String(flagForkVersion, "0x00000000", "Genesis fork version of the chain (hex)")
+	return cmd
+}
+
+// getDepositSigningRequest returns a request to sign the deposit of the DKG round master key
+// with the parameters from the command flags
+func getDepositSigningRequest(cmd *cobra.Command, listenAddr, dkgID string) (*eth2.SigningRequest, error) {
+	fsmDumpResponse, err := getFSMDumpRequest(listenAddr, dkgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get FSM dump: %w", err)
+	}
+	if fsmDumpResponse.ErrorMessage != "" {
+		return nil, fmt.Errorf("failed to get FSM dump: %v", fsmDumpResponse.ErrorMessage)
+	}
+
+	var message eth2.DepositMessage
+	masterKey := fsmDumpResponse.Result.Payload.DKGMasterKey()
+	if len(masterKey) != len(message.Pubkey) {
+		return nil, fmt.Errorf("DKG round %s is not finished", dkgID)
+	}
+	copy(message.Pubkey[:], masterKey)
+
+	withdrawalCredentials, err := cmd.Flags().GetString(flagWithdrawalCredentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %v", err)
+	}
+	if err = message.WithdrawalCredentials.UnmarshalText([]byte(withdrawalCredentials)); err != nil {
+		return nil, fmt.Errorf("invalid withdrawal credentials: %w", err)
+	}
+	if message.Amount, err = cmd.Flags().GetUint64(flagDepositAmount); err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %v", err)
+	}
+
+	req := &eth2.SigningRequest{
+		Type:           eth2.OperationDeposit,
+		DepositMessage: &message,
+	}
+	forkVersion, err := cmd.Flags().GetString(flagForkVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %v", err)
+	}
+	if err = req.ForkVersion.UnmarshalText([]byte(forkVersion)); err != nil {
+		return nil, fmt.Errorf("invalid fork version: %w", err)
+	}
+	return req, nil
+}
+
+func proposeDepositCommand() *cobra.Command {
+	return withDepositFlags(&cobra.Command{
+		Use:   "propose_deposit [dkg_id]",
+		Args:  cobra.ExactArgs(1),
+		Short: "sends a propose message to sign the deposit of the DKG round master key",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+
+			req, err := getDepositSigningRequest(cmd, listenAddr, args[0])
+			if err != nil {
+				return err
+			}
+			signingRoot, err := req.SigningRoot()
+			if err != nil {
+				return fmt.Errorf("invalid deposit: %w", err)
+			}
+
+			messageDataBz, err := json.Marshal(types.Eth2SigningProposal{DKGID: args[0], SigningRequest: *req})
+			if err != nil {
+				return fmt.Errorf("failed to marshal Eth2SigningProposal: %v", err)
+			}
+			resp, err := rawPostRequest(fmt.Sprintf("%s/proposeSignEth2Operation", apiURL(listenAddr)),
+				"application/json", messageDataBz)
+			if err != nil {
+				return fmt.Errorf("failed to make HTTP request to propose deposit to sign: %w", err)
+			}
+			if resp.ErrorMessage != "" {
+				return fmt.Errorf("failed to make HTTP request to propose deposit to sign: %v", resp.ErrorMessage)
+			}
+			fmt.Printf("Signing root: %s\n", signingRoot)
+			return nil
+		},
+	})
+}
+
+func getDepositDataCommand() *cobra.Command {
+	return withDepositFlags(&cobra.Command{
+		Use:   "get_deposit_data [dkg_id] [file_path]",
+		Args:  cobra.ExactArgs(2),
+		Short: "writes deposit_data.json of the deposit proposed with the same flags, when its signature is reconstructed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+
+			req, err := getDepositSigningRequest(cmd, listenAddr, args[0])
+			if err != nil {
+				return err
+			}
+			signingRoot, err := req.SigningRoot()
+			if err != nil {
+				return fmt.Errorf("invalid deposit: %w", err)
+			}
+
+			signatures, err := getSignaturesRequest(listenAddr, args[0])
+			if err != nil {
+				return fmt.Errorf("failed to get signatures: %w", err)
+			}
+			if signatures.ErrorMessage != "" {
+				return fmt.Errorf("failed to get signatures: %s", signatures.ErrorMessage)
+			}
+
+			suite := bls12381.NewBLS12381Suite(nil).(pairing.Suite)
+			pubKey := suite.G1().Point()
+			if err = pubKey.UnmarshalBinary(req.DepositMessage.Pubkey[:]); err != nil {
+				return fmt.Errorf("failed to unmarshal master pub key: %w", err)
+			}
+
+			// the same deposit may be signed several times, any participant may broadcast a broken signature,
+			// so the first valid signature in the order of signing IDs is taken
+			signingIDs := make([]string, 0, len(signatures.Result))
+			for signingID := range signatures.Result {
+				signingIDs = append(signingIDs, signingID)
+			}
+			sort.Strings(signingIDs)
+
+			var signature []byte
+			for _, signingID := range signingIDs {
+				for _, participantSig := range signatures.Result[signingID] {
+					if !bytes.Equal(participantSig.SrcPayload, signingRoot[:]) || len(participantSig.Signature) == 0 {
+						continue
+					}
+					if err = bls.Verify(suite, pubKey, signingRoot[:], participantSig.Signature); err != nil {
+						fmt.Printf("Skipping invalid signature from %s in signing %s: %v\n", participantSig.Username, signingID, err)
+						continue
+					}
+					signature = participantSig.Signature
+					break
+				}
+				if signature != nil {
+					break
+				}
+			}
+			if signature == nil {
+				return fmt.Errorf("valid signature of the deposit with signing root %s is not reconstructed yet", signingRoot)
+			}
+
+			var depositSignature eth2.BLSSignature
+			if len(signature) != len(depositSignature) {
+				return fmt.Errorf("invalid signature length: %d", len(signature))
+			}
+			copy(depositSignature[:], signature)
+
+			depositData := eth2.NewDepositDataJSON(req.DepositMessage, depositSignature, req.ForkVersion)
+			depositDataBz, err := json.Marshal([]*eth2.DepositDataJSON{depositData})
+			if err != nil {
+				return fmt.Errorf("failed to marshal deposit data: %w", err)
+			}
+			if err = ioutil.WriteFile(args[1], depositDataBz, 0644); err != nil {
+				return fmt.Errorf("failed to write deposit data: %w", err)
+			}
+			fmt.Printf("Deposit data root: 0x%s\n", depositData.DepositDataRoot)
+			return nil
+		},
+	})
+}
+
+func startResharingCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "start_resharing [dkg_id] [proposing_file]",
+		Args:  cobra.ExactArgs(2),
+		Short: "sends a propose message to reshare the DKG round key to a new set of participants, dealing starts after a threshold of current participants approves it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listenAddr, err := cmd.Flags().GetString(flagListenAddr)
+			if err != nil {
+				return fmt.Errorf("failed to read configuration: %v", err)
+			}
+
+			resharingProposeFileData, err := ioutil.ReadFile(args[1])
+			if err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+			var req types.ResharingProposal
+			if err = json.Unmarshal(resharingProposeFileData, &req.ResharingProposalStartRequest); err != nil {
+				return fmt.Errorf("failed to unmarshal resharing proposing file: %w", err)
+			}
+
+			if len(req.NewParticipants) == 0 || req.NewThreshold > len(req.NewParticipants) {
+				return fmt.Errorf("invalid threshold: %d", req.NewThreshold)
+			}
+			req.DKGID = args[0]
+
+			messageDataBz, err := json.Marshal(req)
+			if err != nil {
+				return fmt.Errorf("failed to marshal ResharingProposal: %v", err)
+			}
+			resp, err := rawPostRequest(fmt.Sprintf("%s/startResharing", apiURL(listenAddr)),
+				"application/json", messageDataBz)
+			if err != nil {
+				return fmt.Errorf("failed to make HTTP request to start resharing: %w", err)
+			}
+			if resp.ErrorMessage != "" {
+				return fmt.Errorf("failed to make HTTP request to start resharing: %v", resp.ErrorMessage)
+			}
+			return nil
+		},
+	}
+}
+
 func getFSMDumpRequest(host string, dkgID string) (*FSMDumpResponse, error) {
-	resp, err := http.Get(fmt.Sprintf("http://%s/getFSMDump?dkgID=%s", host, dkgID))
+	resp, err := apiGet(fmt.Sprintf("%s/getFSMDump?dkgID=%s", apiURL(host), dkgID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get FSM dump: %w", err)
 	}
@@ -649,54 +1143,85 @@ func getFSMStatusCommand() *cobra.Command {
 
 			fmt.Printf("FSM current status is %s\n", dump.State)
 
-			quorum := make(map[int]state_machines.Participant)
-			if strings.HasPrefix(string(dump.State), "state_signing") {
-				for k, v := range dump.Payload.SigningProposalPayload.Quorum {
-					quorum[k] = v
+			if dump.State == signing_proposal_fsm.StateSigningIdle {
+				for signingID, signing := range dump.Payload.SigningProposalsPayload {
+					if signing_proposal_fsm.IsSigningFinished(signing.State) {
+						continue
+					}
+					fmt.Printf("Signing %s status is %s, deadline is %s\n", signingID, signing.State,
+						signing.ExpiresAt.Format(time.RFC3339))
+					quorum := make(map[int]state_machines.Participant)
+					for k, v := range signing.Quorum {
+						quorum[k] = v
+					}
+					printQuorumStatus(quorum)
 				}
+				return nil
 			}
+
+			quorum := make(map[int]state_machines.Participant)
 			if strings.HasPrefix(string(dump.State), "state_dkg") {
 				for k, v := range dump.Payload.DKGProposalPayload.Quorum {
 					quorum[k] = v
 				}
+				fmt.Printf("Deadline is %s\n", dump.Payload.DKGProposalPayload.ExpiresAt.Format(time.RFC3339))
 			}
 			if strings.HasPrefix(string(dump.State), "state_sig_") {
 				for k, v := range dump.Payload.SignatureProposalPayload.Quorum {
 					quorum[k] = v
 				}
+				fmt.Printf("Deadline is %s\n", dump.Payload.SignatureProposalPayload.ExpiresAt.Format(time.RFC3339))
 			}
-
-			waiting := make([]string, 0)
-			confirmed := make([]string, 0)
-			failed := make([]string, 0)
-
-			for _, p := range quorum {
-				if strings.Contains(p.GetStatus().String(), "Await") {
-					waiting = append(waiting, p.GetUsername())
-				}
-				if strings.Contains(p.GetStatus().String(), "Error") {
-					failed = append(failed, p.GetUsername())
+			if strings.HasPrefix(string(dump.State), "state_resharing") {
+				fmt.Printf("Deadline is %s\n", dump.Payload.ResharingProposalPayload.ExpiresAt.Format(time.RFC3339))
+				// dealers and receivers have separate ids, so receivers are shifted to avoid collisions
+				var offset int
+				for k, v := range dump.Payload.ResharingProposalPayload.Dealers {
+					quorum[k] = v
+					if k >= offset {
+						offset = k + 1
+					}
 				}
-				if strings.Contains(p.GetStatus().String(), "Confirmed") {
-					confirmed = append(confirmed, p.GetUsername())
+				for k, v := range dump.Payload.ResharingProposalPayload.Receivers {
+					quorum[offset+k] = v
 				}
 			}
 
-			if len(waiting) > 0 {
-				fmt.Printf("Waiting for a data from: %s\n", strings.Join(waiting, ", "))
-			}
-			if len(confirmed) > 0 {
-				fmt.Printf("Received a data from: %s\n", strings.Join(confirmed, ", "))
-			}
-			if len(failed) > 0 {
-				fmt.Printf("Participants who got some error during a process: %s\n", strings.Join(waiting, ", "))
-			}
+			printQuorumStatus(quorum)
 
 			return nil
 		},
 	}
 }
 
+func printQuorumStatus(quorum map[int]state_machines.Participant) {
+	waiting := make([]string, 0)
+	confirmed := make([]string, 0)
+	failed := make([]string, 0)
+
+	for _, p := range quorum {
+		if strings.Contains(p.GetStatus().String(), "Await") {
+			waiting = append(waiting, p.GetUsername())
+		}
+		if strings.Contains(p.GetStatus().String(), "Error") {
+			failed = append(failed, p.GetUsername())
+		}
+		if strings.Contains(p.GetStatus().String(), "Confirmed") {
+			confirmed = append(confirmed, p.GetUsername())
+		}
+	}
+
+	if len(waiting) > 0 {
+		fmt.Printf("Waiting for a data from: %s\n", strings.Join(waiting, ", "))
+	}
+	if len(confirmed) > 0 {
+		fmt.Printf("Received a data from: %s\n", strings.Join(confirmed, ", "))
+	}
+	if len(failed) > 0 {
+		fmt.Printf("Participants who got some error during a process: %s\n", strings.Join(failed, ", "))
+	}
+}
+
 func getFSMListCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "get_fsm_list",
@@ -707,7 +1232,7 @@ func getFSMListCommand() *cobra.Command {
 				return fmt.Errorf("failed to read configuration: %v", err)
 			}
 
-			resp, err := rawGetRequest(fmt.Sprintf("http://%s/getFSMList", listenAddr))
+			resp, err := rawGetRequest(fmt.Sprintf("%s/getFSMList", apiURL(listenAddr)))
 			if err != nil {
 				return fmt.Errorf("failed to make HTTP request to get FSM list: %w", err)
 			}
@@ -9,6 +9,7 @@ import (
 	"github.com/lidofinance/dc4bc/fsm/fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/types/requests"
@@ -43,6 +44,11 @@ type SignaturesResponse struct {
 	Result       map[string][]types.ReconstructedSignature `json:"result"`
 }
 
+type EvidenceResponse struct {
+	ErrorMessage string           `json:"error_message,omitempty"`
+	Result       []types.Evidence `json:"result"`
+}
+
 type SignatureResponse struct {
 	ErrorMessage string                         `json:"error_message,omitempty"`
 	Result       []types.ReconstructedSignature `json:"result"`
@@ -92,6 +98,8 @@ func getShortOperationDescription(operationType types.OperationType) string {
 		return "send deals for the DKG round"
 	case dkg_proposal_fsm.StateDkgResponsesAwaitConfirmations:
 		return "send responses for the DKG round"
+	case dkg_proposal_fsm.StateDkgJustificationsAwaitConfirmations:
+		return "answer complaints about your deal in the DKG round"
 	case dkg_proposal_fsm.StateDkgMasterKeyAwaitConfirmations:
 		return "reconstruct the public key and broadcast it"
 	case signing_proposal_fsm.StateSigningAwaitConfirmations:
@@ -100,6 +108,14 @@ func getShortOperationDescription(operationType types.OperationType) string {
 		return "send your partial sign for the message"
 	case signing_proposal_fsm.StateSigningPartialSignsCollected:
 		return "recover full signature for the message"
+	case resharing_proposal_fsm.StateResharingProposalAwaitConfirmations:
+		return "approve the key resharing"
+	case resharing_proposal_fsm.StateResharingDealsAwaitConfirmations:
+		return "send deals for the key resharing"
+	case resharing_proposal_fsm.StateResharingResponsesAwaitConfirmations:
+		return "send responses for the key resharing"
+	case resharing_proposal_fsm.StateResharingMasterKeyAwaitConfirmations:
+		return "reconstruct the public key after resharing and broadcast it"
 	default:
 		return "unknown operation"
 	}
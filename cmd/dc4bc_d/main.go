@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
@@ -15,21 +18,44 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 const (
 	flagUserName                 = "username"
 	flagListenAddr               = "listen_addr"
 	flagStateDBDSN               = "state_dbdsn"
+	flagStorageType              = "storage_type"
 	flagStorageDBDSN             = "storage_dbdsn"
 	flagStorageTopic             = "storage_topic"
 	flagKafkaProducerCredentials = "producer_credentials"
 	flagKafkaConsumerCredentials = "consumer_credentials"
 	flagKafkaTrustStorePath      = "kafka_truststore_path"
+	flagBoardCA                  = "board_ca"
+	flagBoardAppendTokenFile     = "board_append_token_file"
 	flagStoreDBDSN               = "key_store_dbdsn"
+	flagKeyStorePasswordFile     = "key_store_password_file"
+	flagNewKeyStorePasswordFile  = "new_key_store_password_file"
 	flagFramesDelay              = "frames_delay"
 	flagChunkSize                = "chunk_size"
 	flagConfig                   = "config"
+	flagAllowLegacyMessages      = "allow_legacy_messages"
+	flagHTTPTLSCert              = "http_tls_cert"
+	flagHTTPTLSKey               = "http_tls_key"
+	flagHTTPClientCA             = "http_client_ca"
+	flagHTTPCredentials          = "http_credentials"
+	flagHTTPAuditLog             = "http_audit_log"
+	flagHTTPNoAuth               = "http_no_auth"
+)
+
+const (
+	storageTypeKafka = "kafka"
+	storageTypeBoard = "board"
+)
+
+const (
+	envKeyStorePassword    = "DC4BC_KEYSTORE_PASSWORD"
+	envNewKeyStorePassword = "DC4BC_NEW_KEYSTORE_PASSWORD"
 )
 
 var (
@@ -42,27 +68,53 @@ func init() {
 	rootCmd.PersistentFlags().String(flagUserName, "testUser", "Username")
 	rootCmd.PersistentFlags().String(flagListenAddr, "localhost:8080", "Listen Address")
 	rootCmd.PersistentFlags().String(flagStateDBDSN, "./dc4bc_client_state", "State DBDSN")
-	rootCmd.PersistentFlags().String(flagStorageDBDSN, "./dc4bc_file_storage", "Storage DBDSN")
+	rootCmd.PersistentFlags().String(flagStorageType, storageTypeKafka, "Storage type: kafka or board (dc4bc_board server)")
+	rootCmd.PersistentFlags().String(flagStorageDBDSN, "./dc4bc_file_storage", "Storage DBDSN (Kafka endpoint or board address)")
 	rootCmd.PersistentFlags().String(flagStorageTopic, "messages", "Storage Topic (Kafka)")
 	rootCmd.PersistentFlags().String(flagKafkaProducerCredentials, "producer:producerpass", "Producer credentials for Kafka: username:password")
 	rootCmd.PersistentFlags().String(flagKafkaConsumerCredentials, "consumer:consumerpass", "Consumer credentials for Kafka: username:password")
 	rootCmd.PersistentFlags().String(flagKafkaTrustStorePath, "certs/ca.pem", "Path to kafka truststore")
+	rootCmd.PersistentFlags().String(flagBoardCA, "", "Path to the CA of the board TLS certificate, the system roots are used without it")
+	rootCmd.PersistentFlags().String(flagBoardAppendTokenFile, "", "Path to the file with the token the board requires to send messages")
 	rootCmd.PersistentFlags().String(flagStoreDBDSN, "./dc4bc_key_store", "Key Store DBDSN")
+	rootCmd.PersistentFlags().String(flagKeyStorePasswordFile, "", "Path to the file with the keystore password, "+
+		"without it the password is taken from "+envKeyStorePassword+" or the terminal")
+	rootCmd.PersistentFlags().String(flagNewKeyStorePasswordFile, "", "Path to the file with the new keystore password of change_password, "+
+		"without it the password is taken from "+envNewKeyStorePassword+" or the terminal")
 	rootCmd.PersistentFlags().Int(flagFramesDelay, 10, "Delay times between frames in 100ths of a second")
 	rootCmd.PersistentFlags().Int(flagChunkSize, 256, "QR-code's chunk size")
+	rootCmd.PersistentFlags().Bool(flagAllowLegacyMessages, false, "Accept messages signed by older clients without an envelope, needed to replay their logs")
+	rootCmd.PersistentFlags().String(flagHTTPTLSCert, "", "Path to the TLS certificate of the HTTP API, the API is served over HTTPS with it")
+	rootCmd.PersistentFlags().String(flagHTTPTLSKey, "", "Path to the TLS private key of the HTTP API")
+	rootCmd.PersistentFlags().String(flagHTTPClientCA, "", "Path to the CA of client certificates, enables mTLS authentication")
+	rootCmd.PersistentFlags().String(flagHTTPCredentials, "", "Path to the JSON file of tokens and client certificates allowed to use the HTTP API")
+	rootCmd.PersistentFlags().String(flagHTTPAuditLog, "./dc4bc_audit.log", "Path to the audit log of mutating HTTP API calls")
+	rootCmd.PersistentFlags().Bool(flagHTTPNoAuth, false, "Serve the HTTP API without authentication, anyone who can reach it is an operator")
 	rootCmd.PersistentFlags().StringVar(&cfgFile, flagConfig, "", "path to your config file")
 
 	exitIfError(viper.BindPFlag(flagUserName, rootCmd.PersistentFlags().Lookup(flagUserName)))
 	exitIfError(viper.BindPFlag(flagListenAddr, rootCmd.PersistentFlags().Lookup(flagListenAddr)))
 	exitIfError(viper.BindPFlag(flagStateDBDSN, rootCmd.PersistentFlags().Lookup(flagStateDBDSN)))
+	exitIfError(viper.BindPFlag(flagStorageType, rootCmd.PersistentFlags().Lookup(flagStorageType)))
 	exitIfError(viper.BindPFlag(flagStorageDBDSN, rootCmd.PersistentFlags().Lookup(flagStorageDBDSN)))
 	exitIfError(viper.BindPFlag(flagStorageTopic, rootCmd.PersistentFlags().Lookup(flagStorageTopic)))
 	exitIfError(viper.BindPFlag(flagKafkaProducerCredentials, rootCmd.PersistentFlags().Lookup(flagKafkaProducerCredentials)))
 	exitIfError(viper.BindPFlag(flagKafkaConsumerCredentials, rootCmd.PersistentFlags().Lookup(flagKafkaConsumerCredentials)))
 	exitIfError(viper.BindPFlag(flagKafkaTrustStorePath, rootCmd.PersistentFlags().Lookup(flagKafkaTrustStorePath)))
+	exitIfError(viper.BindPFlag(flagBoardCA, rootCmd.PersistentFlags().Lookup(flagBoardCA)))
+	exitIfError(viper.BindPFlag(flagBoardAppendTokenFile, rootCmd.PersistentFlags().Lookup(flagBoardAppendTokenFile)))
 	exitIfError(viper.BindPFlag(flagStoreDBDSN, rootCmd.PersistentFlags().Lookup(flagStoreDBDSN)))
+	exitIfError(viper.BindPFlag(flagKeyStorePasswordFile, rootCmd.PersistentFlags().Lookup(flagKeyStorePasswordFile)))
+	exitIfError(viper.BindPFlag(flagNewKeyStorePasswordFile, rootCmd.PersistentFlags().Lookup(flagNewKeyStorePasswordFile)))
 	exitIfError(viper.BindPFlag(flagFramesDelay, rootCmd.PersistentFlags().Lookup(flagFramesDelay)))
 	exitIfError(viper.BindPFlag(flagChunkSize, rootCmd.PersistentFlags().Lookup(flagChunkSize)))
+	exitIfError(viper.BindPFlag(flagAllowLegacyMessages, rootCmd.PersistentFlags().Lookup(flagAllowLegacyMessages)))
+	exitIfError(viper.BindPFlag(flagHTTPTLSCert, rootCmd.PersistentFlags().Lookup(flagHTTPTLSCert)))
+	exitIfError(viper.BindPFlag(flagHTTPTLSKey, rootCmd.PersistentFlags().Lookup(flagHTTPTLSKey)))
+	exitIfError(viper.BindPFlag(flagHTTPClientCA, rootCmd.PersistentFlags().Lookup(flagHTTPClientCA)))
+	exitIfError(viper.BindPFlag(flagHTTPCredentials, rootCmd.PersistentFlags().Lookup(flagHTTPCredentials)))
+	exitIfError(viper.BindPFlag(flagHTTPAuditLog, rootCmd.PersistentFlags().Lookup(flagHTTPAuditLog)))
+	exitIfError(viper.BindPFlag(flagHTTPNoAuth, rootCmd.PersistentFlags().Lookup(flagHTTPNoAuth)))
 	exitIfError(viper.BindPFlag(flagUserName, rootCmd.PersistentFlags().Lookup(flagUserName)))
 }
 
@@ -81,6 +133,66 @@ func initConfig() {
 	exitIfError(viper.ReadInConfig())
 }
 
+// passwordSource is a file flag and an environment variable a password is read from, so the node can run without
+// a terminal, e.g. as a service. If neither is set, the password is read from the terminal
+type passwordSource struct {
+	fileFlag string
+	env      string
+}
+
+var (
+	keyStorePasswordSource    = passwordSource{fileFlag: flagKeyStorePasswordFile, env: envKeyStorePassword}
+	newKeyStorePasswordSource = passwordSource{fileFlag: flagNewKeyStorePasswordFile, env: envNewKeyStorePassword}
+)
+
+func (s passwordSource) interactive() bool {
+	_, envSet := os.LookupEnv(s.env)
+	return viper.GetString(s.fileFlag) == "" && !envSet
+}
+
+func readPassword(source passwordSource, prompt string) (string, error) {
+	if path := viper.GetString(source.fileFlag); path != "" {
+		passwordBz, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password file: %w", err)
+		}
+		return strings.TrimRight(string(passwordBz), "\r\n"), nil
+	}
+	if password, ok := os.LookupEnv(source.env); ok {
+		return password, nil
+	}
+
+	fmt.Print(prompt)
+	password, err := terminal.ReadPassword(syscall.Stdin)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return string(password), nil
+}
+
+// readNewPassword reads a password to encrypt the keypair with, a password typed in the terminal is asked twice
+func readNewPassword(source passwordSource, prompt string) (string, error) {
+	password, err := readPassword(source, prompt)
+	if err != nil {
+		return "", err
+	}
+	if len(password) == 0 {
+		return "", errors.New("password must not be empty")
+	}
+	if !source.interactive() {
+		return password, nil
+	}
+	confirmedPassword, err := readPassword(source, "Confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != confirmedPassword {
+		return "", errors.New("passwords do not match")
+	}
+	return password, nil
+}
+
 func genKeyPairCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "gen_keys",
@@ -89,12 +201,17 @@ func genKeyPairCommand() *cobra.Command {
 			username := viper.GetString(flagUserName)
 			keyStoreDBDSN := viper.GetString(flagStoreDBDSN)
 
+			password, err := readNewPassword(keyStorePasswordSource, "Enter keystore password: ")
+			if err != nil {
+				return err
+			}
+
 			keyPair := client.NewKeyPair()
 			keyStore, err := client.NewLevelDBKeyStore(username, keyStoreDBDSN)
 			if err != nil {
 				return fmt.Errorf("failed to init key store: %w", err)
 			}
-			if err = keyStore.PutKeys(username, keyPair); err != nil {
+			if err = keyStore.PutKeys(username, password, keyPair); err != nil {
 				return fmt.Errorf("failed to save keypair: %w", err)
 			}
 			fmt.Printf("keypair generated for user %s and saved to %s\n", username, keyStoreDBDSN)
@@ -103,6 +220,39 @@ func genKeyPairCommand() *cobra.Command {
 	}
 }
 
+func changePasswordCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "change_password",
+		Short: "re-encrypts the keypair with a key derived from a new password",
+		Long: "re-encrypts the keypair with a key derived from a new password, the keypair itself stays the same. " +
+			"A keypair generated by an older version is stored unencrypted, leave the current password empty for it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			username := viper.GetString(flagUserName)
+			keyStoreDBDSN := viper.GetString(flagStoreDBDSN)
+
+			keyStore, err := client.NewLevelDBKeyStore(username, keyStoreDBDSN)
+			if err != nil {
+				return fmt.Errorf("failed to init key store: %w", err)
+			}
+
+			oldPassword, err := readPassword(keyStorePasswordSource, "Enter current keystore password: ")
+			if err != nil {
+				return err
+			}
+			newPassword, err := readNewPassword(newKeyStorePasswordSource, "Enter new keystore password: ")
+			if err != nil {
+				return err
+			}
+
+			if err = keyStore.ChangePassword(username, oldPassword, newPassword); err != nil {
+				return fmt.Errorf("failed to change keystore password: %w", err)
+			}
+			fmt.Printf("keypair of user %s is re-encrypted\n", username)
+			return nil
+		},
+	}
+}
+
 func parseKafkaAuthCredentials(creds string) (*storage.KafkaAuthCredentials, error) {
 	credsSplited := strings.SplitN(creds, ":", 2)
 	if len(credsSplited) == 1 {
@@ -114,13 +264,90 @@ func parseKafkaAuthCredentials(creds string) (*storage.KafkaAuthCredentials, err
 	}, nil
 }
 
+func newStorage(ctx context.Context) (storage.Storage, error) {
+	storageDBDSN := viper.GetString(flagStorageDBDSN)
+
+	switch storageType := viper.GetString(flagStorageType); storageType {
+	case storageTypeKafka:
+		kafkaTrustStorePath := viper.GetString(flagKafkaTrustStorePath)
+		tlsConfig, err := storage.GetTLSConfig(kafkaTrustStorePath)
+		if err != nil {
+			return nil, fmt.Errorf("faile to create tls config: %w", err)
+		}
+
+		producerCredentials := viper.GetString(flagKafkaProducerCredentials)
+		producerCreds, err := parseKafkaAuthCredentials(producerCredentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse kafka credentials: %w", err)
+		}
+
+		consumerCredentials := viper.GetString(flagKafkaConsumerCredentials)
+		consumerCreds, err := parseKafkaAuthCredentials(consumerCredentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse kafka credentials: %w", err)
+		}
+
+		storageTopic := viper.GetString(flagStorageTopic)
+		return storage.NewKafkaStorage(ctx, storageDBDSN, storageTopic, tlsConfig, producerCreds, consumerCreds)
+	case storageTypeBoard:
+		var tlsConfig *tls.Config
+		if boardCA := viper.GetString(flagBoardCA); boardCA != "" {
+			var err error
+			if tlsConfig, err = storage.GetTLSConfig(boardCA); err != nil {
+				return nil, fmt.Errorf("failed to create tls config: %w", err)
+			}
+		}
+
+		var appendToken string
+		if appendTokenFile := viper.GetString(flagBoardAppendTokenFile); appendTokenFile != "" {
+			tokenBz, err := ioutil.ReadFile(appendTokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read board append token file: %w", err)
+			}
+			appendToken = strings.TrimSpace(string(tokenBz))
+		}
+		return storage.NewBoardStorage(storageDBDSN, tlsConfig, appendToken)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", storageType)
+	}
+}
+
+func newHTTPServerConfig() (*client.HTTPServerConfig, error) {
+	httpConfig := &client.HTTPServerConfig{
+		ListenAddr:   viper.GetString(flagListenAddr),
+		TLSCertFile:  viper.GetString(flagHTTPTLSCert),
+		TLSKeyFile:   viper.GetString(flagHTTPTLSKey),
+		ClientCAFile: viper.GetString(flagHTTPClientCA),
+		AuditLogPath: viper.GetString(flagHTTPAuditLog),
+		NoAuth:       viper.GetBool(flagHTTPNoAuth),
+	}
+	if credentialsPath := viper.GetString(flagHTTPCredentials); credentialsPath != "" {
+		credentials, err := client.LoadHTTPCredentials(credentialsPath)
+		if err != nil {
+			return nil, err
+		}
+		httpConfig.Credentials = credentials
+	}
+	if err := httpConfig.Validate(); err != nil {
+		return nil, err
+	}
+	return httpConfig, nil
+}
+
 func startClientCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "start",
 		Short: "starts dc4bc client",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// the HTTP server config is checked before anything is opened, so a misconfigured client fails fast
+			httpConfig, err := newHTTPServerConfig()
+			if err != nil {
+				return fmt.Errorf("failed to init HTTP server config: %w", err)
+			}
+
 			ctx := context.Background()
 			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
 
 			stateDBDSN := viper.GetString(flagStateDBDSN)
 			state, err := client.NewLevelDBState(stateDBDSN)
@@ -128,27 +355,7 @@ func startClientCommand() *cobra.Command {
 				return fmt.Errorf("failed to init state client: %w", err)
 			}
 
-			kafkaTrustStorePath := viper.GetString(flagKafkaTrustStorePath)
-			tlsConfig, err := storage.GetTLSConfig(kafkaTrustStorePath)
-			if err != nil {
-				return fmt.Errorf("faile to create tls config: %w", err)
-			}
-
-			producerCredentials := viper.GetString(flagKafkaProducerCredentials)
-			producerCreds, err := parseKafkaAuthCredentials(producerCredentials)
-			if err != nil {
-				return fmt.Errorf("failed to parse kafka credentials: %w", err)
-			}
-
-			consumerCredentials := viper.GetString(flagKafkaConsumerCredentials)
-			consumerCreds, err := parseKafkaAuthCredentials(consumerCredentials)
-			if err != nil {
-				return fmt.Errorf("failed to parse kafka credentials: %w", err)
-			}
-
-			storageDBDSN := viper.GetString(flagStorageDBDSN)
-			storageTopic := viper.GetString(flagStorageTopic)
-			stg, err := storage.NewKafkaStorage(ctx, storageDBDSN, storageTopic, tlsConfig, producerCreds, consumerCreds)
+			stg, err := newStorage(ctx)
 			if err != nil {
 				return fmt.Errorf("failed to init storage client: %w", err)
 			}
@@ -159,6 +366,10 @@ func startClientCommand() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to init key store: %w", err)
 			}
+			keyStorePassword, err := readPassword(keyStorePasswordSource, "Enter keystore password: ")
+			if err != nil {
+				return err
+			}
 
 			framesDelay := viper.GetInt(flagFramesDelay)
 			chunkSize := viper.GetInt(flagChunkSize)
@@ -166,10 +377,12 @@ func startClientCommand() *cobra.Command {
 			processor.SetDelay(framesDelay)
 			processor.SetChunkSize(chunkSize)
 
-			cli, err := client.NewClient(ctx, username, state, stg, keyStore, processor)
+			cli, err := client.NewClient(ctx, username, state, stg, keyStore, keyStorePassword, processor)
 			if err != nil {
 				return fmt.Errorf("failed to init client: %w", err)
 			}
+			cli.SetAllowLegacyMessages(viper.GetBool(flagAllowLegacyMessages))
+			cli.SetHTTPServerConfig(*httpConfig)
 
 			sigs := make(chan os.Signal, 1)
 			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -210,6 +423,7 @@ func main() {
 	rootCmd.AddCommand(
 		startClientCommand(),
 		genKeyPairCommand(),
+		changePasswordCommand(),
 	)
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatalf("Failed to execute root command: %v", err)
@@ -1,6 +1,8 @@
 package dkg
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
@@ -11,6 +13,7 @@ import (
 	"github.com/corestario/kyber/share"
 	dkg "github.com/corestario/kyber/share/dkg/pedersen"
 	vss "github.com/corestario/kyber/share/vss/pedersen"
+	"github.com/corestario/kyber/sign/schnorr"
 	"github.com/google/go-cmp/cmp"
 	"lukechampine.com/frand"
 )
@@ -25,6 +28,27 @@ type DKG struct {
 	responses *messageStore
 	pubKeys   PKStore
 
+	// justifications are the dealers' answers to complaints, keyed by a dealer
+	justifications map[string][]*dkg.Justification
+	// processedResponses marks responses passed to the instance, keyed by a dealer and a verifier indexes
+	processedResponses map[[2]uint32]bool
+
+	// newPubKeys holds the share holders of a resharing, they replace pubKeys once the resharing is done
+	newPubKeys  PKStore
+	isResharing bool
+
+	// generator describes how the instance was created. The state of the instance isn't exported by kyber,
+	// so the instance is created again from it on restore and the state of its verifiers is set back
+	generator *generatorConfig
+	// plainDeals are the deals the verifiers of the instance got, keyed by a dealer index
+	plainDeals map[uint32]*vss.Deal
+	// complaints marks the dealers whose verifiers were replaced by a complaint
+	complaints map[uint32]bool
+	// badJustifications are the justifications which made the instance mark their dealers as bad
+	badJustifications map[uint32]*dkg.Justification
+	dealsIssued       bool
+	timeout           bool
+
 	pubKey        kyber.Point
 	secKey        kyber.Scalar
 	suite         vss.Suite
@@ -45,10 +69,22 @@ func Init(suite vss.Suite, pubKey kyber.Point, secKey kyber.Scalar) *DKG {
 
 	d.deals = make(map[string]*dkg.Deal)
 	d.commits = make(map[string][]kyber.Point)
+	d.justifications = make(map[string][]*dkg.Justification)
+	d.processedResponses = make(map[[2]uint32]bool)
+	d.resetInstanceState()
 
 	return &d
 }
 
+// resetInstanceState drops the tracked state of the verifiers before a new instance is created
+func (d *DKG) resetInstanceState() {
+	d.plainDeals = make(map[uint32]*vss.Deal)
+	d.complaints = make(map[uint32]bool)
+	d.badJustifications = make(map[uint32]*dkg.Justification)
+	d.dealsIssued = false
+	d.timeout = false
+}
+
 func (d *DKG) Equals(other *DKG) error {
 	for addr, commits := range d.commits {
 		otherCommits := other.commits[addr]
@@ -85,6 +121,10 @@ func (d *DKG) GetPubKeyByParticipant(participant string) (kyber.Point, error) {
 	return pk, nil
 }
 
+func (d *DKG) GetIndexByParticipant(participant string) int {
+	return d.pubKeys.GetIndexByParticipant(participant)
+}
+
 func (d *DKG) GetParticipantByIndex(index int) string {
 	return d.pubKeys.GetParticipantByIndex(index)
 }
@@ -104,9 +144,28 @@ func (d *DKG) StorePubKey(participant string, pid int, pk kyber.Point) bool {
 	})
 }
 
+func (d *DKG) StoreNewPubKey(participant string, pid int, pk kyber.Point) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.newPubKeys.Add(&PK2Participant{
+		Participant:   participant,
+		PK:            pk,
+		ParticipantID: pid,
+	})
+}
+
+func (d *DKG) GetNewPKByIndex(index int) kyber.Point {
+	return d.newPubKeys.GetPKByIndex(index)
+}
+
 func (d *DKG) calcParticipantID() int {
-	for idx, p := range d.pubKeys {
-		if p.PK.Equal(d.pubKey) {
+	return calcParticipantID(d.pubKeys, d.pubKey)
+}
+
+func calcParticipantID(pubKeys PKStore, pubKey kyber.Point) int {
+	for idx, p := range pubKeys {
+		if p.PK.Equal(pubKey) {
 			return idx
 		}
 	}
@@ -130,12 +189,132 @@ func (d *DKG) InitDKGInstance(seed []byte) (err error) {
 
 	d.responses = newMessageStore(int(math.Pow(float64(participantsCount)-1, 2)))
 
-	reader := frand.NewCustom(seed, 32, 20)
+	return d.newGenerator(&generatorConfig{
+		seed:      seed,
+		oldNodes:  publicKeys,
+		newNodes:  publicKeys,
+		threshold: d.Threshold,
+	})
+}
 
-	d.instance, err = dkg.NewDistKeyGenerator(d.suite, d.secKey, publicKeys, d.Threshold, reader)
-	if err != nil {
+// generatorConfig holds the inputs the kyber instance is created from
+type generatorConfig struct {
+	seed         []byte
+	oldNodes     []kyber.Point
+	newNodes     []kyber.Point
+	threshold    int
+	oldThreshold int
+	keyring      *BLSKeyring
+	publicCoeffs []kyber.Point
+	resharing    bool
+}
+
+// newGenerator creates the kyber instance, the dealer of the instance is deterministic for the seed
+func (d *DKG) newGenerator(g *generatorConfig) (err error) {
+	d.resetInstanceState()
+	if !g.resharing {
+		d.instance, err = dkg.NewDistKeyGenerator(d.suite, d.secKey, g.newNodes, g.threshold,
+			frand.NewCustom(g.seed, 32, 20))
+		if err != nil {
+			return err
+		}
+		d.generator = g
+		return nil
+	}
+
+	config := &dkg.Config{
+		Suite:          d.suite,
+		Longterm:       d.secKey,
+		OldNodes:       g.oldNodes,
+		NewNodes:       g.newNodes,
+		Threshold:      g.threshold,
+		OldThreshold:   g.oldThreshold,
+		Reader:         frand.NewCustom(g.seed, 32, 20),
+		UserReaderOnly: true,
+	}
+	if g.keyring != nil {
+		_, commits := g.keyring.PubPoly.Info()
+		config.Share = &dkg.DistKeyShare{
+			Commits: commits,
+			Share:   g.keyring.Share,
+		}
+	} else {
+		config.PublicCoeffs = g.publicCoeffs
+	}
+
+	if d.instance, err = dkg.NewDistKeyHandler(config); err != nil {
 		return err
 	}
+	d.generator = g
+	return nil
+}
+
+// InitResharingInstance prepares the instance to move the round key to the participants
+// stored with StoreNewPubKey. A dealer must provide its current share, the other new share holders
+// need only the public coefficients of the current key
+func (d *DKG) InitResharingInstance(keyring *BLSKeyring, publicCoeffs []kyber.Point, oldThreshold, newThreshold int, seed []byte) (err error) {
+	sort.Sort(d.pubKeys)
+	sort.Sort(d.newPubKeys)
+
+	if calcParticipantID(d.newPubKeys, d.pubKey) < 0 && keyring == nil {
+		return fmt.Errorf("participant is neither a dealer nor a receiver of the resharing")
+	}
+
+	d.deals = make(map[string]*dkg.Deal)
+	d.commits = make(map[string][]kyber.Point)
+	d.responses = newMessageStore(len(d.pubKeys))
+	d.justifications = make(map[string][]*dkg.Justification)
+	d.processedResponses = make(map[[2]uint32]bool)
+	d.isResharing = true
+
+	g := &generatorConfig{
+		seed:         seed,
+		oldNodes:     d.pubKeys.GetPKs(),
+		newNodes:     d.newPubKeys.GetPKs(),
+		threshold:    newThreshold,
+		oldThreshold: oldThreshold,
+		resharing:    true,
+	}
+	if keyring != nil {
+		g.keyring = keyring
+	} else {
+		g.publicCoeffs = publicCoeffs
+	}
+	return d.newGenerator(g)
+}
+
+// ResetParticipants drops the participants of the round before a resharing,
+// both the current and the new ones are stored again from the resharing proposal
+func (d *DKG) ResetParticipants() {
+	d.Lock()
+	defer d.Unlock()
+
+	d.pubKeys = nil
+	d.newPubKeys = nil
+	d.isResharing = false
+}
+
+// IsResharing reports whether the instance was initialized for a resharing
+func (d *DKG) IsResharing() bool {
+	return d.isResharing
+}
+
+// SwitchToNewParticipants makes the new share holders of a resharing the participants of the round
+func (d *DKG) SwitchToNewParticipants() error {
+	d.Lock()
+	defer d.Unlock()
+
+	participantID := calcParticipantID(d.newPubKeys, d.pubKey)
+	if participantID < 0 {
+		return fmt.Errorf("failed to determine participant index")
+	}
+
+	d.pubKeys = d.newPubKeys
+	d.newPubKeys = nil
+	d.ParticipantID = participantID
+	d.N = len(d.pubKeys)
+	d.Threshold = d.instance.GetConfig().Threshold
+
 	return nil
 }
 
@@ -150,11 +329,13 @@ func (d *DKG) StoreCommits(participant string, commits []kyber.Point) {
 	d.commits[participant] = commits
 }
 
+// GetDeals returns deals of the instance to send, own deal is processed by the instance at the same time
 func (d *DKG) GetDeals() (map[int]*dkg.Deal, error) {
 	deals, err := d.instance.Deals()
 	if err != nil {
 		return nil, err
 	}
+	d.dealsIssued = true
 	return deals, nil
 }
 
@@ -165,34 +346,176 @@ func (d *DKG) StoreDeal(participant string, deal *dkg.Deal) {
 	d.deals[participant] = deal
 }
 
+// ProcessDeals verifies the stored deals and returns a response to every one of them.
+// A deal which is missing, can't be decrypted or doesn't match the broadcasted commits
+// gets a complaint, the dealer has to justify it by revealing the deal to everyone.
+// A resharing has no justification phase, so there a bad deal fails the whole round
 func (d *DKG) ProcessDeals() ([]*dkg.Response, error) {
 	responses := make([]*dkg.Response, 0)
-	for _, deal := range d.deals {
-		if deal.Index == uint32(d.ParticipantID) {
+	for participant, deal := range d.deals {
+		if d.isResharing {
+			resp, err := d.processResharingDeal(deal)
+			if err != nil {
+				return nil, err
+			}
+			responses = append(responses, resp)
 			continue
 		}
-		resp, err := d.instance.ProcessDeal(deal)
+
+		pk, err := d.pubKeys.GetPKByParticipant(participant)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to get pk for participant %s: %w", participant, err)
+		}
+		dealerIndex := calcParticipantID(d.pubKeys, pk)
+		if dealerIndex == d.ParticipantID {
+			continue
 		}
 
-		// Commits verification.
-		allVerifiers := d.instance.Verifiers()
-		verifier := allVerifiers[deal.Index]
-		commitsOK, err := d.processDealCommits(verifier, deal)
-		if err != nil {
-			return nil, err
+		if deal == nil || deal.Deal == nil || deal.Index != uint32(dealerIndex) {
+			resp, err := d.complain(participant, dealerIndex)
+			if err != nil {
+				return nil, err
+			}
+			responses = append(responses, resp)
+			continue
 		}
 
-		// If something goes wrong, party complains.
-		if !resp.Response.Status || !commitsOK {
-			return nil, fmt.Errorf("failed to process deals")
+		resp, err := d.instance.ProcessDeal(deal)
+		d.recordPlainDeal(deal.Index, func(v *vss.Verifier) (*vss.Deal, error) {
+			return v.DecryptDeal(deal.Deal)
+		})
+		if err == nil && resp.Response.Status == vss.StatusApproval {
+			var commitsOK bool
+			commitsOK, err = d.processDealCommits(d.instance.Verifiers()[deal.Index], deal)
+			if err == nil && !commitsOK {
+				err = fmt.Errorf("commits of %s are missing", participant)
+			}
+		}
+		if err != nil || resp.Response.Status == vss.StatusComplaint {
+			if resp, err = d.complain(participant, dealerIndex); err != nil {
+				return nil, err
+			}
 		}
 		responses = append(responses, resp)
 	}
 	return responses, nil
 }
 
+// processResharingDeal processes a deal of a resharing. There is no complaint and justification phase
+// for a resharing: a bad deal fails the resharing, and the round is rolled back to the current share holders
+func (d *DKG) processResharingDeal(deal *dkg.Deal) (*dkg.Response, error) {
+	// Deal indexes of a resharing are the dealers' indexes in the old participants list,
+	// own deal is processed by the instance itself and never stored
+	resp, err := d.instance.ProcessDeal(deal)
+	d.recordPlainDeal(deal.Index, func(v *vss.Verifier) (*vss.Deal, error) {
+		return v.DecryptDeal(deal.Deal)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Commits verification.
+	allVerifiers := d.instance.Verifiers()
+	verifier := allVerifiers[deal.Index]
+	commitsOK, err := d.processDealCommits(verifier, deal)
+	if err != nil {
+		return nil, err
+	}
+
+	// If something goes wrong, party complains.
+	if !resp.Response.Status || !commitsOK {
+		return nil, fmt.Errorf("failed to process deals")
+	}
+	return resp, nil
+}
+
+// complain returns a signed complaint against the deal of the participant. The verifier of the deal
+// is replaced with an empty one, so the share is taken from the dealer's justification only
+func (d *DKG) complain(participant string, dealerIndex int) (*dkg.Response, error) {
+	verifier, err := d.emptyVerifier(dealerIndex)
+	if err != nil {
+		return nil, err
+	}
+	dealerPK := d.pubKeys.GetPKByIndex(dealerIndex)
+
+	resp := &vss.Response{
+		SessionID: d.sessionID(dealerPK, d.commits[participant]),
+		Index:     uint32(d.ParticipantID),
+		Status:    vss.StatusComplaint,
+	}
+	if resp.Signature, err = schnorr.Sign(d.suite, d.secKey, resp.Hash(d.suite)); err != nil {
+		return nil, fmt.Errorf("failed to sign a complaint: %w", err)
+	}
+	verifier.Responses()[resp.Index] = resp
+	d.instance.Verifiers()[uint32(dealerIndex)] = verifier
+	d.complaints[uint32(dealerIndex)] = true
+	delete(d.plainDeals, uint32(dealerIndex))
+
+	return &dkg.Response{
+		Index:    uint32(dealerIndex),
+		Response: resp,
+	}, nil
+}
+
+// emptyVerifier returns a verifier of the dealer's deal which has got no deal
+func (d *DKG) emptyVerifier(dealerIndex int) (*vss.Verifier, error) {
+	verifier, err := vss.NewVerifier(d.suite, d.secKey, d.pubKeys.GetPKByIndex(dealerIndex), d.pubKeys.GetPKs())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a verifier: %w", err)
+	}
+	verifier.SetThreshold(d.Threshold)
+	// the dealer doesn't respond to its own deal
+	verifier.UnsafeSetResponseDKG(uint32(dealerIndex), vss.StatusApproval)
+	return verifier, nil
+}
+
+// recordPlainDeal keeps the deal a verifier of the instance has just got, kyber doesn't export it.
+// A verifier keeps the first deal it got, even if the deal turned out to be wrong
+func (d *DKG) recordPlainDeal(dealerIndex uint32, getDeal func(v *vss.Verifier) (*vss.Deal, error)) {
+	verifier, ok := d.instance.Verifiers()[dealerIndex]
+	if !ok || d.plainDeals[dealerIndex] != nil || verifier.SessionID() == nil {
+		return
+	}
+	if deal, err := getDeal(verifier); err == nil {
+		d.plainDeals[dealerIndex] = deal
+	}
+}
+
+// sessionID repeats the session ID computation of vss, the dealer signs its deals for this ID
+func (d *DKG) sessionID(dealerPK kyber.Point, commits []kyber.Point) []byte {
+	h := d.suite.Hash()
+	_, _ = dealerPK.MarshalTo(h)
+	for _, pk := range d.pubKeys.GetPKs() {
+		_, _ = pk.MarshalTo(h)
+	}
+	for _, commit := range commits {
+		_, _ = commit.MarshalTo(h)
+	}
+	_ = binary.Write(h, binary.LittleEndian, uint32(d.Threshold))
+	return h.Sum(nil)
+}
+
+// Complaints returns IDs of participants whose deals got a complaint in the given responses
+func (d *DKG) Complaints(responses []*dkg.Response) []int {
+	complaints := make([]int, 0)
+	for _, resp := range responses {
+		if resp.Response.Status == vss.StatusComplaint && int(resp.Index) < len(d.pubKeys) {
+			complaints = append(complaints, d.pubKeys[resp.Index].ParticipantID)
+		}
+	}
+	return complaints
+}
+
+// IsAccused reports whether own deal got a complaint in the given responses
+func (d *DKG) IsAccused(responses []*dkg.Response) bool {
+	for _, resp := range responses {
+		if resp.Response.Status == vss.StatusComplaint && int(resp.Index) == d.ParticipantID {
+			return true
+		}
+	}
+	return false
+}
+
 func (d *DKG) StoreResponses(participant string, responses []*dkg.Response) {
 	d.Lock()
 	defer d.Unlock()
@@ -202,28 +525,153 @@ func (d *DKG) StoreResponses(participant string, responses []*dkg.Response) {
 	}
 }
 
+// StoreJustifications stores the dealer's answers to complaints against its deal
+func (d *DKG) StoreJustifications(participant string, justifications []*dkg.Justification) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.justifications[participant] = append(d.justifications[participant], justifications...)
+}
+
+// JustifyDeal processes the stored responses and returns justifications for complaints against own deal
+func (d *DKG) JustifyDeal() ([]*dkg.Justification, error) {
+	justifications, err := d.processResponses()
+	if err != nil {
+		return nil, err
+	}
+	return justifications, nil
+}
+
+// ProcessResponses processes the stored responses and justifications and checks that the instance
+// has enough certified deals to build the key. A deal with an unanswered or a wrongly answered
+// complaint is left out of the key
 func (d *DKG) ProcessResponses() error {
+	if _, err := d.processResponses(); err != nil {
+		return err
+	}
+
+	if !d.isResharing {
+		// own share of a deal with a complaint is revealed in the justification, and only then
+		// the responses to the deal can be processed
+		d.processJustifications(true)
+		if _, err := d.processResponses(); err != nil {
+			return err
+		}
+		d.processJustifications(false)
+		// the round is over, so deals may lack responses from absent participants
+		d.instance.SetTimeout()
+		d.timeout = true
+	}
+
+	if !d.isCertified() {
+		return fmt.Errorf("praticipant %v is not certified", d.ParticipantID)
+	}
+
+	return nil
+}
+
+// processResponses passes the stored responses to the instance and returns justifications for
+// complaints against own deal. A response to a deal we complained about waits for the justification
+func (d *DKG) processResponses() ([]*dkg.Justification, error) {
+	justifications := make([]*dkg.Justification, 0)
 	for _, peerResponses := range d.responses.indexToData {
 		for _, response := range peerResponses {
 			resp := response.(*dkg.Response)
 			if int(resp.Response.Index) == d.ParticipantID {
 				continue
 			}
+			key := [2]uint32{resp.Index, resp.Response.Index}
+			if d.processedResponses[key] {
+				continue
+			}
 
-			_, err := d.instance.ProcessResponse(resp)
+			j, err := d.instance.ProcessResponse(resp)
 			if err != nil {
-				return fmt.Errorf("failed to ProcessResponse: %w", err)
+				if d.isResharing {
+					return nil, fmt.Errorf("failed to ProcessResponse: %w", err)
+				}
+				if errors.Is(err, vss.ErrNoDealBeforeResponse) {
+					continue
+				}
+			}
+			d.processedResponses[key] = true
+			if j != nil {
+				justifications = append(justifications, j)
 			}
 		}
 	}
+	return justifications, nil
+}
 
-	if !d.instance.Certified() {
-		return fmt.Errorf("praticipant %v is not certified", d.ParticipantID)
+// processJustifications passes the valid stored justifications to the instance, either the ones
+// revealing own shares or all the others. A dealer with an invalid justification is marked as bad
+func (d *DKG) processJustifications(own bool) {
+	for participant, justifications := range d.justifications {
+		for _, j := range justifications {
+			if err := d.verifyJustification(participant, j); err != nil {
+				continue
+			}
+			if (int(j.Justification.Index) == d.ParticipantID) != own {
+				continue
+			}
+			// a justification for a complaint which fails the check marks the dealer as bad
+			var complained bool
+			if verifier, ok := d.instance.Verifiers()[j.Index]; ok {
+				resp, ok := verifier.Responses()[j.Justification.Index]
+				complained = ok && resp.Status == vss.StatusComplaint
+			}
+			if err := d.instance.ProcessJustification(j); err != nil && complained &&
+				d.badJustifications[j.Index] == nil {
+				d.badJustifications[j.Index] = j
+			}
+			d.recordPlainDeal(j.Index, func(*vss.Verifier) (*vss.Deal, error) {
+				return j.Justification.Deal, nil
+			})
+		}
+	}
+}
+
+// verifyJustification checks that the justification is signed by the participant and reveals a deal
+// made for the broadcasted commits. A deal of a justification is checked against them by the instance
+func (d *DKG) verifyJustification(participant string, j *dkg.Justification) error {
+	pk, err := d.pubKeys.GetPKByParticipant(participant)
+	if err != nil {
+		return err
+	}
+	dealerIndex := calcParticipantID(d.pubKeys, pk)
+	if int(j.Index) != dealerIndex || dealerIndex == d.ParticipantID {
+		return fmt.Errorf("unexpected justification index %d", j.Index)
+	}
+	if j.Justification == nil || j.Justification.Deal == nil || j.Justification.Deal.SecShare == nil {
+		return errors.New("justification without a deal")
+	}
+	if err = schnorr.Verify(d.suite, pk, j.Justification.Hash(d.suite), j.Justification.Signature); err != nil {
+		return fmt.Errorf("failed to verify a justification signature: %w", err)
 	}
 
+	deal := j.Justification.Deal
+	if deal.SecShare.I != int(j.Justification.Index) {
+		return errors.New("justification deal is for another participant")
+	}
+	sid := d.sessionID(pk, d.commits[participant])
+	if !bytes.Equal(sid, j.Justification.SessionID) || !bytes.Equal(sid, deal.SessionID) {
+		return errors.New("justification deal is not made for the broadcasted commits")
+	}
 	return nil
 }
 
+// isCertified checks that the instance has enough certified deals. Only a subset of
+// the old participants deals during a resharing, so a threshold of them is enough,
+// and a DKG round is certified when a threshold of deals survived complaints
+func (d *DKG) isCertified() bool {
+	return d.instance.ThresholdCertified()
+}
+
+// QUAL returns indexes of participants whose deals make up the key
+func (d *DKG) QUAL() []int {
+	return d.instance.QUAL()
+}
+
 func (d *DKG) processDealCommits(verifier *vss.Verifier, deal *dkg.Deal) (bool, error) {
 	decryptedDeal, err := verifier.DecryptDeal(deal.Deal)
 	if err != nil {
@@ -272,7 +720,7 @@ func (d *DKG) GetDistributedPublicKey() (kyber.Point, error) {
 }
 
 func (d *DKG) GetBLSKeyring() (*BLSKeyring, error) {
-	if d.instance == nil || !d.instance.Certified() {
+	if d.instance == nil || !d.isCertified() {
 		return nil, fmt.Errorf("dkg instance is not ready")
 	}
 
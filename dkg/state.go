@@ -0,0 +1,461 @@
+package dkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/corestario/kyber"
+	"github.com/corestario/kyber/share"
+	dkg "github.com/corestario/kyber/share/dkg/pedersen"
+	vss "github.com/corestario/kyber/share/vss/pedersen"
+)
+
+// stateVersion is the version of the encoded DKG state. The state is a snapshot of the instance:
+// the stored messages, the config of the kyber instance and the state of its verifiers
+const stateVersion = 1
+
+type encodedPubKey struct {
+	Participant   string
+	ParticipantID int
+	PubKey        []byte
+}
+
+// encodedDeal is a plain deal of a verifier, it holds a secret share
+type encodedDeal struct {
+	SessionID   []byte
+	ShareIndex  int
+	Share       []byte
+	T           uint32
+	Commitments [][]byte
+}
+
+// encodedVerifier is the state of a verifier of a dealer's deal. Kyber doesn't export the state of a verifier,
+// so the deal is set back with VerifyDeal, the responses are set back as is, and a dealer marked as bad
+// is marked again by its bad justification
+type encodedVerifier struct {
+	DealerIndex      uint32
+	Complained       bool            `json:",omitempty"`
+	Deal             *encodedDeal    `json:",omitempty"`
+	Responses        []*vss.Response `json:",omitempty"`
+	BadJustification []byte          `json:",omitempty"`
+}
+
+type encodedGenerator struct {
+	Seed         []byte
+	OldNodes     [][]byte
+	NewNodes     [][]byte
+	Threshold    int
+	OldThreshold int      `json:",omitempty"`
+	Keyring      []byte   `json:",omitempty"`
+	PublicCoeffs [][]byte `json:",omitempty"`
+	Resharing    bool     `json:",omitempty"`
+
+	DealsIssued bool `json:",omitempty"`
+	Timeout     bool `json:",omitempty"`
+	Verifiers   []encodedVerifier
+}
+
+type encodedState struct {
+	Version       int
+	ParticipantID int
+	N             int
+	Threshold     int
+
+	PubKeys              []encodedPubKey            `json:",omitempty"`
+	NewPubKeys           []encodedPubKey            `json:",omitempty"`
+	IsResharing          bool                       `json:",omitempty"`
+	Commits              map[string][][]byte        `json:",omitempty"`
+	Deals                map[string]*dkg.Deal       `json:",omitempty"`
+	Responses            map[string][]*dkg.Response // null until the instance is initialized
+	MaxResponsesFromPeer int                        `json:",omitempty"`
+	Justifications       map[string][]byte          `json:",omitempty"`
+	ProcessedResponses   [][2]uint32                `json:",omitempty"`
+	Generator            *encodedGenerator          `json:",omitempty"`
+}
+
+// MarshalState encodes the state of the instance, it contains secret data and must be kept encrypted
+func (d *DKG) MarshalState() ([]byte, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	state := encodedState{
+		Version:        stateVersion,
+		ParticipantID:  d.ParticipantID,
+		N:              d.N,
+		Threshold:      d.Threshold,
+		IsResharing:    d.isResharing,
+		Commits:        make(map[string][][]byte, len(d.commits)),
+		Deals:          d.deals,
+		Justifications: make(map[string][]byte, len(d.justifications)),
+	}
+
+	var err error
+	if state.PubKeys, err = encodePubKeys(d.pubKeys); err != nil {
+		return nil, fmt.Errorf("failed to encode pub keys: %w", err)
+	}
+	if state.NewPubKeys, err = encodePubKeys(d.newPubKeys); err != nil {
+		return nil, fmt.Errorf("failed to encode new pub keys: %w", err)
+	}
+	for participant, commits := range d.commits {
+		if state.Commits[participant], err = marshalPoints(commits); err != nil {
+			return nil, fmt.Errorf("failed to marshal commits of %s: %w", participant, err)
+		}
+	}
+	if d.responses != nil {
+		state.MaxResponsesFromPeer = d.responses.maxMessagesFromPeer
+		state.Responses = make(map[string][]*dkg.Response, len(d.responses.addrToData))
+		for participant, responses := range d.responses.addrToData {
+			for _, resp := range responses {
+				state.Responses[participant] = append(state.Responses[participant], resp.(*dkg.Response))
+			}
+		}
+	}
+	for participant, justifications := range d.justifications {
+		if state.Justifications[participant], err = EncodeJustifications(justifications); err != nil {
+			return nil, fmt.Errorf("failed to encode justifications of %s: %w", participant, err)
+		}
+	}
+	for key := range d.processedResponses {
+		state.ProcessedResponses = append(state.ProcessedResponses, key)
+	}
+	sort.Slice(state.ProcessedResponses, func(i, j int) bool {
+		a, b := state.ProcessedResponses[i], state.ProcessedResponses[j]
+		return a[0] < b[0] || a[0] == b[0] && a[1] < b[1]
+	})
+
+	if d.instance != nil && d.generator != nil {
+		if state.Generator, err = d.encodeGenerator(); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(state)
+}
+
+func (d *DKG) encodeGenerator() (*encodedGenerator, error) {
+	g := d.generator
+	encoded := &encodedGenerator{
+		Seed:         g.seed,
+		Threshold:    g.threshold,
+		OldThreshold: g.oldThreshold,
+		Resharing:    g.resharing,
+		DealsIssued:  d.dealsIssued,
+		Timeout:      d.timeout,
+	}
+
+	var err error
+	if encoded.OldNodes, err = marshalPoints(g.oldNodes); err != nil {
+		return nil, fmt.Errorf("failed to marshal old nodes: %w", err)
+	}
+	if encoded.NewNodes, err = marshalPoints(g.newNodes); err != nil {
+		return nil, fmt.Errorf("failed to marshal new nodes: %w", err)
+	}
+	if encoded.PublicCoeffs, err = marshalPoints(g.publicCoeffs); err != nil {
+		return nil, fmt.Errorf("failed to marshal public coefficients: %w", err)
+	}
+	if g.keyring != nil {
+		if encoded.Keyring, err = g.keyring.Bytes(); err != nil {
+			return nil, fmt.Errorf("failed to encode keyring: %w", err)
+		}
+	}
+
+	for dealerIndex, verifier := range d.instance.Verifiers() {
+		encodedVerifier := encodedVerifier{
+			DealerIndex: dealerIndex,
+			Complained:  d.complaints[dealerIndex],
+		}
+		if deal := d.plainDeals[dealerIndex]; deal != nil {
+			if encodedVerifier.Deal, err = encodeDeal(deal); err != nil {
+				return nil, fmt.Errorf("failed to encode deal of %d: %w", dealerIndex, err)
+			}
+		}
+		for _, resp := range verifier.Responses() {
+			encodedVerifier.Responses = append(encodedVerifier.Responses, resp)
+		}
+		sort.Slice(encodedVerifier.Responses, func(i, j int) bool {
+			return encodedVerifier.Responses[i].Index < encodedVerifier.Responses[j].Index
+		})
+		if j := d.badJustifications[dealerIndex]; j != nil {
+			if encodedVerifier.BadJustification, err = EncodeJustifications([]*dkg.Justification{j}); err != nil {
+				return nil, fmt.Errorf("failed to encode justification of %d: %w", dealerIndex, err)
+			}
+		}
+		encoded.Verifiers = append(encoded.Verifiers, encodedVerifier)
+	}
+	sort.Slice(encoded.Verifiers, func(i, j int) bool {
+		return encoded.Verifiers[i].DealerIndex < encoded.Verifiers[j].DealerIndex
+	})
+	return encoded, nil
+}
+
+// RestoreState rebuilds an instance from the state encoded by MarshalState.
+// The suite and the keys must be the same as the ones the instance was initialized with
+func RestoreState(suite vss.Suite, pubKey kyber.Point, secKey kyber.Scalar, data []byte) (*DKG, error) {
+	var state encodedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported state version %d", state.Version)
+	}
+
+	d := Init(suite, pubKey, secKey)
+	if err := d.restoreSnapshot(&state); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DKG) restoreSnapshot(state *encodedState) error {
+	d.ParticipantID, d.N, d.Threshold = state.ParticipantID, state.N, state.Threshold
+	d.isResharing = state.IsResharing
+
+	var err error
+	if d.pubKeys, err = decodePubKeys(d.suite, state.PubKeys); err != nil {
+		return fmt.Errorf("failed to decode pub keys: %w", err)
+	}
+	if d.newPubKeys, err = decodePubKeys(d.suite, state.NewPubKeys); err != nil {
+		return fmt.Errorf("failed to decode new pub keys: %w", err)
+	}
+	for participant, commitsBz := range state.Commits {
+		if d.commits[participant], err = unmarshalPoints(d.suite, commitsBz); err != nil {
+			return fmt.Errorf("failed to unmarshal commits of %s: %w", participant, err)
+		}
+	}
+	for participant, deal := range state.Deals {
+		d.deals[participant] = deal
+	}
+	if state.Responses != nil {
+		d.responses = newMessageStore(state.MaxResponsesFromPeer)
+		for participant, responses := range state.Responses {
+			for _, resp := range responses {
+				d.responses.add(participant, int(resp.Response.Index), resp)
+			}
+		}
+	}
+	for participant, justificationsBz := range state.Justifications {
+		if d.justifications[participant], err = DecodeJustifications(d.suite, justificationsBz); err != nil {
+			return fmt.Errorf("failed to decode justifications of %s: %w", participant, err)
+		}
+	}
+	for _, key := range state.ProcessedResponses {
+		d.processedResponses[key] = true
+	}
+
+	if state.Generator != nil {
+		if err = d.restoreGenerator(state.Generator); err != nil {
+			return fmt.Errorf("failed to restore dkg instance: %w", err)
+		}
+	}
+	return nil
+}
+
+// restoreGenerator creates the kyber instance again from its config and sets back the state of its verifiers.
+// The own deals aren't stored: the dealer is deterministic for the seed, so they are made again the same
+func (d *DKG) restoreGenerator(encoded *encodedGenerator) error {
+	g := &generatorConfig{
+		seed:         encoded.Seed,
+		threshold:    encoded.Threshold,
+		oldThreshold: encoded.OldThreshold,
+		resharing:    encoded.Resharing,
+	}
+
+	var err error
+	if g.oldNodes, err = unmarshalPoints(d.suite, encoded.OldNodes); err != nil {
+		return fmt.Errorf("failed to unmarshal old nodes: %w", err)
+	}
+	if g.newNodes, err = unmarshalPoints(d.suite, encoded.NewNodes); err != nil {
+		return fmt.Errorf("failed to unmarshal new nodes: %w", err)
+	}
+	if g.publicCoeffs, err = unmarshalPoints(d.suite, encoded.PublicCoeffs); err != nil {
+		return fmt.Errorf("failed to unmarshal public coefficients: %w", err)
+	}
+	if len(encoded.Keyring) > 0 {
+		if g.keyring, err = LoadBLSKeyringFromBytes(d.suite, encoded.Keyring); err != nil {
+			return fmt.Errorf("failed to load keyring: %w", err)
+		}
+	}
+	if err = d.newGenerator(g); err != nil {
+		return err
+	}
+
+	// the own deal is given to the own verifier by the instance itself, it's the same deal as before the restore
+	if encoded.DealsIssued {
+		if _, err = d.instance.Deals(); err != nil {
+			return fmt.Errorf("failed to make deals: %w", err)
+		}
+		d.dealsIssued = true
+	}
+
+	for _, encodedVerifier := range encoded.Verifiers {
+		dealerIndex := encodedVerifier.DealerIndex
+		if encodedVerifier.Complained {
+			verifier, err := d.emptyVerifier(int(dealerIndex))
+			if err != nil {
+				return err
+			}
+			d.instance.Verifiers()[dealerIndex] = verifier
+			d.complaints[dealerIndex] = true
+		}
+		verifier, ok := d.instance.Verifiers()[dealerIndex]
+		if !ok {
+			return fmt.Errorf("unknown verifier of dealer %d", dealerIndex)
+		}
+
+		if encodedVerifier.Deal != nil {
+			deal, err := decodeDeal(d.suite, encodedVerifier.Deal)
+			if err != nil {
+				return fmt.Errorf("failed to decode deal of %d: %w", dealerIndex, err)
+			}
+			// a wrong deal is kept by the verifier as well
+			_ = verifier.VerifyDeal(deal, false)
+			d.plainDeals[dealerIndex] = deal
+		}
+
+		if len(encodedVerifier.BadJustification) > 0 {
+			justifications, err := DecodeJustifications(d.suite, encodedVerifier.BadJustification)
+			if err != nil || len(justifications) != 1 {
+				return fmt.Errorf("failed to decode justification of %d: %v", dealerIndex, err)
+			}
+			j := justifications[0]
+			verifier.Responses()[j.Justification.Index] = &vss.Response{Status: vss.StatusComplaint}
+			if err = verifier.ProcessJustification(j.Justification); err == nil {
+				return fmt.Errorf("justification of %d is expected to fail", dealerIndex)
+			}
+			d.badJustifications[dealerIndex] = j
+		}
+
+		responses := verifier.Responses()
+		for index := range responses {
+			delete(responses, index)
+		}
+		for _, resp := range encodedVerifier.Responses {
+			responses[resp.Index] = resp
+		}
+	}
+
+	// a dealer which leaves the round in a resharing keeps the responses to its deal out of the verifiers
+	if g.resharing && d.responses != nil && !containsPoint(g.newNodes, d.pubKey) {
+		for _, peerResponses := range d.responses.indexToData {
+			for _, response := range peerResponses {
+				resp := response.(*dkg.Response)
+				if d.processedResponses[[2]uint32{resp.Index, resp.Response.Index}] {
+					_, _ = d.instance.ProcessResponse(resp)
+				}
+			}
+		}
+	}
+
+	if encoded.Timeout {
+		d.instance.SetTimeout()
+		d.timeout = true
+	}
+	return nil
+}
+
+func containsPoint(points []kyber.Point, point kyber.Point) bool {
+	for _, p := range points {
+		if p.Equal(point) {
+			return true
+		}
+	}
+	return false
+}
+
+func encodePubKeys(pubKeys PKStore) ([]encodedPubKey, error) {
+	encoded := make([]encodedPubKey, 0, len(pubKeys))
+	for _, pk := range pubKeys {
+		pubKey, err := pk.PK.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, encodedPubKey{
+			Participant:   pk.Participant,
+			ParticipantID: pk.ParticipantID,
+			PubKey:        pubKey,
+		})
+	}
+	return encoded, nil
+}
+
+func decodePubKeys(suite vss.Suite, encoded []encodedPubKey) (PKStore, error) {
+	var pubKeys PKStore
+	for _, pk := range encoded {
+		pubKey := suite.Point()
+		if err := pubKey.UnmarshalBinary(pk.PubKey); err != nil {
+			return nil, err
+		}
+		pubKeys = append(pubKeys, &PK2Participant{
+			Participant:   pk.Participant,
+			PK:            pubKey,
+			ParticipantID: pk.ParticipantID,
+		})
+	}
+	return pubKeys, nil
+}
+
+func encodeDeal(deal *vss.Deal) (*encodedDeal, error) {
+	shareBz, err := deal.SecShare.V.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal share: %w", err)
+	}
+	commitments, err := marshalPoints(deal.Commitments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal commitments: %w", err)
+	}
+	return &encodedDeal{
+		SessionID:   deal.SessionID,
+		ShareIndex:  deal.SecShare.I,
+		Share:       shareBz,
+		T:           deal.T,
+		Commitments: commitments,
+	}, nil
+}
+
+func decodeDeal(suite vss.Suite, encoded *encodedDeal) (*vss.Deal, error) {
+	shareV := suite.Scalar()
+	if err := shareV.UnmarshalBinary(encoded.Share); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal share: %w", err)
+	}
+	commitments, err := unmarshalPoints(suite, encoded.Commitments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal commitments: %w", err)
+	}
+	return &vss.Deal{
+		SessionID:   encoded.SessionID,
+		SecShare:    &share.PriShare{I: encoded.ShareIndex, V: shareV},
+		T:           encoded.T,
+		Commitments: commitments,
+	}, nil
+}
+
+func marshalPoints(points []kyber.Point) ([][]byte, error) {
+	if points == nil {
+		return nil, nil
+	}
+	pointsBz := make([][]byte, 0, len(points))
+	for _, point := range points {
+		pointBz, err := point.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		pointsBz = append(pointsBz, pointBz)
+	}
+	return pointsBz, nil
+}
+
+func unmarshalPoints(suite vss.Suite, pointsBz [][]byte) ([]kyber.Point, error) {
+	if pointsBz == nil {
+		return nil, nil
+	}
+	points := make([]kyber.Point, 0, len(pointsBz))
+	for _, pointBz := range pointsBz {
+		point := suite.Point()
+		if err := point.UnmarshalBinary(pointBz); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
@@ -7,7 +7,9 @@ import (
 	"fmt"
 
 	"github.com/corestario/kyber/pairing"
+	dkg "github.com/corestario/kyber/share/dkg/pedersen"
 	vss "github.com/corestario/kyber/share/vss/pedersen"
+	"github.com/corestario/kyber/sign/tbls"
 
 	"github.com/corestario/kyber"
 	"github.com/corestario/kyber/share"
@@ -52,6 +54,16 @@ func (s PKStore) GetPKByParticipant(p string) (kyber.Point, error) {
 	return nil, fmt.Errorf("participant %s does not exist", p)
 }
 
+// GetIndexByParticipant returns an index of the participant's share, or -1 if there is no such participant
+func (s PKStore) GetIndexByParticipant(p string) int {
+	for idx, val := range s {
+		if val.Participant == p {
+			return idx
+		}
+	}
+	return -1
+}
+
 func (s PKStore) GetPKByIndex(index int) kyber.Point {
 	if index < 0 || index > len(s) {
 		return nil
@@ -175,3 +187,143 @@ func LoadBLSKeyringFromBytes(suite vss.Suite, data []byte) (*BLSKeyring, error)
 		Share:   priShare,
 	}, nil
 }
+
+// PubPolyBytes encodes commitments of a public polynomial into JSON
+func PubPolyBytes(pubPoly *share.PubPoly) ([]byte, error) {
+	_, commitments := pubPoly.Info()
+	commitmentsBz := make([][]byte, 0, len(commitments))
+	for _, commitment := range commitments {
+		commitmentBz, err := commitment.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal commitment: %w", err)
+		}
+		commitmentsBz = append(commitmentsBz, commitmentBz)
+	}
+	return json.Marshal(commitmentsBz)
+}
+
+// LoadPubPolyFromBytes decodes the form generated by PubPolyBytes
+func LoadPubPolyFromBytes(suite vss.Suite, data []byte) (*share.PubPoly, error) {
+	var commitmentsBz [][]byte
+	if err := json.Unmarshal(data, &commitmentsBz); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal commitments: %w", err)
+	}
+	if len(commitmentsBz) == 0 {
+		return nil, fmt.Errorf("public polynomial has no commitments")
+	}
+
+	commitments := make([]kyber.Point, 0, len(commitmentsBz))
+	for _, commitmentBz := range commitmentsBz {
+		commitment := suite.Point()
+		if err := commitment.UnmarshalBinary(commitmentBz); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal commitment: %w", err)
+		}
+		commitments = append(commitments, commitment)
+	}
+	return share.NewPubPoly(suite, nil, commitments), nil
+}
+
+// VerifyPartialSign checks that the partial signature of the message is made with the share of the given index.
+// The public share of the index is evaluated from the public polynomial of the round key
+func VerifyPartialSign(suite pairing.Suite, pubPoly *share.PubPoly, index int, msg, partialSign []byte) error {
+	// a signature share is a 2-byte index followed by a signature
+	if len(partialSign) <= 2 {
+		return fmt.Errorf("partial signature is too short")
+	}
+	signIndex, err := tbls.SigShare(partialSign).Index()
+	if err != nil {
+		return fmt.Errorf("failed to get partial signature index: %w", err)
+	}
+	if signIndex != index {
+		return fmt.Errorf("partial signature is made with share %d, expected %d", signIndex, index)
+	}
+	if err = tbls.Verify(suite, pubPoly, msg, partialSign); err != nil {
+		return fmt.Errorf("failed to verify partial signature: %w", err)
+	}
+	return nil
+}
+
+// justificationJSON is a wire form of a justification, a revealed deal holds kyber interfaces,
+// so it can't be decoded from JSON without a suite
+type justificationJSON struct {
+	DealerIndex   uint32   `json:"dealer_index"`
+	SessionID     []byte   `json:"session_id"`
+	VerifierIndex uint32   `json:"verifier_index"`
+	Signature     []byte   `json:"signature"`
+	DealSessionID []byte   `json:"deal_session_id"`
+	ShareIndex    int      `json:"share_index"`
+	Share         []byte   `json:"share"`
+	T             uint32   `json:"t"`
+	Commitments   [][]byte `json:"commitments"`
+}
+
+// EncodeJustifications encodes justifications into JSON
+func EncodeJustifications(justifications []*dkg.Justification) ([]byte, error) {
+	justificationsJSON := make([]justificationJSON, 0, len(justifications))
+	for _, j := range justifications {
+		deal := j.Justification.Deal
+		shareBz, err := deal.SecShare.V.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal share: %w", err)
+		}
+		commitmentsBz := make([][]byte, 0, len(deal.Commitments))
+		for _, commitment := range deal.Commitments {
+			commitmentBz, err := commitment.MarshalBinary()
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal commitment: %w", err)
+			}
+			commitmentsBz = append(commitmentsBz, commitmentBz)
+		}
+		justificationsJSON = append(justificationsJSON, justificationJSON{
+			DealerIndex:   j.Index,
+			SessionID:     j.Justification.SessionID,
+			VerifierIndex: j.Justification.Index,
+			Signature:     j.Justification.Signature,
+			DealSessionID: deal.SessionID,
+			ShareIndex:    deal.SecShare.I,
+			Share:         shareBz,
+			T:             deal.T,
+			Commitments:   commitmentsBz,
+		})
+	}
+	return json.Marshal(justificationsJSON)
+}
+
+// DecodeJustifications decodes the form generated by EncodeJustifications
+func DecodeJustifications(suite vss.Suite, data []byte) ([]*dkg.Justification, error) {
+	var justificationsJSON []justificationJSON
+	if err := json.Unmarshal(data, &justificationsJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal justifications: %w", err)
+	}
+
+	justifications := make([]*dkg.Justification, 0, len(justificationsJSON))
+	for _, j := range justificationsJSON {
+		shareV := suite.Scalar()
+		if err := shareV.UnmarshalBinary(j.Share); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal share: %w", err)
+		}
+		commitments := make([]kyber.Point, 0, len(j.Commitments))
+		for _, commitmentBz := range j.Commitments {
+			commitment := suite.Point()
+			if err := commitment.UnmarshalBinary(commitmentBz); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal commitment: %w", err)
+			}
+			commitments = append(commitments, commitment)
+		}
+		justifications = append(justifications, &dkg.Justification{
+			Index: j.DealerIndex,
+			Justification: &vss.Justification{
+				SessionID: j.SessionID,
+				Index:     j.VerifierIndex,
+				Deal: &vss.Deal{
+					SessionID:   j.DealSessionID,
+					SecShare:    &share.PriShare{I: j.ShareIndex, V: shareV},
+					T:           j.T,
+					Commitments: commitments,
+				},
+				Signature: j.Signature,
+			},
+		})
+	}
+	return justifications, nil
+}
@@ -0,0 +1,52 @@
+package eth2
+
+import "encoding/hex"
+
+// DepositCLIVersion is the version of the official deposit tool, which deposit_data.json format we produce.
+// The launchpad rejects files of too old versions
+const DepositCLIVersion = "2.7.0"
+
+// networkNames are names of the public networks by their genesis fork versions, the launchpad checks them
+var networkNames = map[Version]string{
+	{0x00, 0x00, 0x00, 0x00}: "mainnet",
+	{0x90, 0x00, 0x00, 0x69}: "sepolia",
+	{0x01, 0x01, 0x70, 0x00}: "holesky",
+	{0x10, 0x00, 0x09, 0x10}: "hoodi",
+}
+
+// DepositDataJSON is an entry of deposit_data.json, hex fields are encoded without the 0x prefix
+type DepositDataJSON struct {
+	Pubkey                string `json:"pubkey"`
+	WithdrawalCredentials string `json:"withdrawal_credentials"`
+	Amount                uint64 `json:"amount"`
+	Signature             string `json:"signature"`
+	DepositMessageRoot    string `json:"deposit_message_root"`
+	DepositDataRoot       string `json:"deposit_data_root"`
+	ForkVersion           string `json:"fork_version"`
+	NetworkName           string `json:"network_name,omitempty"`
+	DepositCLIVersion     string `json:"deposit_cli_version"`
+}
+
+// NewDepositDataJSON returns a deposit_data.json entry of the deposit message signed for the chain with the fork version
+func NewDepositDataJSON(message *DepositMessage, signature BLSSignature, forkVersion Version) *DepositDataJSON {
+	depositData := DepositData{
+		Pubkey:                message.Pubkey,
+		WithdrawalCredentials: message.WithdrawalCredentials,
+		Amount:                message.Amount,
+		Signature:             signature,
+	}
+	depositMessageRoot := message.HashTreeRoot()
+	depositDataRoot := depositData.HashTreeRoot()
+
+	return &DepositDataJSON{
+		Pubkey:                hex.EncodeToString(message.Pubkey[:]),
+		WithdrawalCredentials: hex.EncodeToString(message.WithdrawalCredentials[:]),
+		Amount:                message.Amount,
+		Signature:             hex.EncodeToString(signature[:]),
+		DepositMessageRoot:    hex.EncodeToString(depositMessageRoot[:]),
+		DepositDataRoot:       hex.EncodeToString(depositDataRoot[:]),
+		ForkVersion:           hex.EncodeToString(forkVersion[:]),
+		NetworkName:           networkNames[forkVersion],
+		DepositCLIVersion:     DepositCLIVersion,
+	}
+}
@@ -0,0 +1,34 @@
+package eth2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDepositDataJSON(t *testing.T) {
+	var (
+		message   = DepositMessage{Amount: 32000000000}
+		signature BLSSignature
+	)
+	for i := range message.Pubkey {
+		message.Pubkey[i] = byte(i)
+	}
+	message.WithdrawalCredentials[0] = 0x01
+	for i := range message.WithdrawalCredentials[12:] {
+		message.WithdrawalCredentials[12+i] = byte(i)
+	}
+	for i := range signature {
+		signature[i] = byte(200 + i)
+	}
+
+	depositData := NewDepositDataJSON(&message, signature, Version{})
+	require.Equal(t, "3f1ec307ec5e93ae82b453fed86efaacfb6b753148bafae10a29ce687f69ae3d", depositData.DepositMessageRoot)
+	require.Equal(t, "c2b3bcf7fc24c7b9c49387372484c9ba2ef50b629662b049eb566a4def5d6e89", depositData.DepositDataRoot)
+	require.Equal(t, "00000000", depositData.ForkVersion)
+	require.Equal(t, "mainnet", depositData.NetworkName)
+	require.Equal(t, "010000000000000000000000000102030405060708090a0b0c0d0e0f10111213", depositData.WithdrawalCredentials)
+
+	depositData = NewDepositDataJSON(&message, signature, Version{0x01, 0x02, 0x03, 0x04})
+	require.Empty(t, depositData.NetworkName)
+}
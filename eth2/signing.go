@@ -0,0 +1,205 @@
+package eth2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	DomainBeaconProposer       = DomainType{0x00, 0x00, 0x00, 0x00}
+	DomainBeaconAttester       = DomainType{0x01, 0x00, 0x00, 0x00}
+	DomainDeposit              = DomainType{0x03, 0x00, 0x00, 0x00}
+	DomainVoluntaryExit        = DomainType{0x04, 0x00, 0x00, 0x00}
+	DomainBLSToExecutionChange = DomainType{0x0a, 0x00, 0x00, 0x00}
+)
+
+// ComputeDomain returns a signature domain of the operation type on the chain
+// with the given fork version and genesis validators root
+func ComputeDomain(domainType DomainType, forkVersion Version, genesisValidatorsRoot Root) Domain {
+	var domain Domain
+	forkDataRoot := (&forkData{CurrentVersion: forkVersion, GenesisValidatorsRoot: genesisValidatorsRoot}).HashTreeRoot()
+	copy(domain[:4], domainType[:])
+	copy(domain[4:], forkDataRoot[:28])
+	return domain
+}
+
+// ComputeSigningRoot returns a root of the object mixed with the domain, a BLS signature is made over it
+func ComputeSigningRoot(objectRoot Root, domain Domain) Root {
+	return (&signingData{ObjectRoot: objectRoot, Domain: domain}).HashTreeRoot()
+}
+
+// OperationType is a type of a validator operation to sign
+type OperationType string
+
+const (
+	OperationVoluntaryExit        OperationType = "voluntary_exit"
+	OperationDeposit              OperationType = "deposit"
+	OperationBLSToExecutionChange OperationType = "bls_to_execution_change"
+	OperationAttestation          OperationType = "attestation"
+	OperationBlock                OperationType = "block"
+)
+
+// SigningRequest is a validator operation with the chain parameters required to compute its signing root.
+// Exactly one of the operations must be set, the one matching the Type
+type SigningRequest struct {
+	Type                  OperationType `json:"type"`
+	ForkVersion           Version       `json:"fork_version"`
+	GenesisValidatorsRoot Root          `json:"genesis_validators_root"`
+
+	VoluntaryExit        *VoluntaryExit        `json:"voluntary_exit,omitempty"`
+	DepositMessage       *DepositMessage       `json:"deposit_message,omitempty"`
+	BLSToExecutionChange *BLSToExecutionChange `json:"bls_to_execution_change,omitempty"`
+	AttestationData      *AttestationData      `json:"attestation_data,omitempty"`
+	BlockHeader          *BeaconBlockHeader    `json:"block_header,omitempty"`
+}
+
+func (r *SigningRequest) Validate() error {
+	operations := 0
+	for _, isSet := range []bool{r.VoluntaryExit != nil, r.DepositMessage != nil, r.BLSToExecutionChange != nil,
+		r.AttestationData != nil, r.BlockHeader != nil} {
+		if isSet {
+			operations++
+		}
+	}
+	if operations != 1 {
+		return errors.New("exactly one operation must be set")
+	}
+
+	switch r.Type {
+	case OperationVoluntaryExit:
+		if r.VoluntaryExit == nil {
+			return errors.New("voluntary exit is not set")
+		}
+	case OperationDeposit:
+		if r.DepositMessage == nil {
+			return errors.New("deposit message is not set")
+		}
+		// deposits are valid across forks, so they are signed for the genesis fork of any chain
+		if r.GenesisValidatorsRoot != (Root{}) {
+			return errors.New("deposit is signed with the zero genesis validators root")
+		}
+	case OperationBLSToExecutionChange:
+		if r.BLSToExecutionChange == nil {
+			return errors.New("BLS to execution change is not set")
+		}
+	case OperationAttestation:
+		if r.AttestationData == nil {
+			return errors.New("attestation data is not set")
+		}
+		if r.AttestationData.Source.Epoch > r.AttestationData.Target.Epoch {
+			return errors.New("attestation source epoch is greater than target epoch")
+		}
+	case OperationBlock:
+		if r.BlockHeader == nil {
+			return errors.New("block header is not set")
+		}
+	default:
+		return fmt.Errorf("unknown operation type %q", r.Type)
+	}
+	return nil
+}
+
+// Domain returns the signature domain of the operation
+func (r *SigningRequest) Domain() (Domain, error) {
+	if err := r.Validate(); err != nil {
+		return Domain{}, err
+	}
+
+	var domainType DomainType
+	switch r.Type {
+	case OperationVoluntaryExit:
+		domainType = DomainVoluntaryExit
+	case OperationDeposit:
+		domainType = DomainDeposit
+	case OperationBLSToExecutionChange:
+		domainType = DomainBLSToExecutionChange
+	case OperationAttestation:
+		domainType = DomainBeaconAttester
+	case OperationBlock:
+		domainType = DomainBeaconProposer
+	}
+	return ComputeDomain(domainType, r.ForkVersion, r.GenesisValidatorsRoot), nil
+}
+
+// ObjectRoot returns the hash tree root of the operation
+func (r *SigningRequest) ObjectRoot() (Root, error) {
+	if err := r.Validate(); err != nil {
+		return Root{}, err
+	}
+
+	switch r.Type {
+	case OperationVoluntaryExit:
+		return r.VoluntaryExit.HashTreeRoot(), nil
+	case OperationDeposit:
+		return r.DepositMessage.HashTreeRoot(), nil
+	case OperationAttestation:
+		return r.AttestationData.HashTreeRoot(), nil
+	case OperationBlock:
+		return r.BlockHeader.HashTreeRoot(), nil
+	default:
+		return r.BLSToExecutionChange.HashTreeRoot(), nil
+	}
+}
+
+// SigningRoot returns the root to sign for the operation
+func (r *SigningRequest) SigningRoot() (Root, error) {
+	domain, err := r.Domain()
+	if err != nil {
+		return Root{}, fmt.Errorf("failed to compute domain: %w", err)
+	}
+	objectRoot, err := r.ObjectRoot()
+	if err != nil {
+		return Root{}, fmt.Errorf("failed to compute object root: %w", err)
+	}
+	return ComputeSigningRoot(objectRoot, domain), nil
+}
+
+// String returns a human-readable description of the operation
+func (r *SigningRequest) String() string {
+	var sb strings.Builder
+	switch r.Type {
+	case OperationVoluntaryExit:
+		sb.WriteString("Voluntary exit\n")
+	case OperationDeposit:
+		sb.WriteString("Deposit\n")
+	case OperationBLSToExecutionChange:
+		sb.WriteString("BLS to execution change\n")
+	case OperationAttestation:
+		sb.WriteString("Attestation\n")
+	case OperationBlock:
+		sb.WriteString("Block\n")
+	default:
+		fmt.Fprintf(&sb, "Unknown operation %q\n", r.Type)
+	}
+	if r.VoluntaryExit != nil {
+		fmt.Fprintf(&sb, "  Validator index: %d\n", r.VoluntaryExit.ValidatorIndex)
+		fmt.Fprintf(&sb, "  Epoch: %d\n", r.VoluntaryExit.Epoch)
+	}
+	if r.DepositMessage != nil {
+		fmt.Fprintf(&sb, "  Validator pubkey: %s\n", r.DepositMessage.Pubkey)
+		fmt.Fprintf(&sb, "  Withdrawal credentials: %s\n", r.DepositMessage.WithdrawalCredentials)
+		fmt.Fprintf(&sb, "  Amount: %d Gwei\n", r.DepositMessage.Amount)
+	}
+	if r.BLSToExecutionChange != nil {
+		fmt.Fprintf(&sb, "  Validator index: %d\n", r.BLSToExecutionChange.ValidatorIndex)
+		fmt.Fprintf(&sb, "  From BLS pubkey: %s\n", r.BLSToExecutionChange.FromBLSPubkey)
+		fmt.Fprintf(&sb, "  To execution address: %s\n", r.BLSToExecutionChange.ToExecutionAddress)
+	}
+	if r.AttestationData != nil {
+		fmt.Fprintf(&sb, "  Slot: %d\n", r.AttestationData.Slot)
+		fmt.Fprintf(&sb, "  Committee index: %d\n", r.AttestationData.Index)
+		fmt.Fprintf(&sb, "  Beacon block root: %s\n", r.AttestationData.BeaconBlockRoot)
+		fmt.Fprintf(&sb, "  Source epoch: %d\n", r.AttestationData.Source.Epoch)
+		fmt.Fprintf(&sb, "  Target epoch: %d\n", r.AttestationData.Target.Epoch)
+	}
+	if r.BlockHeader != nil {
+		fmt.Fprintf(&sb, "  Slot: %d\n", r.BlockHeader.Slot)
+		fmt.Fprintf(&sb, "  Proposer index: %d\n", r.BlockHeader.ProposerIndex)
+		fmt.Fprintf(&sb, "  Parent root: %s\n", r.BlockHeader.ParentRoot)
+		fmt.Fprintf(&sb, "  Body root: %s\n", r.BlockHeader.BodyRoot)
+	}
+	fmt.Fprintf(&sb, "  Fork version: %s\n", r.ForkVersion)
+	fmt.Fprintf(&sb, "  Genesis validators root: %s", r.GenesisValidatorsRoot)
+	return sb.String()
+}
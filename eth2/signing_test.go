@@ -0,0 +1,116 @@
+package eth2
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const mainnetGenesisValidatorsRoot = "0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95"
+
+func TestComputeDomain_MainnetDeposit(t *testing.T) {
+	domain := ComputeDomain(DomainDeposit, Version{}, Root{})
+	require.Equal(t, "0x03000000f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a9", domain.String())
+}
+
+func TestSigningRequest_SigningRoot(t *testing.T) {
+	var (
+		pubkey BLSPubkey
+		addr   ExecutionAddress
+		wc     Root
+		gvr    Root
+	)
+	for i := range pubkey {
+		pubkey[i] = byte(i)
+	}
+	for i := range addr {
+		addr[i] = byte(100 + i)
+	}
+	// 0x01 withdrawal credentials of an execution address
+	wc[0] = 0x01
+	for i := range wc[12:] {
+		wc[12+i] = byte(i)
+	}
+	require.NoError(t, gvr.UnmarshalText([]byte(mainnetGenesisValidatorsRoot)))
+
+	testCases := []struct {
+		name        string
+		request     SigningRequest
+		signingRoot string
+	}{
+		{
+			name: "voluntary exit",
+			request: SigningRequest{
+				Type:                  OperationVoluntaryExit,
+				ForkVersion:           Version{0x03, 0x00, 0x00, 0x00},
+				GenesisValidatorsRoot: gvr,
+				VoluntaryExit:         &VoluntaryExit{Epoch: 194048, ValidatorIndex: 12345},
+			},
+			signingRoot: "0x2a981a5cbb1790eba496b8861a335c59ac56bb12ad9e16e1d6b66b79c799c1b1",
+		},
+		{
+			name: "deposit",
+			request: SigningRequest{
+				Type:           OperationDeposit,
+				DepositMessage: &DepositMessage{Pubkey: pubkey, WithdrawalCredentials: wc, Amount: 32000000000},
+			},
+			signingRoot: "0x2fed4d3801fdc87b83d2329255d84a94609ca3d686ea41830f9e4735d78ad504",
+		},
+		{
+			name: "BLS to execution change",
+			request: SigningRequest{
+				Type:                  OperationBLSToExecutionChange,
+				GenesisValidatorsRoot: gvr,
+				BLSToExecutionChange:  &BLSToExecutionChange{ValidatorIndex: 42, FromBLSPubkey: pubkey, ToExecutionAddress: addr},
+			},
+			signingRoot: "0xb0c10123feddc632160cd05011c0e0a5dfbd9bb805046eeb8b4df2ec4791de4b",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			signingRoot, err := tc.request.SigningRoot()
+			require.NoError(t, err)
+			require.Equal(t, tc.signingRoot, signingRoot.String())
+
+			// the request survives a JSON round trip, so the airgapped machine gets the same root
+			requestBz, err := json.Marshal(tc.request)
+			require.NoError(t, err)
+			var decoded SigningRequest
+			require.NoError(t, json.Unmarshal(requestBz, &decoded))
+			decodedRoot, err := decoded.SigningRoot()
+			require.NoError(t, err)
+			require.Equal(t, signingRoot, decodedRoot)
+		})
+	}
+}
+
+func TestSigningRequest_Validate(t *testing.T) {
+	exit := &VoluntaryExit{Epoch: 1, ValidatorIndex: 2}
+
+	require.Error(t, (&SigningRequest{Type: OperationVoluntaryExit}).Validate())
+	require.Error(t, (&SigningRequest{Type: OperationDeposit, VoluntaryExit: exit}).Validate())
+	require.Error(t, (&SigningRequest{Type: "sync_committee", VoluntaryExit: exit}).Validate())
+	require.Error(t, (&SigningRequest{
+		Type:                  OperationDeposit,
+		GenesisValidatorsRoot: Root{0x01},
+		DepositMessage:        &DepositMessage{},
+	}).Validate())
+	require.Error(t, (&SigningRequest{
+		Type:           OperationVoluntaryExit,
+		VoluntaryExit:  exit,
+		DepositMessage: &DepositMessage{},
+	}).Validate())
+	require.NoError(t, (&SigningRequest{Type: OperationVoluntaryExit, VoluntaryExit: exit}).Validate())
+	require.Error(t, (&SigningRequest{
+		Type:            OperationAttestation,
+		AttestationData: &AttestationData{Source: Checkpoint{Epoch: 2}, Target: Checkpoint{Epoch: 1}},
+	}).Validate())
+	require.NoError(t, (&SigningRequest{Type: OperationBlock, BlockHeader: &BeaconBlockHeader{Slot: 1}}).Validate())
+
+	var version Version
+	require.Error(t, json.Unmarshal([]byte(`"0x0102"`), &version))
+	require.NoError(t, json.Unmarshal([]byte(`"0x01020304"`), &version))
+	require.Equal(t, Version{1, 2, 3, 4}, version)
+}
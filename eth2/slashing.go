@@ -0,0 +1,222 @@
+package eth2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// InterchangeFormatVersion is the version of the EIP-3076 slashing protection interchange format
+const InterchangeFormatVersion = "5"
+
+// ErrSlashable is returned for a block or an attestation which conflicts with the ones already signed
+var ErrSlashable = errors.New("slashable operation")
+
+// Interchange is a slashing protection history in the EIP-3076 interchange format
+type Interchange struct {
+	Metadata InterchangeMetadata `json:"metadata"`
+	Data     []*ValidatorHistory `json:"data"`
+}
+
+type InterchangeMetadata struct {
+	InterchangeFormatVersion string `json:"interchange_format_version"`
+	GenesisValidatorsRoot    Root   `json:"genesis_validators_root"`
+}
+
+func (i *Interchange) Validate() error {
+	if i.Metadata.InterchangeFormatVersion != InterchangeFormatVersion {
+		return fmt.Errorf("unsupported interchange format version %q", i.Metadata.InterchangeFormatVersion)
+	}
+	for _, history := range i.Data {
+		if history == nil {
+			return errors.New("empty validator history")
+		}
+		for _, attestation := range history.SignedAttestations {
+			if attestation.SourceEpoch > attestation.TargetEpoch {
+				return fmt.Errorf("attestation of validator %s has source epoch %d greater than target epoch %d",
+					history.Pubkey, attestation.SourceEpoch, attestation.TargetEpoch)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidatorHistory holds blocks and attestations signed with the validator key.
+// Signing roots are optional in the interchange format, a record without a root conflicts with any other signing
+type ValidatorHistory struct {
+	Pubkey             BLSPubkey           `json:"pubkey"`
+	SignedBlocks       []SignedBlock       `json:"signed_blocks"`
+	SignedAttestations []SignedAttestation `json:"signed_attestations"`
+}
+
+type SignedBlock struct {
+	Slot        uint64 `json:"slot,string"`
+	SigningRoot *Root  `json:"signing_root,omitempty"`
+}
+
+type SignedAttestation struct {
+	SourceEpoch uint64 `json:"source_epoch,string"`
+	TargetEpoch uint64 `json:"target_epoch,string"`
+	SigningRoot *Root  `json:"signing_root,omitempty"`
+}
+
+// Check returns ErrSlashable if the block or the attestation of the request conflicts with the history.
+// The same block or attestation can be signed again, other operations are never slashable
+func (h *ValidatorHistory) Check(request *SigningRequest, signingRoot Root) error {
+	if request == nil {
+		return nil
+	}
+	switch {
+	case request.Type == OperationBlock && request.BlockHeader != nil:
+		return h.checkBlock(request.BlockHeader.Slot, signingRoot)
+	case request.Type == OperationAttestation && request.AttestationData != nil:
+		return h.checkAttestation(request.AttestationData.Source.Epoch, request.AttestationData.Target.Epoch, signingRoot)
+	}
+	return nil
+}
+
+func (h *ValidatorHistory) checkBlock(slot uint64, signingRoot Root) error {
+	if h.hasBlock(slot, signingRoot) {
+		return nil
+	}
+
+	for _, block := range h.SignedBlocks {
+		if block.Slot == slot {
+			return fmt.Errorf("%w: another block at slot %d was already signed", ErrSlashable, slot)
+		}
+	}
+	// blocks below the lowest signed slot may be missing from an imported history
+	if minSlot, ok := h.minSlot(); ok && slot < minSlot {
+		return fmt.Errorf("%w: slot %d is lower than the lowest signed slot %d", ErrSlashable, slot, minSlot)
+	}
+	return nil
+}
+
+func (h *ValidatorHistory) checkAttestation(sourceEpoch, targetEpoch uint64, signingRoot Root) error {
+	if sourceEpoch > targetEpoch {
+		return fmt.Errorf("%w: source epoch %d is greater than target epoch %d", ErrSlashable, sourceEpoch, targetEpoch)
+	}
+	if h.hasAttestation(sourceEpoch, targetEpoch, signingRoot) {
+		return nil
+	}
+
+	for _, attestation := range h.SignedAttestations {
+		switch {
+		case attestation.TargetEpoch == targetEpoch:
+			return fmt.Errorf("%w: another attestation with target epoch %d was already signed", ErrSlashable, targetEpoch)
+		case sourceEpoch < attestation.SourceEpoch && targetEpoch > attestation.TargetEpoch:
+			return fmt.Errorf("%w: attestation %d->%d surrounds the signed attestation %d->%d", ErrSlashable,
+				sourceEpoch, targetEpoch, attestation.SourceEpoch, attestation.TargetEpoch)
+		case sourceEpoch > attestation.SourceEpoch && targetEpoch < attestation.TargetEpoch:
+			return fmt.Errorf("%w: attestation %d->%d is surrounded by the signed attestation %d->%d", ErrSlashable,
+				sourceEpoch, targetEpoch, attestation.SourceEpoch, attestation.TargetEpoch)
+		}
+	}
+	// attestations below the lowest signed epochs may be missing from an imported history
+	if minSource, minTarget, ok := h.minEpochs(); ok && (sourceEpoch < minSource || targetEpoch < minTarget) {
+		return fmt.Errorf("%w: attestation %d->%d is lower than the lowest signed epochs %d->%d", ErrSlashable,
+			sourceEpoch, targetEpoch, minSource, minTarget)
+	}
+	return nil
+}
+
+// Add records the block or the attestation of the request, it must be checked first
+func (h *ValidatorHistory) Add(request *SigningRequest, signingRoot Root) {
+	if request == nil {
+		return
+	}
+	switch {
+	case request.Type == OperationBlock && request.BlockHeader != nil:
+		h.addBlock(SignedBlock{Slot: request.BlockHeader.Slot, SigningRoot: &signingRoot})
+	case request.Type == OperationAttestation && request.AttestationData != nil:
+		h.addAttestation(SignedAttestation{
+			SourceEpoch: request.AttestationData.Source.Epoch,
+			TargetEpoch: request.AttestationData.Target.Epoch,
+			SigningRoot: &signingRoot,
+		})
+	}
+}
+
+// Merge adds the records of another history of the same validator, conflicting records are kept,
+// so the merged history is at least as strict as both of them
+func (h *ValidatorHistory) Merge(other *ValidatorHistory) {
+	for _, block := range other.SignedBlocks {
+		h.addBlock(block)
+	}
+	for _, attestation := range other.SignedAttestations {
+		h.addAttestation(attestation)
+	}
+}
+
+func (h *ValidatorHistory) addBlock(block SignedBlock) {
+	for _, signed := range h.SignedBlocks {
+		if signed.Slot == block.Slot && equalRoots(signed.SigningRoot, block.SigningRoot) {
+			return
+		}
+	}
+	h.SignedBlocks = append(h.SignedBlocks, block)
+}
+
+func (h *ValidatorHistory) addAttestation(attestation SignedAttestation) {
+	for _, signed := range h.SignedAttestations {
+		if signed.SourceEpoch == attestation.SourceEpoch && signed.TargetEpoch == attestation.TargetEpoch &&
+			equalRoots(signed.SigningRoot, attestation.SigningRoot) {
+			return
+		}
+	}
+	h.SignedAttestations = append(h.SignedAttestations, attestation)
+}
+
+func (h *ValidatorHistory) hasBlock(slot uint64, signingRoot Root) bool {
+	for _, block := range h.SignedBlocks {
+		if block.Slot == slot && block.SigningRoot != nil && *block.SigningRoot == signingRoot {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *ValidatorHistory) hasAttestation(sourceEpoch, targetEpoch uint64, signingRoot Root) bool {
+	for _, attestation := range h.SignedAttestations {
+		if attestation.SourceEpoch == sourceEpoch && attestation.TargetEpoch == targetEpoch &&
+			attestation.SigningRoot != nil && *attestation.SigningRoot == signingRoot {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *ValidatorHistory) minSlot() (uint64, bool) {
+	if len(h.SignedBlocks) == 0 {
+		return 0, false
+	}
+	minSlot := h.SignedBlocks[0].Slot
+	for _, block := range h.SignedBlocks[1:] {
+		if block.Slot < minSlot {
+			minSlot = block.Slot
+		}
+	}
+	return minSlot, true
+}
+
+func (h *ValidatorHistory) minEpochs() (uint64, uint64, bool) {
+	if len(h.SignedAttestations) == 0 {
+		return 0, 0, false
+	}
+	minSource, minTarget := h.SignedAttestations[0].SourceEpoch, h.SignedAttestations[0].TargetEpoch
+	for _, attestation := range h.SignedAttestations[1:] {
+		if attestation.SourceEpoch < minSource {
+			minSource = attestation.SourceEpoch
+		}
+		if attestation.TargetEpoch < minTarget {
+			minTarget = attestation.TargetEpoch
+		}
+	}
+	return minSource, minTarget, true
+}
+
+func equalRoots(a, b *Root) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
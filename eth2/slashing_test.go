@@ -0,0 +1,119 @@
+package eth2
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorHistory_Check(t *testing.T) {
+	var (
+		req     = require.New(t)
+		history = &ValidatorHistory{}
+	)
+
+	block := func(slot uint64, parentRoot byte) *SigningRequest {
+		return &SigningRequest{
+			Type:        OperationBlock,
+			BlockHeader: &BeaconBlockHeader{Slot: slot, ProposerIndex: 7, ParentRoot: Root{parentRoot}},
+		}
+	}
+	attestation := func(source, target uint64, blockRoot byte) *SigningRequest {
+		return &SigningRequest{
+			Type: OperationAttestation,
+			AttestationData: &AttestationData{
+				Slot:            target * 32,
+				BeaconBlockRoot: Root{blockRoot},
+				Source:          Checkpoint{Epoch: source},
+				Target:          Checkpoint{Epoch: target},
+			},
+		}
+	}
+	sign := func(request *SigningRequest) error {
+		signingRoot, err := request.SigningRoot()
+		req.NoError(err)
+		if err = history.Check(request, signingRoot); err != nil {
+			return err
+		}
+		history.Add(request, signingRoot)
+		return nil
+	}
+	requireSlashable := func(err error) {
+		req.Error(err)
+		req.True(errors.Is(err, ErrSlashable))
+	}
+
+	req.NoError(sign(block(100, 1)))
+	// the same block again is fine, another block at the slot isn't
+	req.NoError(sign(block(100, 1)))
+	requireSlashable(sign(block(100, 2)))
+	// below the lowest signed slot
+	requireSlashable(sign(block(99, 1)))
+	req.NoError(sign(block(101, 1)))
+	req.Len(history.SignedBlocks, 2)
+
+	req.NoError(sign(attestation(10, 11, 1)))
+	req.NoError(sign(attestation(10, 11, 1)))
+	// double vote
+	requireSlashable(sign(attestation(10, 11, 2)))
+	req.NoError(sign(attestation(11, 14, 1)))
+	// surrounded by 11->14
+	requireSlashable(sign(attestation(12, 13, 1)))
+	// surrounds 11->14
+	requireSlashable(sign(attestation(10, 15, 1)))
+	// below the lowest signed epochs
+	requireSlashable(sign(attestation(9, 16, 1)))
+	req.NoError(sign(attestation(14, 16, 1)))
+	req.Len(history.SignedAttestations, 3)
+
+	// other operations are not tracked
+	exit := &SigningRequest{Type: OperationVoluntaryExit, VoluntaryExit: &VoluntaryExit{Epoch: 1}}
+	req.NoError(sign(exit))
+	req.NoError(sign(exit))
+}
+
+func TestInterchange_JSON(t *testing.T) {
+	req := require.New(t)
+	interchangeBz := []byte(`{
+		"metadata": {
+			"interchange_format_version": "5",
+			"genesis_validators_root": "0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673"
+		},
+		"data": [{
+			"pubkey": "0xb845089a1457f811bfc000588fbb4e713669be8ce060ea6be3c6ece09afc3794106c91ca73acda5e5457122d58723bed",
+			"signed_blocks": [
+				{"slot": "81952", "signing_root": "0x4ff6f743a43f3b4f95350831aeaf0a122a1a392922c45d804280284a69eb850b"},
+				{"slot": "81951"}
+			],
+			"signed_attestations": [
+				{"source_epoch": "2290", "target_epoch": "3007", "signing_root": "0x587d6a4f59a58fe24f406e0502413e77fe1babddee641fda30034ed37ecc884d"},
+				{"source_epoch": "2290", "target_epoch": "3008"}
+			]
+		}]
+	}`)
+
+	var interchange Interchange
+	req.NoError(json.Unmarshal(interchangeBz, &interchange))
+	req.NoError(interchange.Validate())
+	req.Len(interchange.Data, 1)
+	history := interchange.Data[0]
+	req.Equal(uint64(81952), history.SignedBlocks[0].Slot)
+	req.NotNil(history.SignedBlocks[0].SigningRoot)
+	req.Nil(history.SignedBlocks[1].SigningRoot)
+	req.Equal(uint64(3007), history.SignedAttestations[0].TargetEpoch)
+
+	// a record without a signing root conflicts with any other signing
+	req.Error(history.checkBlock(81951, Root{}))
+	req.Error(history.checkAttestation(2290, 3008, Root{}))
+
+	// numbers are written as strings
+	encoded, err := json.Marshal(interchange)
+	req.NoError(err)
+	req.Contains(string(encoded), `"slot":"81952"`)
+	req.Contains(string(encoded), `"target_epoch":"3007"`)
+
+	interchange.Metadata.InterchangeFormatVersion = "4"
+	req.Error(interchange.Validate())
+}
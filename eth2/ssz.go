@@ -0,0 +1,44 @@
+package eth2
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+const chunkSize = 32
+
+// merkleize returns the root of a binary merkle tree built on the chunks,
+// the number of leaves is padded to the next power of two with zero chunks
+func merkleize(chunks ...[chunkSize]byte) [chunkSize]byte {
+	leaves := 1
+	for leaves < len(chunks) {
+		leaves *= 2
+	}
+	layer := make([][chunkSize]byte, leaves)
+	copy(layer, chunks)
+
+	for len(layer) > 1 {
+		next := make([][chunkSize]byte, len(layer)/2)
+		for i := range next {
+			next[i] = sha256.Sum256(append(layer[2*i][:], layer[2*i+1][:]...))
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+// uint64Root returns the hash tree root of uint64, which is its little-endian chunk
+func uint64Root(v uint64) [chunkSize]byte {
+	var chunk [chunkSize]byte
+	binary.LittleEndian.PutUint64(chunk[:], v)
+	return chunk
+}
+
+// bytesRoot returns the hash tree root of a fixed-size byte vector packed into chunks
+func bytesRoot(b []byte) [chunkSize]byte {
+	chunks := make([][chunkSize]byte, (len(b)+chunkSize-1)/chunkSize)
+	for i := range chunks {
+		copy(chunks[i][:], b[i*chunkSize:])
+	}
+	return merkleize(chunks...)
+}
@@ -0,0 +1,166 @@
+package eth2
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Version is a fork version
+type Version [4]byte
+
+// Root is a 32 bytes hash tree root
+type Root [32]byte
+
+// Domain is a signature domain, a domain type followed by a part of the fork data root
+type Domain [32]byte
+
+// DomainType separates signatures of different operations
+type DomainType [4]byte
+
+// BLSPubkey is a compressed BLS12-381 public key
+type BLSPubkey [48]byte
+
+// BLSSignature is a compressed BLS12-381 signature
+type BLSSignature [96]byte
+
+// ExecutionAddress is an address of an execution layer account
+type ExecutionAddress [20]byte
+
+func (v Version) MarshalText() ([]byte, error)              { return encodeHex(v[:]), nil }
+func (v *Version) UnmarshalText(text []byte) error          { return decodeHex(text, v[:]) }
+func (r Root) MarshalText() ([]byte, error)                 { return encodeHex(r[:]), nil }
+func (r *Root) UnmarshalText(text []byte) error             { return decodeHex(text, r[:]) }
+func (d Domain) MarshalText() ([]byte, error)               { return encodeHex(d[:]), nil }
+func (d *Domain) UnmarshalText(text []byte) error           { return decodeHex(text, d[:]) }
+func (p BLSPubkey) MarshalText() ([]byte, error)            { return encodeHex(p[:]), nil }
+func (p *BLSPubkey) UnmarshalText(text []byte) error        { return decodeHex(text, p[:]) }
+func (s BLSSignature) MarshalText() ([]byte, error)         { return encodeHex(s[:]), nil }
+func (s *BLSSignature) UnmarshalText(text []byte) error     { return decodeHex(text, s[:]) }
+func (a ExecutionAddress) MarshalText() ([]byte, error)     { return encodeHex(a[:]), nil }
+func (a *ExecutionAddress) UnmarshalText(text []byte) error { return decodeHex(text, a[:]) }
+
+func (v Version) String() string          { return string(encodeHex(v[:])) }
+func (r Root) String() string             { return string(encodeHex(r[:])) }
+func (d Domain) String() string           { return string(encodeHex(d[:])) }
+func (p BLSPubkey) String() string        { return string(encodeHex(p[:])) }
+func (s BLSSignature) String() string     { return string(encodeHex(s[:])) }
+func (a ExecutionAddress) String() string { return string(encodeHex(a[:])) }
+
+func encodeHex(b []byte) []byte {
+	return []byte("0x" + hex.EncodeToString(b))
+}
+
+// decodeHex decodes a hex string with an optional 0x prefix into the fixed-size destination
+func decodeHex(text []byte, dst []byte) error {
+	b, err := hex.DecodeString(strings.TrimPrefix(string(text), "0x"))
+	if err != nil {
+		return fmt.Errorf("failed to decode hex: %w", err)
+	}
+	if len(b) != len(dst) {
+		return fmt.Errorf("expected %d bytes, got %d", len(dst), len(b))
+	}
+	copy(dst, b)
+	return nil
+}
+
+// VoluntaryExit is a message to exit a validator
+type VoluntaryExit struct {
+	Epoch          uint64 `json:"epoch"`
+	ValidatorIndex uint64 `json:"validator_index"`
+}
+
+func (e *VoluntaryExit) HashTreeRoot() Root {
+	return merkleize(uint64Root(e.Epoch), uint64Root(e.ValidatorIndex))
+}
+
+// DepositMessage is a deposit signed by the validator key
+type DepositMessage struct {
+	Pubkey                BLSPubkey `json:"pubkey"`
+	WithdrawalCredentials Root      `json:"withdrawal_credentials"`
+	Amount                uint64    `json:"amount"`
+}
+
+func (m *DepositMessage) HashTreeRoot() Root {
+	return merkleize(bytesRoot(m.Pubkey[:]), m.WithdrawalCredentials, uint64Root(m.Amount))
+}
+
+// DepositData is a deposit message with its signature, it's sent to the deposit contract
+type DepositData struct {
+	Pubkey                BLSPubkey    `json:"pubkey"`
+	WithdrawalCredentials Root         `json:"withdrawal_credentials"`
+	Amount                uint64       `json:"amount"`
+	Signature             BLSSignature `json:"signature"`
+}
+
+func (d *DepositData) HashTreeRoot() Root {
+	return merkleize(bytesRoot(d.Pubkey[:]), d.WithdrawalCredentials, uint64Root(d.Amount), bytesRoot(d.Signature[:]))
+}
+
+// BLSToExecutionChange is a message to change BLS withdrawal credentials of a validator to an execution address
+type BLSToExecutionChange struct {
+	ValidatorIndex     uint64           `json:"validator_index"`
+	FromBLSPubkey      BLSPubkey        `json:"from_bls_pubkey"`
+	ToExecutionAddress ExecutionAddress `json:"to_execution_address"`
+}
+
+func (c *BLSToExecutionChange) HashTreeRoot() Root {
+	return merkleize(uint64Root(c.ValidatorIndex), bytesRoot(c.FromBLSPubkey[:]), bytesRoot(c.ToExecutionAddress[:]))
+}
+
+// Checkpoint is an epoch with the root of its first block
+type Checkpoint struct {
+	Epoch uint64 `json:"epoch"`
+	Root  Root   `json:"root"`
+}
+
+func (c *Checkpoint) HashTreeRoot() Root {
+	return merkleize(uint64Root(c.Epoch), c.Root)
+}
+
+// AttestationData is a vote of a validator for the head block and the source and target checkpoints
+type AttestationData struct {
+	Slot            uint64     `json:"slot"`
+	Index           uint64     `json:"index"`
+	BeaconBlockRoot Root       `json:"beacon_block_root"`
+	Source          Checkpoint `json:"source"`
+	Target          Checkpoint `json:"target"`
+}
+
+func (a *AttestationData) HashTreeRoot() Root {
+	return merkleize(uint64Root(a.Slot), uint64Root(a.Index), a.BeaconBlockRoot, a.Source.HashTreeRoot(),
+		a.Target.HashTreeRoot())
+}
+
+// BeaconBlockHeader is a block with the root of its body, a block is signed through its header root
+type BeaconBlockHeader struct {
+	Slot          uint64 `json:"slot"`
+	ProposerIndex uint64 `json:"proposer_index"`
+	ParentRoot    Root   `json:"parent_root"`
+	StateRoot     Root   `json:"state_root"`
+	BodyRoot      Root   `json:"body_root"`
+}
+
+func (h *BeaconBlockHeader) HashTreeRoot() Root {
+	return merkleize(uint64Root(h.Slot), uint64Root(h.ProposerIndex), h.ParentRoot, h.StateRoot, h.BodyRoot)
+}
+
+// forkData is hashed to bind a signature domain to a chain
+type forkData struct {
+	CurrentVersion        Version
+	GenesisValidatorsRoot Root
+}
+
+func (f *forkData) HashTreeRoot() Root {
+	return merkleize(bytesRoot(f.CurrentVersion[:]), f.GenesisValidatorsRoot)
+}
+
+// signingData is an object root mixed with a domain, its root is what is actually signed
+type signingData struct {
+	ObjectRoot Root
+	Domain     Domain
+}
+
+func (s *signingData) HashTreeRoot() Root {
+	return merkleize(s.ObjectRoot, s.Domain)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/lidofinance/dc4bc/fsm/fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
 	"log"
@@ -27,4 +28,10 @@ func main() {
 		log.Fatal("invalid type")
 	}
 	fmt.Println(fsm.Visualize(signingFSM.FSM))
+
+	resharingFSM, ok := resharing_proposal_fsm.New().(*resharing_proposal_fsm.ResharingProposalFSM)
+	if !ok {
+		log.Fatal("invalid type")
+	}
+	fmt.Println(fsm.Visualize(resharingFSM.FSM))
 }
@@ -7,4 +7,5 @@ const (
 	SignatureProposalConfirmationDeadline = time.Hour * 24
 	DkgConfirmationDeadline               = time.Hour * 24
 	SigningConfirmationDeadline           = time.Hour * 24
+	ResharingConfirmationDeadline         = time.Hour * 24
 )
@@ -3,7 +3,6 @@ package dkg_proposal_fsm
 import (
 	"errors"
 	"fmt"
-	"reflect"
 
 	"github.com/lidofinance/dc4bc/fsm/config"
 	"github.com/lidofinance/dc4bc/fsm/fsm"
@@ -308,8 +307,17 @@ func (m *DKGProposalFSM) actionResponseConfirmationReceived(inEvent fsm.Event, a
 		return
 	}
 
+	for _, dealerId := range request.Complaints {
+		if !m.payload.DKGQuorumExists(dealerId) {
+			err = errors.New("{Complaints} contains a participant not exist in quorum")
+			return
+		}
+	}
+
 	dkgProposalParticipant.DkgResponse = make([]byte, len(request.Response))
 	copy(dkgProposalParticipant.DkgResponse, request.Response)
+	dkgProposalParticipant.DkgComplaints = make([]int, len(request.Complaints))
+	copy(dkgProposalParticipant.DkgComplaints, request.Complaints)
 	dkgProposalParticipant.Status = internal.ResponseConfirmed
 
 	dkgProposalParticipant.UpdatedAt = request.CreatedAt
@@ -353,9 +361,19 @@ func (m *DKGProposalFSM) actionValidateDkgProposalAwaitResponses(inEvent fsm.Eve
 	}
 
 	outEvent = eventDKGResponsesConfirmedInternal
+	nextStatus := internal.MasterKeyAwaitConfirmation
 
+	// dealers have to justify their deals if there are complaints
 	for _, participant := range m.payload.DKGProposalPayload.Quorum {
-		participant.Status = internal.MasterKeyAwaitConfirmation
+		if len(participant.DkgComplaints) > 0 {
+			outEvent = eventDKGResponsesComplainedInternal
+			nextStatus = internal.JustificationAwaitConfirmation
+			break
+		}
+	}
+
+	for _, participant := range m.payload.DKGProposalPayload.Quorum {
+		participant.Status = nextStatus
 	}
 
 	// Make response
@@ -376,6 +394,161 @@ func (m *DKGProposalFSM) actionValidateDkgProposalAwaitResponses(inEvent fsm.Eve
 	return
 }
 
+// Justifications
+
+func (m *DKGProposalFSM) actionJustificationConfirmationReceived(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {DKGProposalJustificationConfirmationRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.DKGProposalJustificationConfirmationRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {DKGProposalJustificationConfirmationRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	if !m.payload.DKGQuorumExists(request.ParticipantId) {
+		err = errors.New("{ParticipantId} not exist in quorum")
+		return
+	}
+
+	dkgProposalParticipant := m.payload.DKGQuorumGet(request.ParticipantId)
+
+	if dkgProposalParticipant.Status != internal.JustificationAwaitConfirmation {
+		err = fmt.Errorf("cannot confirm justification with {Status} = {\"%s\"}", dkgProposalParticipant.Status)
+		return
+	}
+
+	dkgProposalParticipant.DkgJustification = make([]byte, len(request.Justification))
+	copy(dkgProposalParticipant.DkgJustification, request.Justification)
+	dkgProposalParticipant.Status = internal.JustificationConfirmed
+
+	dkgProposalParticipant.UpdatedAt = request.CreatedAt
+	m.payload.DKGProposalPayload.UpdatedAt = request.CreatedAt
+
+	m.payload.DKGQuorumUpdate(request.ParticipantId, dkgProposalParticipant)
+
+	return
+}
+
+func (m *DKGProposalFSM) actionValidateDkgProposalAwaitJustifications(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if m.payload.DKGProposalPayload.IsExpired() {
+		outEvent, response = m.finishJustifications(true)
+		return
+	}
+
+	// Only the dealers with complaints against their deals have to answer them,
+	// a participant who failed at this stage is disqualified and doesn't have to
+	for participantId := range m.accusedDealers() {
+		if m.payload.DKGQuorumGet(participantId).Status == internal.JustificationAwaitConfirmation {
+			if m.qualifiedDealersCount(false) < m.payload.SigQuorumThreshold() {
+				outEvent = eventDKGJustificationsConfirmationCancelByErrorInternal
+			}
+			return
+		}
+	}
+
+	outEvent, response = m.finishJustifications(false)
+
+	return
+}
+
+// accusedDealers returns IDs of participants whose deals got complaints
+func (m *DKGProposalFSM) accusedDealers() map[int]bool {
+	accused := make(map[int]bool)
+	for _, participant := range m.payload.DKGProposalPayload.Quorum {
+		for _, dealerId := range participant.DkgComplaints {
+			accused[dealerId] = true
+		}
+	}
+	return accused
+}
+
+// disqualifiedDealer reports whether the deal of the participant is left out of the key: the participant failed
+// at the justifications stage, or its deal got complaints and it didn't answer them in time
+func (m *DKGProposalFSM) disqualifiedDealer(participantId int, accused, expired bool) bool {
+	switch m.payload.DKGQuorumGet(participantId).Status {
+	case internal.JustificationConfirmationError:
+		return true
+	case internal.JustificationConfirmed:
+		return false
+	default:
+		return accused && expired
+	}
+}
+
+// qualifiedDealersCount returns the number of participants whose deals are still in the key
+func (m *DKGProposalFSM) qualifiedDealersCount(expired bool) int {
+	accused := m.accusedDealers()
+	qualifiedDealers := 0
+	for participantId := range m.payload.DKGProposalPayload.Quorum {
+		if !m.disqualifiedDealer(participantId, accused[participantId], expired) {
+			qualifiedDealers++
+		}
+	}
+	return qualifiedDealers
+}
+
+// finishJustifications disqualifies the participants which failed at the justifications stage and, once it's expired,
+// the dealers which didn't answer complaints in time. The round goes on if the deals of the other participants
+// are enough to build the key, the master key stage gets its own deadline then
+func (m *DKGProposalFSM) finishJustifications(expired bool) (outEvent fsm.Event, response interface{}) {
+	if m.qualifiedDealersCount(expired) < m.payload.SigQuorumThreshold() {
+		if expired {
+			return eventDKGJustificationsConfirmationCancelByTimeoutInternal, nil
+		}
+		return eventDKGJustificationsConfirmationCancelByErrorInternal, nil
+	}
+
+	accused := m.accusedDealers()
+	for participantId, participant := range m.payload.DKGProposalPayload.Quorum {
+		if m.disqualifiedDealer(participantId, accused[participantId], expired) {
+			participant.DkgDisqualified = true
+		}
+	}
+
+	m.payload.DKGProposalPayload.ExpiresAt = m.payload.DKGProposalPayload.UpdatedAt.Add(config.DkgConfirmationDeadline)
+
+	return eventDKGJustificationsConfirmedInternal, m.justificationsResponse()
+}
+
+// justificationsResponse switches the participants to the master key stage and returns the responses
+// with the justifications of the accused dealers
+func (m *DKGProposalFSM) justificationsResponse() responses.DKGProposalResponseParticipantResponse {
+	for _, participant := range m.payload.DKGProposalPayload.Quorum {
+		participant.Status = internal.MasterKeyAwaitConfirmation
+	}
+
+	// Make response
+
+	responseData := make(responses.DKGProposalResponseParticipantResponse, 0)
+
+	for participantId, participant := range m.payload.DKGProposalPayload.Quorum {
+		responseEntry := &responses.DKGProposalResponseParticipantEntry{
+			ParticipantId:    participantId,
+			Username:         participant.Username,
+			DkgResponse:      participant.DkgResponse,
+			DkgJustification: participant.DkgJustification,
+			Disqualified:     participant.DkgDisqualified,
+		}
+		responseData = append(responseData, responseEntry)
+	}
+
+	return responseData
+}
+
 // Master key
 
 func (m *DKGProposalFSM) actionMasterKeyConfirmationReceived(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
@@ -412,6 +585,8 @@ func (m *DKGProposalFSM) actionMasterKeyConfirmationReceived(inEvent fsm.Event,
 
 	dkgProposalParticipant.DkgMasterKey = make([]byte, len(request.MasterKey))
 	copy(dkgProposalParticipant.DkgMasterKey, request.MasterKey)
+	dkgProposalParticipant.DkgPubPoly = make([]byte, len(request.PubPoly))
+	copy(dkgProposalParticipant.DkgPubPoly, request.PubPoly)
 	dkgProposalParticipant.Status = internal.MasterKeyConfirmed
 
 	dkgProposalParticipant.UpdatedAt = request.CreatedAt
@@ -425,7 +600,9 @@ func (m *DKGProposalFSM) actionMasterKeyConfirmationReceived(inEvent fsm.Event,
 func (m *DKGProposalFSM) actionValidateDkgProposalAwaitMasterKey(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
 	var (
 		isContainsError bool
-		masterKeys      [][]byte
+		// confirmations groups IDs of participants by the confirmed master key and public polynomial
+		confirmations = make(map[string][]int)
+		agreedKey     string
 	)
 
 	m.payloadMu.Lock()
@@ -436,14 +613,24 @@ func (m *DKGProposalFSM) actionValidateDkgProposalAwaitMasterKey(inEvent fsm.Eve
 		return
 	}
 
-	unconfirmedParticipants := m.payload.DKGQuorumCount()
+	threshold := m.payload.SigQuorumThreshold()
+	unconfirmedParticipants := 0
 
-	for _, participant := range m.payload.DKGProposalPayload.Quorum {
-		if participant.Status == internal.MasterKeyConfirmationError {
+	for participantId, participant := range m.payload.DKGProposalPayload.Quorum {
+		switch {
+		case participant.Status == internal.MasterKeyConfirmationError:
 			isContainsError = true
-		} else if participant.Status == internal.MasterKeyConfirmed {
-			masterKeys = append(masterKeys, participant.DkgMasterKey)
-			unconfirmedParticipants--
+		case participant.DkgDisqualified:
+			// the key doesn't depend on the deal of a disqualified dealer, but its confirmation is not counted
+		case participant.Status == internal.MasterKeyConfirmed:
+			// Partial signs are verified against the public polynomial, so participants must agree on it too
+			key := masterKeyConfirmation(participant)
+			confirmations[key] = append(confirmations[key], participantId)
+			if len(confirmations[key]) >= threshold {
+				agreedKey = key
+			}
+		default:
+			unconfirmedParticipants++
 		}
 	}
 
@@ -452,35 +639,45 @@ func (m *DKGProposalFSM) actionValidateDkgProposalAwaitMasterKey(inEvent fsm.Eve
 		return
 	}
 
-	// Temporary simplest match master keys
-	if len(masterKeys) > 1 {
-		for _, masterKey := range masterKeys {
-			if !reflect.DeepEqual(masterKey, masterKeys[0]) {
-				for _, participant := range m.payload.DKGProposalPayload.Quorum {
-					participant.Status = internal.MasterKeyConfirmationError
-					participant.Error = errors.New("master key is mismatched")
-				}
-
-				outEvent = eventDKGMasterKeyConfirmationCancelByErrorInternal
-				return
+	if agreedKey == "" {
+		var largestGroup int
+		for _, participantIds := range confirmations {
+			if len(participantIds) > largestGroup {
+				largestGroup = len(participantIds)
 			}
 		}
-	}
 
-	// The are no declined and timed out participants, check for all confirmations
-	if unconfirmedParticipants > 0 {
+		// Not confirmed yet, but a threshold of matching master keys is still possible
+		if largestGroup+unconfirmedParticipants >= threshold {
+			return
+		}
+
+		for _, participant := range m.payload.DKGProposalPayload.Quorum {
+			participant.Status = internal.MasterKeyConfirmationError
+			participant.Error = internal.NewParticipantError(errors.New("master key is mismatched"))
+		}
+
+		outEvent = eventDKGMasterKeyConfirmationCancelByErrorInternal
 		return
 	}
 
 	outEvent = eventDKGMasterKeyConfirmedInternal
 
+	// The participants who didn't confirm the agreed key in time are left without it
 	for _, participant := range m.payload.DKGProposalPayload.Quorum {
-		participant.Status = internal.MasterKeyConfirmed
+		if participant.Status != internal.MasterKeyConfirmed || masterKeyConfirmation(participant) != agreedKey {
+			participant.Status = internal.MasterKeyConfirmationError
+		}
 	}
 
 	return
 }
 
+// masterKeyConfirmation identifies the master key and the public polynomial confirmed by the participant
+func masterKeyConfirmation(participant *internal.DKGProposalParticipant) string {
+	return fmt.Sprintf("%x %x", participant.DkgMasterKey, participant.DkgPubPoly)
+}
+
 // Errors
 func (m *DKGProposalFSM) actionConfirmationError(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
 	m.payloadMu.Lock()
@@ -556,6 +753,21 @@ func (m *DKGProposalFSM) actionConfirmationError(inEvent fsm.Event, args ...inte
 				internal.ResponseConfirmationError,
 			)
 		}
+	case EventDKGJustificationConfirmationError:
+		switch dkgProposalParticipant.Status {
+		case internal.JustificationAwaitConfirmation:
+			dkgProposalParticipant.Status = internal.JustificationConfirmationError
+		case internal.JustificationConfirmed:
+			err = errors.New("{Status} already confirmed")
+		case internal.JustificationConfirmationError:
+			err = fmt.Errorf("{Status} already has {\"%s\"}", internal.JustificationConfirmationError)
+		default:
+			err = fmt.Errorf(
+				"{Status} now is \"%s\" and cannot set to {\"%s\"}",
+				dkgProposalParticipant.Status,
+				internal.JustificationConfirmationError,
+			)
+		}
 	case EventDKGMasterKeyConfirmationError:
 		switch dkgProposalParticipant.Status {
 		case internal.MasterKeyAwaitConfirmation:
@@ -579,7 +791,7 @@ func (m *DKGProposalFSM) actionConfirmationError(inEvent fsm.Event, args ...inte
 		return
 	}
 
-	dkgProposalParticipant.Error = request.Error
+	dkgProposalParticipant.Error = internal.NewParticipantError(request.Error)
 
 	dkgProposalParticipant.UpdatedAt = request.CreatedAt
 	m.payload.DKGProposalPayload.UpdatedAt = request.CreatedAt
@@ -590,3 +802,50 @@ func (m *DKGProposalFSM) actionConfirmationError(inEvent fsm.Event, args ...inte
 
 	return
 }
+
+func (m *DKGProposalFSM) actionConfirmationTimeout(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	err = m.applyConfirmationTimeout(args...)
+
+	return
+}
+
+// actionJustificationsConfirmationTimeout disqualifies the dealers which haven't answered the complaints in time
+func (m *DKGProposalFSM) actionJustificationsConfirmationTimeout(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if err = m.applyConfirmationTimeout(args...); err != nil {
+		return
+	}
+
+	outEvent, response = m.finishJustifications(true)
+
+	return
+}
+
+func (m *DKGProposalFSM) applyConfirmationTimeout(args ...interface{}) error {
+	if len(args) != 1 {
+		return errors.New("{arg0} required {DefaultRequest}")
+	}
+
+	request, ok := args[0].(requests.DefaultRequest)
+
+	if !ok {
+		return errors.New("cannot cast {arg0} to type {DefaultRequest}")
+	}
+
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	if !m.payload.DKGProposalPayload.ExpiresAt.Before(request.CreatedAt) {
+		return errors.New("{ExpiresAt} is not reached yet")
+	}
+
+	m.payload.DKGProposalPayload.UpdatedAt = request.CreatedAt
+
+	return nil
+}
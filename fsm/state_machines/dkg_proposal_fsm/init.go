@@ -35,6 +35,12 @@ const (
 	// Confirmed
 	StateDkgResponsesCollected = fsm.State("state_dkg_responses_collected")
 
+	// Answering complaints, the stage is skipped if there are no complaints
+	StateDkgJustificationsAwaitConfirmations = fsm.State("state_dkg_justifications_await_confirmations")
+	// Canceled
+	StateDkgJustificationsAwaitCanceledByError   = fsm.State("state_dkg_justifications_await_canceled_by_error")
+	StateDkgJustificationsAwaitCanceledByTimeout = fsm.State("state_dkg_justifications_await_canceled_by_timeout")
+
 	StateDkgMasterKeyAwaitConfirmations     = fsm.State("state_dkg_master_key_await_confirmations")
 	StateDkgMasterKeyAwaitCanceledByError   = fsm.State("state_dkg_master_key_await_canceled_by_error")
 	StateDkgMasterKeyAwaitCanceledByTimeout = fsm.State("state_dkg_master_key_await_canceled_by_timeout")
@@ -50,6 +56,7 @@ const (
 	eventDKGCommitsConfirmationCancelByErrorInternal   = fsm.Event("event_dkg_commits_confirm_canceled_by_error_internal")
 	eventDKGCommitsConfirmedInternal                   = fsm.Event("event_dkg_commits_confirmed_internal")
 	eventAutoDKGValidateConfirmationCommitsInternal    = fsm.Event("event_dkg_commits_validate_internal")
+	EventDKGCommitsConfirmationTimeout                 = fsm.Event("event_dkg_commits_confirm_timeout")
 
 	EventDKGDealConfirmationReceived                 = fsm.Event("event_dkg_deal_confirm_received")
 	EventDKGDealConfirmationError                    = fsm.Event("event_dkg_deal_confirm_canceled_by_error")
@@ -57,6 +64,7 @@ const (
 	eventDKGDealsConfirmationCancelByErrorInternal   = fsm.Event("event_dkg_deals_confirm_canceled_by_error_internal")
 	eventDKGDealsConfirmedInternal                   = fsm.Event("event_dkg_deals_confirmed_internal")
 	eventAutoDKGValidateConfirmationDealsInternal    = fsm.Event("event_dkg_deals_validate_internal")
+	EventDKGDealsConfirmationTimeout                 = fsm.Event("event_dkg_deals_confirm_timeout")
 
 	EventDKGResponseConfirmationReceived                = fsm.Event("event_dkg_response_confirm_received")
 	EventDKGResponseConfirmationError                   = fsm.Event("event_dkg_response_confirm_canceled_by_error")
@@ -64,6 +72,16 @@ const (
 	eventDKGResponseConfirmationCancelByErrorInternal   = fsm.Event("event_dkg_response_confirm_canceled_by_error_internal")
 	eventDKGResponsesConfirmedInternal                  = fsm.Event("event_dkg_responses_confirmed_internal")
 	eventAutoDKGValidateResponsesConfirmationInternal   = fsm.Event("event_dkg_responses_validate_internal")
+	eventDKGResponsesComplainedInternal                 = fsm.Event("event_dkg_responses_complained_internal")
+	EventDKGResponsesConfirmationTimeout                = fsm.Event("event_dkg_responses_confirm_timeout")
+
+	EventDKGJustificationConfirmationReceived                 = fsm.Event("event_dkg_justification_confirm_received")
+	EventDKGJustificationConfirmationError                    = fsm.Event("event_dkg_justification_confirm_canceled_by_error")
+	eventDKGJustificationsConfirmationCancelByTimeoutInternal = fsm.Event("event_dkg_justifications_confirm_canceled_by_timeout_internal")
+	eventDKGJustificationsConfirmationCancelByErrorInternal   = fsm.Event("event_dkg_justifications_confirm_canceled_by_error_internal")
+	eventDKGJustificationsConfirmedInternal                   = fsm.Event("event_dkg_justifications_confirmed_internal")
+	eventAutoDKGValidateJustificationsConfirmationInternal    = fsm.Event("event_dkg_justifications_validate_internal")
+	EventDKGJustificationsConfirmationTimeout                 = fsm.Event("event_dkg_justifications_confirm_timeout")
 
 	EventDKGMasterKeyConfirmationReceived                = fsm.Event("event_dkg_master_key_confirm_received")
 	EventDKGMasterKeyConfirmationError                   = fsm.Event("event_dkg_master_key_confirm_canceled_by_error")
@@ -71,6 +89,7 @@ const (
 	eventDKGMasterKeyConfirmationCancelByErrorInternal   = fsm.Event("event_dkg_master_key_confirm_canceled_by_error_internal")
 	eventDKGMasterKeyConfirmedInternal                   = fsm.Event("event_dkg_master_key_confirmed_internal")
 	eventAutoDKGValidateMasterKeyConfirmationInternal    = fsm.Event("event_dkg_master_key_validate_internal")
+	EventDKGMasterKeyConfirmationTimeout                 = fsm.Event("event_dkg_master_key_confirm_timeout")
 
 	EventDKGMasterKeyRequiredInternal = fsm.Event("event_dkg_master_key_required_internal")
 )
@@ -106,6 +125,8 @@ func New() internal.DumpedMachineProvider {
 			// Canceled
 			{Name: EventDKGCommitConfirmationError, SrcState: []fsm.State{StateDkgCommitsAwaitConfirmations}, DstState: StateDkgCommitsAwaitCanceledByError},
 			{Name: eventDKGCommitsConfirmationCancelByTimeoutInternal, SrcState: []fsm.State{StateDkgCommitsAwaitConfirmations}, DstState: StateDkgCommitsAwaitCanceledByTimeout, IsInternal: true},
+			// Timeout is reported by participants when the deadline has passed
+			{Name: EventDKGCommitsConfirmationTimeout, SrcState: []fsm.State{StateDkgCommitsAwaitConfirmations}, DstState: StateDkgCommitsAwaitCanceledByTimeout},
 
 			{Name: eventAutoDKGValidateConfirmationCommitsInternal, SrcState: []fsm.State{StateDkgCommitsAwaitConfirmations}, DstState: StateDkgCommitsAwaitConfirmations, IsInternal: true, IsAuto: true},
 
@@ -117,6 +138,7 @@ func New() internal.DumpedMachineProvider {
 			// Canceled
 			{Name: EventDKGDealConfirmationError, SrcState: []fsm.State{StateDkgDealsAwaitConfirmations}, DstState: StateDkgDealsAwaitCanceledByError},
 			{Name: eventDKGDealsConfirmationCancelByTimeoutInternal, SrcState: []fsm.State{StateDkgDealsAwaitConfirmations}, DstState: StateDkgDealsAwaitCanceledByTimeout, IsInternal: true},
+			{Name: EventDKGDealsConfirmationTimeout, SrcState: []fsm.State{StateDkgDealsAwaitConfirmations}, DstState: StateDkgDealsAwaitCanceledByTimeout},
 			{Name: eventAutoDKGValidateConfirmationDealsInternal, SrcState: []fsm.State{StateDkgDealsAwaitConfirmations}, DstState: StateDkgDealsAwaitConfirmations, IsInternal: true, IsAuto: true},
 
 			{Name: eventDKGDealsConfirmedInternal, SrcState: []fsm.State{StateDkgDealsAwaitConfirmations}, DstState: StateDkgResponsesAwaitConfirmations, IsInternal: true},
@@ -126,10 +148,27 @@ func New() internal.DumpedMachineProvider {
 			// Canceled
 			{Name: EventDKGResponseConfirmationError, SrcState: []fsm.State{StateDkgResponsesAwaitConfirmations}, DstState: StateDkgResponsesAwaitCanceledByError},
 			{Name: eventDKGResponseConfirmationCancelByTimeoutInternal, SrcState: []fsm.State{StateDkgResponsesAwaitConfirmations}, DstState: StateDkgResponsesAwaitCanceledByTimeout, IsInternal: true},
+			{Name: EventDKGResponsesConfirmationTimeout, SrcState: []fsm.State{StateDkgResponsesAwaitConfirmations}, DstState: StateDkgResponsesAwaitCanceledByTimeout},
 
 			{Name: eventAutoDKGValidateResponsesConfirmationInternal, SrcState: []fsm.State{StateDkgResponsesAwaitConfirmations}, DstState: StateDkgResponsesAwaitConfirmations, IsInternal: true, IsAuto: true},
 
 			{Name: eventDKGResponsesConfirmedInternal, SrcState: []fsm.State{StateDkgResponsesAwaitConfirmations}, DstState: StateDkgMasterKeyAwaitConfirmations, IsInternal: true},
+			// Some deals were not accepted, dealers have to answer complaints
+			{Name: eventDKGResponsesComplainedInternal, SrcState: []fsm.State{StateDkgResponsesAwaitConfirmations}, DstState: StateDkgJustificationsAwaitConfirmations, IsInternal: true},
+
+			// Justifications
+			{Name: EventDKGJustificationConfirmationReceived, SrcState: []fsm.State{StateDkgJustificationsAwaitConfirmations}, DstState: StateDkgJustificationsAwaitConfirmations},
+			// Canceled
+			// A failed participant is disqualified, the round is canceled if the rest can't reach the threshold
+			{Name: EventDKGJustificationConfirmationError, SrcState: []fsm.State{StateDkgJustificationsAwaitConfirmations}, DstState: StateDkgJustificationsAwaitConfirmations},
+			{Name: eventDKGJustificationsConfirmationCancelByErrorInternal, SrcState: []fsm.State{StateDkgJustificationsAwaitConfirmations}, DstState: StateDkgJustificationsAwaitCanceledByError, IsInternal: true},
+			{Name: eventDKGJustificationsConfirmationCancelByTimeoutInternal, SrcState: []fsm.State{StateDkgJustificationsAwaitConfirmations}, DstState: StateDkgJustificationsAwaitCanceledByTimeout, IsInternal: true},
+			// Dealers which haven't answered are disqualified, the rest go on to the master key if they reach the threshold
+			{Name: EventDKGJustificationsConfirmationTimeout, SrcState: []fsm.State{StateDkgJustificationsAwaitConfirmations}, DstState: StateDkgJustificationsAwaitCanceledByTimeout},
+
+			{Name: eventAutoDKGValidateJustificationsConfirmationInternal, SrcState: []fsm.State{StateDkgJustificationsAwaitConfirmations}, DstState: StateDkgJustificationsAwaitConfirmations, IsInternal: true, IsAuto: true},
+
+			{Name: eventDKGJustificationsConfirmedInternal, SrcState: []fsm.State{StateDkgJustificationsAwaitConfirmations}, DstState: StateDkgMasterKeyAwaitConfirmations, IsInternal: true},
 
 			// Master key
 
@@ -137,6 +176,7 @@ func New() internal.DumpedMachineProvider {
 			{Name: EventDKGMasterKeyConfirmationError, SrcState: []fsm.State{StateDkgMasterKeyAwaitConfirmations}, DstState: StateDkgMasterKeyAwaitCanceledByError},
 			{Name: eventDKGMasterKeyConfirmationCancelByErrorInternal, SrcState: []fsm.State{StateDkgMasterKeyAwaitConfirmations}, DstState: StateDkgMasterKeyAwaitCanceledByError, IsInternal: true},
 			{Name: eventDKGMasterKeyConfirmationCancelByTimeoutInternal, SrcState: []fsm.State{StateDkgMasterKeyAwaitConfirmations}, DstState: StateDkgMasterKeyAwaitCanceledByTimeout, IsInternal: true},
+			{Name: EventDKGMasterKeyConfirmationTimeout, SrcState: []fsm.State{StateDkgMasterKeyAwaitConfirmations}, DstState: StateDkgMasterKeyAwaitCanceledByTimeout},
 
 			{Name: eventAutoDKGValidateMasterKeyConfirmationInternal, SrcState: []fsm.State{StateDkgMasterKeyAwaitConfirmations}, DstState: StateDkgMasterKeyAwaitConfirmations, IsInternal: true, IsAuto: true},
 
@@ -149,18 +189,27 @@ func New() internal.DumpedMachineProvider {
 			EventDKGCommitConfirmationReceived:              machine.actionCommitConfirmationReceived,
 			EventDKGCommitConfirmationError:                 machine.actionConfirmationError,
 			eventAutoDKGValidateConfirmationCommitsInternal: machine.actionValidateDkgProposalAwaitCommits,
+			EventDKGCommitsConfirmationTimeout:              machine.actionConfirmationTimeout,
 
 			EventDKGDealConfirmationReceived:              machine.actionDealConfirmationReceived,
 			EventDKGDealConfirmationError:                 machine.actionConfirmationError,
 			eventAutoDKGValidateConfirmationDealsInternal: machine.actionValidateDkgProposalAwaitDeals,
+			EventDKGDealsConfirmationTimeout:              machine.actionConfirmationTimeout,
 
 			EventDKGResponseConfirmationReceived:              machine.actionResponseConfirmationReceived,
 			EventDKGResponseConfirmationError:                 machine.actionConfirmationError,
 			eventAutoDKGValidateResponsesConfirmationInternal: machine.actionValidateDkgProposalAwaitResponses,
+			EventDKGResponsesConfirmationTimeout:              machine.actionConfirmationTimeout,
+
+			EventDKGJustificationConfirmationReceived:              machine.actionJustificationConfirmationReceived,
+			EventDKGJustificationConfirmationError:                 machine.actionConfirmationError,
+			eventAutoDKGValidateJustificationsConfirmationInternal: machine.actionValidateDkgProposalAwaitJustifications,
+			EventDKGJustificationsConfirmationTimeout:              machine.actionJustificationsConfirmationTimeout,
 
 			EventDKGMasterKeyConfirmationReceived:             machine.actionMasterKeyConfirmationReceived,
 			EventDKGMasterKeyConfirmationError:                machine.actionConfirmationError,
 			eventAutoDKGValidateMasterKeyConfirmationInternal: machine.actionValidateDkgProposalAwaitMasterKey,
+			EventDKGMasterKeyConfirmationTimeout:              machine.actionConfirmationTimeout,
 		},
 	)
 	return machine
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"bytes"
 	"crypto/ed25519"
 	"errors"
 
@@ -19,9 +20,16 @@ type DumpedMachineStatePayload struct {
 	DkgId                    string
 	SignatureProposalPayload *SignatureConfirmation
 	DKGProposalPayload       *DKGConfirmation
-	SigningProposalPayload   *SigningConfirmation
-	PubKeys                  map[string]ed25519.PublicKey
-	IDs                      map[string]int
+	// The signing session the signing machine works with, is set for every single session
+	// from SigningProposalsPayload and never dumped on its own, signings of legacy dumps are migrated on load
+	SigningProposalPayload   *SigningConfirmation `json:"-"`
+	SigningProposalsPayload  SigningProposals
+	ResharingProposalPayload *ResharingConfirmation
+	// ResharingGeneration counts started resharings, so messages of a resharing started again after a rollback
+	// are told from the messages of the rolled back one
+	ResharingGeneration int
+	PubKeys             map[string]ed25519.PublicKey
+	IDs                 map[string]int
 }
 
 // Signature quorum
@@ -55,6 +63,16 @@ func (p *DumpedMachineStatePayload) SigQuorumUpdate(id int, participant *Signatu
 	}
 }
 
+// SigQuorumThreshold returns a number of participants required to sign a message with the round key
+func (p *DumpedMachineStatePayload) SigQuorumThreshold() int {
+	var threshold int
+	for _, participant := range p.SignatureProposalPayload.Quorum {
+		threshold = participant.Threshold
+		break
+	}
+	return threshold
+}
+
 // DKG quorum
 
 func (p *DumpedMachineStatePayload) DKGQuorumCount() int {
@@ -86,6 +104,70 @@ func (p *DumpedMachineStatePayload) DKGQuorumUpdate(id int, participant *DKGProp
 	}
 }
 
+// DKGPubPoly returns the public polynomial confirmed by the quorum, it's empty for rounds finished without it
+func (p *DumpedMachineStatePayload) DKGPubPoly() []byte {
+	for _, participant := range p.DKGProposalPayload.Quorum {
+		if participant.Status == MasterKeyConfirmed && len(participant.DkgPubPoly) > 0 {
+			return participant.DkgPubPoly
+		}
+	}
+	return nil
+}
+
+// DKGMasterKey returns the master public key confirmed by the quorum, it's empty until the DKG is finished.
+// A DKG is finished by a threshold of matching master keys, participants who didn't confirm the key
+// or confirmed another one are marked with an error then
+func (p *DumpedMachineStatePayload) DKGMasterKey() []byte {
+	if p.DKGProposalPayload == nil || len(p.DKGProposalPayload.Quorum) == 0 {
+		return nil
+	}
+	var masterKey []byte
+	for _, participant := range p.DKGProposalPayload.Quorum {
+		switch participant.Status {
+		case MasterKeyConfirmationError:
+			continue
+		case MasterKeyConfirmed:
+		default:
+			return nil
+		}
+		if len(participant.DkgMasterKey) == 0 {
+			return nil
+		}
+		if masterKey != nil && !bytes.Equal(masterKey, participant.DkgMasterKey) {
+			return nil
+		}
+		masterKey = participant.DkgMasterKey
+	}
+	return masterKey
+}
+
+// DKGQuorumShareIndex returns an index of the participant's key share, shares are ordered by participant ids
+func (p *DumpedMachineStatePayload) DKGQuorumShareIndex(id int) int {
+	var index int
+	for participantId := range p.DKGProposalPayload.Quorum {
+		if participantId < id {
+			index++
+		}
+	}
+	return index
+}
+
+// Signing sessions
+
+func (p *DumpedMachineStatePayload) SigningProposalGet(signingId string) (signing *SigningConfirmation) {
+	if p.SigningProposalsPayload != nil {
+		signing = p.SigningProposalsPayload[signingId]
+	}
+	return
+}
+
+func (p *DumpedMachineStatePayload) SigningProposalUpdate(signingId string, signing *SigningConfirmation) {
+	if p.SigningProposalsPayload == nil {
+		p.SigningProposalsPayload = make(SigningProposals)
+	}
+	p.SigningProposalsPayload[signingId] = signing
+}
+
 // Signing quorum
 
 func (p *DumpedMachineStatePayload) SigningQuorumCount() int {
@@ -117,6 +199,66 @@ func (p *DumpedMachineStatePayload) SigningQuorumUpdate(id int, participant *Sig
 	}
 }
 
+// Resharing quorums
+
+func (p *DumpedMachineStatePayload) ResharingDealersCount() int {
+	var count int
+	if p.ResharingProposalPayload.Dealers != nil {
+		count = len(p.ResharingProposalPayload.Dealers)
+	}
+	return count
+}
+
+func (p *DumpedMachineStatePayload) ResharingDealersExists(id int) bool {
+	var exists bool
+	if p.ResharingProposalPayload.Dealers != nil {
+		_, exists = p.ResharingProposalPayload.Dealers[id]
+	}
+	return exists
+}
+
+func (p *DumpedMachineStatePayload) ResharingDealersGet(id int) (participant *ResharingProposalParticipant) {
+	if p.ResharingProposalPayload.Dealers != nil {
+		participant = p.ResharingProposalPayload.Dealers[id]
+	}
+	return
+}
+
+func (p *DumpedMachineStatePayload) ResharingDealersUpdate(id int, participant *ResharingProposalParticipant) {
+	if p.ResharingProposalPayload.Dealers != nil {
+		p.ResharingProposalPayload.Dealers[id] = participant
+	}
+}
+
+func (p *DumpedMachineStatePayload) ResharingReceiversCount() int {
+	var count int
+	if p.ResharingProposalPayload.Receivers != nil {
+		count = len(p.ResharingProposalPayload.Receivers)
+	}
+	return count
+}
+
+func (p *DumpedMachineStatePayload) ResharingReceiversExists(id int) bool {
+	var exists bool
+	if p.ResharingProposalPayload.Receivers != nil {
+		_, exists = p.ResharingProposalPayload.Receivers[id]
+	}
+	return exists
+}
+
+func (p *DumpedMachineStatePayload) ResharingReceiversGet(id int) (participant *ResharingProposalParticipant) {
+	if p.ResharingProposalPayload.Receivers != nil {
+		participant = p.ResharingProposalPayload.Receivers[id]
+	}
+	return
+}
+
+func (p *DumpedMachineStatePayload) ResharingReceiversUpdate(id int, participant *ResharingProposalParticipant) {
+	if p.ResharingProposalPayload.Receivers != nil {
+		p.ResharingProposalPayload.Receivers[id] = participant
+	}
+}
+
 func (p *DumpedMachineStatePayload) SetPubKeyUsername(username string, pubKey ed25519.PublicKey) {
 	if p.PubKeys == nil {
 		p.PubKeys = make(map[string]ed25519.PublicKey)
@@ -146,6 +288,25 @@ func (p *DumpedMachineStatePayload) GetPubKeyByUsername(username string) (ed2551
 	return pubKey, nil
 }
 
+// GetDkgPubKeyByUsername returns the DKG public key of the participant from the DKG proposal,
+// a new share holder of a resharing is found in the resharing proposal
+func (p *DumpedMachineStatePayload) GetDkgPubKeyByUsername(username string) ([]byte, error) {
+	if id, err := p.GetIDByUsername(username); err == nil && p.SignatureProposalPayload != nil &&
+		p.SignatureProposalPayload.Quorum[id] != nil {
+		return p.SignatureProposalPayload.Quorum[id].DkgPubKey, nil
+	}
+
+	if p.ResharingProposalPayload != nil {
+		for _, participant := range p.ResharingProposalPayload.Receivers {
+			if participant.Username == username {
+				return participant.DkgPubKey, nil
+			}
+		}
+	}
+
+	return nil, errors.New("cannot find participant by {username}")
+}
+
 func (p *DumpedMachineStatePayload) GetIDByUsername(username string) (int, error) {
 	if p.IDs == nil {
 		return -1, errors.New("{IDs} not initialized")
@@ -2,7 +2,11 @@ package internal
 
 import (
 	"crypto/ed25519"
+	"encoding/json"
 	"time"
+
+	"github.com/lidofinance/dc4bc/eth2"
+	"github.com/lidofinance/dc4bc/fsm/fsm"
 )
 
 type ParticipantStatus interface {
@@ -84,18 +88,29 @@ const (
 	MasterKeyAwaitConfirmation
 	MasterKeyConfirmed
 	MasterKeyConfirmationError
+	JustificationAwaitConfirmation
+	JustificationConfirmed
+	JustificationConfirmationError
 )
 
 type DKGProposalParticipant struct {
-	Username     string
-	DkgPubKey    []byte
-	DkgCommit    []byte
-	DkgDeal      []byte
-	DkgResponse  []byte
-	DkgMasterKey []byte
-	Status       DKGParticipantStatus
-	Error        error
-	UpdatedAt    time.Time
+	Username    string
+	DkgPubKey   []byte
+	DkgCommit   []byte
+	DkgDeal     []byte
+	DkgResponse []byte
+	// DkgComplaints are participant IDs of dealers whose deals the participant did not accept
+	DkgComplaints    []int
+	DkgJustification []byte
+	DkgMasterKey     []byte
+	DkgPubPoly       []byte
+	Status           DKGParticipantStatus
+	Error            *ParticipantError
+	UpdatedAt        time.Time
+
+	// DkgDisqualified is set if the participant didn't answer complaints against its deal in time,
+	// its deal is left out of the key and its master key is not counted
+	DkgDisqualified bool
 }
 
 func (dkgP DKGProposalParticipant) GetStatus() ParticipantStatus {
@@ -148,28 +163,62 @@ func (s DKGParticipantStatus) String() string {
 		str = "MasterKeyConfirmed"
 	case MasterKeyConfirmationError:
 		str = "MasterKeyConfirmationError"
+	case JustificationAwaitConfirmation:
+		str = "JustificationAwaitConfirmation"
+	case JustificationConfirmed:
+		str = "JustificationConfirmed"
+	case JustificationConfirmationError:
+		str = "JustificationConfirmationError"
 	}
 	return str
 }
 
 // Signing proposal
 
+// SigningConfirmation is a signing session of the round, a single payload is signed as a batch of one
 type SigningConfirmation struct {
 	SigningId        string
+	State            fsm.State
 	InitiatorId      int
 	Quorum           SigningProposalQuorum
 	RecoveredKey     []byte
-	SrcPayload       []byte
+	SrcPayloads      [][]byte
+	Eth2Requests     []*eth2.SigningRequest
 	EncryptedPayload []byte
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
 	ExpiresAt        time.Time
 }
 
+// UnmarshalJSON decodes a single payload of a signing dumped before batches as a batch of one
+func (c *SigningConfirmation) UnmarshalJSON(data []byte) error {
+	type signing SigningConfirmation
+	var legacy struct {
+		signing
+		SrcPayload  []byte
+		Eth2Request *eth2.SigningRequest
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*c = SigningConfirmation(legacy.signing)
+	if len(c.SrcPayloads) == 0 && len(legacy.SrcPayload) > 0 {
+		c.SrcPayloads = [][]byte{legacy.SrcPayload}
+		if legacy.Eth2Request != nil {
+			c.Eth2Requests = []*eth2.SigningRequest{legacy.Eth2Request}
+		}
+	}
+	return nil
+}
+
 func (c *SigningConfirmation) IsExpired() bool {
 	return c.ExpiresAt.Before(c.UpdatedAt)
 }
 
+// SigningProposals keeps all signing sessions of the round by their SigningId
+type SigningProposals map[string]*SigningConfirmation
+
 type SigningProposalQuorum map[int]*SigningProposalParticipant
 
 type SigningParticipantStatus uint8
@@ -203,12 +252,46 @@ func (s SigningParticipantStatus) String() string {
 	return str
 }
 
+// ParticipantError is an error of a participant kept in the dump, an error value doesn't survive the JSON encoding
+type ParticipantError string
+
+func NewParticipantError(err error) *ParticipantError {
+	if err == nil {
+		return nil
+	}
+	e := ParticipantError(err.Error())
+	return &e
+}
+
+func (e *ParticipantError) Error() string {
+	return string(*e)
+}
+
+// SigningProposalParticipant keeps a partial sign of the participant for each of the payloads of the signing
 type SigningProposalParticipant struct {
-	Username    string
-	Status      SigningParticipantStatus
-	PartialSign []byte
-	Error       error
-	UpdatedAt   time.Time
+	Username     string
+	Status       SigningParticipantStatus
+	PartialSigns [][]byte
+	Error        *ParticipantError
+	UpdatedAt    time.Time
+}
+
+// UnmarshalJSON decodes a single partial sign of a signing dumped before batches as a batch of one
+func (signingP *SigningProposalParticipant) UnmarshalJSON(data []byte) error {
+	type participant SigningProposalParticipant
+	var legacy struct {
+		participant
+		PartialSign []byte
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*signingP = SigningProposalParticipant(legacy.participant)
+	if len(signingP.PartialSigns) == 0 && len(legacy.PartialSign) > 0 {
+		signingP.PartialSigns = [][]byte{legacy.PartialSign}
+	}
+	return nil
 }
 
 func (signingP SigningProposalParticipant) GetStatus() ParticipantStatus {
@@ -218,3 +301,49 @@ func (signingP SigningProposalParticipant) GetStatus() ParticipantStatus {
 func (signingP SigningProposalParticipant) GetUsername() string {
 	return signingP.Username
 }
+
+// Resharing proposal
+
+type ResharingProposalParticipant struct {
+	Username     string
+	PubKey       ed25519.PublicKey
+	DkgPubKey    []byte
+	DkgCommit    []byte
+	DkgPubPoly   []byte
+	DkgDeals     map[int][]byte
+	DkgResponse  []byte
+	DkgMasterKey []byte
+	Status       DKGParticipantStatus
+	Error        *ParticipantError
+	UpdatedAt    time.Time
+}
+
+func (resharingP ResharingProposalParticipant) GetStatus() ParticipantStatus {
+	return resharingP.Status
+}
+
+func (resharingP ResharingProposalParticipant) GetUsername() string {
+	return resharingP.Username
+}
+
+type ResharingProposalQuorum map[int]*ResharingProposalParticipant
+
+// ResharingConfirmation keeps both sides of a resharing: dealers are the current share holders
+// keyed by their current participant id, receivers are the new share holders keyed by their new id.
+// Approvals are the answers of the current share holders to the proposal, dealing starts after a threshold of them
+type ResharingConfirmation struct {
+	InitiatorId  int
+	OldThreshold int
+	NewThreshold int
+	MasterKey    []byte
+	Approvals    map[int]ConfirmationParticipantStatus
+	Dealers      ResharingProposalQuorum
+	Receivers    ResharingProposalQuorum
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+func (c *ResharingConfirmation) IsExpired() bool {
+	return c.ExpiresAt.Before(c.UpdatedAt)
+}
@@ -1,11 +1,15 @@
 package state_machines
 
 import (
+	"bytes"
 	"crypto/ed25519"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
 	"strings"
+	"time"
 
 	"github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
 
@@ -15,6 +19,12 @@ import (
 	"github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
 )
 
+// Timeout is an event which cancels a stage of the round or a signing session after its deadline
+type Timeout struct {
+	Event     fsm.Event
+	SigningId string
+}
+
 // Is machine state scope dump will be locked?
 type FSMDump struct {
 	TransactionId string
@@ -49,6 +59,7 @@ func Create(dkgID string) (*FSMInstance, error) {
 		signature_proposal_fsm.New(),
 		dkg_proposal_fsm.New(),
 		signing_proposal_fsm.New(),
+		resharing_proposal_fsm.New(),
 	)
 
 	machine, err := fsmPoolProvider.EntryPointMachine()
@@ -69,6 +80,7 @@ func FromDump(data []byte) (*FSMInstance, error) {
 		signature_proposal_fsm.New(),
 		dkg_proposal_fsm.New(),
 		signing_proposal_fsm.New(),
+		resharing_proposal_fsm.New(),
 	)
 
 	i := &FSMInstance{
@@ -99,12 +111,25 @@ func (i *FSMInstance) GetPubKeyByUsername(username string) (ed25519.PublicKey, e
 	return i.dump.Payload.GetPubKeyByUsername(username)
 }
 
-func (i *FSMInstance) SigningQuorumGetParticipant(id int) (*internal.SigningProposalParticipant, error) {
+func (i *FSMInstance) GetDkgPubKeyByUsername(username string) ([]byte, error) {
+	if i.dump == nil {
+		return nil, errors.New("dump not initialized")
+	}
+
+	return i.dump.Payload.GetDkgPubKeyByUsername(username)
+}
+
+func (i *FSMInstance) SigningQuorumGetParticipant(signingId string, id int) (*internal.SigningProposalParticipant, error) {
 	if i.dump == nil {
 		return nil, errors.New("dump not initialized")
 	}
 
-	return i.dump.Payload.SigningQuorumGet(id), nil
+	signing := i.dump.Payload.SigningProposalGet(signingId)
+	if signing == nil {
+		return nil, fmt.Errorf("signing {%s} not found", signingId)
+	}
+
+	return signing.Quorum[id], nil
 }
 
 func (i *FSMInstance) GetIDByUsername(username string) (int, error) {
@@ -115,6 +140,15 @@ func (i *FSMInstance) GetIDByUsername(username string) (int, error) {
 	return i.dump.Payload.GetIDByUsername(username)
 }
 
+// ResharingGeneration returns the number of resharings started in the round
+func (i *FSMInstance) ResharingGeneration() (int, error) {
+	if i.dump == nil {
+		return 0, errors.New("dump not initialized")
+	}
+
+	return i.dump.Payload.ResharingGeneration, nil
+}
+
 func (i *FSMInstance) Do(event fsm.Event, args ...interface{}) (result *fsm.Response, dump []byte, err error) {
 	var dumpErr error
 
@@ -137,6 +171,132 @@ func (i *FSMInstance) Do(event fsm.Event, args ...interface{}) (result *fsm.Resp
 	return result, dump, err
 }
 
+// DoSigning executes the event for the signing session with the given id,
+// the round itself stays in the idle signing state, so many signings can be processed at once
+func (i *FSMInstance) DoSigning(signingId string, event fsm.Event, args ...interface{}) (result *fsm.Response, dump []byte, err error) {
+	if i.machine == nil {
+		return nil, []byte{}, errors.New("machine is not initialized")
+	}
+
+	if i.machine.State() != signing_proposal_fsm.StateSigningIdle {
+		return nil, []byte{}, fmt.Errorf("cannot process signing in state \"%s\"", i.machine.State())
+	}
+
+	signing := i.dump.Payload.SigningProposalGet(signingId)
+	if signing == nil {
+		if event != signing_proposal_fsm.EventSigningStart {
+			return nil, []byte{}, fmt.Errorf("signing {%s} not found", signingId)
+		}
+		signing = &internal.SigningConfirmation{
+			SigningId: signingId,
+			State:     signing_proposal_fsm.StateSigningIdle,
+		}
+	}
+
+	// Late messages, e.g. partial signs above the threshold, could arrive after the signing is finished
+	if signing_proposal_fsm.IsSigningFinished(signing.State) {
+		return nil, []byte{}, fmt.Errorf("signing {%s} is already finished in state \"%s\"", signingId, signing.State)
+	}
+
+	// Every signing session gets its own machine, which sees the round payload with the session set
+	payload := *i.dump.Payload
+	payload.SigningProposalPayload = signing
+
+	machine := signing_proposal_fsm.New().WithSetup(signing.State, &payload)
+
+	result, err = machine.Do(event, args...)
+	if err != nil {
+		return result, []byte{}, err
+	}
+
+	signing.State = result.State
+	i.dump.Payload.SigningProposalUpdate(signingId, signing)
+
+	dump, err = i.dump.Marshal()
+	if err != nil {
+		return result, []byte{}, err
+	}
+
+	return result, dump, nil
+}
+
+// SigningState returns the state of the signing session with the given id
+func (i *FSMInstance) SigningState(signingId string) (fsm.State, error) {
+	if i.dump == nil {
+		return "", errors.New("dump not initialized")
+	}
+
+	signing := i.dump.Payload.SigningProposalGet(signingId)
+	if signing == nil {
+		return "", fmt.Errorf("signing {%s} not found", signingId)
+	}
+
+	return signing.State, nil
+}
+
+// stageTimeouts are the timeout events of the stages of the round and of the signing sessions
+var stageTimeouts = map[fsm.State]fsm.Event{
+	dkg_proposal_fsm.StateDkgCommitsAwaitConfirmations:        dkg_proposal_fsm.EventDKGCommitsConfirmationTimeout,
+	dkg_proposal_fsm.StateDkgDealsAwaitConfirmations:          dkg_proposal_fsm.EventDKGDealsConfirmationTimeout,
+	dkg_proposal_fsm.StateDkgResponsesAwaitConfirmations:      dkg_proposal_fsm.EventDKGResponsesConfirmationTimeout,
+	dkg_proposal_fsm.StateDkgJustificationsAwaitConfirmations: dkg_proposal_fsm.EventDKGJustificationsConfirmationTimeout,
+	dkg_proposal_fsm.StateDkgMasterKeyAwaitConfirmations:      dkg_proposal_fsm.EventDKGMasterKeyConfirmationTimeout,
+
+	resharing_proposal_fsm.StateResharingProposalAwaitConfirmations:  resharing_proposal_fsm.EventResharingProposalTimeout,
+	resharing_proposal_fsm.StateResharingDealsAwaitConfirmations:     resharing_proposal_fsm.EventResharingDealsConfirmationTimeout,
+	resharing_proposal_fsm.StateResharingResponsesAwaitConfirmations: resharing_proposal_fsm.EventResharingResponsesConfirmationTimeout,
+	resharing_proposal_fsm.StateResharingMasterKeyAwaitConfirmations: resharing_proposal_fsm.EventResharingMasterKeyConfirmationTimeout,
+
+	signing_proposal_fsm.StateSigningAwaitConfirmations: signing_proposal_fsm.EventSigningConfirmationsTimeout,
+	signing_proposal_fsm.StateSigningAwaitPartialSigns:  signing_proposal_fsm.EventSigningPartialSignsTimeout,
+}
+
+// ExpiredTimeouts returns timeouts for the current stage of the round and for the signing sessions,
+// which deadlines have passed by the given time
+func (i *FSMInstance) ExpiredTimeouts(now time.Time) ([]Timeout, error) {
+	if i.machine == nil {
+		return nil, errors.New("machine is not initialized")
+	}
+
+	var (
+		timeouts = make([]Timeout, 0)
+		payload  = i.dump.Payload
+	)
+	switch state := i.machine.State(); state {
+	case signature_proposal_fsm.StateAwaitParticipantsConfirmations:
+		if payload.SignatureProposalPayload.ExpiresAt.Before(now) {
+			timeouts = append(timeouts, Timeout{Event: signature_proposal_fsm.EventSignatureProposalTimeout})
+		}
+	case dkg_proposal_fsm.StateDkgCommitsAwaitConfirmations,
+		dkg_proposal_fsm.StateDkgDealsAwaitConfirmations,
+		dkg_proposal_fsm.StateDkgResponsesAwaitConfirmations,
+		dkg_proposal_fsm.StateDkgJustificationsAwaitConfirmations,
+		dkg_proposal_fsm.StateDkgMasterKeyAwaitConfirmations:
+		if payload.DKGProposalPayload.ExpiresAt.Before(now) {
+			timeouts = append(timeouts, Timeout{Event: stageTimeouts[state]})
+		}
+	case resharing_proposal_fsm.StateResharingProposalAwaitConfirmations,
+		resharing_proposal_fsm.StateResharingDealsAwaitConfirmations,
+		resharing_proposal_fsm.StateResharingResponsesAwaitConfirmations,
+		resharing_proposal_fsm.StateResharingMasterKeyAwaitConfirmations:
+		if payload.ResharingProposalPayload.ExpiresAt.Before(now) {
+			timeouts = append(timeouts, Timeout{Event: stageTimeouts[state]})
+		}
+	case signing_proposal_fsm.StateSigningIdle:
+		for signingId, signing := range payload.SigningProposalsPayload {
+			event, ok := stageTimeouts[signing.State]
+			if !ok {
+				continue
+			}
+			if signing.ExpiresAt.Before(now) {
+				timeouts = append(timeouts, Timeout{Event: event, SigningId: signingId})
+			}
+		}
+	}
+
+	return timeouts, nil
+}
+
 func (i *FSMInstance) InitDump(dkgID string) error {
 	if i.dump != nil {
 		return errors.New("dump already initialized")
@@ -196,5 +356,62 @@ func (d *FSMDump) Unmarshal(data []byte) error {
 		return errors.New("dump is not initialized")
 	}
 
-	return json.Unmarshal(data, d)
+	if err := json.Unmarshal(data, d); err != nil {
+		return err
+	}
+
+	return d.migrateSigningProposal(data)
+}
+
+// legacyFSMDump is a dump made before signing sessions were kept by their ids,
+// the only signing of the round was kept in the payload and the round was in its state
+type legacyFSMDump struct {
+	Payload *struct {
+		SigningProposalPayload *internal.SigningConfirmation
+	}
+}
+
+// migrateSigningProposal moves the signing of a legacy dump to the signing sessions of the round
+// and resets the round to the idle signing state, so the signing is continued by its id
+func (d *FSMDump) migrateSigningProposal(data []byte) error {
+	if !bytes.Contains(data, []byte(`"SigningProposalPayload"`)) {
+		return nil
+	}
+
+	var legacy legacyFSMDump
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	if legacy.Payload == nil || legacy.Payload.SigningProposalPayload == nil || d.Payload == nil {
+		return nil
+	}
+
+	switch d.State {
+	case signing_proposal_fsm.StateSigningAwaitConfirmations,
+		signing_proposal_fsm.StateSigningConfirmationsAwaitCancelledByTimeout,
+		signing_proposal_fsm.StateSigningConfirmationsAwaitCancelledByParticipant,
+		signing_proposal_fsm.StateSigningAwaitPartialSigns,
+		signing_proposal_fsm.StateSigningPartialSignsAwaitCancelledByTimeout,
+		signing_proposal_fsm.StateSigningPartialSignsAwaitCancelledByError,
+		signing_proposal_fsm.StateSigningPartialSignsCollected:
+	default:
+		// The payload of an idle round is only a leftover of the signing init
+		return nil
+	}
+
+	signing := legacy.Payload.SigningProposalPayload
+	if signing.SigningId == "" {
+		return fmt.Errorf("signing of legacy dump in state \"%s\" has no id", d.State)
+	}
+	signing.State = d.State
+
+	if d.Payload.SigningProposalsPayload == nil {
+		d.Payload.SigningProposalsPayload = make(internal.SigningProposals)
+	}
+	if _, exists := d.Payload.SigningProposalsPayload[signing.SigningId]; !exists {
+		d.Payload.SigningProposalsPayload[signing.SigningId] = signing
+	}
+	d.State = signing_proposal_fsm.StateSigningIdle
+
+	return nil
 }
@@ -4,17 +4,28 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/corestario/kyber/pairing"
+	bls12381 "github.com/corestario/kyber/pairing/bls12381"
+	"github.com/corestario/kyber/share"
+	"github.com/corestario/kyber/sign/tbls"
 	"github.com/stretchr/testify/require"
 
+	"github.com/lidofinance/dc4bc/dkg"
+	"github.com/lidofinance/dc4bc/eth2"
 	sif "github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
 
+	"github.com/lidofinance/dc4bc/fsm/config"
 	"github.com/lidofinance/dc4bc/fsm/fsm"
 	dpf "github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/internal"
+	rpf "github.com/lidofinance/dc4bc/fsm/state_machines/resharing_proposal_fsm"
 	spf "github.com/lidofinance/dc4bc/fsm/state_machines/signature_proposal_fsm"
 	"github.com/lidofinance/dc4bc/fsm/types/requests"
 	"github.com/lidofinance/dc4bc/fsm/types/responses"
@@ -26,14 +37,13 @@ const (
 )
 
 type testParticipantsPayload struct {
-	Username      string
-	HotPrivKey    ed25519.PrivateKey
-	HotPubKey     ed25519.PublicKey
-	DkgPubKey     []byte
-	DkgCommit     []byte
-	DkgDeal       []byte
-	DkgResponse   []byte
-	DkgPartialKey []byte
+	Username    string
+	HotPrivKey  ed25519.PrivateKey
+	HotPubKey   ed25519.PublicKey
+	DkgPubKey   []byte
+	DkgCommit   []byte
+	DkgDeal     []byte
+	DkgResponse []byte
 }
 
 var (
@@ -55,6 +65,11 @@ var (
 	testSigningInitiator int
 	testSigningPayload   = []byte("message to sign")
 
+	// the round key, partial signs are verified against its public polynomial
+	testSuite   = bls12381.NewBLS12381Suite(nil).(pairing.Suite)
+	testPriPoly *share.PriPoly
+	testPubPoly []byte
+
 	testFSMDump = map[fsm.State][]byte{}
 )
 
@@ -62,17 +77,22 @@ func init() {
 	for i := 0; i < 3; i++ {
 
 		participant := &testParticipantsPayload{
-			Username:      base64.StdEncoding.EncodeToString(genDataMock(usernameMockLen)),
-			HotPrivKey:    genDataMock(keysMockLen),
-			HotPubKey:     genDataMock(keysMockLen),
-			DkgPubKey:     genDataMock(keysMockLen),
-			DkgCommit:     genDataMock(keysMockLen),
-			DkgDeal:       genDataMock(keysMockLen),
-			DkgResponse:   genDataMock(keysMockLen),
-			DkgPartialKey: genDataMock(keysMockLen),
+			Username:    base64.StdEncoding.EncodeToString(genDataMock(usernameMockLen)),
+			HotPrivKey:  genDataMock(keysMockLen),
+			HotPubKey:   genDataMock(keysMockLen),
+			DkgPubKey:   genDataMock(keysMockLen),
+			DkgCommit:   genDataMock(keysMockLen),
+			DkgDeal:     genDataMock(keysMockLen),
+			DkgResponse: genDataMock(keysMockLen),
 		}
 		testUsernameMapParticipants[participant.Username] = participant
 	}
+
+	var err error
+	testPriPoly = share.NewPriPoly(testSuite.G1(), 2, nil, testSuite.RandomStream())
+	if testPubPoly, err = dkg.PubPolyBytes(testPriPoly.Commit(nil)); err != nil {
+		panic(err)
+	}
 }
 
 func TestCreate_Positive(t *testing.T) {
@@ -92,6 +112,13 @@ func genDataMock(len int) []byte {
 	return data
 }
 
+// genPartialSign signs the message with the key share of the participant, ids of test participants match their shares
+func genPartialSign(t *testing.T, participantId int, msg []byte) []byte {
+	partialSign, err := tbls.Sign(testSuite, testPriPoly.Eval(participantId), msg)
+	require.NoError(t, err)
+	return partialSign
+}
+
 func compareErrNil(t *testing.T, got error) {
 	if got != nil {
 		t.Fatalf("expected nil error, got {%s}", got)
@@ -310,6 +337,41 @@ func Test_SignatureProposal_EventConfirmSignatureProposal_Canceled_Timeout(t *te
 	compareState(t, spf.StateValidationCanceledByTimeout, fsmResponse.State)
 }
 
+func Test_SignatureProposal_EventSignatureProposalTimeout(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[spf.StateAwaitParticipantsConfirmations])
+
+	compareErrNil(t, err)
+
+	compareFSMInstanceNotNil(t, testFSMInstance)
+
+	timeouts, err := testFSMInstance.ExpiredTimeouts(time.Now())
+	compareErrNil(t, err)
+	require.Empty(t, timeouts)
+
+	// The deadline is not reached yet
+	_, _, err = testFSMInstance.Do(spf.EventSignatureProposalTimeout, requests.DefaultRequest{
+		CreatedAt: time.Now(),
+	})
+
+	require.Error(t, err)
+
+	timeouts, err = testFSMInstance.ExpiredTimeouts(time.Now().Add(36 * time.Hour))
+	compareErrNil(t, err)
+	require.Equal(t, []Timeout{{Event: spf.EventSignatureProposalTimeout}}, timeouts)
+
+	fsmResponse, testFSMDumpLocal, err := testFSMInstance.Do(timeouts[0].Event, requests.DefaultRequest{
+		CreatedAt: time.Now().Add(36 * time.Hour),
+	})
+
+	compareErrNil(t, err)
+
+	compareDumpNotZero(t, testFSMDumpLocal)
+
+	compareFSMResponseNotNil(t, fsmResponse)
+
+	compareState(t, spf.StateValidationCanceledByTimeout, fsmResponse.State)
+}
+
 func Test_DkgProposal_EventDKGInitProcess_Positive(t *testing.T) {
 	var fsmResponse *fsm.Response
 
@@ -604,6 +666,43 @@ func Test_DkgProposal_EventDKGDealConfirmationReceived_Canceled_Timeout(t *testi
 
 }
 
+func Test_DkgProposal_EventDKGDealsConfirmationTimeout(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[dpf.StateDkgDealsAwaitConfirmations])
+
+	compareErrNil(t, err)
+
+	compareFSMInstanceNotNil(t, testFSMInstance)
+
+	_, _, err = testFSMInstance.Do(dpf.EventDKGDealsConfirmationTimeout, requests.DefaultRequest{
+		CreatedAt: time.Now(),
+	})
+
+	require.Error(t, err)
+
+	// the timeout of another stage doesn't apply
+	_, _, err = testFSMInstance.Do(dpf.EventDKGCommitsConfirmationTimeout, requests.DefaultRequest{
+		CreatedAt: time.Now().Add(36 * time.Hour),
+	})
+
+	require.Error(t, err)
+
+	timeouts, err := testFSMInstance.ExpiredTimeouts(time.Now().Add(36 * time.Hour))
+	compareErrNil(t, err)
+	require.Equal(t, []Timeout{{Event: dpf.EventDKGDealsConfirmationTimeout}}, timeouts)
+
+	fsmResponse, testFSMDumpLocal, err := testFSMInstance.Do(dpf.EventDKGDealsConfirmationTimeout, requests.DefaultRequest{
+		CreatedAt: time.Now().Add(36 * time.Hour),
+	})
+
+	compareErrNil(t, err)
+
+	compareFSMResponseNotNil(t, fsmResponse)
+
+	compareDumpNotZero(t, testFSMDumpLocal)
+
+	compareState(t, dpf.StateDkgDealsAwaitCanceledByTimeout, fsmResponse.State)
+}
+
 // Responses
 func Test_DkgProposal_EventDKGResponseConfirmationReceived_Positive(t *testing.T) {
 	var (
@@ -723,6 +822,206 @@ func Test_DkgProposal_EventDKGResponseConfirmationReceived_Canceled_Timeout(t *t
 
 }
 
+// runDKGResponsesWithComplaint confirms the responses of the quorum, one participant complains
+// about the deal of another one. It returns the dump in the justifications stage and the accused dealer
+func runDKGResponsesWithComplaint(t *testing.T, dump []byte) ([]byte, int) {
+	var (
+		fsmResponse *fsm.Response
+		complainer  = -1
+		accused     = -1
+	)
+
+	for participantId := range testIdMapParticipants {
+		if complainer < 0 {
+			complainer = participantId
+		} else if accused < 0 {
+			accused = participantId
+		}
+	}
+
+	for participantId, participant := range testIdMapParticipants {
+		testFSMInstance, err := FromDump(dump)
+
+		compareErrNil(t, err)
+
+		compareFSMInstanceNotNil(t, testFSMInstance)
+
+		var complaints []int
+		if participantId == complainer {
+			complaints = []int{accused}
+		}
+
+		fsmResponse, dump, err = testFSMInstance.Do(dpf.EventDKGResponseConfirmationReceived, requests.DKGProposalResponseConfirmationRequest{
+			ParticipantId: participantId,
+			Response:      participant.DkgResponse,
+			Complaints:    complaints,
+			CreatedAt:     tm,
+		})
+
+		compareErrNil(t, err)
+
+		compareFSMResponseNotNil(t, fsmResponse)
+	}
+
+	compareState(t, dpf.StateDkgJustificationsAwaitConfirmations, fsmResponse.State)
+
+	return dump, accused
+}
+
+func Test_DkgProposal_EventDKGJustificationConfirmationReceived_Positive(t *testing.T) {
+	testFSMDumpLocal, accused := runDKGResponsesWithComplaint(t, testFSMDump[dpf.StateDkgResponsesAwaitConfirmations])
+
+	justificationMockup := genDataMock(keysMockLen)
+
+	testFSMInstance, err := FromDump(testFSMDumpLocal)
+
+	compareErrNil(t, err)
+
+	compareFSMInstanceNotNil(t, testFSMInstance)
+
+	// only the accused dealer has to answer complaints
+	fsmResponse, testFSMDumpLocal, err := testFSMInstance.Do(dpf.EventDKGJustificationConfirmationReceived, requests.DKGProposalJustificationConfirmationRequest{
+		ParticipantId: accused,
+		Justification: justificationMockup,
+		CreatedAt:     tm,
+	})
+
+	compareErrNil(t, err)
+
+	compareFSMResponseNotNil(t, fsmResponse)
+
+	compareDumpNotZero(t, testFSMDumpLocal)
+
+	compareState(t, dpf.StateDkgMasterKeyAwaitConfirmations, fsmResponse.State)
+
+	response, ok := fsmResponse.Data.(responses.DKGProposalResponseParticipantResponse)
+
+	if !ok {
+		t.Fatalf("expected response {DKGProposalResponseParticipantResponse}")
+	}
+
+	require.Len(t, response, len(testIdMapParticipants))
+	for _, responseEntry := range response {
+		if responseEntry.ParticipantId == accused {
+			require.Equal(t, justificationMockup, responseEntry.DkgJustification)
+		} else {
+			require.Empty(t, responseEntry.DkgJustification)
+		}
+		require.False(t, responseEntry.Disqualified)
+	}
+}
+
+func Test_DkgProposal_EventDKGJustificationConfirmationReceived_Disqualified(t *testing.T) {
+	dump, accused := runDKGResponsesWithComplaint(t, withSigningThreshold(t, testFSMDump[dpf.StateDkgResponsesAwaitConfirmations], 2))
+
+	testFSMInstance, err := FromDump(dump)
+
+	compareErrNil(t, err)
+
+	// the accused dealer doesn't answer in time, the deals of the others are enough to build the key
+	fsmResponse, _, err := testFSMInstance.Do(dpf.EventDKGJustificationsConfirmationTimeout, requests.DefaultRequest{
+		CreatedAt: time.Now().Add(36 * time.Hour),
+	})
+
+	compareErrNil(t, err)
+
+	compareState(t, dpf.StateDkgMasterKeyAwaitConfirmations, fsmResponse.State)
+
+	response, ok := fsmResponse.Data.(responses.DKGProposalResponseParticipantResponse)
+
+	if !ok {
+		t.Fatalf("expected response {DKGProposalResponseParticipantResponse}")
+	}
+
+	for _, responseEntry := range response {
+		require.Equal(t, responseEntry.ParticipantId == accused, responseEntry.Disqualified)
+	}
+}
+
+func Test_DkgProposal_EventDKGJustificationConfirmationReceived_Canceled_Timeout(t *testing.T) {
+	dump, _ := runDKGResponsesWithComplaint(t, testFSMDump[dpf.StateDkgResponsesAwaitConfirmations])
+
+	testFSMInstance, err := FromDump(dump)
+
+	compareErrNil(t, err)
+
+	// the whole quorum is required to build the key, so it can't be built without the accused dealer
+	fsmResponse, _, err := testFSMInstance.Do(dpf.EventDKGJustificationsConfirmationTimeout, requests.DefaultRequest{
+		CreatedAt: time.Now().Add(36 * time.Hour),
+	})
+
+	compareErrNil(t, err)
+
+	compareState(t, dpf.StateDkgJustificationsAwaitCanceledByTimeout, fsmResponse.State)
+}
+
+func Test_DkgProposal_EventDKGJustificationConfirmationError_Disqualified(t *testing.T) {
+	dump, accused := runDKGResponsesWithComplaint(t, withSigningThreshold(t, testFSMDump[dpf.StateDkgResponsesAwaitConfirmations], 2))
+
+	testFSMInstance, err := FromDump(dump)
+
+	compareErrNil(t, err)
+
+	// the accused dealer fails to justify its deal, it's disqualified and the others go on
+	fsmResponse, _, err := testFSMInstance.Do(dpf.EventDKGJustificationConfirmationError, requests.DKGProposalConfirmationErrorRequest{
+		ParticipantId: accused,
+		Error:         errors.New("test error"),
+		CreatedAt:     tm,
+	})
+
+	compareErrNil(t, err)
+
+	compareState(t, dpf.StateDkgMasterKeyAwaitConfirmations, fsmResponse.State)
+
+	response, ok := fsmResponse.Data.(responses.DKGProposalResponseParticipantResponse)
+
+	if !ok {
+		t.Fatalf("expected response {DKGProposalResponseParticipantResponse}")
+	}
+
+	for _, responseEntry := range response {
+		require.Equal(t, responseEntry.ParticipantId == accused, responseEntry.Disqualified)
+	}
+}
+
+func Test_DkgProposal_EventDKGJustificationConfirmationError_Canceled_Error(t *testing.T) {
+	dump, accused := runDKGResponsesWithComplaint(t, testFSMDump[dpf.StateDkgResponsesAwaitConfirmations])
+
+	testFSMInstance, err := FromDump(dump)
+
+	compareErrNil(t, err)
+
+	// the whole quorum is required to build the key, so it can't be built without the failed participant
+	fsmResponse, _, err := testFSMInstance.Do(dpf.EventDKGJustificationConfirmationError, requests.DKGProposalConfirmationErrorRequest{
+		ParticipantId: accused,
+		Error:         errors.New("test error"),
+		CreatedAt:     tm,
+	})
+
+	compareErrNil(t, err)
+
+	compareState(t, dpf.StateDkgJustificationsAwaitCanceledByError, fsmResponse.State)
+}
+
+func Test_DkgProposal_EventDKGResponseConfirmationReceived_UnknownComplaint(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[dpf.StateDkgResponsesAwaitConfirmations])
+
+	compareErrNil(t, err)
+
+	compareFSMInstanceNotNil(t, testFSMInstance)
+
+	_, _, err = testFSMInstance.Do(dpf.EventDKGResponseConfirmationReceived, requests.DKGProposalResponseConfirmationRequest{
+		ParticipantId: 0,
+		Response:      testIdMapParticipants[0].DkgResponse,
+		Complaints:    []int{len(testIdMapParticipants)},
+		CreatedAt:     tm,
+	})
+
+	if err == nil {
+		t.Fatalf("expected error for a complaint against an unknown participant")
+	}
+}
+
 // Master keys
 func Test_DkgProposal_EventDKGMasterKeyConfirmationReceived_Positive(t *testing.T) {
 	var (
@@ -747,6 +1046,7 @@ func Test_DkgProposal_EventDKGMasterKeyConfirmationReceived_Positive(t *testing.
 		fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(dpf.EventDKGMasterKeyConfirmationReceived, requests.DKGProposalMasterKeyConfirmationRequest{
 			ParticipantId: participantId,
 			MasterKey:     masterKeyMockup,
+			PubPoly:       testPubPoly,
 			CreatedAt:     tm,
 		})
 
@@ -759,6 +1059,14 @@ func Test_DkgProposal_EventDKGMasterKeyConfirmationReceived_Positive(t *testing.
 
 	compareState(t, dpf.StateDkgMasterKeyCollected, fsmResponse.State)
 
+	testFSMInstance, err := FromDump(testFSMDumpLocal)
+
+	compareErrNil(t, err)
+
+	if !reflect.DeepEqual(testFSMInstance.dump.Payload.DKGMasterKey(), masterKeyMockup) {
+		t.Fatalf("expected confirmed {DKGMasterKey}")
+	}
+
 	testFSMDump[dpf.StateDkgMasterKeyCollected] = testFSMDumpLocal
 
 	compareDumpNotZero(t, testFSMDump[dpf.StateDkgMasterKeyCollected])
@@ -803,6 +1111,7 @@ func Test_DkgProposal_EventDKGMasterKeyConfirmationReceived_Canceled_Timeout(t *
 	fsmResponse, testFSMDumpLocal, err := testFSMInstance.Do(dpf.EventDKGMasterKeyConfirmationReceived, requests.DKGProposalMasterKeyConfirmationRequest{
 		ParticipantId: 0,
 		MasterKey:     genDataMock(keysMockLen),
+		PubPoly:       testPubPoly,
 		CreatedAt:     time.Now().Add(36 * time.Hour),
 	})
 
@@ -829,6 +1138,7 @@ func Test_DkgProposal_EventDKGMasterKeyConfirmationReceived_Canceled_Mismatched(
 	fsmResponse, testFSMDumpLocal, err := testFSMInstance.Do(dpf.EventDKGMasterKeyConfirmationReceived, requests.DKGProposalMasterKeyConfirmationRequest{
 		ParticipantId: 0,
 		MasterKey:     genDataMock(keysMockLen),
+		PubPoly:       testPubPoly,
 		CreatedAt:     time.Now(),
 	})
 
@@ -841,6 +1151,7 @@ func Test_DkgProposal_EventDKGMasterKeyConfirmationReceived_Canceled_Mismatched(
 	fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(dpf.EventDKGMasterKeyConfirmationReceived, requests.DKGProposalMasterKeyConfirmationRequest{
 		ParticipantId: 1,
 		MasterKey:     genDataMock(keysMockLen),
+		PubPoly:       testPubPoly,
 		CreatedAt:     time.Now(),
 	})
 
@@ -854,7 +1165,85 @@ func Test_DkgProposal_EventDKGMasterKeyConfirmationReceived_Canceled_Mismatched(
 
 }
 
+func Test_DkgProposal_EventDKGMasterKeyConfirmationReceived_Threshold(t *testing.T) {
+	var (
+		fsmResponse      *fsm.Response
+		testFSMDumpLocal = withSigningThreshold(t, testFSMDump[dpf.StateDkgMasterKeyAwaitConfirmations], 2)
+		masterKeyMockup  = genDataMock(keysMockLen)
+	)
+
+	// the first participant confirms another key, the others agree on the key and make the threshold
+	masterKeys := map[int][]byte{0: genDataMock(keysMockLen), 1: masterKeyMockup, 2: masterKeyMockup}
+	expectedStates := map[int]fsm.State{
+		0: dpf.StateDkgMasterKeyAwaitConfirmations,
+		1: dpf.StateDkgMasterKeyAwaitConfirmations,
+		2: dpf.StateDkgMasterKeyCollected,
+	}
+
+	for participantId := 0; participantId < len(masterKeys); participantId++ {
+		testFSMInstance, err := FromDump(testFSMDumpLocal)
+
+		compareErrNil(t, err)
+
+		fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(dpf.EventDKGMasterKeyConfirmationReceived, requests.DKGProposalMasterKeyConfirmationRequest{
+			ParticipantId: participantId,
+			MasterKey:     masterKeys[participantId],
+			PubPoly:       testPubPoly,
+			CreatedAt:     tm,
+		})
+
+		compareErrNil(t, err)
+
+		compareState(t, expectedStates[participantId], fsmResponse.State)
+	}
+
+	testFSMInstance, err := FromDump(testFSMDumpLocal)
+
+	compareErrNil(t, err)
+
+	payload := testFSMInstance.dump.Payload
+	require.Equal(t, masterKeyMockup, payload.DKGMasterKey())
+	require.Equal(t, testPubPoly, payload.DKGPubPoly())
+	require.Equal(t, internal.MasterKeyConfirmationError, payload.DKGQuorumGet(0).Status)
+}
+
 // Signing
+func Test_DkgProposal_EventDKGMasterKeyConfirmationReceived_Canceled_PubPolyMismatched(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[dpf.StateDkgMasterKeyAwaitConfirmations])
+
+	compareErrNil(t, err)
+
+	masterKeyMockup := genDataMock(keysMockLen)
+
+	fsmResponse, testFSMDumpLocal, err := testFSMInstance.Do(dpf.EventDKGMasterKeyConfirmationReceived, requests.DKGProposalMasterKeyConfirmationRequest{
+		ParticipantId: 0,
+		MasterKey:     masterKeyMockup,
+		PubPoly:       testPubPoly,
+		CreatedAt:     time.Now(),
+	})
+
+	compareErrNil(t, err)
+
+	compareFSMResponseNotNil(t, fsmResponse)
+
+	compareDumpNotZero(t, testFSMDumpLocal)
+
+	fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(dpf.EventDKGMasterKeyConfirmationReceived, requests.DKGProposalMasterKeyConfirmationRequest{
+		ParticipantId: 1,
+		MasterKey:     masterKeyMockup,
+		PubPoly:       genDataMock(keysMockLen),
+		CreatedAt:     time.Now(),
+	})
+
+	compareErrNil(t, err)
+
+	compareFSMResponseNotNil(t, fsmResponse)
+
+	compareDumpNotZero(t, testFSMDumpLocal)
+
+	compareState(t, dpf.StateDkgMasterKeyAwaitCanceledByError, fsmResponse.State)
+}
+
 func Test_SigningProposal_EventSigningInit(t *testing.T) {
 	var fsmResponse *fsm.Response
 
@@ -896,10 +1285,10 @@ func Test_SigningProposal_EventSigningStart(t *testing.T) {
 	inState, _ := testFSMInstance.State()
 	compareState(t, sif.StateSigningIdle, inState)
 
-	fsmResponse, testFSMDump[sif.StateSigningAwaitConfirmations], err = testFSMInstance.Do(sif.EventSigningStart, requests.SigningProposalStartRequest{
+	fsmResponse, testFSMDump[sif.StateSigningAwaitConfirmations], err = testFSMInstance.DoSigning("test-signing-id", sif.EventSigningStart, requests.SigningProposalStartRequest{
 		SigningID:     "test-signing-id",
 		ParticipantId: 1,
-		SrcPayload:    []byte("message to sign"),
+		SrcPayloads:   [][]byte{[]byte("message to sign")},
 		CreatedAt:     time.Now(),
 	})
 
@@ -923,8 +1312,8 @@ func Test_SigningProposal_EventSigningStart(t *testing.T) {
 		t.Fatalf("expected field {SigningId}")
 	}
 
-	if !reflect.DeepEqual(response.SrcPayload, testSigningPayload) {
-		t.Fatalf("expected matched {SrcPayload}")
+	if !reflect.DeepEqual(response.SrcPayloads, [][]byte{testSigningPayload}) {
+		t.Fatalf("expected matched {SrcPayloads}")
 	}
 
 	testSigningId = response.SigningId
@@ -958,10 +1347,10 @@ func Test_SigningProposal_EventConfirmSigningConfirmation_Positive(t *testing.T)
 
 		compareFSMInstanceNotNil(t, testFSMInstance)
 
-		inState, _ := testFSMInstance.State()
+		inState, _ := testFSMInstance.SigningState(testSigningId)
 		compareState(t, sif.StateSigningAwaitConfirmations, inState)
 
-		fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(sif.EventConfirmSigningConfirmation, requests.SigningProposalParticipantRequest{
+		fsmResponse, testFSMDumpLocal, err = testFSMInstance.DoSigning(testSigningId, sif.EventConfirmSigningConfirmation, requests.SigningProposalParticipantRequest{
 			SigningId:     testSigningId,
 			ParticipantId: participantId,
 			CreatedAt:     time.Now(),
@@ -991,8 +1380,8 @@ func Test_SigningProposal_EventConfirmSigningConfirmation_Positive(t *testing.T)
 		t.Fatalf("expected field {SigningId}")
 	}
 
-	if !reflect.DeepEqual(response.SrcPayload, testSigningPayload) {
-		t.Fatalf("expected matched {SrcPayload}")
+	if !reflect.DeepEqual(response.SrcPayloads, [][]byte{testSigningPayload}) {
+		t.Fatalf("expected matched {SrcPayloads}")
 	}
 
 	testFSMDump[sif.StateSigningAwaitPartialSigns] = testFSMDumpLocal
@@ -1007,10 +1396,10 @@ func Test_SigningProposal_EventDeclineProposal_Canceled_Participant(t *testing.T
 
 	compareFSMInstanceNotNil(t, testFSMInstance)
 
-	inState, _ := testFSMInstance.State()
+	inState, _ := testFSMInstance.SigningState(testSigningId)
 	compareState(t, sif.StateSigningAwaitConfirmations, inState)
 
-	fsmResponse, testFSMDumpLocal, err := testFSMInstance.Do(sif.EventDeclineSigningConfirmation, requests.SigningProposalParticipantRequest{
+	fsmResponse, testFSMDumpLocal, err := testFSMInstance.DoSigning(testSigningId, sif.EventDeclineSigningConfirmation, requests.SigningProposalParticipantRequest{
 		SigningId:     testSigningId,
 		ParticipantId: 0,
 		CreatedAt:     time.Now(),
@@ -1032,10 +1421,10 @@ func Test_SigningProposal_EventConfirmSignatureProposal_Canceled_Timeout(t *test
 
 	compareFSMInstanceNotNil(t, testFSMInstance)
 
-	inState, _ := testFSMInstance.State()
+	inState, _ := testFSMInstance.SigningState(testSigningId)
 	compareState(t, sif.StateSigningAwaitConfirmations, inState)
 
-	fsmResponse, testFSMDumpLocal, err := testFSMInstance.Do(sif.EventConfirmSigningConfirmation, requests.SigningProposalParticipantRequest{
+	fsmResponse, testFSMDumpLocal, err := testFSMInstance.DoSigning(testSigningId, sif.EventConfirmSigningConfirmation, requests.SigningProposalParticipantRequest{
 		SigningId:     testSigningId,
 		ParticipantId: 0,
 		CreatedAt:     time.Now().Add(36 * time.Hour),
@@ -1050,10 +1439,73 @@ func Test_SigningProposal_EventConfirmSignatureProposal_Canceled_Timeout(t *test
 	compareState(t, sif.StateSigningConfirmationsAwaitCancelledByTimeout, fsmResponse.State)
 }
 
-func Test_SigningProposal_EventSigningPartialKeyReceived_Positive(t *testing.T) {
-	var (
-		fsmResponse      *fsm.Response
-		testFSMDumpLocal []byte
+func Test_SigningProposal_EventSigningPartialSignsTimeout(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningAwaitPartialSigns])
+
+	compareErrNil(t, err)
+
+	compareFSMInstanceNotNil(t, testFSMInstance)
+
+	_, _, err = testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignsTimeout, requests.SigningProposalTimeoutRequest{
+		SigningId: testSigningId,
+		CreatedAt: time.Now(),
+	})
+
+	require.Error(t, err)
+
+	timeouts, err := testFSMInstance.ExpiredTimeouts(time.Now().Add(36 * time.Hour))
+	compareErrNil(t, err)
+	require.Equal(t, []Timeout{{Event: sif.EventSigningPartialSignsTimeout, SigningId: testSigningId}}, timeouts)
+
+	fsmResponse, testFSMDumpLocal, err := testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignsTimeout, requests.SigningProposalTimeoutRequest{
+		SigningId: testSigningId,
+		CreatedAt: time.Now().Add(36 * time.Hour),
+	})
+
+	compareErrNil(t, err)
+
+	compareFSMResponseNotNil(t, fsmResponse)
+
+	compareDumpNotZero(t, testFSMDumpLocal)
+
+	compareState(t, sif.StateSigningPartialSignsAwaitCancelledByTimeout, fsmResponse.State)
+
+	// The round itself is not affected by the signing timeout
+	inState, _ := testFSMInstance.State()
+	compareState(t, sif.StateSigningIdle, inState)
+}
+
+func Test_SigningProposal_EventSigningPartialKeyReceived_Invalid(t *testing.T) {
+	participantId := 0
+	username := testIdMapParticipants[participantId].Username
+
+	invalidPartialSigns := map[string][]byte{
+		"garbage":       genDataMock(keysMockLen),
+		"foreign share": genPartialSign(t, participantId+1, testSigningPayload),
+		"wrong message": genPartialSign(t, participantId, []byte("another message")),
+	}
+
+	for name, partialSign := range invalidPartialSigns {
+		testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningAwaitPartialSigns])
+
+		compareErrNil(t, err)
+
+		_, _, err = testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignReceived, requests.SigningProposalPartialSignRequest{
+			SigningId:     testSigningId,
+			ParticipantId: participantId,
+			PartialSigns:  [][]byte{partialSign},
+			CreatedAt:     time.Now(),
+		})
+
+		require.Error(t, err, name)
+		require.Contains(t, err.Error(), username, name)
+	}
+}
+
+func Test_SigningProposal_EventSigningPartialKeyReceived_Positive(t *testing.T) {
+	var (
+		fsmResponse      *fsm.Response
+		testFSMDumpLocal []byte
 	)
 
 	participantsCount := len(testIdMapParticipants)
@@ -1062,7 +1514,7 @@ func Test_SigningProposal_EventSigningPartialKeyReceived_Positive(t *testing.T)
 
 	testFSMDumpLocal = testFSMDump[sif.StateSigningAwaitPartialSigns]
 
-	for participantId, participant := range testIdMapParticipants {
+	for participantId := range testIdMapParticipants {
 		participantCounter--
 
 		testFSMInstance, err := FromDump(testFSMDumpLocal)
@@ -1071,13 +1523,13 @@ func Test_SigningProposal_EventSigningPartialKeyReceived_Positive(t *testing.T)
 
 		compareFSMInstanceNotNil(t, testFSMInstance)
 
-		inState, _ := testFSMInstance.State()
+		inState, _ := testFSMInstance.SigningState(testSigningId)
 		compareState(t, sif.StateSigningAwaitPartialSigns, inState)
 
-		fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(sif.EventSigningPartialSignReceived, requests.SigningProposalPartialSignRequest{
+		fsmResponse, testFSMDumpLocal, err = testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignReceived, requests.SigningProposalPartialSignRequest{
 			SigningId:     testSigningId,
 			ParticipantId: participantId,
-			PartialSign:   participant.DkgPartialKey,
+			PartialSigns:  [][]byte{genPartialSign(t, participantId, testSigningPayload)},
 			CreatedAt:     time.Now(),
 		})
 
@@ -1105,8 +1557,8 @@ func Test_SigningProposal_EventSigningPartialKeyReceived_Positive(t *testing.T)
 		t.Fatalf("expected field {SigningId}")
 	}
 
-	if !reflect.DeepEqual(response.SrcPayload, testSigningPayload) {
-		t.Fatalf("expected matched {SrcPayload}")
+	if !reflect.DeepEqual(response.SrcPayloads, [][]byte{testSigningPayload}) {
+		t.Fatalf("expected matched {SrcPayloads}")
 	}
 
 	testFSMDump[sif.StateSigningPartialSignsCollected] = testFSMDumpLocal
@@ -1114,32 +1566,957 @@ func Test_SigningProposal_EventSigningPartialKeyReceived_Positive(t *testing.T)
 	compareDumpNotZero(t, testFSMDump[sif.StateSigningPartialSignsCollected])
 }
 
-func Test_DkgProposal_EventSigningRestart_Positive(t *testing.T) {
+func Test_SigningProposal_EventSigningPartialKeyReceived_Finished(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningPartialSignsCollected])
+
+	compareErrNil(t, err)
+
+	// A partial sign could arrive after the threshold of them is collected
+	_, _, err = testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignReceived, requests.SigningProposalPartialSignRequest{
+		SigningId:     testSigningId,
+		ParticipantId: testSigningInitiator,
+		PartialSigns:  [][]byte{genPartialSign(t, testSigningInitiator, testSigningPayload)},
+		CreatedAt:     time.Now(),
+	})
+
+	require.Error(t, err)
+}
+
+// withSigningThreshold returns the dump with the signing threshold of the round replaced
+func withSigningThreshold(t *testing.T, dump []byte, threshold int) []byte {
+	testFSMInstance, err := FromDump(dump)
+
+	compareErrNil(t, err)
+
+	for _, participant := range testFSMInstance.FSMDump().Payload.SignatureProposalPayload.Quorum {
+		participant.Threshold = threshold
+	}
+
+	dump, err = testFSMInstance.Dump()
+
+	compareErrNil(t, err)
+
+	return dump
+}
+
+func Test_SigningProposal_Threshold_Positive(t *testing.T) {
 	var (
-		fsmResponse      *fsm.Response
-		testFSMDumpLocal []byte
+		declinedId  = (testSigningInitiator + 1) % len(testIdMapParticipants)
+		confirmedId = (testSigningInitiator + 2) % len(testIdMapParticipants)
 	)
 
-	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningPartialSignsCollected])
+	testFSMInstance, err := FromDump(withSigningThreshold(t, testFSMDump[sif.StateSigningAwaitConfirmations], 2))
+
+	compareErrNil(t, err)
+
+	// The rest of participants are still able to reach the threshold
+	fsmResponse, testFSMDumpLocal, err := testFSMInstance.DoSigning(testSigningId, sif.EventDeclineSigningConfirmation, requests.SigningProposalParticipantRequest{
+		SigningId:     testSigningId,
+		ParticipantId: declinedId,
+		CreatedAt:     time.Now(),
+	})
+
+	compareErrNil(t, err)
+
+	compareState(t, sif.StateSigningAwaitConfirmations, fsmResponse.State)
+
+	testFSMInstance, err = FromDump(testFSMDumpLocal)
+
+	compareErrNil(t, err)
+
+	fsmResponse, testFSMDumpLocal, err = testFSMInstance.DoSigning(testSigningId, sif.EventConfirmSigningConfirmation, requests.SigningProposalParticipantRequest{
+		SigningId:     testSigningId,
+		ParticipantId: confirmedId,
+		CreatedAt:     time.Now(),
+	})
+
+	compareErrNil(t, err)
+
+	compareState(t, sif.StateSigningAwaitPartialSigns, fsmResponse.State)
+
+	// The declined participant doesn't take part in signing
+	testFSMInstance, err = FromDump(testFSMDumpLocal)
+
+	compareErrNil(t, err)
+
+	_, _, err = testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignReceived, requests.SigningProposalPartialSignRequest{
+		SigningId:     testSigningId,
+		ParticipantId: declinedId,
+		PartialSigns:  [][]byte{genPartialSign(t, declinedId, testSigningPayload)},
+		CreatedAt:     time.Now(),
+	})
+
+	require.Error(t, err)
+
+	for i, participantId := range []int{testSigningInitiator, confirmedId} {
+		testFSMInstance, err = FromDump(testFSMDumpLocal)
+
+		compareErrNil(t, err)
+
+		fsmResponse, testFSMDumpLocal, err = testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignReceived, requests.SigningProposalPartialSignRequest{
+			SigningId:     testSigningId,
+			ParticipantId: participantId,
+			PartialSigns:  [][]byte{genPartialSign(t, participantId, testSigningPayload)},
+			CreatedAt:     time.Now(),
+		})
+
+		compareErrNil(t, err)
+
+		if i == 0 {
+			compareState(t, sif.StateSigningAwaitPartialSigns, fsmResponse.State)
+		}
+	}
+
+	compareState(t, sif.StateSigningPartialSignsCollected, fsmResponse.State)
+
+	response, ok := fsmResponse.Data.(responses.SigningProcessParticipantResponse)
+
+	if !ok {
+		t.Fatalf("expected response {SigningProcessParticipantResponse}")
+	}
+
+	require.Len(t, response.Participants, 2)
+	for _, participant := range response.Participants {
+		require.NotEqual(t, declinedId, participant.ParticipantId)
+		require.NotEmpty(t, participant.PartialSigns)
+	}
+}
+
+func Test_SigningProposal_Threshold_Canceled_Participant(t *testing.T) {
+	testFSMDumpLocal := withSigningThreshold(t, testFSMDump[sif.StateSigningAwaitConfirmations], 2)
+
+	var fsmResponse *fsm.Response
+	for participantId := range testIdMapParticipants {
+		if participantId == testSigningInitiator {
+			continue
+		}
+
+		testFSMInstance, err := FromDump(testFSMDumpLocal)
+
+		compareErrNil(t, err)
+
+		fsmResponse, testFSMDumpLocal, err = testFSMInstance.DoSigning(testSigningId, sif.EventDeclineSigningConfirmation, requests.SigningProposalParticipantRequest{
+			SigningId:     testSigningId,
+			ParticipantId: participantId,
+			CreatedAt:     time.Now(),
+		})
+
+		compareErrNil(t, err)
+	}
+
+	// Only the initiator is left, so the threshold could not be reached
+	compareState(t, sif.StateSigningConfirmationsAwaitCancelledByParticipant, fsmResponse.State)
+}
+
+func Test_SigningProposal_Threshold_PartialSignError(t *testing.T) {
+	var (
+		failedId    = (testSigningInitiator + 1) % len(testIdMapParticipants)
+		fsmResponse *fsm.Response
+	)
+
+	testFSMDumpLocal := withSigningThreshold(t, testFSMDump[sif.StateSigningAwaitPartialSigns], 2)
+
+	// A participant who failed to sign doesn't cancel the signing while the rest can reach the threshold
+	testFSMInstance, err := FromDump(testFSMDumpLocal)
+
+	compareErrNil(t, err)
+
+	fsmResponse, testFSMDumpLocal, err = testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignError, requests.SignatureProposalConfirmationErrorRequest{
+		ParticipantId: failedId,
+		Error:         errors.New("signing policy violation"),
+		CreatedAt:     time.Now(),
+	})
+
+	compareErrNil(t, err)
+
+	compareState(t, sif.StateSigningAwaitPartialSigns, fsmResponse.State)
+
+	for participantId := range testIdMapParticipants {
+		if participantId == failedId {
+			continue
+		}
+
+		testFSMInstance, err = FromDump(testFSMDumpLocal)
+
+		compareErrNil(t, err)
+
+		fsmResponse, testFSMDumpLocal, err = testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignReceived, requests.SigningProposalPartialSignRequest{
+			SigningId:     testSigningId,
+			ParticipantId: participantId,
+			PartialSigns:  [][]byte{genPartialSign(t, participantId, testSigningPayload)},
+			CreatedAt:     time.Now(),
+		})
+
+		compareErrNil(t, err)
+
+		if fsmResponse.State == sif.StateSigningPartialSignsCollected {
+			break
+		}
+	}
+
+	compareState(t, sif.StateSigningPartialSignsCollected, fsmResponse.State)
+}
+
+func Test_SigningProposal_Threshold_Canceled_PartialSignError(t *testing.T) {
+	testFSMDumpLocal := testFSMDump[sif.StateSigningAwaitPartialSigns]
+
+	// Every participant is required without a threshold, so a single error cancels the signing
+	testFSMInstance, err := FromDump(testFSMDumpLocal)
+
+	compareErrNil(t, err)
+
+	fsmResponse, _, err := testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignError, requests.SignatureProposalConfirmationErrorRequest{
+		ParticipantId: testSigningInitiator,
+		Error:         errors.New("signing policy violation"),
+		CreatedAt:     time.Now(),
+	})
+
+	compareErrNil(t, err)
+
+	compareState(t, sif.StateSigningPartialSignsAwaitCancelledByError, fsmResponse.State)
+}
+
+func Test_SigningProposal_ConcurrentSignings(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningAwaitPartialSigns])
 
 	compareErrNil(t, err)
 
 	compareFSMInstanceNotNil(t, testFSMInstance)
 
+	// The round stays idle, while the first signing is awaiting partial signs
 	inState, _ := testFSMInstance.State()
-	compareState(t, sif.StateSigningPartialSignsCollected, inState)
+	compareState(t, sif.StateSigningIdle, inState)
 
-	fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(sif.EventSigningRestart, requests.DefaultRequest{
+	fsmResponse, testFSMDumpLocal, err := testFSMInstance.DoSigning("test-signing-id-2", sif.EventSigningStart, requests.SigningProposalStartRequest{
+		SigningID:     "test-signing-id-2",
+		ParticipantId: 0,
+		SrcPayloads:   [][]byte{[]byte("another message to sign")},
+		CreatedAt:     time.Now(),
+	})
+
+	compareErrNil(t, err)
+
+	compareDumpNotZero(t, testFSMDumpLocal)
+
+	compareFSMResponseNotNil(t, fsmResponse)
+
+	compareState(t, sif.StateSigningAwaitConfirmations, fsmResponse.State)
+
+	testFSMInstance, err = FromDump(testFSMDumpLocal)
+
+	compareErrNil(t, err)
+
+	inState, _ = testFSMInstance.SigningState(testSigningId)
+	compareState(t, sif.StateSigningAwaitPartialSigns, inState)
+
+	inState, _ = testFSMInstance.SigningState("test-signing-id-2")
+	compareState(t, sif.StateSigningAwaitConfirmations, inState)
+
+	// The same signing could not be started twice
+	_, _, err = testFSMInstance.DoSigning("test-signing-id-2", sif.EventSigningStart, requests.SigningProposalStartRequest{
+		SigningID:     "test-signing-id-2",
+		ParticipantId: 0,
+		SrcPayloads:   [][]byte{[]byte("another message to sign")},
+		CreatedAt:     time.Now(),
+	})
+
+	require.Error(t, err)
+
+	// Resharing is not allowed while signings are in progress
+	_, _, err = testFSMInstance.Do(sif.EventSigningResharingRequest, requests.DefaultRequest{
 		CreatedAt: time.Now(),
 	})
 
+	require.Error(t, err)
+
+	_, _, err = testFSMInstance.DoSigning("unknown-signing-id", sif.EventConfirmSigningConfirmation, requests.SigningProposalParticipantRequest{
+		SigningId:     "unknown-signing-id",
+		ParticipantId: 0,
+		CreatedAt:     time.Now(),
+	})
+
+	require.Error(t, err)
+}
+
+func Test_SigningProposal_Eth2Request(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningIdle])
+
+	compareErrNil(t, err)
+
+	compareFSMInstanceNotNil(t, testFSMInstance)
+
+	eth2Request := &eth2.SigningRequest{
+		Type:          eth2.OperationVoluntaryExit,
+		VoluntaryExit: &eth2.VoluntaryExit{Epoch: 194048, ValidatorIndex: 12345},
+	}
+	signingRoot, err := eth2Request.SigningRoot()
+
+	compareErrNil(t, err)
+
+	// The source payload must be the signing root of the operation
+	_, _, err = testFSMInstance.DoSigning("test-eth2-signing-id", sif.EventSigningStart, requests.SigningProposalStartRequest{
+		SigningID:     "test-eth2-signing-id",
+		ParticipantId: 1,
+		SrcPayloads:   [][]byte{[]byte("message to sign")},
+		Eth2Requests:  []*eth2.SigningRequest{eth2Request},
+		CreatedAt:     time.Now(),
+	})
+
+	require.Error(t, err)
+
+	fsmResponse, _, err := testFSMInstance.DoSigning("test-eth2-signing-id", sif.EventSigningStart, requests.SigningProposalStartRequest{
+		SigningID:     "test-eth2-signing-id",
+		ParticipantId: 1,
+		SrcPayloads:   [][]byte{signingRoot[:]},
+		Eth2Requests:  []*eth2.SigningRequest{eth2Request},
+		CreatedAt:     time.Now(),
+	})
+
 	compareErrNil(t, err)
 
 	compareFSMResponseNotNil(t, fsmResponse)
 
-	compareState(t, sif.StateSigningIdle, fsmResponse.State)
+	response, ok := fsmResponse.Data.(responses.SigningProposalParticipantInvitationsResponse)
 
-	compareDumpNotZero(t, testFSMDumpLocal)
+	if !ok {
+		t.Fatalf("expected response {SigningProposalParticipantInvitationsResponse}")
+	}
+
+	if !reflect.DeepEqual(response.Eth2Requests, []*eth2.SigningRequest{eth2Request}) {
+		t.Fatalf("expected matched {Eth2Requests}")
+	}
+}
+
+func Test_SigningProposal_LegacySinglePayload(t *testing.T) {
+	// Messages sent before batches have a single payload, it's signed as a batch of one
+	var startRequest requests.SigningProposalStartRequest
+	err := json.Unmarshal([]byte(`{"SigningID":"legacy","ParticipantId":1,"SrcPayload":"`+
+		base64.StdEncoding.EncodeToString(testSigningPayload)+`"}`), &startRequest)
+	compareErrNil(t, err)
+	require.Equal(t, [][]byte{testSigningPayload}, startRequest.SrcPayloads)
+	require.Empty(t, startRequest.Eth2Requests)
+
+	err = json.Unmarshal([]byte(`{"SrcPayload":"AQ==","SrcPayloads":["AQ=="]}`), &startRequest)
+	require.Error(t, err)
+
+	partialSign := genPartialSign(t, testSigningInitiator, testSigningPayload)
+	legacyPartialSign, err := json.Marshal(map[string]interface{}{
+		"SigningId":     testSigningId,
+		"ParticipantId": testSigningInitiator,
+		"PartialSign":   partialSign,
+		"CreatedAt":     time.Now(),
+	})
+	compareErrNil(t, err)
+
+	var partialSignRequest requests.SigningProposalPartialSignRequest
+	compareErrNil(t, json.Unmarshal(legacyPartialSign, &partialSignRequest))
+	require.Equal(t, [][]byte{partialSign}, partialSignRequest.PartialSigns)
+
+	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningAwaitPartialSigns])
+	compareErrNil(t, err)
+
+	_, _, err = testFSMInstance.DoSigning(testSigningId, sif.EventSigningPartialSignReceived, partialSignRequest)
+	compareErrNil(t, err)
+}
+
+func Test_SigningProposal_Batch(t *testing.T) {
+	var (
+		initiatorId    = 1
+		confirmedId    = 2
+		srcPayloads    = [][]byte{[]byte("first message to sign"), []byte("second message to sign")}
+		batchSigningId = "test-batch-signing-id"
+	)
+
+	genPartialSigns := func(participantId int) [][]byte {
+		partialSigns := make([][]byte, 0, len(srcPayloads))
+		for _, srcPayload := range srcPayloads {
+			partialSigns = append(partialSigns, genPartialSign(t, participantId, srcPayload))
+		}
+		return partialSigns
+	}
+
+	testFSMInstance, err := FromDump(withSigningThreshold(t, testFSMDump[sif.StateSigningIdle], 2))
+
+	compareErrNil(t, err)
+
+	fsmResponse, testFSMDumpLocal, err := testFSMInstance.DoSigning(batchSigningId, sif.EventSigningStart, requests.SigningProposalStartRequest{
+		SigningID:     batchSigningId,
+		ParticipantId: initiatorId,
+		SrcPayloads:   srcPayloads,
+		CreatedAt:     time.Now(),
+	})
+
+	compareErrNil(t, err)
+
+	invitationsResponse, ok := fsmResponse.Data.(responses.SigningProposalParticipantInvitationsResponse)
+
+	if !ok {
+		t.Fatalf("expected response {SigningProposalParticipantInvitationsResponse}")
+	}
+
+	require.Equal(t, srcPayloads, invitationsResponse.SrcPayloads)
+
+	testFSMInstance, err = FromDump(testFSMDumpLocal)
+
+	compareErrNil(t, err)
+
+	fsmResponse, testFSMDumpLocal, err = testFSMInstance.DoSigning(batchSigningId, sif.EventConfirmSigningConfirmation, requests.SigningProposalParticipantRequest{
+		SigningId:     batchSigningId,
+		ParticipantId: confirmedId,
+		CreatedAt:     time.Now(),
+	})
+
+	compareErrNil(t, err)
+
+	compareState(t, sif.StateSigningAwaitPartialSigns, fsmResponse.State)
+
+	partialSignsResponse, ok := fsmResponse.Data.(responses.SigningPartialSignsParticipantInvitationsResponse)
+
+	if !ok {
+		t.Fatalf("expected response {SigningPartialSignsParticipantInvitationsResponse}")
+	}
+
+	require.Equal(t, srcPayloads, partialSignsResponse.SrcPayloads)
+
+	testFSMInstance, err = FromDump(testFSMDumpLocal)
+
+	compareErrNil(t, err)
+
+	// Every payload of the batch must be signed
+	invalidPartialSigns := [][][]byte{
+		genPartialSigns(initiatorId)[:1],
+		{genPartialSigns(initiatorId)[1], genPartialSigns(initiatorId)[0]},
+	}
+	for _, partialSigns := range invalidPartialSigns {
+		_, _, err = testFSMInstance.DoSigning(batchSigningId, sif.EventSigningPartialSignReceived, requests.SigningProposalPartialSignRequest{
+			SigningId:     batchSigningId,
+			ParticipantId: initiatorId,
+			PartialSigns:  partialSigns,
+			CreatedAt:     time.Now(),
+		})
+
+		require.Error(t, err)
+	}
+
+	for _, participantId := range []int{initiatorId, confirmedId} {
+		testFSMInstance, err = FromDump(testFSMDumpLocal)
+
+		compareErrNil(t, err)
+
+		fsmResponse, testFSMDumpLocal, err = testFSMInstance.DoSigning(batchSigningId, sif.EventSigningPartialSignReceived, requests.SigningProposalPartialSignRequest{
+			SigningId:     batchSigningId,
+			ParticipantId: participantId,
+			PartialSigns:  genPartialSigns(participantId),
+			CreatedAt:     time.Now(),
+		})
+
+		compareErrNil(t, err)
+	}
+
+	compareState(t, sif.StateSigningPartialSignsCollected, fsmResponse.State)
+
+	response, ok := fsmResponse.Data.(responses.SigningProcessParticipantResponse)
+
+	if !ok {
+		t.Fatalf("expected response {SigningProcessParticipantResponse}")
+	}
+
+	require.Equal(t, srcPayloads, response.SrcPayloads)
+	require.Len(t, response.Participants, 2)
+	for _, participant := range response.Participants {
+		require.Equal(t, genPartialSigns(participant.ParticipantId), participant.PartialSigns)
+	}
+}
+
+// A dump made before signing sessions were kept by their ids, the round waits for partial signs
+func Test_SigningProposal_LegacyDump(t *testing.T) {
+	legacyDump, err := ioutil.ReadFile("testdata/baseline_signing_dump.json")
+	require.NoError(t, err)
+
+	testFSMInstance, err := FromDump(legacyDump)
+	compareErrNil(t, err)
+	compareFSMInstanceNotNil(t, testFSMInstance)
+
+	state, err := testFSMInstance.State()
+	compareErrNil(t, err)
+	compareState(t, sif.StateSigningIdle, state)
+
+	payload := testFSMInstance.FSMDump().Payload
+	require.Len(t, payload.SigningProposalsPayload, 1)
+	var signingId string
+	for id, signing := range payload.SigningProposalsPayload {
+		signingId = id
+		require.Equal(t, id, signing.SigningId)
+		require.Len(t, signing.SrcPayloads, 1)
+		require.Len(t, signing.Quorum, len(testIdMapParticipants))
+	}
+
+	signingState, err := testFSMInstance.SigningState(signingId)
+	compareErrNil(t, err)
+	compareState(t, sif.StateSigningAwaitPartialSigns, signingState)
+
+	// The signing is continued by its id
+	fsmResponse, dump, err := testFSMInstance.DoSigning(signingId, sif.EventSigningPartialSignsTimeout, requests.SigningProposalTimeoutRequest{
+		SigningId: signingId,
+		CreatedAt: payload.SigningProposalsPayload[signingId].ExpiresAt.Add(time.Minute),
+	})
+	compareErrNil(t, err)
+	compareState(t, sif.StateSigningPartialSignsAwaitCancelledByTimeout, fsmResponse.State)
+
+	// The migrated dump has no legacy signing anymore
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	signingState, err = testFSMInstance.SigningState(signingId)
+	compareErrNil(t, err)
+	compareState(t, sif.StateSigningPartialSignsAwaitCancelledByTimeout, signingState)
+}
+
+func Test_ResharingProposal_Positive(t *testing.T) {
+	var (
+		fsmResponse      *fsm.Response
+		testFSMDumpLocal []byte
+		dealers          []int
+		newParticipants  []*requests.SignatureProposalParticipantsEntry
+	)
+
+	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningIdle])
+	compareErrNil(t, err)
+	compareFSMInstanceNotNil(t, testFSMInstance)
+
+	fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(sif.EventSigningResharingRequest, requests.DefaultRequest{
+		CreatedAt: time.Now(),
+	})
+	compareErrNil(t, err)
+	compareFSMResponseNotNil(t, fsmResponse)
+	compareState(t, sif.StateSigningResharingRequested, fsmResponse.State)
+
+	for participantId, participant := range testIdMapParticipants {
+		dealers = append(dealers, participantId)
+		if len(newParticipants) < 2 {
+			newParticipants = append(newParticipants, &requests.SignatureProposalParticipantsEntry{
+				Username:  participant.Username,
+				PubKey:    participant.HotPubKey,
+				DkgPubKey: participant.DkgPubKey,
+			})
+		}
+	}
+	newParticipants = append(newParticipants, &requests.SignatureProposalParticipantsEntry{
+		Username:  base64.StdEncoding.EncodeToString(genDataMock(usernameMockLen)),
+		PubKey:    genDataMock(keysMockLen),
+		DkgPubKey: genDataMock(keysMockLen),
+	})
+
+	testFSMInstance, err = FromDump(testFSMDumpLocal)
+	compareErrNil(t, err)
+
+	// Not enough dealers for the current threshold
+	_, _, err = testFSMInstance.Do(rpf.EventResharingProposalStart, requests.ResharingProposalStartRequest{
+		ParticipantId:   dealers[0],
+		Dealers:         dealers[:1],
+		NewParticipants: newParticipants,
+		NewThreshold:    2,
+		CreatedAt:       tm,
+	})
+	require.Error(t, err)
+
+	fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(rpf.EventResharingProposalStart, requests.ResharingProposalStartRequest{
+		ParticipantId:   dealers[0],
+		Dealers:         dealers,
+		NewParticipants: newParticipants,
+		NewThreshold:    2,
+		CreatedAt:       tm,
+	})
+	compareErrNil(t, err)
+	compareFSMResponseNotNil(t, fsmResponse)
+	compareState(t, rpf.StateResharingProposalAwaitConfirmations, fsmResponse.State)
+
+	confirmationResponse, ok := fsmResponse.Data.(responses.ResharingProposalConfirmationResponse)
+	require.True(t, ok)
+	require.Equal(t, dealers[0], confirmationResponse.InitiatorId)
+
+	fsmResponse, testFSMDumpLocal = approveResharing(t, testFSMDumpLocal, dealers[0])
+	compareState(t, rpf.StateResharingDealsAwaitConfirmations, fsmResponse.State)
+
+	participantsResponse, ok := fsmResponse.Data.(responses.ResharingProposalParticipantsResponse)
+	require.True(t, ok)
+	require.Equal(t, len(testIdMapParticipants), participantsResponse.OldThreshold)
+	require.Equal(t, 2, participantsResponse.NewThreshold)
+	require.Len(t, participantsResponse.Dealers, len(dealers))
+	require.Len(t, participantsResponse.NewParticipants, len(newParticipants))
+
+	for _, dealerId := range dealers {
+		testFSMInstance, err = FromDump(testFSMDumpLocal)
+		compareErrNil(t, err)
+
+		deals := make(map[int][]byte)
+		for receiverId := range newParticipants {
+			deals[receiverId] = genDataMock(keysMockLen)
+		}
+
+		fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(rpf.EventResharingDealConfirmationReceived, requests.ResharingProposalDealConfirmationRequest{
+			ParticipantId: dealerId,
+			Commit:        genDataMock(keysMockLen),
+			PubPoly:       genDataMock(keysMockLen),
+			Deals:         deals,
+			CreatedAt:     tm,
+		})
+		compareErrNil(t, err)
+		compareFSMResponseNotNil(t, fsmResponse)
+	}
+	compareState(t, rpf.StateResharingResponsesAwaitConfirmations, fsmResponse.State)
+
+	dealsResponse, ok := fsmResponse.Data.(responses.ResharingProposalDealsResponse)
+	require.True(t, ok)
+	require.Len(t, dealsResponse.Deals, len(dealers))
+
+	for receiverId := range newParticipants {
+		testFSMInstance, err = FromDump(testFSMDumpLocal)
+		compareErrNil(t, err)
+
+		fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(rpf.EventResharingResponseConfirmationReceived, requests.ResharingProposalResponseConfirmationRequest{
+			ParticipantId: receiverId,
+			Response:      genDataMock(keysMockLen),
+			CreatedAt:     tm,
+		})
+		compareErrNil(t, err)
+		compareFSMResponseNotNil(t, fsmResponse)
+	}
+	compareState(t, rpf.StateResharingMasterKeyAwaitConfirmations, fsmResponse.State)
+
+	testFSMInstance, err = FromDump(testFSMDumpLocal)
+	compareErrNil(t, err)
+	masterKey := testFSMInstance.FSMDump().Payload.ResharingProposalPayload.MasterKey
+	require.NotEmpty(t, masterKey)
+
+	newPubPoly := genDataMock(keysMockLen)
+	for receiverId := range newParticipants {
+		testFSMInstance, err = FromDump(testFSMDumpLocal)
+		compareErrNil(t, err)
+
+		fsmResponse, testFSMDumpLocal, err = testFSMInstance.Do(rpf.EventResharingMasterKeyConfirmationReceived, requests.ResharingProposalMasterKeyConfirmationRequest{
+			ParticipantId: receiverId,
+			MasterKey:     masterKey,
+			PubPoly:       newPubPoly,
+			CreatedAt:     tm,
+		})
+		compareErrNil(t, err)
+		compareFSMResponseNotNil(t, fsmResponse)
+	}
+	compareState(t, dpf.StateDkgMasterKeyCollected, fsmResponse.State)
+
+	testFSMInstance, err = FromDump(testFSMDumpLocal)
+	compareErrNil(t, err)
+
+	payload := testFSMInstance.FSMDump().Payload
+	require.Equal(t, len(newParticipants), payload.DKGQuorumCount())
+	require.Equal(t, newPubPoly, payload.DKGPubPoly())
+	for receiverId, participant := range newParticipants {
+		id, err := testFSMInstance.GetIDByUsername(participant.Username)
+		require.NoError(t, err)
+		require.Equal(t, receiverId, id)
+		require.Equal(t, 2, payload.SigQuorumGet(receiverId).Threshold)
+	}
+
+	fsmResponse, _, err = testFSMInstance.Do(sif.EventSigningInit, requests.DefaultRequest{
+		CreatedAt: time.Now(),
+	})
+	compareErrNil(t, err)
+	compareState(t, sif.StateSigningIdle, fsmResponse.State)
+}
+
+func Test_ResharingProposal_MasterKeyMismatched(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningIdle])
+	compareErrNil(t, err)
+
+	_, dump, err := testFSMInstance.Do(sif.EventSigningResharingRequest, requests.DefaultRequest{
+		CreatedAt: time.Now(),
+	})
+	compareErrNil(t, err)
+
+	var (
+		dealers         []int
+		newParticipants []*requests.SignatureProposalParticipantsEntry
+	)
+	for participantId, participant := range testIdMapParticipants {
+		dealers = append(dealers, participantId)
+		newParticipants = append(newParticipants, &requests.SignatureProposalParticipantsEntry{
+			Username:  participant.Username,
+			PubKey:    participant.HotPubKey,
+			DkgPubKey: participant.DkgPubKey,
+		})
+	}
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	_, dump, err = testFSMInstance.Do(rpf.EventResharingProposalStart, requests.ResharingProposalStartRequest{
+		ParticipantId:   dealers[0],
+		Dealers:         dealers,
+		NewParticipants: newParticipants,
+		NewThreshold:    2,
+		CreatedAt:       tm,
+	})
+	compareErrNil(t, err)
+	_, dump = approveResharing(t, dump, dealers[0])
+
+	for _, dealerId := range dealers {
+		testFSMInstance, err = FromDump(dump)
+		compareErrNil(t, err)
+		_, dump, err = testFSMInstance.Do(rpf.EventResharingDealConfirmationReceived, requests.ResharingProposalDealConfirmationRequest{
+			ParticipantId: dealerId,
+			Commit:        genDataMock(keysMockLen),
+			PubPoly:       genDataMock(keysMockLen),
+			Deals:         map[int][]byte{0: genDataMock(keysMockLen)},
+			CreatedAt:     tm,
+		})
+		compareErrNil(t, err)
+	}
+
+	for receiverId := range newParticipants {
+		testFSMInstance, err = FromDump(dump)
+		compareErrNil(t, err)
+		_, dump, err = testFSMInstance.Do(rpf.EventResharingResponseConfirmationReceived, requests.ResharingProposalResponseConfirmationRequest{
+			ParticipantId: receiverId,
+			Response:      genDataMock(keysMockLen),
+			CreatedAt:     tm,
+		})
+		compareErrNil(t, err)
+	}
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	fsmResponse, _, err := testFSMInstance.Do(rpf.EventResharingMasterKeyConfirmationReceived, requests.ResharingProposalMasterKeyConfirmationRequest{
+		ParticipantId: 0,
+		MasterKey:     genDataMock(keysMockLen),
+		PubPoly:       genDataMock(keysMockLen),
+		CreatedAt:     tm,
+	})
+	compareErrNil(t, err)
+	compareState(t, rpf.StateResharingMasterKeyAwaitCanceledByError, fsmResponse.State)
+}
+
+// approveResharing confirms the started resharing by all current share holders except the initiator
+func approveResharing(t *testing.T, dump []byte, initiatorId int) (*fsm.Response, []byte) {
+	var fsmResponse *fsm.Response
+	for participantId := range testIdMapParticipants {
+		if participantId == initiatorId {
+			continue
+		}
+		testFSMInstance, err := FromDump(dump)
+		compareErrNil(t, err)
+		fsmResponse, dump, err = testFSMInstance.Do(rpf.EventResharingProposalConfirm, requests.ResharingProposalConfirmationRequest{
+			ParticipantId: participantId,
+			CreatedAt:     tm,
+		})
+		compareErrNil(t, err)
+		compareFSMResponseNotNil(t, fsmResponse)
+	}
+	return fsmResponse, dump
+}
+
+func genResharingParticipants(n int) []*requests.SignatureProposalParticipantsEntry {
+	participants := make([]*requests.SignatureProposalParticipantsEntry, 0, n)
+	for i := 0; i < n; i++ {
+		participants = append(participants, &requests.SignatureProposalParticipantsEntry{
+			Username:  base64.StdEncoding.EncodeToString(genDataMock(usernameMockLen)),
+			PubKey:    genDataMock(keysMockLen),
+			DkgPubKey: genDataMock(keysMockLen),
+		})
+	}
+	return participants
+}
+
+func Test_ResharingProposal_Rejected_Unchanged(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningIdle])
+	compareErrNil(t, err)
+	pubKeysCount := len(testFSMInstance.FSMDump().Payload.PubKeys)
+
+	_, dump, err := testFSMInstance.Do(sif.EventSigningResharingRequest, requests.DefaultRequest{
+		CreatedAt: time.Now(),
+	})
+	compareErrNil(t, err)
+
+	var dealers []int
+	for participantId := range testIdMapParticipants {
+		dealers = append(dealers, participantId)
+	}
+
+	// The second new participant takes the username of a current one with another key
+	newParticipants := genResharingParticipants(2)
+	newParticipants[1].Username = testIdMapParticipants[dealers[0]].Username
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	_, _, err = testFSMInstance.Do(rpf.EventResharingProposalStart, requests.ResharingProposalStartRequest{
+		ParticipantId:   dealers[0],
+		Dealers:         dealers,
+		NewParticipants: newParticipants,
+		NewThreshold:    2,
+		CreatedAt:       tm,
+	})
+	require.Error(t, err)
+
+	payload := testFSMInstance.FSMDump().Payload
+	require.Nil(t, payload.ResharingProposalPayload)
+	require.Len(t, payload.PubKeys, pubKeysCount)
+	_, ok := payload.PubKeys[newParticipants[0].Username]
+	require.False(t, ok)
+
+	// An unknown dealer is rejected before the payload is changed too
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	_, _, err = testFSMInstance.Do(rpf.EventResharingProposalStart, requests.ResharingProposalStartRequest{
+		ParticipantId:   dealers[0],
+		Dealers:         append(dealers, len(dealers)+100),
+		NewParticipants: genResharingParticipants(2),
+		NewThreshold:    2,
+		CreatedAt:       tm,
+	})
+	require.Error(t, err)
+
+	payload = testFSMInstance.FSMDump().Payload
+	require.Nil(t, payload.ResharingProposalPayload)
+	require.Len(t, payload.PubKeys, pubKeysCount)
+}
+
+func Test_ResharingProposal_Declined_RolledBack(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningIdle])
+	compareErrNil(t, err)
+	pubKeysCount := len(testFSMInstance.FSMDump().Payload.PubKeys)
+
+	_, dump, err := testFSMInstance.Do(sif.EventSigningResharingRequest, requests.DefaultRequest{
+		CreatedAt: time.Now(),
+	})
+	compareErrNil(t, err)
+
+	var dealers []int
+	for participantId := range testIdMapParticipants {
+		dealers = append(dealers, participantId)
+	}
+	newParticipants := genResharingParticipants(2)
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	_, dump, err = testFSMInstance.Do(rpf.EventResharingProposalStart, requests.ResharingProposalStartRequest{
+		ParticipantId:   dealers[0],
+		Dealers:         dealers,
+		NewParticipants: newParticipants,
+		NewThreshold:    2,
+		CreatedAt:       tm,
+	})
+	compareErrNil(t, err)
+
+	// Dealing can't start before the proposal is approved
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	_, _, err = testFSMInstance.Do(rpf.EventResharingDealConfirmationReceived, requests.ResharingProposalDealConfirmationRequest{
+		ParticipantId: dealers[0],
+		Commit:        genDataMock(keysMockLen),
+		PubPoly:       genDataMock(keysMockLen),
+		Deals:         map[int][]byte{0: genDataMock(keysMockLen)},
+		CreatedAt:     tm,
+	})
+	require.Error(t, err)
+
+	// The initiator has approved the proposal already
+	_, _, err = testFSMInstance.Do(rpf.EventResharingProposalConfirm, requests.ResharingProposalConfirmationRequest{
+		ParticipantId: dealers[0],
+		CreatedAt:     tm,
+	})
+	require.Error(t, err)
+
+	// The threshold is the whole quorum, so a single decline cancels the resharing
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	fsmResponse, dump, err := testFSMInstance.Do(rpf.EventResharingProposalDecline, requests.ResharingProposalConfirmationRequest{
+		ParticipantId: dealers[1],
+		CreatedAt:     tm,
+	})
+	compareErrNil(t, err)
+	compareState(t, rpf.StateResharingProposalAwaitCanceledByParticipant, fsmResponse.State)
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	fsmResponse, dump, err = testFSMInstance.Do(rpf.EventResharingRollback, requests.DefaultRequest{
+		CreatedAt: tm,
+	})
+	compareErrNil(t, err)
+	compareState(t, sif.StateSigningIdle, fsmResponse.State)
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	payload := testFSMInstance.FSMDump().Payload
+	require.Nil(t, payload.ResharingProposalPayload)
+	require.Len(t, payload.PubKeys, pubKeysCount)
+	for _, participant := range newParticipants {
+		_, ok := payload.PubKeys[participant.Username]
+		require.False(t, ok)
+	}
+
+	// The round can be signed and reshared again, messages of the next resharing are told by its generation
+	_, dump, err = testFSMInstance.Do(sif.EventSigningResharingRequest, requests.DefaultRequest{
+		CreatedAt: time.Now(),
+	})
+	compareErrNil(t, err)
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	_, dump, err = testFSMInstance.Do(rpf.EventResharingProposalStart, requests.ResharingProposalStartRequest{
+		ParticipantId:   dealers[0],
+		Dealers:         dealers,
+		NewParticipants: newParticipants,
+		NewThreshold:    2,
+		CreatedAt:       tm,
+	})
+	compareErrNil(t, err)
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	generation, err := testFSMInstance.ResharingGeneration()
+	compareErrNil(t, err)
+	require.Equal(t, 2, generation)
+}
+
+func Test_ResharingProposal_Timeout_RolledBack(t *testing.T) {
+	testFSMInstance, err := FromDump(testFSMDump[sif.StateSigningIdle])
+	compareErrNil(t, err)
+
+	_, dump, err := testFSMInstance.Do(sif.EventSigningResharingRequest, requests.DefaultRequest{
+		CreatedAt: time.Now(),
+	})
+	compareErrNil(t, err)
+
+	var dealers []int
+	for participantId := range testIdMapParticipants {
+		dealers = append(dealers, participantId)
+	}
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	_, dump, err = testFSMInstance.Do(rpf.EventResharingProposalStart, requests.ResharingProposalStartRequest{
+		ParticipantId:   dealers[0],
+		Dealers:         dealers,
+		NewParticipants: genResharingParticipants(2),
+		NewThreshold:    2,
+		CreatedAt:       tm,
+	})
+	compareErrNil(t, err)
+	_, dump = approveResharing(t, dump, dealers[0])
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	fsmResponse, dump, err := testFSMInstance.Do(rpf.EventResharingDealsConfirmationTimeout, requests.DefaultRequest{
+		CreatedAt: tm.Add(config.ResharingConfirmationDeadline + time.Minute),
+	})
+	compareErrNil(t, err)
+	compareState(t, rpf.StateResharingDealsAwaitCanceledByTimeout, fsmResponse.State)
+
+	testFSMInstance, err = FromDump(dump)
+	compareErrNil(t, err)
+	fsmResponse, _, err = testFSMInstance.Do(rpf.EventResharingRollback, requests.DefaultRequest{
+		CreatedAt: tm,
+	})
+	compareErrNil(t, err)
+	compareState(t, sif.StateSigningIdle, fsmResponse.State)
 }
 
 func Test_Parallel(t *testing.T) {
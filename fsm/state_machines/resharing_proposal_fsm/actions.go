@@ -0,0 +1,791 @@
+package resharing_proposal_fsm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/lidofinance/dc4bc/fsm/config"
+	"github.com/lidofinance/dc4bc/fsm/fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/internal"
+	"github.com/lidofinance/dc4bc/fsm/types/requests"
+	"github.com/lidofinance/dc4bc/fsm/types/responses"
+)
+
+// Start
+
+func (m *ResharingProposalFSM) actionStartResharingProposal(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	var (
+		oldThreshold int
+		masterKey    []byte
+	)
+
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {ResharingProposalStartRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.ResharingProposalStartRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {ResharingProposalStartRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	if m.payload.DKGProposalPayload == nil || m.payload.SignatureProposalPayload == nil {
+		err = errors.New("cannot start resharing without finished {DKGProposal}")
+		return
+	}
+
+	if !m.payload.DKGQuorumExists(request.ParticipantId) {
+		err = errors.New("{ParticipantId} not exist in quorum")
+		return
+	}
+
+	for _, participant := range m.payload.SignatureProposalPayload.Quorum {
+		oldThreshold = participant.Threshold
+		break
+	}
+
+	if len(request.Dealers) < oldThreshold {
+		err = fmt.Errorf("too few dealers, the current threshold is {%d}", oldThreshold)
+		return
+	}
+
+	if masterKey = m.payload.DKGMasterKey(); len(masterKey) == 0 {
+		err = errors.New("cannot start resharing without confirmed {DKGMasterKey}")
+		return
+	}
+
+	for _, dealerId := range request.Dealers {
+		if !m.payload.DKGQuorumExists(dealerId) {
+			err = fmt.Errorf("dealer {%d} not exist in quorum", dealerId)
+			return
+		}
+	}
+
+	for _, participant := range request.NewParticipants {
+		pubKey, ok := m.payload.PubKeys[participant.Username]
+		if ok && !bytes.Equal(pubKey, participant.PubKey) {
+			err = fmt.Errorf("{Username} \"%s\" is already used with another {PubKey}", participant.Username)
+			return
+		}
+	}
+
+	// The payload is changed only after all the checks, so a rejected request leaves it as it was
+	m.payload.ResharingProposalPayload = &internal.ResharingConfirmation{
+		InitiatorId:  request.ParticipantId,
+		OldThreshold: oldThreshold,
+		NewThreshold: request.NewThreshold,
+		MasterKey:    make([]byte, len(masterKey)),
+		Approvals:    make(map[int]internal.ConfirmationParticipantStatus),
+		Dealers:      make(internal.ResharingProposalQuorum),
+		Receivers:    make(internal.ResharingProposalQuorum),
+		CreatedAt:    request.CreatedAt,
+		UpdatedAt:    request.CreatedAt,
+		ExpiresAt:    request.CreatedAt.Add(config.ResharingConfirmationDeadline),
+	}
+	copy(m.payload.ResharingProposalPayload.MasterKey, masterKey)
+
+	// The initiator approves the proposal by starting it, the rest of current share holders have to answer
+	for participantId := range m.payload.SignatureProposalPayload.Quorum {
+		m.payload.ResharingProposalPayload.Approvals[participantId] = internal.SigConfirmationAwaitConfirmation
+	}
+	m.payload.ResharingProposalPayload.Approvals[request.ParticipantId] = internal.SigConfirmationConfirmed
+
+	for _, dealerId := range request.Dealers {
+		dkgParticipant := m.payload.DKGQuorumGet(dealerId)
+
+		m.payload.ResharingProposalPayload.Dealers[dealerId] = &internal.ResharingProposalParticipant{
+			Username:  dkgParticipant.Username,
+			PubKey:    m.payload.SigQuorumGet(dealerId).PubKey,
+			DkgPubKey: make([]byte, len(dkgParticipant.DkgPubKey)),
+			Status:    internal.DealAwaitConfirmation,
+			UpdatedAt: request.CreatedAt,
+		}
+		copy(m.payload.ResharingProposalPayload.Dealers[dealerId].DkgPubKey, dkgParticipant.DkgPubKey)
+	}
+
+	for index, participant := range request.NewParticipants {
+		m.payload.ResharingProposalPayload.Receivers[index] = &internal.ResharingProposalParticipant{
+			Username:  participant.Username,
+			PubKey:    participant.PubKey,
+			DkgPubKey: participant.DkgPubKey,
+			Status:    internal.DealAwaitConfirmation,
+			UpdatedAt: request.CreatedAt,
+		}
+	}
+
+	// New participants have to be able to send messages before the new quorum is set
+	for _, participant := range request.NewParticipants {
+		m.payload.SetPubKeyUsername(participant.Username, participant.PubKey)
+	}
+
+	m.payload.ResharingGeneration++
+
+	// Make response
+
+	responseData := responses.ResharingProposalConfirmationResponse{
+		ResharingProposalParticipantsResponse: m.makeParticipantsResponse(),
+		InitiatorId:                           request.ParticipantId,
+	}
+
+	return inEvent, responseData, nil
+}
+
+func (m *ResharingProposalFSM) makeParticipantsResponse() responses.ResharingProposalParticipantsResponse {
+	responseData := responses.ResharingProposalParticipantsResponse{
+		OldThreshold:    m.payload.ResharingProposalPayload.OldThreshold,
+		NewThreshold:    m.payload.ResharingProposalPayload.NewThreshold,
+		Dealers:         make([]int, 0),
+		OldParticipants: make([]*responses.ResharingProposalParticipantEntry, 0),
+		NewParticipants: make([]*responses.ResharingProposalParticipantEntry, 0),
+	}
+
+	for participantId := range m.payload.ResharingProposalPayload.Dealers {
+		responseData.Dealers = append(responseData.Dealers, participantId)
+	}
+
+	for participantId, participant := range m.payload.DKGProposalPayload.Quorum {
+		responseEntry := &responses.ResharingProposalParticipantEntry{
+			ParticipantId: participantId,
+			Username:      participant.Username,
+			DkgPubKey:     participant.DkgPubKey,
+		}
+		responseData.OldParticipants = append(responseData.OldParticipants, responseEntry)
+	}
+
+	for participantId, participant := range m.payload.ResharingProposalPayload.Receivers {
+		responseEntry := &responses.ResharingProposalParticipantEntry{
+			ParticipantId: participantId,
+			Username:      participant.Username,
+			DkgPubKey:     participant.DkgPubKey,
+		}
+		responseData.NewParticipants = append(responseData.NewParticipants, responseEntry)
+	}
+
+	return responseData
+}
+
+// Approval
+
+func (m *ResharingProposalFSM) actionProposalResponseByParticipant(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {ResharingProposalConfirmationRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.ResharingProposalConfirmationRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {ResharingProposalConfirmationRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	status, ok := m.payload.ResharingProposalPayload.Approvals[request.ParticipantId]
+	if !ok {
+		err = errors.New("{ParticipantId} not exist in quorum")
+		return
+	}
+
+	if status != internal.SigConfirmationAwaitConfirmation {
+		err = fmt.Errorf("cannot confirm participant with {Status} = {\"%s\"}", status)
+		return
+	}
+
+	switch inEvent {
+	case EventResharingProposalConfirm:
+		status = internal.SigConfirmationConfirmed
+	case EventResharingProposalDecline:
+		status = internal.SigConfirmationDeclined
+	default:
+		err = fmt.Errorf("unsupported event for action {inEvent} = {\"%s\"}", inEvent)
+		return
+	}
+
+	m.payload.ResharingProposalPayload.Approvals[request.ParticipantId] = status
+	m.payload.ResharingProposalPayload.UpdatedAt = request.CreatedAt
+
+	return
+}
+
+func (m *ResharingProposalFSM) actionValidateResharingProposalConfirmations(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	var (
+		confirmedParticipants int
+		declinedParticipants  int
+	)
+
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if m.payload.ResharingProposalPayload.IsExpired() {
+		outEvent = eventResharingProposalCanceledByTimeoutInternal
+		return
+	}
+
+	threshold := m.payload.ResharingProposalPayload.OldThreshold
+	for _, status := range m.payload.ResharingProposalPayload.Approvals {
+		if status == internal.SigConfirmationDeclined {
+			declinedParticipants++
+		} else if status == internal.SigConfirmationConfirmed {
+			confirmedParticipants++
+		}
+	}
+
+	// The rest of share holders are not enough to reach the threshold
+	if len(m.payload.ResharingProposalPayload.Approvals)-declinedParticipants < threshold {
+		outEvent = eventResharingProposalCanceledByParticipantInternal
+		return
+	}
+
+	// A threshold of current share holders is able to reconstruct the key anyway, so it's enough to reshare it
+	if confirmedParticipants < threshold {
+		return
+	}
+
+	outEvent = eventResharingProposalConfirmedInternal
+
+	// Dealers get the whole deadline to send deals
+	m.payload.ResharingProposalPayload.ExpiresAt = m.payload.ResharingProposalPayload.UpdatedAt.Add(config.ResharingConfirmationDeadline)
+
+	// Make response
+
+	response = m.makeParticipantsResponse()
+
+	return
+}
+
+// Deals
+
+func (m *ResharingProposalFSM) actionDealConfirmationReceived(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {ResharingProposalDealConfirmationRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.ResharingProposalDealConfirmationRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {ResharingProposalDealConfirmationRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	if !m.payload.ResharingDealersExists(request.ParticipantId) {
+		err = errors.New("{ParticipantId} not exist in dealers quorum")
+		return
+	}
+
+	for receiverId := range request.Deals {
+		if !m.payload.ResharingReceiversExists(receiverId) {
+			err = fmt.Errorf("deal recipient {%d} not exist in receivers quorum", receiverId)
+			return
+		}
+	}
+
+	dealer := m.payload.ResharingDealersGet(request.ParticipantId)
+
+	if dealer.Status != internal.DealAwaitConfirmation {
+		err = fmt.Errorf("cannot confirm deal with {Status} = {\"%s\"}", dealer.Status)
+		return
+	}
+
+	dealer.DkgCommit = make([]byte, len(request.Commit))
+	copy(dealer.DkgCommit, request.Commit)
+	dealer.DkgPubPoly = make([]byte, len(request.PubPoly))
+	copy(dealer.DkgPubPoly, request.PubPoly)
+	dealer.DkgDeals = make(map[int][]byte)
+	for receiverId, deal := range request.Deals {
+		dealer.DkgDeals[receiverId] = make([]byte, len(deal))
+		copy(dealer.DkgDeals[receiverId], deal)
+	}
+	dealer.Status = internal.DealConfirmed
+
+	dealer.UpdatedAt = request.CreatedAt
+	m.payload.ResharingProposalPayload.UpdatedAt = request.CreatedAt
+
+	m.payload.ResharingDealersUpdate(request.ParticipantId, dealer)
+
+	return
+}
+
+func (m *ResharingProposalFSM) actionValidateResharingProposalAwaitDeals(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	var (
+		isContainsError bool
+	)
+
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if m.payload.ResharingProposalPayload.IsExpired() {
+		outEvent = eventResharingDealsConfirmationCancelByTimeoutInternal
+		return
+	}
+
+	unconfirmedParticipants := m.payload.ResharingDealersCount()
+	for _, participant := range m.payload.ResharingProposalPayload.Dealers {
+		if participant.Status == internal.DealConfirmationError {
+			isContainsError = true
+		} else if participant.Status == internal.DealConfirmed {
+			unconfirmedParticipants--
+		}
+	}
+
+	if isContainsError {
+		outEvent = eventResharingDealsConfirmationCancelByErrorInternal
+		return
+	}
+
+	// The are no declined and timed out participants, check for all confirmations
+	if unconfirmedParticipants > 0 {
+		return
+	}
+
+	outEvent = eventResharingDealsConfirmedInternal
+
+	for _, participant := range m.payload.ResharingProposalPayload.Receivers {
+		participant.Status = internal.ResponseAwaitConfirmation
+	}
+
+	// Make response
+
+	responseData := responses.ResharingProposalDealsResponse{
+		ResharingProposalParticipantsResponse: m.makeParticipantsResponse(),
+		Deals:                                 make([]*responses.ResharingProposalDealEntry, 0),
+	}
+
+	for participantId, participant := range m.payload.ResharingProposalPayload.Dealers {
+		responseEntry := &responses.ResharingProposalDealEntry{
+			ParticipantId: participantId,
+			Username:      participant.Username,
+			DkgCommit:     participant.DkgCommit,
+			DkgPubPoly:    participant.DkgPubPoly,
+			DkgDeals:      participant.DkgDeals,
+		}
+		responseData.Deals = append(responseData.Deals, responseEntry)
+	}
+
+	response = responseData
+
+	return
+}
+
+// Responses
+
+func (m *ResharingProposalFSM) actionResponseConfirmationReceived(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {ResharingProposalResponseConfirmationRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.ResharingProposalResponseConfirmationRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {ResharingProposalResponseConfirmationRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	if !m.payload.ResharingReceiversExists(request.ParticipantId) {
+		err = errors.New("{ParticipantId} not exist in receivers quorum")
+		return
+	}
+
+	receiver := m.payload.ResharingReceiversGet(request.ParticipantId)
+
+	if receiver.Status != internal.ResponseAwaitConfirmation {
+		err = fmt.Errorf("cannot confirm response with {Status} = {\"%s\"}", receiver.Status)
+		return
+	}
+
+	receiver.DkgResponse = make([]byte, len(request.Response))
+	copy(receiver.DkgResponse, request.Response)
+	receiver.Status = internal.ResponseConfirmed
+
+	receiver.UpdatedAt = request.CreatedAt
+	m.payload.ResharingProposalPayload.UpdatedAt = request.CreatedAt
+
+	m.payload.ResharingReceiversUpdate(request.ParticipantId, receiver)
+
+	return
+}
+
+func (m *ResharingProposalFSM) actionValidateResharingProposalAwaitResponses(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	var (
+		isContainsError bool
+	)
+
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if m.payload.ResharingProposalPayload.IsExpired() {
+		outEvent = eventResharingResponsesConfirmationCancelByTimeoutInternal
+		return
+	}
+
+	unconfirmedParticipants := m.payload.ResharingReceiversCount()
+	for _, participant := range m.payload.ResharingProposalPayload.Receivers {
+		if participant.Status == internal.ResponseConfirmationError {
+			isContainsError = true
+		} else if participant.Status == internal.ResponseConfirmed {
+			unconfirmedParticipants--
+		}
+	}
+
+	if isContainsError {
+		outEvent = eventResharingResponsesConfirmationCancelByErrorInternal
+		return
+	}
+
+	// The are no declined and timed out participants, check for all confirmations
+	if unconfirmedParticipants > 0 {
+		return
+	}
+
+	outEvent = eventResharingResponsesConfirmedInternal
+
+	for _, participant := range m.payload.ResharingProposalPayload.Receivers {
+		participant.Status = internal.MasterKeyAwaitConfirmation
+	}
+
+	// Make response
+
+	responseData := make(responses.ResharingProposalResponsesResponse, 0)
+
+	for participantId, participant := range m.payload.ResharingProposalPayload.Receivers {
+		responseEntry := &responses.ResharingProposalResponseEntry{
+			ParticipantId: participantId,
+			Username:      participant.Username,
+			DkgResponse:   participant.DkgResponse,
+		}
+		responseData = append(responseData, responseEntry)
+	}
+
+	response = responseData
+
+	return
+}
+
+// Master key
+
+func (m *ResharingProposalFSM) actionMasterKeyConfirmationReceived(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {ResharingProposalMasterKeyConfirmationRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.ResharingProposalMasterKeyConfirmationRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {ResharingProposalMasterKeyConfirmationRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	if !m.payload.ResharingReceiversExists(request.ParticipantId) {
+		err = errors.New("{ParticipantId} not exist in receivers quorum")
+		return
+	}
+
+	receiver := m.payload.ResharingReceiversGet(request.ParticipantId)
+
+	if receiver.Status != internal.MasterKeyAwaitConfirmation {
+		err = fmt.Errorf("cannot confirm master key with {Status} = {\"%s\"}", receiver.Status)
+		return
+	}
+
+	receiver.DkgMasterKey = make([]byte, len(request.MasterKey))
+	copy(receiver.DkgMasterKey, request.MasterKey)
+	receiver.DkgPubPoly = make([]byte, len(request.PubPoly))
+	copy(receiver.DkgPubPoly, request.PubPoly)
+	receiver.Status = internal.MasterKeyConfirmed
+
+	receiver.UpdatedAt = request.CreatedAt
+	m.payload.ResharingProposalPayload.UpdatedAt = request.CreatedAt
+
+	m.payload.ResharingReceiversUpdate(request.ParticipantId, receiver)
+
+	return
+}
+
+func (m *ResharingProposalFSM) actionValidateResharingProposalAwaitMasterKey(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	var (
+		isContainsError bool
+		pubPoly         []byte
+	)
+
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if m.payload.ResharingProposalPayload.IsExpired() {
+		outEvent = eventResharingMasterKeyConfirmationCancelByTimeoutInternal
+		return
+	}
+
+	unconfirmedParticipants := m.payload.ResharingReceiversCount()
+
+	for _, participant := range m.payload.ResharingProposalPayload.Receivers {
+		if participant.Status == internal.MasterKeyConfirmationError {
+			isContainsError = true
+		} else if participant.Status == internal.MasterKeyConfirmed {
+			unconfirmedParticipants--
+		}
+	}
+
+	if isContainsError {
+		outEvent = eventResharingMasterKeyConfirmationCancelByErrorInternal
+		return
+	}
+
+	// Resharing must keep the master key of the round untouched
+	for _, participant := range m.payload.ResharingProposalPayload.Receivers {
+		if participant.Status != internal.MasterKeyConfirmed {
+			continue
+		}
+		if !bytes.Equal(participant.DkgMasterKey, m.payload.ResharingProposalPayload.MasterKey) {
+			for _, participant := range m.payload.ResharingProposalPayload.Receivers {
+				participant.Status = internal.MasterKeyConfirmationError
+				participant.Error = internal.NewParticipantError(errors.New("master key is mismatched"))
+			}
+
+			outEvent = eventResharingMasterKeyConfirmationCancelByErrorInternal
+			return
+		}
+		// The new shares are verified against the new public polynomial, so receivers must agree on it
+		if pubPoly == nil {
+			pubPoly = participant.DkgPubPoly
+		}
+		if !bytes.Equal(participant.DkgPubPoly, pubPoly) {
+			for _, participant := range m.payload.ResharingProposalPayload.Receivers {
+				participant.Status = internal.MasterKeyConfirmationError
+				participant.Error = internal.NewParticipantError(errors.New("public polynomial is mismatched"))
+			}
+
+			outEvent = eventResharingMasterKeyConfirmationCancelByErrorInternal
+			return
+		}
+	}
+
+	// The are no declined and timed out participants, check for all confirmations
+	if unconfirmedParticipants > 0 {
+		return
+	}
+
+	outEvent = eventResharingMasterKeyConfirmedInternal
+
+	m.replaceQuorums()
+
+	return
+}
+
+// replaceQuorums makes the receivers the only participants of the round,
+// so the signing machine works with the new share holders
+func (m *ResharingProposalFSM) replaceQuorums() {
+	resharing := m.payload.ResharingProposalPayload
+
+	m.payload.SignatureProposalPayload.Quorum = make(internal.SignatureProposalQuorum)
+	m.payload.DKGProposalPayload.Quorum = make(internal.DKGProposalQuorum)
+	m.payload.PubKeys = nil
+	m.payload.IDs = nil
+
+	for participantId, participant := range resharing.Receivers {
+		m.payload.SignatureProposalPayload.Quorum[participantId] = &internal.SignatureProposalParticipant{
+			Username:  participant.Username,
+			PubKey:    participant.PubKey,
+			DkgPubKey: participant.DkgPubKey,
+			Status:    internal.SigConfirmationConfirmed,
+			Threshold: resharing.NewThreshold,
+			UpdatedAt: resharing.UpdatedAt,
+		}
+
+		m.payload.DKGProposalPayload.Quorum[participantId] = &internal.DKGProposalParticipant{
+			Username:     participant.Username,
+			DkgPubKey:    participant.DkgPubKey,
+			DkgMasterKey: participant.DkgMasterKey,
+			DkgPubPoly:   participant.DkgPubPoly,
+			Status:       internal.MasterKeyConfirmed,
+			UpdatedAt:    participant.UpdatedAt,
+		}
+
+		m.payload.SetPubKeyUsername(participant.Username, participant.PubKey)
+		m.payload.SetIDUsername(participant.Username, participantId)
+	}
+
+	m.payload.DKGProposalPayload.UpdatedAt = resharing.UpdatedAt
+}
+
+// Errors
+func (m *ResharingProposalFSM) actionConfirmationError(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	var (
+		participant   *internal.ResharingProposalParticipant
+		awaitStatus   internal.DKGParticipantStatus
+		confirmStatus internal.DKGParticipantStatus
+		errorStatus   internal.DKGParticipantStatus
+	)
+
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {ResharingProposalConfirmationErrorRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.ResharingProposalConfirmationErrorRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {ResharingProposalConfirmationErrorRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	switch inEvent {
+	case EventResharingDealConfirmationError:
+		participant = m.payload.ResharingDealersGet(request.ParticipantId)
+		awaitStatus, confirmStatus, errorStatus = internal.DealAwaitConfirmation, internal.DealConfirmed, internal.DealConfirmationError
+	case EventResharingResponseConfirmationError:
+		participant = m.payload.ResharingReceiversGet(request.ParticipantId)
+		awaitStatus, confirmStatus, errorStatus = internal.ResponseAwaitConfirmation, internal.ResponseConfirmed, internal.ResponseConfirmationError
+	case EventResharingMasterKeyConfirmationError:
+		participant = m.payload.ResharingReceiversGet(request.ParticipantId)
+		awaitStatus, confirmStatus, errorStatus = internal.MasterKeyAwaitConfirmation, internal.MasterKeyConfirmed, internal.MasterKeyConfirmationError
+	default:
+		err = fmt.Errorf("{%s} event cannot be used for action {actionConfirmationError}", inEvent)
+		return
+	}
+
+	if participant == nil {
+		err = errors.New("{ParticipantId} not exist in quorum")
+		return
+	}
+
+	switch participant.Status {
+	case awaitStatus:
+		participant.Status = errorStatus
+	case confirmStatus:
+		err = errors.New("{Status} already confirmed")
+	case errorStatus:
+		err = fmt.Errorf("{Status} already has {\"%s\"}", errorStatus)
+	default:
+		err = fmt.Errorf(
+			"{Status} now is \"%s\" and cannot set to {\"%s\"}",
+			participant.Status,
+			errorStatus,
+		)
+	}
+
+	if err != nil {
+		return
+	}
+
+	participant.Error = internal.NewParticipantError(request.Error)
+
+	participant.UpdatedAt = request.CreatedAt
+	m.payload.ResharingProposalPayload.UpdatedAt = request.CreatedAt
+
+	return
+}
+
+func (m *ResharingProposalFSM) actionConfirmationTimeout(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {DefaultRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.DefaultRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {DefaultRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	if !m.payload.ResharingProposalPayload.ExpiresAt.Before(request.CreatedAt) {
+		err = errors.New("{ExpiresAt} is not reached yet")
+		return
+	}
+
+	m.payload.ResharingProposalPayload.UpdatedAt = request.CreatedAt
+
+	return
+}
+
+// Rollback
+
+// actionRollbackResharing drops a canceled resharing, so the round is signed by the current share holders again
+func (m *ResharingProposalFSM) actionRollbackResharing(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {DefaultRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.DefaultRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {DefaultRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	// Public keys of the new participants were added to let them send messages before the new quorum is set
+	currentUsernames := make(map[string]bool)
+	for _, participant := range m.payload.SignatureProposalPayload.Quorum {
+		currentUsernames[participant.Username] = true
+	}
+	for username := range m.payload.PubKeys {
+		if !currentUsernames[username] {
+			delete(m.payload.PubKeys, username)
+		}
+	}
+
+	m.payload.ResharingProposalPayload = nil
+
+	return
+}
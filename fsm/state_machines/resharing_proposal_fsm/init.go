@@ -0,0 +1,196 @@
+package resharing_proposal_fsm
+
+import (
+	"sync"
+
+	"github.com/lidofinance/dc4bc/fsm/fsm"
+	dkp "github.com/lidofinance/dc4bc/fsm/state_machines/dkg_proposal_fsm"
+	"github.com/lidofinance/dc4bc/fsm/state_machines/internal"
+	sipf "github.com/lidofinance/dc4bc/fsm/state_machines/signing_proposal_fsm"
+)
+
+const (
+	FsmName = "resharing_proposal_fsm"
+
+	StateResharingInitial = sipf.StateSigningResharingRequested
+
+	// Approval of the proposal by a threshold of current share holders
+	StateResharingProposalAwaitConfirmations = fsm.State("state_resharing_proposal_await_confirmations")
+	// Canceled
+	StateResharingProposalAwaitCanceledByParticipant = fsm.State("state_resharing_proposal_await_canceled_by_participant")
+	StateResharingProposalAwaitCanceledByTimeout     = fsm.State("state_resharing_proposal_await_canceled_by_timeout")
+
+	// Sending resharing deals by current share holders
+	StateResharingDealsAwaitConfirmations = fsm.State("state_resharing_deals_await_confirmations")
+	// Canceled
+	StateResharingDealsAwaitCanceledByError   = fsm.State("state_resharing_deals_await_canceled_by_error")
+	StateResharingDealsAwaitCanceledByTimeout = fsm.State("state_resharing_deals_await_canceled_by_timeout")
+
+	// Sending responses by new share holders
+	StateResharingResponsesAwaitConfirmations = fsm.State("state_resharing_responses_await_confirmations")
+	// Canceled
+	StateResharingResponsesAwaitCanceledByError   = fsm.State("state_resharing_responses_await_canceled_by_error")
+	StateResharingResponsesAwaitCanceledByTimeout = fsm.State("state_resharing_responses_await_canceled_by_timeout")
+
+	StateResharingMasterKeyAwaitConfirmations     = fsm.State("state_resharing_master_key_await_confirmations")
+	StateResharingMasterKeyAwaitCanceledByError   = fsm.State("state_resharing_master_key_await_canceled_by_error")
+	StateResharingMasterKeyAwaitCanceledByTimeout = fsm.State("state_resharing_master_key_await_canceled_by_timeout")
+
+	// The new share holders have the same master key, signing can be continued
+	StateResharingMasterKeyCollected = dkp.StateDkgMasterKeyCollected
+
+	// A canceled resharing is rolled back, signing is continued by the current share holders
+	StateResharingRolledBack = sipf.StateSigningIdle
+
+	// Events
+	EventResharingProposalStart = fsm.Event("event_resharing_proposal_start")
+
+	EventResharingProposalConfirm                       = fsm.Event("event_resharing_proposal_confirm_by_participant")
+	EventResharingProposalDecline                       = fsm.Event("event_resharing_proposal_decline_by_participant")
+	eventResharingProposalCanceledByParticipantInternal = fsm.Event("event_resharing_proposal_canceled_by_participant_internal")
+	eventResharingProposalCanceledByTimeoutInternal     = fsm.Event("event_resharing_proposal_canceled_by_timeout_internal")
+	eventResharingProposalConfirmedInternal             = fsm.Event("event_resharing_proposal_confirmed_internal")
+	eventAutoResharingValidateProposalInternal          = fsm.Event("event_resharing_proposal_validate_internal")
+	EventResharingProposalTimeout                       = fsm.Event("event_resharing_proposal_timeout")
+
+	EventResharingDealConfirmationReceived                 = fsm.Event("event_resharing_deal_confirm_received")
+	EventResharingDealConfirmationError                    = fsm.Event("event_resharing_deal_confirm_canceled_by_error")
+	eventResharingDealsConfirmationCancelByTimeoutInternal = fsm.Event("event_resharing_deals_confirm_canceled_by_timeout_internal")
+	eventResharingDealsConfirmationCancelByErrorInternal   = fsm.Event("event_resharing_deals_confirm_canceled_by_error_internal")
+	eventResharingDealsConfirmedInternal                   = fsm.Event("event_resharing_deals_confirmed_internal")
+	eventAutoResharingValidateDealsInternal                = fsm.Event("event_resharing_deals_validate_internal")
+	EventResharingDealsConfirmationTimeout                 = fsm.Event("event_resharing_deals_confirm_timeout")
+
+	EventResharingResponseConfirmationReceived                 = fsm.Event("event_resharing_response_confirm_received")
+	EventResharingResponseConfirmationError                    = fsm.Event("event_resharing_response_confirm_canceled_by_error")
+	eventResharingResponsesConfirmationCancelByTimeoutInternal = fsm.Event("event_resharing_responses_confirm_canceled_by_timeout_internal")
+	eventResharingResponsesConfirmationCancelByErrorInternal   = fsm.Event("event_resharing_responses_confirm_canceled_by_error_internal")
+	eventResharingResponsesConfirmedInternal                   = fsm.Event("event_resharing_responses_confirmed_internal")
+	eventAutoResharingValidateResponsesInternal                = fsm.Event("event_resharing_responses_validate_internal")
+	EventResharingResponsesConfirmationTimeout                 = fsm.Event("event_resharing_responses_confirm_timeout")
+
+	EventResharingMasterKeyConfirmationReceived                = fsm.Event("event_resharing_master_key_confirm_received")
+	EventResharingMasterKeyConfirmationError                   = fsm.Event("event_resharing_master_key_confirm_canceled_by_error")
+	eventResharingMasterKeyConfirmationCancelByTimeoutInternal = fsm.Event("event_resharing_master_key_confirm_canceled_by_timeout_internal")
+	eventResharingMasterKeyConfirmationCancelByErrorInternal   = fsm.Event("event_resharing_master_key_confirm_canceled_by_error_internal")
+	eventResharingMasterKeyConfirmedInternal                   = fsm.Event("event_resharing_master_key_confirmed_internal")
+	eventAutoResharingValidateMasterKeyInternal                = fsm.Event("event_resharing_master_key_validate_internal")
+	EventResharingMasterKeyConfirmationTimeout                 = fsm.Event("event_resharing_master_key_confirm_timeout")
+
+	EventResharingRollback = fsm.Event("event_resharing_rollback")
+)
+
+type ResharingProposalFSM struct {
+	*fsm.FSM
+	payload   *internal.DumpedMachineStatePayload
+	payloadMu sync.RWMutex
+}
+
+func New() internal.DumpedMachineProvider {
+	machine := &ResharingProposalFSM{}
+
+	machine.FSM = fsm.MustNewFSM(
+		FsmName,
+		StateResharingInitial,
+		[]fsm.EventDesc{
+			// Start
+			{Name: EventResharingProposalStart, SrcState: []fsm.State{StateResharingInitial}, DstState: StateResharingProposalAwaitConfirmations},
+
+			// Approval by current share holders
+			{Name: EventResharingProposalConfirm, SrcState: []fsm.State{StateResharingProposalAwaitConfirmations}, DstState: StateResharingProposalAwaitConfirmations},
+			{Name: EventResharingProposalDecline, SrcState: []fsm.State{StateResharingProposalAwaitConfirmations}, DstState: StateResharingProposalAwaitConfirmations},
+			// Canceled
+			{Name: eventResharingProposalCanceledByParticipantInternal, SrcState: []fsm.State{StateResharingProposalAwaitConfirmations}, DstState: StateResharingProposalAwaitCanceledByParticipant, IsInternal: true},
+			{Name: eventResharingProposalCanceledByTimeoutInternal, SrcState: []fsm.State{StateResharingProposalAwaitConfirmations}, DstState: StateResharingProposalAwaitCanceledByTimeout, IsInternal: true},
+			// Timeout is reported by participants when the deadline has passed
+			{Name: EventResharingProposalTimeout, SrcState: []fsm.State{StateResharingProposalAwaitConfirmations}, DstState: StateResharingProposalAwaitCanceledByTimeout},
+
+			{Name: eventAutoResharingValidateProposalInternal, SrcState: []fsm.State{StateResharingProposalAwaitConfirmations}, DstState: StateResharingProposalAwaitConfirmations, IsInternal: true, IsAuto: true},
+
+			{Name: eventResharingProposalConfirmedInternal, SrcState: []fsm.State{StateResharingProposalAwaitConfirmations}, DstState: StateResharingDealsAwaitConfirmations, IsInternal: true},
+
+			// Deals
+			{Name: EventResharingDealConfirmationReceived, SrcState: []fsm.State{StateResharingDealsAwaitConfirmations}, DstState: StateResharingDealsAwaitConfirmations},
+			// Canceled
+			{Name: EventResharingDealConfirmationError, SrcState: []fsm.State{StateResharingDealsAwaitConfirmations}, DstState: StateResharingDealsAwaitCanceledByError},
+			{Name: eventResharingDealsConfirmationCancelByTimeoutInternal, SrcState: []fsm.State{StateResharingDealsAwaitConfirmations}, DstState: StateResharingDealsAwaitCanceledByTimeout, IsInternal: true},
+			{Name: EventResharingDealsConfirmationTimeout, SrcState: []fsm.State{StateResharingDealsAwaitConfirmations}, DstState: StateResharingDealsAwaitCanceledByTimeout},
+			{Name: eventResharingDealsConfirmationCancelByErrorInternal, SrcState: []fsm.State{StateResharingDealsAwaitConfirmations}, DstState: StateResharingDealsAwaitCanceledByError, IsInternal: true},
+
+			{Name: eventAutoResharingValidateDealsInternal, SrcState: []fsm.State{StateResharingDealsAwaitConfirmations}, DstState: StateResharingDealsAwaitConfirmations, IsInternal: true, IsAuto: true},
+
+			{Name: eventResharingDealsConfirmedInternal, SrcState: []fsm.State{StateResharingDealsAwaitConfirmations}, DstState: StateResharingResponsesAwaitConfirmations, IsInternal: true},
+
+			// Responses
+			{Name: EventResharingResponseConfirmationReceived, SrcState: []fsm.State{StateResharingResponsesAwaitConfirmations}, DstState: StateResharingResponsesAwaitConfirmations},
+			// Canceled
+			{Name: EventResharingResponseConfirmationError, SrcState: []fsm.State{StateResharingResponsesAwaitConfirmations}, DstState: StateResharingResponsesAwaitCanceledByError},
+			{Name: eventResharingResponsesConfirmationCancelByTimeoutInternal, SrcState: []fsm.State{StateResharingResponsesAwaitConfirmations}, DstState: StateResharingResponsesAwaitCanceledByTimeout, IsInternal: true},
+			{Name: EventResharingResponsesConfirmationTimeout, SrcState: []fsm.State{StateResharingResponsesAwaitConfirmations}, DstState: StateResharingResponsesAwaitCanceledByTimeout},
+			{Name: eventResharingResponsesConfirmationCancelByErrorInternal, SrcState: []fsm.State{StateResharingResponsesAwaitConfirmations}, DstState: StateResharingResponsesAwaitCanceledByError, IsInternal: true},
+
+			{Name: eventAutoResharingValidateResponsesInternal, SrcState: []fsm.State{StateResharingResponsesAwaitConfirmations}, DstState: StateResharingResponsesAwaitConfirmations, IsInternal: true, IsAuto: true},
+
+			{Name: eventResharingResponsesConfirmedInternal, SrcState: []fsm.State{StateResharingResponsesAwaitConfirmations}, DstState: StateResharingMasterKeyAwaitConfirmations, IsInternal: true},
+
+			// Master key
+			{Name: EventResharingMasterKeyConfirmationReceived, SrcState: []fsm.State{StateResharingMasterKeyAwaitConfirmations}, DstState: StateResharingMasterKeyAwaitConfirmations},
+			{Name: EventResharingMasterKeyConfirmationError, SrcState: []fsm.State{StateResharingMasterKeyAwaitConfirmations}, DstState: StateResharingMasterKeyAwaitCanceledByError},
+			{Name: eventResharingMasterKeyConfirmationCancelByErrorInternal, SrcState: []fsm.State{StateResharingMasterKeyAwaitConfirmations}, DstState: StateResharingMasterKeyAwaitCanceledByError, IsInternal: true},
+			{Name: eventResharingMasterKeyConfirmationCancelByTimeoutInternal, SrcState: []fsm.State{StateResharingMasterKeyAwaitConfirmations}, DstState: StateResharingMasterKeyAwaitCanceledByTimeout, IsInternal: true},
+			{Name: EventResharingMasterKeyConfirmationTimeout, SrcState: []fsm.State{StateResharingMasterKeyAwaitConfirmations}, DstState: StateResharingMasterKeyAwaitCanceledByTimeout},
+
+			{Name: eventAutoResharingValidateMasterKeyInternal, SrcState: []fsm.State{StateResharingMasterKeyAwaitConfirmations}, DstState: StateResharingMasterKeyAwaitConfirmations, IsInternal: true, IsAuto: true},
+
+			// Done
+			{Name: eventResharingMasterKeyConfirmedInternal, SrcState: []fsm.State{StateResharingMasterKeyAwaitConfirmations}, DstState: StateResharingMasterKeyCollected, IsInternal: true},
+
+			// Rollback of a canceled resharing returns the round to the current share holders
+			{Name: EventResharingRollback, SrcState: []fsm.State{
+				StateResharingProposalAwaitCanceledByParticipant,
+				StateResharingProposalAwaitCanceledByTimeout,
+				StateResharingDealsAwaitCanceledByError,
+				StateResharingDealsAwaitCanceledByTimeout,
+				StateResharingResponsesAwaitCanceledByError,
+				StateResharingResponsesAwaitCanceledByTimeout,
+				StateResharingMasterKeyAwaitCanceledByError,
+				StateResharingMasterKeyAwaitCanceledByTimeout,
+			}, DstState: StateResharingRolledBack},
+		},
+		fsm.Callbacks{
+			EventResharingProposalStart: machine.actionStartResharingProposal,
+
+			EventResharingProposalConfirm:              machine.actionProposalResponseByParticipant,
+			EventResharingProposalDecline:              machine.actionProposalResponseByParticipant,
+			eventAutoResharingValidateProposalInternal: machine.actionValidateResharingProposalConfirmations,
+			EventResharingProposalTimeout:              machine.actionConfirmationTimeout,
+
+			EventResharingDealConfirmationReceived:  machine.actionDealConfirmationReceived,
+			EventResharingDealConfirmationError:     machine.actionConfirmationError,
+			eventAutoResharingValidateDealsInternal: machine.actionValidateResharingProposalAwaitDeals,
+			EventResharingDealsConfirmationTimeout:  machine.actionConfirmationTimeout,
+
+			EventResharingResponseConfirmationReceived:  machine.actionResponseConfirmationReceived,
+			EventResharingResponseConfirmationError:     machine.actionConfirmationError,
+			eventAutoResharingValidateResponsesInternal: machine.actionValidateResharingProposalAwaitResponses,
+			EventResharingResponsesConfirmationTimeout:  machine.actionConfirmationTimeout,
+
+			EventResharingMasterKeyConfirmationReceived: machine.actionMasterKeyConfirmationReceived,
+			EventResharingMasterKeyConfirmationError:    machine.actionConfirmationError,
+			eventAutoResharingValidateMasterKeyInternal: machine.actionValidateResharingProposalAwaitMasterKey,
+			EventResharingMasterKeyConfirmationTimeout:  machine.actionConfirmationTimeout,
+
+			EventResharingRollback: machine.actionRollbackResharing,
+		},
+	)
+	return machine
+}
+
+func (m *ResharingProposalFSM) WithSetup(state fsm.State, payload *internal.DumpedMachineStatePayload) internal.DumpedMachineProvider {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	m.payload = payload
+	m.FSM = m.FSM.MustCopyWithState(state)
+	return m
+}
@@ -77,7 +77,6 @@ func (m *SignatureProposalFSM) actionInitSignatureProposal(inEvent fsm.Event, ar
 	return inEvent, responseData, nil
 }
 
-// TODO: Add timeout checking
 func (m *SignatureProposalFSM) actionProposalResponseByParticipant(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
 	m.payloadMu.Lock()
 	defer m.payloadMu.Unlock()
@@ -103,12 +102,14 @@ func (m *SignatureProposalFSM) actionProposalResponseByParticipant(inEvent fsm.E
 		return
 	}
 
-	signatureProposalParticipant := m.payload.SigQuorumGet(request.ParticipantId)
-	if signatureProposalParticipant.UpdatedAt.Add(config.SignatureProposalConfirmationDeadline).Before(request.CreatedAt) {
+	// a reply after the deadline cancels the proposal the same way a reported timeout does
+	if m.payload.SignatureProposalPayload.ExpiresAt.Before(request.CreatedAt) {
 		outEvent = eventSetValidationCanceledByTimeout
 		return
 	}
 
+	signatureProposalParticipant := m.payload.SigQuorumGet(request.ParticipantId)
+
 	if signatureProposalParticipant.Status != internal.SigConfirmationAwaitConfirmation {
 		err = fmt.Errorf("cannot apply reply participant with {Status} = {\"%s\"}", signatureProposalParticipant.Status)
 		return
@@ -178,3 +179,33 @@ func (m *SignatureProposalFSM) actionValidateSignatureProposal(fsm.Event, ...int
 
 	return eventSetProposalValidatedInternal, responseData, nil
 }
+
+func (m *SignatureProposalFSM) actionProposalTimeout(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {DefaultRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.DefaultRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {DefaultRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	if !m.payload.SignatureProposalPayload.ExpiresAt.Before(request.CreatedAt) {
+		err = errors.New("{ExpiresAt} is not reached yet")
+		return
+	}
+
+	m.payload.SignatureProposalPayload.UpdatedAt = request.CreatedAt
+
+	return
+}
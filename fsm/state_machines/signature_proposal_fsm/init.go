@@ -27,6 +27,7 @@ const (
 	eventSetProposalValidatedInternal       = fsm.Event("event_sig_proposal_set_validated")
 	eventSetValidationCanceledByTimeout     = fsm.Event("event_sig_proposal_canceled_timeout")
 	eventSetValidationCanceledByParticipant = fsm.Event("event_sig_proposal_canceled_participant")
+	EventSignatureProposalTimeout           = fsm.Event("event_sig_proposal_timeout")
 
 	StateSignatureProposalCollected = fsm.State("state_sig_proposal_collected")
 
@@ -69,12 +70,16 @@ func New() internal.DumpedMachineProvider {
 
 			// nan
 			{Name: eventSetValidationCanceledByTimeout, SrcState: []fsm.State{StateAwaitParticipantsConfirmations}, DstState: StateValidationCanceledByTimeout, IsInternal: true},
+
+			// Timeout is reported by participants when the deadline has passed
+			{Name: EventSignatureProposalTimeout, SrcState: []fsm.State{StateAwaitParticipantsConfirmations}, DstState: StateValidationCanceledByTimeout},
 		},
 		fsm.Callbacks{
 			EventInitProposal:                 machine.actionInitSignatureProposal,
 			EventConfirmSignatureProposal:     machine.actionProposalResponseByParticipant,
 			EventDeclineProposal:              machine.actionProposalResponseByParticipant,
 			eventAutoValidateProposalInternal: machine.actionValidateSignatureProposal,
+			EventSignatureProposalTimeout:     machine.actionProposalTimeout,
 		},
 	)
 	return machine
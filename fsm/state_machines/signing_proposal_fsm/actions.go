@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/corestario/kyber/pairing"
+	bls12381 "github.com/corestario/kyber/pairing/bls12381"
+	"github.com/lidofinance/dc4bc/dkg"
 	"github.com/lidofinance/dc4bc/fsm/config"
 	"github.com/lidofinance/dc4bc/fsm/fsm"
 	"github.com/lidofinance/dc4bc/fsm/state_machines/internal"
@@ -31,11 +34,7 @@ func (m *SigningProposalFSM) actionInitSigningProposal(inEvent fsm.Event, args .
 		return
 	}
 
-	m.payload.SigningProposalPayload = &internal.SigningConfirmation{
-		Quorum:    make(internal.SigningProposalQuorum),
-		CreatedAt: request.CreatedAt,
-		ExpiresAt: request.CreatedAt.Add(config.SigningConfirmationDeadline),
-	}
+	m.payload.SigningProposalsPayload = make(internal.SigningProposals)
 
 	return
 }
@@ -63,7 +62,8 @@ func (m *SigningProposalFSM) actionStartSigningProposal(inEvent fsm.Event, args
 	m.payload.SigningProposalPayload.SigningId = request.SigningID
 
 	m.payload.SigningProposalPayload.InitiatorId = request.ParticipantId
-	m.payload.SigningProposalPayload.SrcPayload = request.SrcPayload
+	m.payload.SigningProposalPayload.SrcPayloads = request.SrcPayloads
+	m.payload.SigningProposalPayload.Eth2Requests = request.Eth2Requests
 
 	m.payload.SigningProposalPayload.Quorum = make(internal.SigningProposalQuorum)
 
@@ -76,14 +76,23 @@ func (m *SigningProposalFSM) actionStartSigningProposal(inEvent fsm.Event, args
 		}
 	}
 
-	m.payload.SigningProposalPayload.Quorum[request.ParticipantId].Status = internal.SigningConfirmed
+	initiator, ok := m.payload.SigningProposalPayload.Quorum[request.ParticipantId]
+	if !ok {
+		err = errors.New("{ParticipantId} not exist in quorum")
+		return
+	}
+	initiator.Status = internal.SigningConfirmed
+
 	m.payload.SigningProposalPayload.CreatedAt = request.CreatedAt
+	m.payload.SigningProposalPayload.UpdatedAt = request.CreatedAt
+	m.payload.SigningProposalPayload.ExpiresAt = request.CreatedAt.Add(config.SigningConfirmationDeadline)
 
 	// Make response
 	responseData := responses.SigningProposalParticipantInvitationsResponse{
 		SigningId:    m.payload.SigningProposalPayload.SigningId,
 		InitiatorId:  m.payload.SigningProposalPayload.InitiatorId,
-		SrcPayload:   m.payload.SigningProposalPayload.SrcPayload,
+		SrcPayloads:  m.payload.SigningProposalPayload.SrcPayloads,
+		Eth2Requests: m.payload.SigningProposalPayload.Eth2Requests,
 		Participants: make([]*responses.SigningProposalParticipantInvitationEntry, 0),
 	}
 
@@ -151,7 +160,8 @@ func (m *SigningProposalFSM) actionProposalResponseByParticipant(inEvent fsm.Eve
 
 func (m *SigningProposalFSM) actionValidateSigningProposalConfirmations(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
 	var (
-		isContainsDecline bool
+		confirmedParticipants int
+		declinedParticipants  int
 	)
 
 	m.payloadMu.Lock()
@@ -162,36 +172,41 @@ func (m *SigningProposalFSM) actionValidateSigningProposalConfirmations(inEvent
 		return
 	}
 
-	unconfirmedParticipants := m.payload.SigningQuorumCount()
+	threshold := m.payload.SigQuorumThreshold()
 	for _, participant := range m.payload.SigningProposalPayload.Quorum {
 		if participant.Status == internal.SigningDeclined {
-			isContainsDecline = true
+			declinedParticipants++
 		} else if participant.Status == internal.SigningConfirmed {
-			unconfirmedParticipants--
+			confirmedParticipants++
 		}
 	}
 
-	if isContainsDecline {
+	// The rest of participants are not enough to reach the threshold
+	if m.payload.SigningQuorumCount()-declinedParticipants < threshold {
 		outEvent = eventSetSigningConfirmCanceledByParticipantInternal
 		return
 	}
 
-	// The are no declined and timed out participants, check for all confirmations
-	if unconfirmedParticipants > 0 {
+	// A threshold of confirmations is enough to start signing
+	if confirmedParticipants < threshold {
 		return
 	}
 
 	outEvent = eventSetProposalValidatedInternal
 
+	// Participants who haven't answered yet are still able to send partial signs
 	for _, participant := range m.payload.SigningProposalPayload.Quorum {
-		participant.Status = internal.SigningAwaitPartialSigns
+		if participant.Status != internal.SigningDeclined {
+			participant.Status = internal.SigningAwaitPartialSigns
+		}
 	}
 
 	// Make response
 	responseData := responses.SigningPartialSignsParticipantInvitationsResponse{
-		SigningId:   m.payload.SigningProposalPayload.SigningId,
-		InitiatorId: m.payload.SigningProposalPayload.InitiatorId,
-		SrcPayload:  m.payload.SigningProposalPayload.SrcPayload,
+		SigningId:    m.payload.SigningProposalPayload.SigningId,
+		InitiatorId:  m.payload.SigningProposalPayload.InitiatorId,
+		SrcPayloads:  m.payload.SigningProposalPayload.SrcPayloads,
+		Eth2Requests: m.payload.SigningProposalPayload.Eth2Requests,
 	}
 
 	response = responseData
@@ -231,21 +246,62 @@ func (m *SigningProposalFSM) actionPartialSignConfirmationReceived(inEvent fsm.E
 		return
 	}
 
-	signingProposalParticipant.PartialSign = make([]byte, len(request.PartialSign))
-	copy(signingProposalParticipant.PartialSign, request.PartialSign)
+	// every payload of the signing has its own partial sign
+	srcPayloads := m.payload.SigningProposalPayload.SrcPayloads
+	if len(request.PartialSigns) != len(srcPayloads) {
+		err = fmt.Errorf("expected {%d} partial signs, got {%d}", len(srcPayloads), len(request.PartialSigns))
+		return
+	}
+	if err = m.verifyPartialSigns(request.ParticipantId, srcPayloads, request.PartialSigns); err != nil {
+		err = fmt.Errorf("invalid partial signs from {%s}: %w", signingProposalParticipant.Username, err)
+		return
+	}
+
+	signingProposalParticipant.PartialSigns = make([][]byte, len(request.PartialSigns))
+	for i, partialSign := range request.PartialSigns {
+		signingProposalParticipant.PartialSigns[i] = make([]byte, len(partialSign))
+		copy(signingProposalParticipant.PartialSigns[i], partialSign)
+	}
 	signingProposalParticipant.Status = internal.SigningPartialSignsConfirmed
 
 	signingProposalParticipant.UpdatedAt = request.CreatedAt
-	m.payload.SignatureProposalPayload.UpdatedAt = request.CreatedAt
+	m.payload.SigningProposalPayload.UpdatedAt = request.CreatedAt
 
 	m.payload.SigningQuorumUpdate(request.ParticipantId, signingProposalParticipant)
 
 	return
 }
 
+// verifyPartialSigns checks partial signs of the messages against the public share of the participant
+func (m *SigningProposalFSM) verifyPartialSigns(participantId int, msgs, partialSigns [][]byte) error {
+	pubPolyBz := m.payload.DKGPubPoly()
+	// rounds finished before the public polynomial was confirmed have nothing to verify against
+	if len(pubPolyBz) == 0 {
+		return nil
+	}
+
+	suite := bls12381.NewBLS12381Suite(nil)
+	pubPoly, err := dkg.LoadPubPolyFromBytes(suite, pubPolyBz)
+	if err != nil {
+		return fmt.Errorf("failed to load {PubPoly}: %w", err)
+	}
+
+	index := m.payload.DKGQuorumShareIndex(participantId)
+	for i, partialSign := range partialSigns {
+		if err = dkg.VerifyPartialSign(suite.(pairing.Suite), pubPoly, index, msgs[i], partialSign); err != nil {
+			if len(partialSigns) > 1 {
+				return fmt.Errorf("partial sign #%d: %w", i, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *SigningProposalFSM) actionValidateSigningPartialSignsAwaitConfirmations(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
 	var (
-		isContainsError bool
+		failedParticipants int
+		signedParticipants int
 	)
 
 	m.payloadMu.Lock()
@@ -256,43 +312,50 @@ func (m *SigningProposalFSM) actionValidateSigningPartialSignsAwaitConfirmations
 		return
 	}
 
-	unconfirmedParticipants := m.payload.SigningQuorumCount()
+	// A participant who failed to sign is treated like one who declined the signing
 	for _, participant := range m.payload.SigningProposalPayload.Quorum {
-		if participant.Status == internal.SigningError {
-			isContainsError = true
-		} else if participant.Status == internal.SigningPartialSignsConfirmed {
-			unconfirmedParticipants--
+		switch participant.Status {
+		case internal.SigningError, internal.SigningDeclined:
+			failedParticipants++
+		case internal.SigningPartialSignsConfirmed:
+			signedParticipants++
 		}
 	}
 
-	if isContainsError {
+	// The rest of participants are not enough to reach the threshold
+	if m.payload.SigningQuorumCount()-failedParticipants < m.payload.SigQuorumThreshold() {
 		outEvent = eventSigningPartialSignsAwaitCancelByErrorInternal
 		return
 	}
 
-	// The are no declined and timed out participants, check for all confirmations
-	if unconfirmedParticipants > 0 {
+	// A threshold of partial signs is enough to reconstruct the signature
+	if signedParticipants < m.payload.SigQuorumThreshold() {
 		return
 	}
 
 	outEvent = eventSigningPartialSignsConfirmedInternal
 
 	for _, participant := range m.payload.SigningProposalPayload.Quorum {
-		participant.Status = internal.SigningProcess
+		if participant.Status == internal.SigningPartialSignsConfirmed {
+			participant.Status = internal.SigningProcess
+		}
 	}
 
 	// Response
 	responseData := responses.SigningProcessParticipantResponse{
 		SigningId:    m.payload.SigningProposalPayload.SigningId,
-		SrcPayload:   m.payload.SigningProposalPayload.SrcPayload,
+		SrcPayloads:  m.payload.SigningProposalPayload.SrcPayloads,
 		Participants: make([]*responses.SigningProcessParticipantEntry, 0),
 	}
 
 	for participantId, participant := range m.payload.SigningProposalPayload.Quorum {
+		if participant.Status != internal.SigningProcess {
+			continue
+		}
 		responseEntry := &responses.SigningProcessParticipantEntry{
 			ParticipantId: participantId,
 			Username:      participant.Username,
-			PartialSign:   participant.PartialSign,
+			PartialSigns:  participant.PartialSigns,
 		}
 		responseData.Participants = append(responseData.Participants, responseEntry)
 	}
@@ -302,7 +365,47 @@ func (m *SigningProposalFSM) actionValidateSigningPartialSignsAwaitConfirmations
 	return
 }
 
-func (m *SigningProposalFSM) actionSigningRestart(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+func (m *SigningProposalFSM) actionRequestResharing(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	// Shares are replaced by resharing, so signing sessions in progress could not be finished
+	for signingId, signing := range m.payload.SigningProposalsPayload {
+		if !IsSigningFinished(signing.State) {
+			err = fmt.Errorf("signing {%s} is in progress", signingId)
+			return
+		}
+	}
+
+	return
+}
+
+func (m *SigningProposalFSM) actionSigningTimeout(inEvent fsm.Event, args ...interface{}) (outEvent fsm.Event, response interface{}, err error) {
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+
+	if len(args) != 1 {
+		err = errors.New("{arg0} required {SigningProposalTimeoutRequest}")
+		return
+	}
+
+	request, ok := args[0].(requests.SigningProposalTimeoutRequest)
+
+	if !ok {
+		err = errors.New("cannot cast {arg0} to type {SigningProposalTimeoutRequest}")
+		return
+	}
+
+	if err = request.Validate(); err != nil {
+		return
+	}
+
+	if !m.payload.SigningProposalPayload.ExpiresAt.Before(request.CreatedAt) {
+		err = errors.New("{ExpiresAt} is not reached yet")
+		return
+	}
+
+	m.payload.SigningProposalPayload.UpdatedAt = request.CreatedAt
 
 	return
 }
@@ -360,10 +463,10 @@ func (m *SigningProposalFSM) actionConfirmationError(inEvent fsm.Event, args ...
 		return
 	}
 
-	signingProposalParticipant.Error = request.Error
+	signingProposalParticipant.Error = internal.NewParticipantError(request.Error)
 
 	signingProposalParticipant.UpdatedAt = request.CreatedAt
-	m.payload.SignatureProposalPayload.UpdatedAt = request.CreatedAt
+	m.payload.SigningProposalPayload.UpdatedAt = request.CreatedAt
 
 	m.payload.SigningQuorumUpdate(request.ParticipantId, signingProposalParticipant)
 
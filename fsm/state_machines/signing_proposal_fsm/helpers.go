@@ -3,6 +3,8 @@ package signing_proposal_fsm
 import (
 	"crypto/rand"
 	"encoding/base64"
+
+	"github.com/lidofinance/dc4bc/fsm/fsm"
 )
 
 const (
@@ -18,3 +20,16 @@ func generateSigningId() (string, error) {
 
 	return base64.URLEncoding.EncodeToString(b), err
 }
+
+// IsSigningFinished returns true if a signing session in the state can not be continued
+func IsSigningFinished(state fsm.State) bool {
+	switch state {
+	case StateSigningPartialSignsCollected,
+		StateSigningConfirmationsAwaitCancelledByTimeout,
+		StateSigningConfirmationsAwaitCancelledByParticipant,
+		StateSigningPartialSignsAwaitCancelledByTimeout,
+		StateSigningPartialSignsAwaitCancelledByError:
+		return true
+	}
+	return false
+}
@@ -28,6 +28,9 @@ const (
 
 	StateSigningPartialSignsCollected = fsm.State("state_signing_partial_signs_collected")
 
+	// Resharing of the round key was requested, the round is handed over to the resharing machine
+	StateSigningResharingRequested = fsm.State("state_signing_resharing_requested")
+
 	// Events
 
 	EventSigningInit                                    = fsm.Event("event_signing_init")
@@ -36,6 +39,7 @@ const (
 	EventDeclineSigningConfirmation                     = fsm.Event("event_signing_proposal_decline_by_participant")
 	eventSetSigningConfirmCanceledByParticipantInternal = fsm.Event("event_signing_proposal_canceled_by_participant")
 	eventSetSigningConfirmCanceledByTimeoutInternal     = fsm.Event("event_signing_proposal_canceled_by_timeout")
+	EventSigningConfirmationsTimeout                    = fsm.Event("event_signing_confirmations_timeout")
 
 	eventAutoSigningValidateProposalInternal = fsm.Event("event_signing_proposal_await_validate")
 	eventSetProposalValidatedInternal        = fsm.Event("event_signing_proposal_set_validated")
@@ -44,11 +48,13 @@ const (
 	EventSigningPartialSignError                         = fsm.Event("event_signing_partial_sign_error_received")
 	eventSigningPartialSignsAwaitCancelByTimeoutInternal = fsm.Event("event_signing_partial_signs_await_cancel_by_timeout_internal")
 	eventSigningPartialSignsAwaitCancelByErrorInternal   = fsm.Event("event_signing_partial_signs_await_sign_cancel_by_error_internal")
+	EventSigningPartialSignsTimeout                      = fsm.Event("event_signing_partial_signs_timeout")
 
 	eventAutoSigningValidatePartialSignInternal = fsm.Event("event_signing_partial_signs_await_validate")
 
 	eventSigningPartialSignsConfirmedInternal = fsm.Event("event_signing_partial_signs_confirmed_internal")
-	EventSigningRestart                       = fsm.Event("event_signing_restart")
+
+	EventSigningResharingRequest = fsm.Event("event_signing_resharing_request")
 )
 
 type SigningProposalFSM struct {
@@ -77,6 +83,8 @@ func New() internal.DumpedMachineProvider {
 			// Canceled
 			{Name: eventSetSigningConfirmCanceledByParticipantInternal, SrcState: []fsm.State{StateSigningAwaitConfirmations}, DstState: StateSigningConfirmationsAwaitCancelledByParticipant, IsInternal: true},
 			{Name: eventSetSigningConfirmCanceledByTimeoutInternal, SrcState: []fsm.State{StateSigningAwaitConfirmations}, DstState: StateSigningConfirmationsAwaitCancelledByTimeout, IsInternal: true},
+			// Timeout is reported by participants when the deadline has passed
+			{Name: EventSigningConfirmationsTimeout, SrcState: []fsm.State{StateSigningAwaitConfirmations}, DstState: StateSigningConfirmationsAwaitCancelledByTimeout},
 
 			// Validate
 			{Name: eventAutoSigningValidateProposalInternal, SrcState: []fsm.State{StateSigningAwaitConfirmations}, DstState: StateSigningAwaitConfirmations, IsInternal: true, IsAuto: true},
@@ -85,16 +93,19 @@ func New() internal.DumpedMachineProvider {
 
 			// Canceled
 			{Name: EventSigningPartialSignReceived, SrcState: []fsm.State{StateSigningAwaitPartialSigns}, DstState: StateSigningAwaitPartialSigns},
-			{Name: EventSigningPartialSignError, SrcState: []fsm.State{StateSigningAwaitPartialSigns}, DstState: StateSigningPartialSignsAwaitCancelledByError},
+			// A failed participant is treated like a declined one, the signing is canceled if the rest can't reach the threshold
+			{Name: EventSigningPartialSignError, SrcState: []fsm.State{StateSigningAwaitPartialSigns}, DstState: StateSigningAwaitPartialSigns},
 			{Name: eventSigningPartialSignsAwaitCancelByTimeoutInternal, SrcState: []fsm.State{StateSigningAwaitPartialSigns}, DstState: StateSigningPartialSignsAwaitCancelledByTimeout, IsInternal: true},
 			{Name: eventSigningPartialSignsAwaitCancelByErrorInternal, SrcState: []fsm.State{StateSigningAwaitPartialSigns}, DstState: StateSigningPartialSignsAwaitCancelledByError, IsInternal: true},
+			{Name: EventSigningPartialSignsTimeout, SrcState: []fsm.State{StateSigningAwaitPartialSigns}, DstState: StateSigningPartialSignsAwaitCancelledByTimeout},
 
 			// Validate
 			{Name: eventAutoSigningValidatePartialSignInternal, SrcState: []fsm.State{StateSigningAwaitPartialSigns}, DstState: StateSigningAwaitPartialSigns, IsInternal: true, IsAuto: true},
 
 			{Name: eventSigningPartialSignsConfirmedInternal, SrcState: []fsm.State{StateSigningAwaitPartialSigns}, DstState: StateSigningPartialSignsCollected, IsInternal: true},
 
-			{Name: EventSigningRestart, SrcState: []fsm.State{StateSigningPartialSignsCollected}, DstState: StateSigningIdle},
+			// Resharing
+			{Name: EventSigningResharingRequest, SrcState: []fsm.State{StateSigningIdle}, DstState: StateSigningResharingRequested},
 		},
 		fsm.Callbacks{
 			EventSigningInit:                            machine.actionInitSigningProposal,
@@ -105,7 +116,9 @@ func New() internal.DumpedMachineProvider {
 			EventSigningPartialSignReceived:             machine.actionPartialSignConfirmationReceived,
 			eventAutoSigningValidatePartialSignInternal: machine.actionValidateSigningPartialSignsAwaitConfirmations,
 			EventSigningPartialSignError:                machine.actionConfirmationError,
-			EventSigningRestart:                         machine.actionSigningRestart,
+			EventSigningResharingRequest:                machine.actionRequestResharing,
+			EventSigningConfirmationsTimeout:            machine.actionSigningTimeout,
+			EventSigningPartialSignsTimeout:             machine.actionSigningTimeout,
 		},
 	)
 
@@ -23,6 +23,16 @@ type DKGProposalDealConfirmationRequest struct {
 type DKGProposalResponseConfirmationRequest struct {
 	ParticipantId int
 	Response      []byte
+	// Complaints are participant IDs of dealers whose deals were not accepted
+	Complaints []int
+	CreatedAt  time.Time
+}
+
+// States: "state_dkg_justifications_await_confirmations"
+// Events: "event_dkg_justification_confirm_received"
+type DKGProposalJustificationConfirmationRequest struct {
+	ParticipantId int
+	Justification []byte
 	CreatedAt     time.Time
 }
 
@@ -31,6 +41,7 @@ type DKGProposalResponseConfirmationRequest struct {
 type DKGProposalMasterKeyConfirmationRequest struct {
 	ParticipantId int
 	MasterKey     []byte
+	PubPoly       []byte
 	CreatedAt     time.Time
 }
 
@@ -38,12 +49,14 @@ type DKGProposalMasterKeyConfirmationRequest struct {
 // 			"state_dkg_commits_sending_await_confirmations"
 //			"state_dkg_deals_await_confirmations"
 //			"state_dkg_responses_await_confirmations"
+//			"state_dkg_justifications_await_confirmations"
 // 			"state_dkg_master_key_await_confirmations"
 //
 // Events:  "event_dkg_pub_key_confirm_canceled_by_error",
 //			"event_dkg_commit_confirm_canceled_by_error"
 //			"event_dkg_deal_confirm_canceled_by_error"
 // 			"event_dkg_response_confirm_canceled_by_error"
+//			"event_dkg_justification_confirm_canceled_by_error"
 //			"event_dkg_master_key_confirm_canceled_by_error"
 type DKGProposalConfirmationErrorRequest struct {
 	ParticipantId int
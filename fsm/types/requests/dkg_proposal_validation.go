@@ -43,6 +43,28 @@ func (r *DKGProposalResponseConfirmationRequest) Validate() error {
 		return errors.New("{Response} cannot zero length")
 	}
 
+	for _, dealerId := range r.Complaints {
+		if dealerId < 0 {
+			return errors.New("{Complaints} cannot contain a negative number")
+		}
+	}
+
+	if r.CreatedAt.IsZero() {
+		return errors.New("{CreatedAt} is not set")
+	}
+
+	return nil
+}
+
+func (r *DKGProposalJustificationConfirmationRequest) Validate() error {
+	if r.ParticipantId < 0 {
+		return errors.New("{ParticipantId} cannot be a negative number")
+	}
+
+	if len(r.Justification) == 0 {
+		return errors.New("{Justification} cannot zero length")
+	}
+
 	if r.CreatedAt.IsZero() {
 		return errors.New("{CreatedAt} is not set")
 	}
@@ -59,6 +81,10 @@ func (r *DKGProposalMasterKeyConfirmationRequest) Validate() error {
 		return errors.New("{MasterKey} cannot zero length")
 	}
 
+	if len(r.PubPoly) == 0 {
+		return errors.New("{PubPoly} cannot zero length")
+	}
+
 	if r.CreatedAt.IsZero() {
 		return errors.New("{CreatedAt} is not set")
 	}
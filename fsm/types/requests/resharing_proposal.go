@@ -0,0 +1,61 @@
+package requests
+
+import "time"
+
+// States: "state_signing_resharing_requested"
+// Events: "event_resharing_proposal_start"
+type ResharingProposalStartRequest struct {
+	ParticipantId   int
+	Dealers         []int
+	NewParticipants []*SignatureProposalParticipantsEntry
+	NewThreshold    int
+	CreatedAt       time.Time
+}
+
+// States: "state_resharing_proposal_await_confirmations"
+// Events: "event_resharing_proposal_confirm_by_participant"
+//		   "event_resharing_proposal_decline_by_participant"
+type ResharingProposalConfirmationRequest struct {
+	ParticipantId int
+	CreatedAt     time.Time
+}
+
+// States: "state_resharing_deals_await_confirmations"
+// Events: "event_resharing_deal_confirm_received"
+type ResharingProposalDealConfirmationRequest struct {
+	ParticipantId int
+	Commit        []byte
+	PubPoly       []byte
+	Deals         map[int][]byte
+	CreatedAt     time.Time
+}
+
+// States: "state_resharing_responses_await_confirmations"
+// Events: "event_resharing_response_confirm_received"
+type ResharingProposalResponseConfirmationRequest struct {
+	ParticipantId int
+	Response      []byte
+	CreatedAt     time.Time
+}
+
+// States: "state_resharing_master_key_await_confirmations"
+// Events: "event_resharing_master_key_confirm_received"
+type ResharingProposalMasterKeyConfirmationRequest struct {
+	ParticipantId int
+	MasterKey     []byte
+	PubPoly       []byte
+	CreatedAt     time.Time
+}
+
+// States:  "state_resharing_deals_await_confirmations"
+//			"state_resharing_responses_await_confirmations"
+// 			"state_resharing_master_key_await_confirmations"
+//
+// Events:  "event_resharing_deal_confirm_canceled_by_error"
+//			"event_resharing_response_confirm_canceled_by_error"
+//			"event_resharing_master_key_confirm_canceled_by_error"
+type ResharingProposalConfirmationErrorRequest struct {
+	ParticipantId int
+	Error         error
+	CreatedAt     time.Time
+}
@@ -0,0 +1,161 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lidofinance/dc4bc/fsm/config"
+)
+
+func (r *ResharingProposalStartRequest) Validate() error {
+	if r.ParticipantId < 0 {
+		return errors.New("{ParticipantId} cannot be a negative number")
+	}
+
+	if len(r.Dealers) == 0 {
+		return errors.New("{Dealers} cannot be empty")
+	}
+
+	uniqueDealers := make(map[int]bool)
+	for _, dealer := range r.Dealers {
+		if dealer < 0 {
+			return errors.New("{Dealers} cannot contain a negative number")
+		}
+		if _, ok := uniqueDealers[dealer]; ok {
+			return errors.New("{Dealers} must be unique")
+		}
+		uniqueDealers[dealer] = true
+	}
+
+	if len(r.NewParticipants) < config.ParticipantsMinCount {
+		return fmt.Errorf("too few participants, minimum is {%d}", config.ParticipantsMinCount)
+	}
+
+	if r.NewThreshold < 2 {
+		return errors.New("{NewThreshold} minimum count is {2}")
+	}
+
+	if r.NewThreshold > len(r.NewParticipants) {
+		return errors.New("{NewThreshold} cannot be higher than {ParticipantsCount}")
+	}
+
+	uniqueUsernames := make(map[string]bool)
+	for _, participant := range r.NewParticipants {
+		if _, ok := uniqueUsernames[participant.Username]; ok {
+			return errors.New("{Username} must be unique")
+		}
+		uniqueUsernames[participant.Username] = true
+	}
+
+	for _, participant := range r.NewParticipants {
+		if len(participant.Username) < 3 {
+			return errors.New("{Username} minimum length is {3}")
+		}
+
+		if len(participant.Username) > 150 {
+			return errors.New("{Username} maximum length is {150}")
+		}
+
+		if len(participant.PubKey) < 10 {
+			return errors.New("{PubKey} too short")
+		}
+
+		if len(participant.DkgPubKey) < 10 {
+			return errors.New("{DkgPubKey} too short")
+		}
+	}
+
+	if r.CreatedAt.IsZero() {
+		return errors.New("{CreatedAt} cannot be a nil")
+	}
+
+	return nil
+}
+
+func (r *ResharingProposalConfirmationRequest) Validate() error {
+	if r.ParticipantId < 0 {
+		return errors.New("{ParticipantId} cannot be a negative number")
+	}
+
+	if r.CreatedAt.IsZero() {
+		return errors.New("{CreatedAt} is not set")
+	}
+
+	return nil
+}
+
+func (r *ResharingProposalDealConfirmationRequest) Validate() error {
+	if r.ParticipantId < 0 {
+		return errors.New("{ParticipantId} cannot be a negative number")
+	}
+
+	if len(r.Commit) == 0 {
+		return errors.New("{Commit} cannot zero length")
+	}
+
+	if len(r.PubPoly) == 0 {
+		return errors.New("{PubPoly} cannot zero length")
+	}
+
+	if len(r.Deals) == 0 {
+		return errors.New("{Deals} cannot be empty")
+	}
+
+	if r.CreatedAt.IsZero() {
+		return errors.New("{CreatedAt} is not set")
+	}
+
+	return nil
+}
+
+func (r *ResharingProposalResponseConfirmationRequest) Validate() error {
+	if r.ParticipantId < 0 {
+		return errors.New("{ParticipantId} cannot be a negative number")
+	}
+
+	if len(r.Response) == 0 {
+		return errors.New("{Response} cannot zero length")
+	}
+
+	if r.CreatedAt.IsZero() {
+		return errors.New("{CreatedAt} is not set")
+	}
+
+	return nil
+}
+
+func (r *ResharingProposalMasterKeyConfirmationRequest) Validate() error {
+	if r.ParticipantId < 0 {
+		return errors.New("{ParticipantId} cannot be a negative number")
+	}
+
+	if len(r.MasterKey) == 0 {
+		return errors.New("{MasterKey} cannot zero length")
+	}
+
+	if len(r.PubPoly) == 0 {
+		return errors.New("{PubPoly} cannot zero length")
+	}
+
+	if r.CreatedAt.IsZero() {
+		return errors.New("{CreatedAt} is not set")
+	}
+
+	return nil
+}
+
+func (r *ResharingProposalConfirmationErrorRequest) Validate() error {
+	if r.ParticipantId < 0 {
+		return errors.New("{ParticipantId} cannot be a negative number")
+	}
+
+	if r.Error == nil {
+		return errors.New("{Error} cannot be a nil")
+	}
+
+	if r.CreatedAt.IsZero() {
+		return errors.New("{CreatedAt} is not set")
+	}
+
+	return nil
+}
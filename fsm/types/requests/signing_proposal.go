@@ -1,14 +1,49 @@
 package requests
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lidofinance/dc4bc/eth2"
+)
 
 // States: "stage_signing_idle"
 // Events: "event_signing_start"
+// A single payload is signed as a batch of one
 type SigningProposalStartRequest struct {
 	SigningID     string
 	ParticipantId int
-	SrcPayload    []byte
-	CreatedAt     time.Time
+	SrcPayloads   [][]byte
+	// Validator operations, which signing roots are the source messages
+	Eth2Requests []*eth2.SigningRequest
+	CreatedAt    time.Time
+}
+
+// UnmarshalJSON decodes a single payload of a request sent before batches as a batch of one
+func (r *SigningProposalStartRequest) UnmarshalJSON(data []byte) error {
+	type request SigningProposalStartRequest
+	var legacy struct {
+		request
+		SrcPayload  []byte
+		Eth2Request *eth2.SigningRequest
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*r = SigningProposalStartRequest(legacy.request)
+	if len(legacy.SrcPayload) == 0 && legacy.Eth2Request == nil {
+		return nil
+	}
+	if len(r.SrcPayloads) > 0 || len(r.Eth2Requests) > 0 {
+		return errors.New("{SrcPayload} and {Eth2Request} cannot be set with {SrcPayloads}")
+	}
+	r.SrcPayloads = [][]byte{legacy.SrcPayload}
+	if legacy.Eth2Request != nil {
+		r.Eth2Requests = []*eth2.SigningRequest{legacy.Eth2Request}
+	}
+	return nil
 }
 
 // States: "state_signing_await_confirmations"
@@ -22,9 +57,41 @@ type SigningProposalParticipantRequest struct {
 
 // States: "state_signing_await_partial_keys"
 // Events: "event_signing_partial_key_received"
+// A partial sign is sent for each of the payloads of the signing
 type SigningProposalPartialSignRequest struct {
 	SigningId     string
 	ParticipantId int
-	PartialSign   []byte
+	PartialSigns  [][]byte
 	CreatedAt     time.Time
 }
+
+// UnmarshalJSON decodes a single partial sign of a request sent before batches as a batch of one
+func (r *SigningProposalPartialSignRequest) UnmarshalJSON(data []byte) error {
+	type request SigningProposalPartialSignRequest
+	var legacy struct {
+		request
+		PartialSign []byte
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*r = SigningProposalPartialSignRequest(legacy.request)
+	if len(legacy.PartialSign) == 0 {
+		return nil
+	}
+	if len(r.PartialSigns) > 0 {
+		return errors.New("{PartialSign} cannot be set with {PartialSigns}")
+	}
+	r.PartialSigns = [][]byte{legacy.PartialSign}
+	return nil
+}
+
+// States: "state_signing_await_confirmations"
+//		   "state_signing_await_partial_signs"
+// Events: "event_signing_confirmations_timeout"
+//		   "event_signing_partial_signs_timeout"
+type SigningProposalTimeoutRequest struct {
+	SigningId string
+	CreatedAt time.Time
+}
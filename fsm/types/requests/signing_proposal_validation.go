@@ -1,14 +1,36 @@
 package requests
 
-import "errors"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/lidofinance/dc4bc/eth2"
+)
 
 func (r *SigningProposalStartRequest) Validate() error {
 	if r.ParticipantId < 0 {
 		return errors.New("{ParticipantId} cannot be a negative number")
 	}
 
-	if len(r.SrcPayload) == 0 {
-		return errors.New("{SrcPayload} cannot zero length")
+	if len(r.SrcPayloads) == 0 {
+		return errors.New("{SrcPayloads} cannot zero length")
+	}
+
+	for i, srcPayload := range r.SrcPayloads {
+		if len(srcPayload) == 0 {
+			return fmt.Errorf("{SrcPayloads[%d]} cannot zero length", i)
+		}
+	}
+
+	if len(r.Eth2Requests) > 0 && len(r.Eth2Requests) != len(r.SrcPayloads) {
+		return errors.New("{Eth2Requests} must be set for each of {SrcPayloads}")
+	}
+
+	for i, eth2Request := range r.Eth2Requests {
+		if err := validateEth2Request(r.SrcPayloads[i], eth2Request); err != nil {
+			return fmt.Errorf("{SrcPayloads[%d]}: %w", i, err)
+		}
 	}
 
 	if r.CreatedAt.IsZero() {
@@ -43,8 +65,26 @@ func (r *SigningProposalPartialSignRequest) Validate() error {
 		return errors.New("{ParticipantId} cannot be a negative number")
 	}
 
-	if len(r.PartialSign) == 0 {
-		return errors.New("{PartialSign} cannot zero length")
+	if len(r.PartialSigns) == 0 {
+		return errors.New("{PartialSigns} cannot zero length")
+	}
+
+	for i, partialSign := range r.PartialSigns {
+		if len(partialSign) == 0 {
+			return fmt.Errorf("{PartialSigns[%d]} cannot zero length", i)
+		}
+	}
+
+	if r.CreatedAt.IsZero() {
+		return errors.New("{CreatedAt} is not set")
+	}
+
+	return nil
+}
+
+func (r *SigningProposalTimeoutRequest) Validate() error {
+	if r.SigningId == "" {
+		return errors.New("{SigningId} cannot be empty")
 	}
 
 	if r.CreatedAt.IsZero() {
@@ -53,3 +93,20 @@ func (r *SigningProposalPartialSignRequest) Validate() error {
 
 	return nil
 }
+
+// validateEth2Request checks that the payload is the signing root of the validator operation, if it's set
+func validateEth2Request(srcPayload []byte, eth2Request *eth2.SigningRequest) error {
+	if eth2Request == nil {
+		return nil
+	}
+
+	signingRoot, err := eth2Request.SigningRoot()
+	if err != nil {
+		return fmt.Errorf("invalid {Eth2Request}: %w", err)
+	}
+	if !bytes.Equal(srcPayload, signingRoot[:]) {
+		return errors.New("{SrcPayload} is not the signing root of {Eth2Request}")
+	}
+
+	return nil
+}
@@ -30,4 +30,8 @@ type DKGProposalResponseParticipantEntry struct {
 	ParticipantId int
 	Username      string
 	DkgResponse   []byte
+	// DkgJustification answers complaints about the participant's deal, it's set only if there were complaints
+	DkgJustification []byte `json:",omitempty"`
+	// Disqualified is set if the participant didn't answer complaints against its deal in time
+	Disqualified bool `json:",omitempty"`
 }
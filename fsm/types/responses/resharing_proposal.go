@@ -0,0 +1,53 @@
+package responses
+
+// Event:  "event_resharing_proposal_start"
+// States: "state_resharing_proposal_await_confirmations"
+type ResharingProposalConfirmationResponse struct {
+	ResharingProposalParticipantsResponse
+	InitiatorId int
+}
+
+// Event:  "event_resharing_proposal_confirm_by_participant"
+// States: "state_resharing_deals_await_confirmations"
+type ResharingProposalParticipantsResponse struct {
+	OldThreshold int
+	NewThreshold int
+	// Current share holders which issue deals to the new ones
+	Dealers []int
+	// All current share holders, indexed by their current participant id
+	OldParticipants []*ResharingProposalParticipantEntry
+	// New share holders, indexed by their new participant id
+	NewParticipants []*ResharingProposalParticipantEntry
+}
+
+type ResharingProposalParticipantEntry struct {
+	ParticipantId int
+	Username      string
+	DkgPubKey     []byte
+}
+
+// Event:  "event_resharing_deal_confirm_received"
+// States: "state_resharing_responses_await_confirmations"
+type ResharingProposalDealsResponse struct {
+	ResharingProposalParticipantsResponse
+	Deals []*ResharingProposalDealEntry
+}
+
+type ResharingProposalDealEntry struct {
+	ParticipantId int
+	Username      string
+	DkgCommit     []byte
+	DkgPubPoly    []byte
+	// Encrypted deals, keyed by the new participant id
+	DkgDeals map[int][]byte
+}
+
+// Event:  "event_resharing_response_confirm_received"
+// States: "state_resharing_master_key_await_confirmations"
+type ResharingProposalResponsesResponse []*ResharingProposalResponseEntry
+
+type ResharingProposalResponseEntry struct {
+	ParticipantId int
+	Username      string
+	DkgResponse   []byte
+}
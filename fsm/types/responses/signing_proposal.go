@@ -1,13 +1,37 @@
 package responses
 
+import (
+	"encoding/json"
+
+	"github.com/lidofinance/dc4bc/eth2"
+)
+
 // Event:  "event_signing_start"
 // States: "state_signing_await_confirmations"
 type SigningProposalParticipantInvitationsResponse struct {
 	SigningId    string
 	InitiatorId  int
 	Participants []*SigningProposalParticipantInvitationEntry
-	// Source message for signing
-	SrcPayload []byte
+	// Source messages for signing, a single message is signed as a batch of one
+	SrcPayloads [][]byte
+	// Validator operations, which signing roots are the source messages
+	Eth2Requests []*eth2.SigningRequest
+}
+
+// UnmarshalJSON decodes a single payload of an operation made before batches as a batch of one
+func (r *SigningProposalParticipantInvitationsResponse) UnmarshalJSON(data []byte) error {
+	type response SigningProposalParticipantInvitationsResponse
+	var legacy struct {
+		response
+		legacyPayload
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*r = SigningProposalParticipantInvitationsResponse(legacy.response)
+	r.SrcPayloads, r.Eth2Requests = legacy.batch(r.SrcPayloads, r.Eth2Requests)
+	return nil
 }
 
 type SigningProposalParticipantInvitationEntry struct {
@@ -19,9 +43,26 @@ type SigningProposalParticipantInvitationEntry struct {
 // Event:  "event_signing_proposal_confirm_by_participant"
 // States: "state_signing_await_partial_keys"
 type SigningPartialSignsParticipantInvitationsResponse struct {
-	SigningId   string
-	InitiatorId int
-	SrcPayload  []byte
+	SigningId    string
+	InitiatorId  int
+	SrcPayloads  [][]byte
+	Eth2Requests []*eth2.SigningRequest
+}
+
+// UnmarshalJSON decodes a single payload of an operation made before batches as a batch of one
+func (r *SigningPartialSignsParticipantInvitationsResponse) UnmarshalJSON(data []byte) error {
+	type response SigningPartialSignsParticipantInvitationsResponse
+	var legacy struct {
+		response
+		legacyPayload
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*r = SigningPartialSignsParticipantInvitationsResponse(legacy.response)
+	r.SrcPayloads, r.Eth2Requests = legacy.batch(r.SrcPayloads, r.Eth2Requests)
+	return nil
 }
 
 // Event:  ""
@@ -41,12 +82,63 @@ type SigningProposalParticipantStatusEntry struct {
 // States: "state_signing_partial_signatures_collected"
 type SigningProcessParticipantResponse struct {
 	SigningId    string
-	SrcPayload   []byte
+	SrcPayloads  [][]byte
 	Participants []*SigningProcessParticipantEntry
 }
 
+// UnmarshalJSON decodes a single payload of an operation made before batches as a batch of one
+func (r *SigningProcessParticipantResponse) UnmarshalJSON(data []byte) error {
+	type response SigningProcessParticipantResponse
+	var legacy struct {
+		response
+		legacyPayload
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*r = SigningProcessParticipantResponse(legacy.response)
+	r.SrcPayloads, _ = legacy.batch(r.SrcPayloads, nil)
+	return nil
+}
+
+// SigningProcessParticipantEntry keeps a partial sign of the participant for each of the source messages
 type SigningProcessParticipantEntry struct {
 	ParticipantId int
 	Username      string
-	PartialSign   []byte
+	PartialSigns  [][]byte
+}
+
+// UnmarshalJSON decodes a single partial sign of an operation made before batches as a batch of one
+func (e *SigningProcessParticipantEntry) UnmarshalJSON(data []byte) error {
+	type entry SigningProcessParticipantEntry
+	var legacy struct {
+		entry
+		PartialSign []byte
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*e = SigningProcessParticipantEntry(legacy.entry)
+	if len(e.PartialSigns) == 0 && len(legacy.PartialSign) > 0 {
+		e.PartialSigns = [][]byte{legacy.PartialSign}
+	}
+	return nil
+}
+
+// legacyPayload is a single source message of the signing responses made before batches
+type legacyPayload struct {
+	SrcPayload  []byte
+	Eth2Request *eth2.SigningRequest
+}
+
+func (p legacyPayload) batch(srcPayloads [][]byte, eth2Requests []*eth2.SigningRequest) ([][]byte, []*eth2.SigningRequest) {
+	if len(srcPayloads) > 0 || len(p.SrcPayload) == 0 {
+		return srcPayloads, eth2Requests
+	}
+	if p.Eth2Request != nil {
+		eth2Requests = []*eth2.SigningRequest{p.Eth2Request}
+	}
+	return [][]byte{p.SrcPayload}, eth2Requests
 }
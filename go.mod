@@ -20,6 +20,7 @@ require (
 	github.com/syndtr/goleveldb v1.0.1-0.20200815110645-5c35d600f0ca
 	gocv.io/x/gocv v0.24.0
 	golang.org/x/crypto v0.0.0-20200728195943-123391ffb6de
+	golang.org/x/text v0.3.3
 	gopkg.in/matryer/try.v1 v1.0.0-20150601225556-312d2599e12e
 	lukechampine.com/frand v1.3.0
 )
@@ -34,17 +34,17 @@ func (m *MockKeyStore) EXPECT() *MockKeyStoreMockRecorder {
 }
 
 // PutKeys mocks base method
-func (m *MockKeyStore) PutKeys(username string, keyPair *client.KeyPair) error {
+func (m *MockKeyStore) PutKeys(username, password string, keyPair *client.KeyPair) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PutKeys", username, keyPair)
+	ret := m.ctrl.Call(m, "PutKeys", username, password, keyPair)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // PutKeys indicates an expected call of PutKeys
-func (mr *MockKeyStoreMockRecorder) PutKeys(username, keyPair interface{}) *gomock.Call {
+func (mr *MockKeyStoreMockRecorder) PutKeys(username, password, keyPair interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutKeys", reflect.TypeOf((*MockKeyStore)(nil).PutKeys), username, keyPair)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutKeys", reflect.TypeOf((*MockKeyStore)(nil).PutKeys), username, password, keyPair)
 }
 
 // LoadKeys mocks base method
@@ -61,3 +61,17 @@ func (mr *MockKeyStoreMockRecorder) LoadKeys(userName, password interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadKeys", reflect.TypeOf((*MockKeyStore)(nil).LoadKeys), userName, password)
 }
+
+// ChangePassword mocks base method
+func (m *MockKeyStore) ChangePassword(userName, oldPassword, newPassword string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", userName, oldPassword, newPassword)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword
+func (mr *MockKeyStoreMockRecorder) ChangePassword(userName, oldPassword, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockKeyStore)(nil).ChangePassword), userName, oldPassword, newPassword)
+}
@@ -9,6 +9,7 @@ import (
 	types "github.com/lidofinance/dc4bc/client/types"
 	state_machines "github.com/lidofinance/dc4bc/fsm/state_machines"
 	reflect "reflect"
+	time "time"
 )
 
 // MockState is a mock of State interface
@@ -209,3 +210,90 @@ func (mr *MockStateMockRecorder) GetSignatures(dkgID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignatures", reflect.TypeOf((*MockState)(nil).GetSignatures), dkgID)
 }
+
+// GetSeenMessage mocks base method
+func (m *MockState) GetSeenMessage(dkgID, key string) (*types.SeenMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSeenMessage", dkgID, key)
+	ret0, _ := ret[0].(*types.SeenMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSeenMessage indicates an expected call of GetSeenMessage
+func (mr *MockStateMockRecorder) GetSeenMessage(dkgID, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSeenMessage", reflect.TypeOf((*MockState)(nil).GetSeenMessage), dkgID, key)
+}
+
+// SaveSeenMessage mocks base method
+func (m *MockState) SaveSeenMessage(dkgID, key string, message types.SeenMessage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveSeenMessage", dkgID, key, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveSeenMessage indicates an expected call of SaveSeenMessage
+func (mr *MockStateMockRecorder) SaveSeenMessage(dkgID, key, message interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveSeenMessage", reflect.TypeOf((*MockState)(nil).SaveSeenMessage), dkgID, key, message)
+}
+
+// SaveEvidence mocks base method
+func (m *MockState) SaveEvidence(evidence types.Evidence) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveEvidence", evidence)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveEvidence indicates an expected call of SaveEvidence
+func (mr *MockStateMockRecorder) SaveEvidence(evidence interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveEvidence", reflect.TypeOf((*MockState)(nil).SaveEvidence), evidence)
+}
+
+// GetEvidence mocks base method
+func (m *MockState) GetEvidence(dkgID string) ([]types.Evidence, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEvidence", dkgID)
+	ret0, _ := ret[0].([]types.Evidence)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEvidence indicates an expected call of GetEvidence
+func (mr *MockStateMockRecorder) GetEvidence(dkgID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEvidence", reflect.TypeOf((*MockState)(nil).GetEvidence), dkgID)
+}
+
+// SaveRoundClock mocks base method
+func (m *MockState) SaveRoundClock(dkgID string, clock time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRoundClock", dkgID, clock)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRoundClock indicates an expected call of SaveRoundClock
+func (mr *MockStateMockRecorder) SaveRoundClock(dkgID, clock interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRoundClock", reflect.TypeOf((*MockState)(nil).SaveRoundClock), dkgID, clock)
+}
+
+// LoadRoundClock mocks base method
+func (m *MockState) LoadRoundClock(dkgID string) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadRoundClock", dkgID)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadRoundClock indicates an expected call of LoadRoundClock
+func (mr *MockStateMockRecorder) LoadRoundClock(dkgID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadRoundClock", reflect.TypeOf((*MockState)(nil).LoadRoundClock), dkgID)
+}
@@ -5,6 +5,7 @@
 package storageMocks
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	storage "github.com/lidofinance/dc4bc/storage"
 	reflect "reflect"
@@ -82,6 +83,21 @@ func (mr *MockStorageMockRecorder) GetMessages(offset interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMessages", reflect.TypeOf((*MockStorage)(nil).GetMessages), offset)
 }
 
+// Subscribe mocks base method
+func (m *MockStorage) Subscribe(ctx context.Context, offset uint64) (<-chan storage.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", ctx, offset)
+	ret0, _ := ret[0].(<-chan storage.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe
+func (mr *MockStorageMockRecorder) Subscribe(ctx, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockStorage)(nil).Subscribe), ctx, offset)
+}
+
 // Close mocks base method
 func (m *MockStorage) Close() error {
 	m.ctrl.T.Helper()
@@ -0,0 +1,62 @@
+//go:build !nocamera
+// +build !nocamera
+
+package qr
+
+import (
+	"fmt"
+	"log"
+
+	"gocv.io/x/gocv"
+)
+
+func (p *CameraProcessor) ReadQR() ([]byte, error) {
+	webcam, err := gocv.OpenVideoCapture(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to OpenVideoCapture: %w", err)
+	}
+	window := gocv.NewWindow("Please, show a gif with QR codes")
+
+	defer func() {
+		if err := webcam.Close(); err != nil {
+			log.Fatalf("failed to close camera: %v", err)
+		}
+	}()
+	defer func() {
+		if err := window.Close(); err != nil {
+			log.Fatalf("failed to close camera window: %v", err)
+		}
+	}()
+
+	img := gocv.NewMat()
+	defer img.Close()
+
+	var collector chunksCollector
+	// detects and scans QR-cods from camera until we scan successfully
+	for !collector.done() {
+		select {
+		case <-p.closeCameraReader:
+			return nil, fmt.Errorf("camera reader was closed")
+		default:
+			webcam.Read(&img)
+			window.IMShow(img)
+			window.WaitKey(1)
+
+			imgObject, err := img.ToImage()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get image object: %w", err)
+			}
+			data, err := ReadDataFromQR(imgObject)
+			if err != nil {
+				continue
+			}
+			if err = collector.add(data); err != nil {
+				return nil, err
+			}
+			read, total := collector.progress()
+			window.SetWindowTitle(fmt.Sprintf("Read %d/%d chunks", read, total))
+		}
+	}
+	window.SetWindowTitle("QR-code chunks successfully read!")
+	return collector.data()
+}
@@ -0,0 +1,11 @@
+//go:build nocamera
+// +build nocamera
+
+package qr
+
+import "errors"
+
+// ReadQR isn't available in builds without OpenCV, use ImageProcessor to read QR codes from files instead
+func (p *CameraProcessor) ReadQR() ([]byte, error) {
+	return nil, errors.New("the binary is built without camera support, read QR codes from files instead")
+}
@@ -3,40 +3,15 @@ package qr
 import (
 	"encoding/json"
 	"fmt"
-	"math"
 )
 
+// chunk is a frame of QR codes made before fountain codes, such GIFs are still read
 type chunk struct {
 	Data  []byte
 	Index uint
 	Total uint
 }
 
-// DataToChunks divides a data on chunks with a size chunkSize
-func DataToChunks(data []byte, chunkSize int) ([][]byte, error) {
-	chunksCount := int(math.Ceil(float64(len(data)) / float64(chunkSize)))
-	chunks := make([][]byte, 0, chunksCount)
-
-	index := uint(0)
-	for offset := 0; offset < len(data); offset += chunkSize {
-		offsetEnd := offset + chunkSize
-		if offsetEnd > len(data) {
-			offsetEnd = len(data)
-		}
-		chunkBz, err := encodeChunk(chunk{
-			Data:  data[offset:offsetEnd],
-			Total: uint(chunksCount),
-			Index: index,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("failed to encode chunk: %w", err)
-		}
-		chunks = append(chunks, chunkBz)
-		index++
-	}
-	return chunks, nil
-}
-
 func decodeChunk(data []byte) (*chunk, error) {
 	var (
 		c   chunk
@@ -48,6 +23,93 @@ func decodeChunk(data []byte) (*chunk, error) {
 	return &c, nil
 }
 
-func encodeChunk(c chunk) ([]byte, error) {
-	return json.Marshal(c)
+// isLegacyChunk checks if a frame is a JSON chunk, fountain-coded frames are base64 encoded and never start with '{'
+func isLegacyChunk(data []byte) bool {
+	return len(data) > 0 && data[0] == '{'
+}
+
+// framesDecoder rebuilds a data from frames read from QR codes in any order
+type framesDecoder interface {
+	add(data []byte) error
+	done() bool
+	progress() (read uint, total uint)
+	data() ([]byte, error)
+}
+
+// chunksCollector gathers frames read from QR codes until the data can be rebuilt,
+// the format of the frames is detected by the first one
+type chunksCollector struct {
+	decoder framesDecoder
+}
+
+func (c *chunksCollector) add(data []byte) error {
+	if c.decoder == nil {
+		if isLegacyChunk(data) {
+			c.decoder = &legacyDecoder{}
+		} else {
+			c.decoder = &fountainDecoder{}
+		}
+	}
+	return c.decoder.add(data)
+}
+
+func (c *chunksCollector) done() bool {
+	return c.decoder != nil && c.decoder.done()
+}
+
+// progress returns a number of read chunks (recovered blocks for fountain-coded frames) and a total number of them
+func (c *chunksCollector) progress() (uint, uint) {
+	if c.decoder == nil {
+		return 0, 0
+	}
+	return c.decoder.progress()
+}
+
+func (c *chunksCollector) data() ([]byte, error) {
+	if !c.done() {
+		return nil, fmt.Errorf("not all QR-code chunks are read")
+	}
+	return c.decoder.data()
+}
+
+// legacyDecoder needs every chunk of the data
+type legacyDecoder struct {
+	chunks     []*chunk
+	readChunks uint
+}
+
+// add decodes a chunk from the QR code data, a repeated chunk is skipped
+func (d *legacyDecoder) add(data []byte) error {
+	decodedChunk, err := decodeChunk(data)
+	if err != nil {
+		return err
+	}
+	if d.chunks == nil {
+		d.chunks = make([]*chunk, decodedChunk.Total)
+	}
+	if decodedChunk.Total != uint(len(d.chunks)) || decodedChunk.Index >= decodedChunk.Total {
+		return fmt.Errorf("invalid QR-code chunk")
+	}
+	if d.chunks[decodedChunk.Index] != nil {
+		return nil
+	}
+	d.chunks[decodedChunk.Index] = decodedChunk
+	d.readChunks++
+	return nil
+}
+
+func (d *legacyDecoder) done() bool {
+	return d.chunks != nil && d.readChunks == uint(len(d.chunks))
+}
+
+func (d *legacyDecoder) progress() (uint, uint) {
+	return d.readChunks, uint(len(d.chunks))
+}
+
+func (d *legacyDecoder) data() ([]byte, error) {
+	data := make([]byte, 0)
+	for _, c := range d.chunks {
+		data = append(data, c.Data...)
+	}
+	return data, nil
 }
@@ -0,0 +1,114 @@
+package qr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	fileVersion = 1
+
+	// fileSignaturePrefix separates signatures of files from signatures of messages made with the same keys
+	fileSignaturePrefix = "dc4bc operation file:"
+)
+
+// File is data transferred between the hot node and the airgapped machine on removable media instead of QR codes.
+// It's checksummed against corruption of the media and signed by the side which wrote it
+type File struct {
+	Version   int
+	Checksum  []byte
+	PubKey    []byte
+	Signature []byte
+	Data      []byte
+}
+
+// Signer signs files written by one side of the air gap
+type Signer interface {
+	PubKey() ([]byte, error)
+	Sign(msg []byte) ([]byte, error)
+}
+
+// Verifier checks a signature of a file written by the other side of the air gap
+type Verifier func(pubKey, msg, signature []byte) error
+
+// FileProcessor exchanges data as signed files, it's an alternative to the camera for teams without reliable ones
+// and a faster way to move large payloads
+type FileProcessor struct {
+	signer   Signer
+	verifier Verifier
+}
+
+func NewFileProcessor(signer Signer, verifier Verifier) *FileProcessor {
+	return &FileProcessor{
+		signer:   signer,
+		verifier: verifier,
+	}
+}
+
+// EncodeFile returns a content of the signed file with the data
+func (p *FileProcessor) EncodeFile(data []byte) ([]byte, error) {
+	checksum := sha256.Sum256(data)
+	file := File{
+		Version:  fileVersion,
+		Checksum: checksum[:],
+		Data:     data,
+	}
+
+	var err error
+	if file.PubKey, err = p.signer.PubKey(); err != nil {
+		return nil, fmt.Errorf("failed to get signer pub key: %w", err)
+	}
+	if file.Signature, err = p.signer.Sign(fileSignedMessage(file.Version, file.Checksum)); err != nil {
+		return nil, fmt.Errorf("failed to sign file: %w", err)
+	}
+	return json.Marshal(file)
+}
+
+// DecodeFile checks the checksum and the signature of the file content and returns the data
+// with the pub key of the signer, the caller should check that the key is the expected one
+func (p *FileProcessor) DecodeFile(fileData []byte) (data []byte, pubKey []byte, err error) {
+	var file File
+	if err = json.Unmarshal(fileData, &file); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal file: %w", err)
+	}
+	if file.Version != fileVersion {
+		return nil, nil, fmt.Errorf("unsupported file version %d", file.Version)
+	}
+
+	checksum := sha256.Sum256(file.Data)
+	if !bytes.Equal(checksum[:], file.Checksum) {
+		return nil, nil, fmt.Errorf("file checksum mismatch, the file is corrupted")
+	}
+	if err = p.verifier(file.PubKey, fileSignedMessage(file.Version, file.Checksum), file.Signature); err != nil {
+		return nil, nil, fmt.Errorf("invalid file signature: %w", err)
+	}
+	return file.Data, file.PubKey, nil
+}
+
+// WriteFile saves the data to the signed file
+func (p *FileProcessor) WriteFile(path string, data []byte) error {
+	fileData, err := p.EncodeFile(data)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(path, fileData, 0600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// ReadFile reads the data from the signed file, see DecodeFile
+func (p *FileProcessor) ReadFile(path string) (data []byte, pubKey []byte, err error) {
+	fileData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return p.DecodeFile(fileData)
+}
+
+func fileSignedMessage(version int, checksum []byte) []byte {
+	return append([]byte(fmt.Sprintf("%s%d:", fileSignaturePrefix, version)), checksum...)
+}
@@ -0,0 +1,79 @@
+package qr
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testSigner struct {
+	pub  ed25519.PublicKey
+	priv ed25519.PrivateKey
+}
+
+func (s *testSigner) PubKey() ([]byte, error) {
+	return s.pub, nil
+}
+
+func (s *testSigner) Sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(s.priv, msg), nil
+}
+
+func testVerifier(pubKey, msg, signature []byte) error {
+	if len(pubKey) != ed25519.PublicKeySize || !ed25519.Verify(pubKey, msg, signature) {
+		return errors.New("signature is not valid")
+	}
+	return nil
+}
+
+func TestFileProcessor(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	p := NewFileProcessor(&testSigner{pub: pub, priv: priv}, testVerifier)
+
+	dir, err := ioutil.TempDir("", "dc4bc_file_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data := []byte("operation to transfer")
+	path := filepath.Join(dir, "operation.json")
+	require.NoError(t, p.WriteFile(path, data))
+
+	readData, signerPubKey, err := p.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, data, readData)
+	require.Equal(t, []byte(pub), signerPubKey)
+
+	fileData, err := p.EncodeFile(data)
+	require.NoError(t, err)
+	tamper := func(modify func(file *File)) []byte {
+		var file File
+		require.NoError(t, json.Unmarshal(fileData, &file))
+		modify(&file)
+		tamperedFileData, err := json.Marshal(file)
+		require.NoError(t, err)
+		return tamperedFileData
+	}
+
+	// corrupted data
+	_, _, err = p.DecodeFile(tamper(func(file *File) { file.Data = []byte("another operation") }))
+	require.Error(t, err)
+
+	// data with a matching checksum, but not signed
+	_, _, err = p.DecodeFile(tamper(func(file *File) {
+		file.Data = []byte("another operation")
+		checksum := sha256.Sum256(file.Data)
+		file.Checksum = checksum[:]
+	}))
+	require.Error(t, err)
+
+	_, _, err = p.DecodeFile(tamper(func(file *File) { file.Version = fileVersion + 1 }))
+	require.Error(t, err)
+}
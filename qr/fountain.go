@@ -0,0 +1,336 @@
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+)
+
+// Data is transferred by QR codes with a fountain (LT) code: a frame carries a XOR of some source blocks, so the data
+// can be rebuilt from any large enough subset of frames and a frame which the camera can't read doesn't stall
+// the transfer. The first frames carry the source blocks as is, repair frames are made from random blocks
+
+const (
+	frameVersion = 1
+
+	// frameHeaderSize is a size of the binary header of a frame:
+	// version (1 byte), data length (4), block size (2), data checksum (4), seed (4), degree (2)
+	frameHeaderSize = 17
+
+	// repairFramesRatio is a ratio of repair frames to source blocks in a written GIF. A reader which gets every
+	// source frame is done after them, so repair frames only take time when some frames can't be read
+	repairFramesRatio = 1
+
+	// maxBlocksCount caps a number of source blocks of a data. The header of a single frame defines the size
+	// of the decoder state, so a forged frame must not make the reader allocate gigabytes
+	maxBlocksCount = 1 << 16
+
+	// parameters of the robust soliton distribution of frame degrees
+	solitonC     = 0.1
+	solitonDelta = 0.5
+)
+
+// frameHeader describes the data a frame belongs to and the source blocks XORed in the frame.
+// Blocks of the frame are derived from the seed, so both sides get them without sending the indices
+type frameHeader struct {
+	DataLength uint32
+	BlockSize  uint16
+	Checksum   uint32
+	Seed       uint32
+	Degree     uint16
+}
+
+func (h *frameHeader) blocksCount() int {
+	return int((h.DataLength + uint32(h.BlockSize) - 1) / uint32(h.BlockSize))
+}
+
+// sameData checks that frames belong to the same data
+func (h *frameHeader) sameData(other *frameHeader) bool {
+	return h.DataLength == other.DataLength && h.BlockSize == other.BlockSize && h.Checksum == other.Checksum
+}
+
+// DataToChunks encodes a data into fountain-coded QR frames, each frame carries a block of chunkSize bytes
+func DataToChunks(data []byte, chunkSize int) ([][]byte, error) {
+	if chunkSize <= 0 || chunkSize > math.MaxUint16 {
+		return nil, fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+	if len(data) == 0 || uint64(len(data)) > math.MaxUint32 {
+		return nil, fmt.Errorf("invalid data length %d", len(data))
+	}
+	if (len(data)+chunkSize-1)/chunkSize > maxBlocksCount {
+		return nil, fmt.Errorf("data of %d bytes doesn't fit into %d blocks of %d bytes, increase the chunk size",
+			len(data), maxBlocksCount, chunkSize)
+	}
+
+	header := frameHeader{
+		DataLength: uint32(len(data)),
+		BlockSize:  uint16(chunkSize),
+		Checksum:   crc32.ChecksumIEEE(data),
+	}
+	blocksCount := header.blocksCount()
+	blocks := make([][]byte, blocksCount)
+	for i := range blocks {
+		// the last block is padded with zeroes
+		blocks[i] = make([]byte, chunkSize)
+		copy(blocks[i], data[i*chunkSize:])
+	}
+
+	cdf := solitonCDF(blocksCount)
+	framesCount := blocksCount + int(math.Ceil(float64(blocksCount)*repairFramesRatio))
+	frames := make([][]byte, 0, framesCount)
+	for seed := 0; seed < framesCount; seed++ {
+		header.Seed = uint32(seed)
+		header.Degree = 1
+		if seed >= blocksCount {
+			rng := splitMix64(seed)
+			header.Degree = uint16(sampleDegree(&rng, cdf))
+		}
+
+		payload := make([]byte, chunkSize)
+		for _, idx := range frameBlocks(&header, blocksCount) {
+			xorBytes(payload, blocks[idx])
+		}
+		frames = append(frames, encodeFrame(&header, payload))
+	}
+	return frames, nil
+}
+
+func encodeFrame(header *frameHeader, payload []byte) []byte {
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	frame[0] = frameVersion
+	binary.BigEndian.PutUint32(frame[1:5], header.DataLength)
+	binary.BigEndian.PutUint16(frame[5:7], header.BlockSize)
+	binary.BigEndian.PutUint32(frame[7:11], header.Checksum)
+	binary.BigEndian.PutUint32(frame[11:15], header.Seed)
+	binary.BigEndian.PutUint16(frame[15:17], header.Degree)
+	frame = append(frame, payload...)
+
+	// QR readers don't return arbitrary binary data reliably, so the frame is sent as text
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(frame)))
+	base64.StdEncoding.Encode(encoded, frame)
+	return encoded
+}
+
+func decodeFrame(data []byte) (*frameHeader, []byte, error) {
+	frame := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(frame, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode QR-code frame: %w", err)
+	}
+	frame = frame[:n]
+	if len(frame) < frameHeaderSize {
+		return nil, nil, fmt.Errorf("QR-code frame is too short")
+	}
+	if frame[0] != frameVersion {
+		return nil, nil, fmt.Errorf("unsupported QR-code frame version %d", frame[0])
+	}
+
+	header := frameHeader{
+		DataLength: binary.BigEndian.Uint32(frame[1:5]),
+		BlockSize:  binary.BigEndian.Uint16(frame[5:7]),
+		Checksum:   binary.BigEndian.Uint32(frame[7:11]),
+		Seed:       binary.BigEndian.Uint32(frame[11:15]),
+		Degree:     binary.BigEndian.Uint16(frame[15:17]),
+	}
+	payload := frame[frameHeaderSize:]
+	if header.DataLength == 0 || header.BlockSize == 0 || len(payload) != int(header.BlockSize) {
+		return nil, nil, fmt.Errorf("invalid QR-code frame header")
+	}
+	if header.blocksCount() > maxBlocksCount {
+		return nil, nil, fmt.Errorf("QR-code frame data has too many blocks: %d", header.blocksCount())
+	}
+	if header.Degree == 0 || int(header.Degree) > header.blocksCount() ||
+		(int(header.Seed) < header.blocksCount() && header.Degree != 1) {
+		return nil, nil, fmt.Errorf("invalid QR-code frame degree %d", header.Degree)
+	}
+	return &header, payload, nil
+}
+
+// frameBlocks returns indices of the source blocks XORed in the frame
+func frameBlocks(header *frameHeader, blocksCount int) []int {
+	if int(header.Seed) < blocksCount {
+		return []int{int(header.Seed)}
+	}
+
+	// a partial Fisher-Yates shuffle, the generator is seeded by the frame seed
+	rng := splitMix64(uint64(header.Seed)<<32 | uint64(header.Degree))
+	indices := make([]int, blocksCount)
+	for i := range indices {
+		indices[i] = i
+	}
+	for i := 0; i < int(header.Degree); i++ {
+		j := i + int(rng.next()%uint64(blocksCount-i))
+		indices[i], indices[j] = indices[j], indices[i]
+	}
+	return indices[:header.Degree]
+}
+
+// solitonCDF returns a cumulative robust soliton distribution of frame degrees from 1 to blocksCount
+func solitonCDF(blocksCount int) []float64 {
+	k := float64(blocksCount)
+	r := solitonC * math.Log(k/solitonDelta) * math.Sqrt(k)
+	spike := int(math.Floor(k / r))
+
+	weights := make([]float64, blocksCount+1)
+	weights[1] = 1 / k
+	for d := 2; d <= blocksCount; d++ {
+		weights[d] = 1 / float64(d*(d-1))
+	}
+	if r > 0 && spike >= 1 {
+		for d := 1; d < spike && d <= blocksCount; d++ {
+			weights[d] += r / (float64(d) * k)
+		}
+		if spike <= blocksCount {
+			weights[spike] += r * math.Log(r/solitonDelta) / k
+		}
+	}
+
+	cdf := make([]float64, blocksCount+1)
+	for d := 1; d <= blocksCount; d++ {
+		if weights[d] < 0 {
+			weights[d] = 0
+		}
+		cdf[d] = cdf[d-1] + weights[d]
+	}
+	for d := range cdf {
+		cdf[d] /= cdf[blocksCount]
+	}
+	return cdf
+}
+
+func sampleDegree(rng *splitMix64, cdf []float64) int {
+	x := rng.float64()
+	for d := 1; d < len(cdf); d++ {
+		if x < cdf[d] {
+			return d
+		}
+	}
+	return len(cdf) - 1
+}
+
+// splitMix64 is a tiny generator with a fixed output for a seed, both sides of the transfer must get the same blocks
+type splitMix64 uint64
+
+func (s *splitMix64) next() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	z := uint64(*s)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix64) float64() float64 {
+	return float64(s.next()>>11) / (1 << 53)
+}
+
+func xorBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// encodedFrame is a received frame which still has unknown source blocks
+type encodedFrame struct {
+	blocks  []int
+	payload []byte
+}
+
+// fountainDecoder rebuilds a data from fountain-coded frames with a peeling decoder: a frame with one unknown block
+// reveals it, which may leave one unknown block in other frames, and so on
+type fountainDecoder struct {
+	header    *frameHeader
+	blocks    [][]byte
+	recovered int
+	pending   []*encodedFrame
+	seen      map[uint32]bool
+}
+
+func (d *fountainDecoder) add(data []byte) error {
+	header, payload, err := decodeFrame(data)
+	if err != nil {
+		return err
+	}
+	if d.header == nil {
+		d.header = header
+		d.blocks = make([][]byte, header.blocksCount())
+		d.seen = make(map[uint32]bool)
+	}
+	if !d.header.sameData(header) {
+		return fmt.Errorf("QR-code frame belongs to another data")
+	}
+	if d.seen[header.Seed] {
+		return nil
+	}
+	d.seen[header.Seed] = true
+
+	frame := &encodedFrame{
+		blocks:  frameBlocks(header, len(d.blocks)),
+		payload: append([]byte{}, payload...),
+	}
+	for queue := []*encodedFrame{frame}; len(queue) > 0; queue = queue[1:] {
+		frame := queue[0]
+		d.reduce(frame)
+		switch len(frame.blocks) {
+		case 0:
+		case 1:
+			idx := frame.blocks[0]
+			d.blocks[idx] = frame.payload
+			d.recovered++
+
+			// pending frames with the recovered block may have one unknown block left now
+			pending := d.pending[:0]
+			for _, p := range d.pending {
+				if containsBlock(p.blocks, idx) {
+					queue = append(queue, p)
+				} else {
+					pending = append(pending, p)
+				}
+			}
+			d.pending = pending
+		default:
+			d.pending = append(d.pending, frame)
+		}
+	}
+	return nil
+}
+
+// reduce XORs the known blocks out of the frame
+func (d *fountainDecoder) reduce(frame *encodedFrame) {
+	unknown := frame.blocks[:0]
+	for _, idx := range frame.blocks {
+		if d.blocks[idx] != nil {
+			xorBytes(frame.payload, d.blocks[idx])
+		} else {
+			unknown = append(unknown, idx)
+		}
+	}
+	frame.blocks = unknown
+}
+
+func containsBlock(blocks []int, idx int) bool {
+	for _, block := range blocks {
+		if block == idx {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *fountainDecoder) done() bool {
+	return d.header != nil && d.recovered == len(d.blocks)
+}
+
+func (d *fountainDecoder) progress() (uint, uint) {
+	return uint(d.recovered), uint(len(d.blocks))
+}
+
+func (d *fountainDecoder) data() ([]byte, error) {
+	data := bytes.Join(d.blocks, nil)[:d.header.DataLength]
+	if crc32.ChecksumIEEE(data) != d.header.Checksum {
+		return nil, fmt.Errorf("QR-code data checksum mismatch")
+	}
+	return data, nil
+}
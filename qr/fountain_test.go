@@ -0,0 +1,115 @@
+package qr
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decodeFrames(frames [][]byte) ([]byte, error) {
+	var collector chunksCollector
+	for _, frame := range frames {
+		if err := collector.add(frame); err != nil {
+			return nil, err
+		}
+		if collector.done() {
+			break
+		}
+	}
+	return collector.data()
+}
+
+func TestFountainFrames(t *testing.T) {
+	data := bytes.Repeat([]byte("fountain-coded operation;"), 200)
+
+	frames, err := DataToChunks(data, 100)
+	require.NoError(t, err)
+	blocksCount := (len(data) + 99) / 100
+	require.True(t, len(frames) > blocksCount)
+
+	decoded, err := decodeFrames(frames)
+	require.NoError(t, err)
+	require.Equal(t, data, decoded)
+
+	// frames in reverse order
+	reversed := make([][]byte, 0, len(frames))
+	for i := len(frames) - 1; i >= 0; i-- {
+		reversed = append(reversed, frames[i])
+	}
+	decoded, err = decodeFrames(reversed)
+	require.NoError(t, err)
+	require.Equal(t, data, decoded)
+
+	// some source frames are never read, repair frames replace them
+	var lossy [][]byte
+	for i, frame := range frames {
+		if i%10 != 3 {
+			lossy = append(lossy, frame)
+		}
+	}
+	decoded, err = decodeFrames(lossy)
+	require.NoError(t, err)
+	require.Equal(t, data, decoded)
+
+	// not enough frames
+	_, err = decodeFrames(frames[:blocksCount-1])
+	require.Error(t, err)
+
+	// frames of another data
+	otherFrames, err := DataToChunks([]byte("another operation"), 100)
+	require.NoError(t, err)
+	_, err = decodeFrames([][]byte{frames[0], otherFrames[0]})
+	require.Error(t, err)
+
+	// a frame of an unknown version
+	header, payload, err := decodeFrame(frames[0])
+	require.NoError(t, err)
+	frame := encodeFrame(header, payload)
+	require.Equal(t, frames[0], frame)
+	unknownVersion := append([]byte{}, frames[0]...)
+	unknownVersion[0] = 'B' // the first base64 character encodes the version byte
+	_, _, err = decodeFrame(unknownVersion)
+	require.Error(t, err)
+
+	// the checksum covers the whole data
+	corrupted := append([][]byte{}, frames[:blocksCount]...)
+	header, payload, err = decodeFrame(corrupted[0])
+	require.NoError(t, err)
+	payload[0] ^= 0xff
+	corrupted[0] = encodeFrame(header, payload)
+	_, err = decodeFrames(corrupted)
+	require.Error(t, err)
+
+	// a forged header of a huge data is refused before the decoder allocates blocks for it
+	forged := *header
+	forged.DataLength, forged.BlockSize = math.MaxUint32, 1
+	_, err = decodeFrames([][]byte{encodeFrame(&forged, []byte{0})})
+	require.Error(t, err)
+	_, err = DataToChunks(make([]byte, maxBlocksCount+1), 1)
+	require.Error(t, err)
+}
+
+func TestLegacyChunks(t *testing.T) {
+	data := bytes.Repeat([]byte("operation from an old GIF;"), 20)
+
+	var frames [][]byte
+	for i, offset := 0, 0; offset < len(data); i, offset = i+1, offset+128 {
+		end := offset + 128
+		if end > len(data) {
+			end = len(data)
+		}
+		frame, err := json.Marshal(chunk{Data: data[offset:end], Index: uint(i), Total: uint((len(data) + 127) / 128)})
+		require.NoError(t, err)
+		frames = append(frames, frame)
+	}
+
+	decoded, err := decodeFrames(frames)
+	require.NoError(t, err)
+	require.Equal(t, data, decoded)
+
+	_, err = decodeFrames(frames[1:])
+	require.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package qr
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImageProcessor reads QR codes from image files instead of a camera, so it works on machines without a camera
+// or a display. A GIF file is read frame by frame, so the animated GIF made by WriteQR can be passed as is
+type ImageProcessor struct {
+	gifFramesDelay int
+	chunkSize      int
+
+	paths []string
+}
+
+func NewImageProcessor() *ImageProcessor {
+	return &ImageProcessor{}
+}
+
+// SetPaths sets the image files to read QR codes from, chunks may be spread over the files in any order
+func (p *ImageProcessor) SetPaths(paths ...string) {
+	p.paths = paths
+}
+
+func (p *ImageProcessor) SetDelay(delay int) {
+	p.gifFramesDelay = delay
+}
+
+func (p *ImageProcessor) SetChunkSize(chunkSize int) {
+	p.chunkSize = chunkSize
+}
+
+// CloseCameraReader does nothing, the processor doesn't use a camera
+func (p *ImageProcessor) CloseCameraReader() {}
+
+func (p *ImageProcessor) ReadQR() ([]byte, error) {
+	if len(p.paths) == 0 {
+		return nil, fmt.Errorf("no image files to read QR codes from")
+	}
+
+	var collector chunksCollector
+	for _, path := range p.paths {
+		frames, err := readImageFrames(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, frame := range frames {
+			data, err := ReadDataFromQR(frame)
+			if err != nil {
+				continue
+			}
+			if err = collector.add(data); err != nil {
+				return nil, fmt.Errorf("failed to read QR-code chunk from %s: %w", path, err)
+			}
+			if collector.done() {
+				return collector.data()
+			}
+		}
+	}
+	read, total := collector.progress()
+	if total == 0 {
+		return nil, fmt.Errorf("no QR codes found in the image files")
+	}
+	return nil, fmt.Errorf("only %d/%d chunks were read from the image files", read, total)
+}
+
+func (p *ImageProcessor) WriteQR(path string, data []byte) error {
+	return writeQRGif(path, data, p.chunkSize, p.gifFramesDelay)
+}
+
+func readImageFrames(path string) ([]image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(path)) == ".gif" {
+		decodedGIF, err := gif.DecodeAll(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gif %s: %w", path, err)
+		}
+		frames := make([]image.Image, 0, len(decodedGIF.Image))
+		for _, frame := range decodedGIF.Image {
+			frames = append(frames, frame)
+		}
+		return frames, nil
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+	}
+	return []image.Image{img}, nil
+}
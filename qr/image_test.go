@@ -0,0 +1,52 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageProcessor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dc4bc_qr_image_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data := bytes.Repeat([]byte("operation to transfer through QR codes;"), 30)
+
+	p := NewImageProcessor()
+	p.SetChunkSize(128)
+
+	// an animated GIF made by WriteQR
+	gifPath := filepath.Join(dir, "operation.gif")
+	require.NoError(t, p.WriteQR(gifPath, data))
+	p.SetPaths(gifPath)
+	readData, err := p.ReadQR()
+	require.NoError(t, err)
+	require.Equal(t, data, readData)
+
+	// frames saved as separate PNG files in any order
+	chunks, err := DataToChunks(data, 128)
+	require.NoError(t, err)
+	var pngPaths []string
+	for i := len(chunks) - 1; i >= 0; i-- {
+		png, err := EncodeQR(chunks[i])
+		require.NoError(t, err)
+		pngPath := filepath.Join(dir, fmt.Sprintf("chunk-%d.png", i))
+		require.NoError(t, ioutil.WriteFile(pngPath, png, 0600))
+		pngPaths = append(pngPaths, pngPath)
+	}
+	p.SetPaths(pngPaths...)
+	readData, err = p.ReadQR()
+	require.NoError(t, err)
+	require.Equal(t, data, readData)
+
+	// too few frames to rebuild the data
+	p.SetPaths(pngPaths[:2]...)
+	_, err = p.ReadQR()
+	require.Error(t, err)
+}
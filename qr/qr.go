@@ -6,11 +6,8 @@ import (
 	"image/color"
 	"image/draw"
 	"image/gif"
-	"log"
 	"os"
 
-	"gocv.io/x/gocv"
-
 	encoder "github.com/skip2/go-qrcode"
 
 	"github.com/makiuchi-d/gozxing"
@@ -58,79 +55,13 @@ func (p *CameraProcessor) SetChunkSize(chunkSize int) {
 	p.chunkSize = chunkSize
 }
 
-func (p *CameraProcessor) ReadQR() ([]byte, error) {
-	webcam, err := gocv.OpenVideoCapture(0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to OpenVideoCapture: %w", err)
-	}
-	window := gocv.NewWindow("Please, show a gif with QR codes")
-
-	defer func() {
-		if err := webcam.Close(); err != nil {
-			log.Fatalf("failed to close camera: %v", err)
-		}
-	}()
-	defer func() {
-		if err := window.Close(); err != nil {
-			log.Fatalf("failed to close camera window: %v", err)
-		}
-	}()
-
-	img := gocv.NewMat()
-	defer img.Close()
-
-	chunks := make([]*chunk, 0)
-	decodedChunksCount := uint(0)
-	// detects and scans QR-cods from camera until we scan successfully
-READER:
-	for {
-		select {
-		case <-p.closeCameraReader:
-			return nil, fmt.Errorf("camera reader was closed")
-		default:
-			webcam.Read(&img)
-			window.IMShow(img)
-			window.WaitKey(1)
-
-			imgObject, err := img.ToImage()
-			if err != nil {
-				return nil, fmt.Errorf("failed to get image object: %w", err)
-			}
-			data, err := ReadDataFromQR(imgObject)
-			if err != nil {
-				continue
-			}
-			decodedChunk, err := decodeChunk(data)
-			if err != nil {
-				return nil, err
-			}
-			if cap(chunks) == 0 {
-				chunks = make([]*chunk, decodedChunk.Total)
-			}
-			if decodedChunk.Index > decodedChunk.Total {
-				return nil, fmt.Errorf("invalid QR-code chunk")
-			}
-			if chunks[decodedChunk.Index] != nil {
-				continue
-			}
-			chunks[decodedChunk.Index] = decodedChunk
-			decodedChunksCount++
-			window.SetWindowTitle(fmt.Sprintf("Read %d/%d chunks", decodedChunksCount, decodedChunk.Total))
-			if decodedChunksCount == decodedChunk.Total {
-				break READER
-			}
-		}
-	}
-	window.SetWindowTitle("QR-code chunks successfully read!")
-	data := make([]byte, 0)
-	for _, c := range chunks {
-		data = append(data, c.Data...)
-	}
-	return data, nil
+func (p *CameraProcessor) WriteQR(path string, data []byte) error {
+	return writeQRGif(path, data, p.chunkSize, p.gifFramesDelay)
 }
 
-func (p *CameraProcessor) WriteQR(path string, data []byte) error {
-	chunks, err := DataToChunks(data, p.chunkSize)
+// writeQRGif divides the data on chunks and saves them as frames of an animated GIF
+func writeQRGif(path string, data []byte, chunkSize, framesDelay int) error {
+	chunks, err := DataToChunks(data, chunkSize)
 	if err != nil {
 		return fmt.Errorf("failed to divide data on chunks: %w", err)
 	}
@@ -146,7 +77,7 @@ func (p *CameraProcessor) WriteQR(path string, data []byte) error {
 		draw.Draw(palettedImage, palettedImage.Rect, frame, bounds.Min, draw.Src)
 
 		outGif.Image = append(outGif.Image, palettedImage)
-		outGif.Delay = append(outGif.Delay, p.gifFramesDelay)
+		outGif.Delay = append(outGif.Delay, framesDelay)
 	}
 	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
@@ -33,32 +33,22 @@ func (p *TestQrProcessor) ReadQR() ([]byte, error) {
 		return nil, err
 	}
 
-	chunks := make([]*chunk, 0)
-	decodedChunksCount := uint(0)
+	var collector chunksCollector
 	for _, frame := range decodedGIF.Image {
 		data, err := ReadDataFromQR(frame)
 		if err != nil {
 			continue
 		}
-		decodedChunk, err := decodeChunk(data)
-		if err != nil {
+		if err = collector.add(data); err != nil {
 			return nil, err
 		}
-		if cap(chunks) == 0 {
-			chunks = make([]*chunk, decodedChunk.Total)
-		}
-		if chunks[decodedChunk.Index] != nil {
-			continue
-		}
-		chunks[decodedChunk.Index] = decodedChunk
-		decodedChunksCount++
-		if decodedChunksCount == decodedChunk.Total {
+		if collector.done() {
 			break
 		}
 	}
-	data := make([]byte, 0)
-	for _, c := range chunks {
-		data = append(data, c.Data...)
+	data, err := collector.data()
+	if err != nil {
+		return nil, err
 	}
 	if err = os.Remove(p.qr); err != nil {
 		return nil, err
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// boardLongPollTimeout is how long a request for new messages waits for them
+	boardLongPollTimeout = 20 * time.Second
+	// boardMaxRequestSize bounds the body of a send request, a batch of DKG deals is far smaller
+	boardMaxRequestSize = 16 << 20
+)
+
+// BoardServer is an append-only bulletin board served over HTTP. It persists messages
+// with an underlying storage (usually FileStorage), which assigns message IDs and offsets
+type BoardServer struct {
+	sync.Mutex
+
+	storage Storage
+	// newMessages is closed and replaced when messages are sent to wake up waiting requests
+	newMessages chan struct{}
+	// appendToken is a bearer token required to send messages, anyone can read the log anyway
+	appendToken string
+}
+
+type boardResponse struct {
+	ErrorMessage string          `json:"error_message,omitempty"`
+	Result       json.RawMessage `json:"result"`
+}
+
+func NewBoardServer(stg Storage) *BoardServer {
+	return &BoardServer{
+		storage:     stg,
+		newMessages: make(chan struct{}),
+	}
+}
+
+// SetAppendToken makes the board accept messages only from senders with the token
+func (s *BoardServer) SetAppendToken(token string) {
+	s.appendToken = token
+}
+
+// Handler returns the HTTP API of the board:
+// POST /send, POST /sendBatch and GET /getMessages?offset=<offset>[&wait=true].
+// With wait=true the request waits for new messages if there are none at the offset yet
+func (s *BoardServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/send", s.sendHandler)
+	mux.HandleFunc("/sendBatch", s.sendBatchHandler)
+	mux.HandleFunc("/getMessages", s.getMessagesHandler)
+
+	return mux
+}
+
+func (s *BoardServer) ListenAndServe(listenAddr string) error {
+	return http.ListenAndServe(listenAddr, s.Handler())
+}
+
+// ListenAndServeTLS serves the board over HTTPS, so the append token and the log can't be tampered with on the way
+func (s *BoardServer) ListenAndServeTLS(listenAddr, certFile, keyFile string) error {
+	server := &http.Server{
+		Addr:      listenAddr,
+		Handler:   s.Handler(),
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	return server.ListenAndServeTLS(certFile, keyFile)
+}
+
+func boardErrorResponse(w http.ResponseWriter, statusCode int, error string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	respBz, err := json.Marshal(boardResponse{ErrorMessage: error})
+	if err != nil {
+		log.Printf("Failed to marshal response: %v\n", err)
+		return
+	}
+	if _, err := w.Write(respBz); err != nil {
+		log.Printf("Failed to write response: %v\n", err)
+	}
+}
+
+func boardSuccessResponse(w http.ResponseWriter, result interface{}) {
+	resultBz, err := json.Marshal(result)
+	if err != nil {
+		boardErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to marshal result: %v", err))
+		return
+	}
+	respBz, err := json.Marshal(boardResponse{Result: resultBz})
+	if err != nil {
+		boardErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to marshal response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(respBz); err != nil {
+		log.Printf("Failed to write response: %v\n", err)
+	}
+}
+
+// readSendRequest checks the append token and reads the body of a send request
+func (s *BoardServer) readSendRequest(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		boardErrorResponse(w, http.StatusBadRequest, "Wrong HTTP method")
+		return nil, false
+	}
+	if s.appendToken != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.appendToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			boardErrorResponse(w, http.StatusUnauthorized, "append token is required")
+			return nil, false
+		}
+	}
+	defer r.Body.Close()
+	reqBytes, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, boardMaxRequestSize))
+	if err != nil {
+		boardErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to read request body: %v", err))
+		return nil, false
+	}
+	return reqBytes, true
+}
+
+func (s *BoardServer) sendHandler(w http.ResponseWriter, r *http.Request) {
+	reqBytes, ok := s.readSendRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		msg Message
+		err error
+	)
+	if err = json.Unmarshal(reqBytes, &msg); err != nil {
+		boardErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to unmarshal message: %v", err))
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if msg, err = s.storage.Send(msg); err != nil {
+		boardErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to send message: %v", err))
+		return
+	}
+	s.notify()
+	boardSuccessResponse(w, msg)
+}
+
+func (s *BoardServer) sendBatchHandler(w http.ResponseWriter, r *http.Request) {
+	reqBytes, ok := s.readSendRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		msgs []Message
+		err  error
+	)
+	if err = json.Unmarshal(reqBytes, &msgs); err != nil {
+		boardErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to unmarshal messages: %v", err))
+		return
+	}
+
+	// the whole batch is written under the lock, so messages of other senders never get in between
+	s.Lock()
+	defer s.Unlock()
+
+	if msgs, err = s.storage.SendBatch(msgs...); err != nil {
+		boardErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to send messages: %v", err))
+		return
+	}
+	s.notify()
+	boardSuccessResponse(w, msgs)
+}
+
+func (s *BoardServer) getMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		boardErrorResponse(w, http.StatusBadRequest, "Wrong HTTP method")
+		return
+	}
+	offset, err := strconv.ParseUint(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		boardErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("failed to parse offset: %v", err))
+		return
+	}
+
+	wait := r.URL.Query().Get("wait") == "true"
+
+	msgs, newMessages, err := s.getMessages(offset)
+	if err == nil && len(msgs) == 0 && wait {
+		select {
+		case <-newMessages:
+			msgs, _, err = s.getMessages(offset)
+		case <-time.After(boardLongPollTimeout):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if err != nil {
+		boardErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to get messages: %v", err))
+		return
+	}
+	if msgs == nil {
+		msgs = []Message{}
+	}
+	boardSuccessResponse(w, msgs)
+}
+
+// getMessages returns messages and a channel, which is closed when messages after them are sent
+func (s *BoardServer) getMessages(offset uint64) ([]Message, <-chan struct{}, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	msgs, err := s.storage.GetMessages(offset)
+	return msgs, s.newMessages, err
+}
+
+// notify wakes up requests waiting for new messages, it must be called under the lock
+func (s *BoardServer) notify() {
+	close(s.newMessages)
+	s.newMessages = make(chan struct{})
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	boardRequestTimeout = boardLongPollTimeout + 10*time.Second
+)
+
+var _ Storage = (*BoardStorage)(nil)
+
+// BoardStorage is a client of a bulletin board server (see BoardServer and cmd/dc4bc_board)
+type BoardStorage struct {
+	boardAddr   string
+	appendToken string
+	client      *http.Client
+}
+
+// NewBoardStorage inits a bulletin board client, boardAddr is an address of the board, e.g. https://localhost:9090.
+// tlsConfig verifies a board served over HTTPS, nil means the system roots.
+// appendToken is sent with new messages if the board requires it
+func NewBoardStorage(boardAddr string, tlsConfig *tls.Config, appendToken string) (Storage, error) {
+	if !strings.HasPrefix(boardAddr, "http://") && !strings.HasPrefix(boardAddr, "https://") {
+		boardAddr = "http://" + boardAddr
+	}
+
+	client := &http.Client{Timeout: boardRequestTimeout}
+	if tlsConfig != nil {
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+	return &BoardStorage{
+		boardAddr:   strings.TrimRight(boardAddr, "/"),
+		appendToken: appendToken,
+		client:      client,
+	}, nil
+}
+
+func (s *BoardStorage) Send(m Message) (Message, error) {
+	var msg Message
+	if err := s.post("/send", m, &msg); err != nil {
+		return m, fmt.Errorf("failed to send message: %w", err)
+	}
+	return msg, nil
+}
+
+// SendBatch sends messages in one request, the board writes them one after another
+func (s *BoardStorage) SendBatch(msgs ...Message) ([]Message, error) {
+	var sentMsgs []Message
+	if err := s.post("/sendBatch", msgs, &sentMsgs); err != nil {
+		return msgs, fmt.Errorf("failed to send messages: %w", err)
+	}
+	return sentMsgs, nil
+}
+
+func (s *BoardStorage) GetMessages(offset uint64) ([]Message, error) {
+	resp, err := s.client.Get(fmt.Sprintf("%s/getMessages?offset=%d", s.boardAddr, offset))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	var msgs []Message
+	if err = decodeBoardResponse(resp, &msgs); err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	return msgs, nil
+}
+
+// Subscribe long-polls the board, which answers as soon as new messages are sent
+func (s *BoardStorage) Subscribe(ctx context.Context, offset uint64) (<-chan Message, error) {
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+
+		var failures int
+		for {
+			msgs, err := s.waitMessages(ctx, offset)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				failures++
+				log.Printf("failed to get messages from the board (%v), %d retries left", err, maxRetries-failures)
+				if failures >= maxRetries {
+					return
+				}
+				select {
+				case <-time.After(reconnectInterval):
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			failures = 0
+
+			for _, msg := range msgs {
+				select {
+				case messages <- msg:
+					offset = msg.Offset + 1
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return messages, nil
+}
+
+func (s *BoardStorage) waitMessages(ctx context.Context, offset uint64) ([]Message, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/getMessages?offset=%d&wait=true", s.boardAddr, offset), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+
+	var msgs []Message
+	if err = decodeBoardResponse(resp, &msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (s *BoardStorage) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func (s *BoardStorage) post(path string, data interface{}, result interface{}) error {
+	dataBz, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.boardAddr+path, bytes.NewReader(dataBz))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.appendToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.appendToken)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	return decodeBoardResponse(resp, result)
+}
+
+func decodeBoardResponse(resp *http.Response, result interface{}) error {
+	defer resp.Body.Close()
+	respBz, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+
+	var boardResp boardResponse
+	if err = json.Unmarshal(respBz, &boardResp); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if boardResp.ErrorMessage == "" {
+			return fmt.Errorf("board returned status %d", resp.StatusCode)
+		}
+		return errors.New(boardResp.ErrorMessage)
+	}
+	if err = json.Unmarshal(boardResp.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+	return nil
+}
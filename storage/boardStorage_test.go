@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestBoardStorage_SendGetMessages(t *testing.T) {
+	N := 10
+	var offset uint64 = 5
+	var testFile = "/tmp/dc4bc_test_board_storage"
+	fs, err := NewFileStorage(testFile, "/tmp/dc4bc_test_board_storage_lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	defer os.Remove(testFile)
+	defer os.Remove(testFile + indexFileSuffix)
+
+	server := httptest.NewServer(NewBoardServer(fs).Handler())
+	defer server.Close()
+
+	stg, err := NewBoardStorage(server.URL, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stg.Close()
+
+	msgs := make([]Message, 0, N)
+	for i := 0; i < N/2; i++ {
+		msg, err := stg.Send(Message{Event: fmt.Sprintf("event_%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	batch := make([]Message, 0, N/2)
+	for i := N / 2; i < N; i++ {
+		batch = append(batch, Message{Event: fmt.Sprintf("event_%d", i)})
+	}
+	batch, err = stg.SendBatch(batch...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs = append(msgs, batch...)
+
+	for i, msg := range msgs {
+		if msg.Offset != uint64(i) || msg.ID == "" {
+			t.Fatalf("expected a message with offset %d and an ID, got %v", i, msg)
+		}
+	}
+
+	offsetMsgs, err := stg.GetMessages(offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offsetMsgs) != N-int(offset) {
+		t.Fatalf("expected %d messages, got %d", N-int(offset), len(offsetMsgs))
+	}
+	for i, msg := range offsetMsgs {
+		expected := msgs[int(offset)+i]
+		if msg.ID != expected.ID || msg.Offset != expected.Offset || msg.Event != expected.Event {
+			t.Errorf("expected message: %v, actual message: %v", expected, msg)
+		}
+	}
+
+	offsetMsgs, err = stg.GetMessages(uint64(N))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offsetMsgs) != 0 {
+		t.Errorf("expected no messages, got %d", len(offsetMsgs))
+	}
+}
+
+func TestBoardStorage_Subscribe(t *testing.T) {
+	var testFile = "/tmp/dc4bc_test_board_storage_subscribe"
+	fs, err := NewFileStorage(testFile, "/tmp/dc4bc_test_board_storage_lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	defer os.Remove(testFile)
+	defer os.Remove(testFile + indexFileSuffix)
+
+	server := httptest.NewServer(NewBoardServer(fs).Handler())
+	defer server.Close()
+
+	stg, err := NewBoardStorage(server.URL, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stg.Close()
+
+	testStorageSubscribe(t, stg, 10, 5)
+}
+
+func TestBoardStorage_AppendToken(t *testing.T) {
+	var testFile = "/tmp/dc4bc_test_board_storage_token"
+	fs, err := NewFileStorage(testFile, "/tmp/dc4bc_test_board_storage_lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	defer os.Remove(testFile)
+	defer os.Remove(testFile + indexFileSuffix)
+
+	board := NewBoardServer(fs)
+	board.SetAppendToken("append_token")
+	server := httptest.NewServer(board.Handler())
+	defer server.Close()
+
+	stg, err := NewBoardStorage(server.URL, nil, "wrong_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stg.Send(Message{Event: "event"}); err == nil {
+		t.Fatal("message is sent with a wrong append token")
+	}
+	if _, err = stg.SendBatch(Message{Event: "event"}); err == nil {
+		t.Fatal("messages are sent with a wrong append token")
+	}
+
+	stg, err = NewBoardStorage(server.URL, nil, "append_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stg.Close()
+	if _, err = stg.Send(Message{Event: "event"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// anyone can read the log
+	stg, err = NewBoardStorage(server.URL, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := stg.GetMessages(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+
+	// a body over the limit isn't read to the end
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/send", bytes.NewReader(make([]byte, boardMaxRequestSize+1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer append_token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
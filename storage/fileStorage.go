@@ -2,10 +2,16 @@ package storage
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/juju/fslock"
@@ -13,29 +19,34 @@ import (
 
 var _ Storage = (*FileStorage)(nil)
 
+// FileStorage is an append-only log of JSON lines. A sidecar index file keeps the byte position
+// of every line, so an offset of a new message and a position of a given offset are found in O(1).
+// A message is written to the data file first and becomes visible when its index entry is written.
+// Complete lines after the last indexed one are indexed on recovery, only a truncated trailing line is dropped
 type FileStorage struct {
+	sync.Mutex
 	lockFile *fslock.Lock
 
-	dataFile *os.File
+	dataFile  *os.File
+	indexFile *os.File
+
+	// count is a number of committed messages, i.e. the next offset
+	count uint64
+	// dataEnd is a size of the data file holding committed messages
+	dataEnd int64
 }
 
 const (
 	defaultLockFile = "/tmp/dc4bc_storage_lock"
+	indexFileSuffix = ".index"
+	indexEntrySize  = 8
+	// fileTailingPeriod is how often a subscription checks the index for new messages
+	fileTailingPeriod = 100 * time.Millisecond
 )
 
-func countLines(r io.Reader) uint64 {
-	var count uint64
-	fileScanner := bufio.NewScanner(r)
-
-	for fileScanner.Scan() {
-		count++
-	}
-
-	return count
-}
-
 // NewFileStorage inits append-only file storage
-// It takes two arguments: filename - path to a data file, lockFilename (optional) - path to a lock file
+// It takes two arguments: filename - path to a data file, lockFilename (optional) - path to a lock file.
+// The index is kept next to the data file as filename.index, it's built from the data file if it does not exist
 func NewFileStorage(filename string, lockFilename ...string) (Storage, error) {
 	var (
 		fs  FileStorage
@@ -50,78 +61,297 @@ func NewFileStorage(filename string, lockFilename ...string) (Storage, error) {
 	if fs.dataFile, err = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644); err != nil {
 		return nil, fmt.Errorf("failed to open a data file: %v", err)
 	}
+	if fs.indexFile, err = os.OpenFile(filename+indexFileSuffix, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644); err != nil {
+		return nil, fmt.Errorf("failed to open an index file: %v", err)
+	}
+
+	if err = fs.lock(); err != nil {
+		return nil, err
+	}
+	defer fs.unlock()
+
+	if err = fs.recover(); err != nil {
+		return nil, fmt.Errorf("failed to recover a file storage: %v", err)
+	}
 	return &fs, nil
 }
 
-// Send sends a message to an append-only data file, returns a message with offset and id
-func (fs *FileStorage) Send(m Message) (Message, error) {
-	var (
-		data []byte
-		err  error
-	)
-	if err = fs.lockFile.Lock(); err != nil {
-		return m, fmt.Errorf("failed to lock a file: %v", err)
+func (fs *FileStorage) lock() error {
+	fs.Lock()
+	if err := fs.lockFile.Lock(); err != nil {
+		fs.Unlock()
+		return fmt.Errorf("failed to lock a file: %v", err)
 	}
-	defer fs.lockFile.Unlock()
+	return nil
+}
+
+func (fs *FileStorage) unlock() {
+	_ = fs.lockFile.Unlock()
+	fs.Unlock()
+}
+
+func (fs *FileStorage) fileSizes() (dataSize, indexSize int64, err error) {
+	dataInfo, err := fs.dataFile.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to stat a data file: %v", err)
+	}
+	indexInfo, err := fs.indexFile.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to stat an index file: %v", err)
+	}
+	return dataInfo.Size(), indexInfo.Size(), nil
+}
 
-	m.ID = uuid.New().String()
+// sync catches up with messages written by other FileStorage instances of the same files.
+// It must be called under the lock
+func (fs *FileStorage) sync() error {
+	dataSize, indexSize, err := fs.fileSizes()
+	if err != nil {
+		return err
+	}
+	if dataSize == fs.dataEnd && uint64(indexSize) == fs.count*indexEntrySize {
+		return nil
+	}
+	return fs.recover()
+}
+
+// recover restores the in-memory state from the files. It indexes lines which were written, but not indexed,
+// e.g. the whole data file written without the index, and drops a truncated trailing line.
+// It must be called under the lock
+func (fs *FileStorage) recover() error {
+	dataSize, indexSize, err := fs.fileSizes()
+	if err != nil {
+		return err
+	}
+
+	// drop a torn index entry and entries pointing outside of the data file
+	count := uint64(indexSize / indexEntrySize)
+	for count > 0 {
+		pos, err := fs.position(count - 1)
+		if err != nil {
+			return err
+		}
+		if pos < dataSize {
+			break
+		}
+		count--
+	}
+
+	var dataEnd int64
+	if count > 0 {
+		lastPos, err := fs.position(count - 1)
+		if err != nil {
+			return err
+		}
+		line, err := bufio.NewReader(io.NewSectionReader(fs.dataFile, lastPos, dataSize-lastPos)).ReadBytes('\n')
+		if err == io.EOF {
+			// the last indexed line is truncated, so is the message
+			count--
+			dataEnd = lastPos
+		} else if err != nil {
+			return fmt.Errorf("failed to read a data file: %v", err)
+		} else {
+			dataEnd = lastPos + int64(len(line))
+		}
+	}
+	if err = fs.indexFile.Truncate(int64(count * indexEntrySize)); err != nil {
+		return fmt.Errorf("failed to truncate an index file: %v", err)
+	}
+
+	return fs.indexLines(count, dataEnd, dataSize)
+}
+
+// indexLines indexes every complete line of the data file after the given position, which is the end
+// of the indexed lines, and drops a truncated trailing line
+func (fs *FileStorage) indexLines(count uint64, pos, dataSize int64) error {
+	var index bytes.Buffer
+	reader := bufio.NewReader(io.NewSectionReader(fs.dataFile, pos, dataSize-pos))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read a data file: %v", err)
+		}
+		index.Write(encodeIndexEntry(pos))
+		pos += int64(len(line))
+		count++
+	}
 
-	if _, err = fs.dataFile.Seek(0, 0); err != nil { // otherwise countLines will return zero
-		return m, fmt.Errorf("failed to seek a offset to the start of a data file: %v", err)
+	if pos < dataSize {
+		if err := fs.dataFile.Truncate(pos); err != nil {
+			return fmt.Errorf("failed to truncate a data file: %v", err)
+		}
 	}
-	m.Offset = countLines(fs.dataFile)
+	if _, err := fs.indexFile.Write(index.Bytes()); err != nil {
+		return fmt.Errorf("failed to write an index file: %v", err)
+	}
+	if err := fs.indexFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync an index file: %v", err)
+	}
+
+	fs.count, fs.dataEnd = count, pos
+	return nil
+}
+
+func encodeIndexEntry(pos int64) []byte {
+	entry := make([]byte, indexEntrySize)
+	binary.BigEndian.PutUint64(entry, uint64(pos))
+	return entry
+}
 
-	if data, err = json.Marshal(m); err != nil {
-		return m, fmt.Errorf("failed to marshal a message %v: %v", m, err)
+// position returns a byte position of a message with the given offset in the data file
+func (fs *FileStorage) position(offset uint64) (int64, error) {
+	entry := make([]byte, indexEntrySize)
+	if _, err := fs.indexFile.ReadAt(entry, int64(offset*indexEntrySize)); err != nil {
+		return 0, fmt.Errorf("failed to read an index file: %v", err)
 	}
+	return int64(binary.BigEndian.Uint64(entry)), nil
+}
 
-	if _, err = fmt.Fprintln(fs.dataFile, string(data)); err != nil {
-		return m, fmt.Errorf("failed to write a message to a data file: %v", err)
+// Send sends a message to an append-only data file, returns a message with offset and id
+func (fs *FileStorage) Send(m Message) (Message, error) {
+	msgs, err := fs.SendBatch(m)
+	if err != nil {
+		return m, err
 	}
-	return m, err
+	return msgs[0], nil
 }
 
+// SendBatch writes messages with a single write to the data file and commits them
+// with a single write to the index file, only a crash in between can leave a part of the batch sent
 func (fs *FileStorage) SendBatch(msgs ...Message) ([]Message, error) {
-	var err error
+	if err := fs.lock(); err != nil {
+		return msgs, err
+	}
+	defer fs.unlock()
+
+	if err := fs.sync(); err != nil {
+		return msgs, err
+	}
+
+	var (
+		data  bytes.Buffer
+		index bytes.Buffer
+		sent  = make([]Message, len(msgs))
+		now   = time.Now().UTC()
+	)
 	for i, m := range msgs {
-		msgs[i], err = fs.Send(m)
+		m.ID = uuid.New().String()
+		m.Offset = fs.count + uint64(i)
+		m.LogTime = now
+
+		row, err := json.Marshal(m)
 		if err != nil {
-			return msgs, err
+			return msgs, fmt.Errorf("failed to marshal a message %v: %v", m, err)
 		}
+		index.Write(encodeIndexEntry(fs.dataEnd + int64(data.Len())))
+		data.Write(row)
+		data.WriteByte('\n')
+		sent[i] = m
 	}
-	return msgs, nil
+
+	if err := fs.write(data.Bytes(), index.Bytes()); err != nil {
+		// roll back a partially written batch, if this fails too the next sync commits its complete lines
+		_ = fs.dataFile.Truncate(fs.dataEnd)
+		_ = fs.indexFile.Truncate(int64(fs.count * indexEntrySize))
+		return msgs, err
+	}
+
+	fs.count += uint64(len(msgs))
+	fs.dataEnd += int64(data.Len())
+	return sent, nil
+}
+
+func (fs *FileStorage) write(data, index []byte) error {
+	if _, err := fs.dataFile.Write(data); err != nil {
+		return fmt.Errorf("failed to write messages to a data file: %v", err)
+	}
+	if err := fs.dataFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync a data file: %v", err)
+	}
+	if _, err := fs.indexFile.Write(index); err != nil {
+		return fmt.Errorf("failed to write messages to an index file: %v", err)
+	}
+	if err := fs.indexFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync an index file: %v", err)
+	}
+	return nil
 }
 
 // GetMessages returns a slice of messages from append-only data file with given offset
 func (fs *FileStorage) GetMessages(offset uint64) ([]Message, error) {
-	var (
-		msgs []Message
-		err  error
-		row  []byte
-		data Message
-	)
-	if _, err = fs.dataFile.Seek(0, 0); err != nil {
-		return nil, fmt.Errorf("failed to seek a offset to the start of a data file: %v", err)
+	if err := fs.lock(); err != nil {
+		return nil, err
+	}
+	defer fs.unlock()
+
+	if err := fs.sync(); err != nil {
+		return nil, err
+	}
+	if offset >= fs.count {
+		return nil, nil
+	}
+
+	pos, err := fs.position(offset)
+	if err != nil {
+		return nil, err
 	}
-	scanner := bufio.NewScanner(fs.dataFile)
-	for scanner.Scan() {
-		if offset > 0 {
-			offset--
-			continue
-		}
 
-		row = scanner.Bytes()
-		if err = json.Unmarshal(row, &data); err != nil {
+	msgs := make([]Message, 0, fs.count-offset)
+	scanner := bufio.NewScanner(io.NewSectionReader(fs.dataFile, pos, fs.dataEnd-pos))
+	scanner.Buffer(nil, int(fs.dataEnd-pos)+1)
+	for scanner.Scan() {
+		var msg Message
+		row := scanner.Bytes()
+		if err = json.Unmarshal(row, &msg); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal a message %s: %v", string(row), err)
 		}
-		msgs = append(msgs, data)
+		msgs = append(msgs, msg)
 	}
-	if scanner.Err() != nil {
+	if err = scanner.Err(); err != nil {
 		return nil, fmt.Errorf("failed to read a data file: %v", err)
 	}
 	return msgs, nil
 }
 
+// Subscribe tails the data file, a check for new messages only stats the index file
+func (fs *FileStorage) Subscribe(ctx context.Context, offset uint64) (<-chan Message, error) {
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+
+		tk := time.NewTicker(fileTailingPeriod)
+		defer tk.Stop()
+		for {
+			msgs, err := fs.GetMessages(offset)
+			if err != nil {
+				log.Printf("failed to get messages from a file storage: %v", err)
+				return
+			}
+			for _, msg := range msgs {
+				select {
+				case messages <- msg:
+					offset = msg.Offset + 1
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-tk.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
+
 func (fs *FileStorage) Close() error {
+	if err := fs.indexFile.Close(); err != nil {
+		return err
+	}
 	return fs.dataFile.Close()
 }
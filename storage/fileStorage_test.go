@@ -1,6 +1,10 @@
 package storage
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"reflect"
@@ -27,6 +31,7 @@ func TestFileStorage_GetMessages(t *testing.T) {
 	}
 	defer fs.Close()
 	defer os.Remove(testFile)
+	defer os.Remove(testFile + indexFileSuffix)
 
 	msgs := make([]Message, 0, N)
 	for i := 0; i < N; i++ {
@@ -62,6 +67,7 @@ func TestFileStorage_SendBatch(t *testing.T) {
 	}
 	defer fs.Close()
 	defer os.Remove(testFile)
+	defer os.Remove(testFile + indexFileSuffix)
 
 	msgs := make([]Message, 0, N)
 	for i := 0; i < N; i++ {
@@ -76,6 +82,11 @@ func TestFileStorage_SendBatch(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	for _, msg := range sentMsgs {
+		if msg.LogTime.IsZero() {
+			t.Errorf("log time of message with offset %d is not set", msg.Offset)
+		}
+	}
 
 	offsetMsgs, err := fs.GetMessages(offset)
 	if err != nil {
@@ -87,3 +98,157 @@ func TestFileStorage_SendBatch(t *testing.T) {
 		t.Errorf("expected messages: %v, actual messages: %v", expectedOffsetMsgs, offsetMsgs)
 	}
 }
+
+func TestFileStorage_Recover(t *testing.T) {
+	N := 5
+	var testFile = "/tmp/dc4bc_test_file_storage_recover"
+	defer os.Remove(testFile)
+	defer os.Remove(testFile + indexFileSuffix)
+
+	// a data file written without an index and with a truncated trailing line after a crash
+	var data []byte
+	msgs := make([]Message, 0, N)
+	for i := 0; i < N; i++ {
+		msg := Message{
+			ID:     fmt.Sprintf("id_%d", i),
+			Offset: uint64(i),
+			Data:   randomBytes(10),
+		}
+		row, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, append(row, '\n')...)
+		msgs = append(msgs, msg)
+	}
+	if err := ioutil.WriteFile(testFile, append(data, []byte(`{"id":"trunc`)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := NewFileStorage(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offsetMsgs, err := fs.GetMessages(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(offsetMsgs, msgs) {
+		t.Errorf("expected messages: %v, actual messages: %v", msgs, offsetMsgs)
+	}
+
+	msg, err := fs.Send(Message{Data: randomBytes(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Offset != uint64(N) {
+		t.Errorf("expected offset %d, got %d", N, msg.Offset)
+	}
+	msgs = append(msgs, msg)
+	if err = fs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a message which was written to the data file, but was not committed to the index,
+	// and a truncated line of the next one
+	uncommitted := Message{ID: "uncommitted", Offset: uint64(N + 1), Data: randomBytes(10)}
+	row, err := json.Marshal(uncommitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataFile, err := os.OpenFile(testFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dataFile.Write(append(append(row, '\n'), []byte(`{"id":"trunc`)...)); err != nil {
+		t.Fatal(err)
+	}
+	dataFile.Close()
+	msgs = append(msgs, uncommitted)
+
+	fs, err = NewFileStorage(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	offsetMsgs, err = fs.GetMessages(uint64(N - 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(offsetMsgs, msgs[N-1:]) {
+		t.Errorf("expected messages: %v, actual messages: %v", msgs[N-1:], offsetMsgs)
+	}
+
+	msg, err = fs.Send(Message{Data: randomBytes(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Offset != uint64(N+2) {
+		t.Errorf("expected offset %d, got %d", N+2, msg.Offset)
+	}
+}
+
+func TestFileStorage_Subscribe(t *testing.T) {
+	N := 10
+	var offset uint64 = 5
+	var testFile = "/tmp/dc4bc_test_file_storage_subscribe"
+	fs, err := NewFileStorage(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	defer os.Remove(testFile)
+	defer os.Remove(testFile + indexFileSuffix)
+
+	testStorageSubscribe(t, fs, N, offset)
+}
+
+// testStorageSubscribe checks that a subscription gets both sent and new messages in order
+func testStorageSubscribe(t *testing.T, stg Storage, N int, offset uint64) {
+	msgs := make([]Message, 0, N)
+	for i := 0; i < N/2; i++ {
+		msg, err := stg.Send(Message{Event: fmt.Sprintf("event_%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	subscription, err := stg.Subscribe(ctx, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := N / 2; i < N; i++ {
+		msg, err := stg.Send(Message{Event: fmt.Sprintf("event_%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	for _, expected := range msgs[offset:] {
+		select {
+		case msg := <-subscription:
+			if msg.ID != expected.ID || msg.Offset != expected.Offset || msg.Event != expected.Event {
+				t.Fatalf("expected message: %v, actual message: %v", expected, msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message with offset %d was not received", expected.Offset)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-subscription:
+		if ok {
+			t.Fatal("expected the subscription to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription was not closed")
+	}
+}
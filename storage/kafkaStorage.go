@@ -194,12 +194,93 @@ func (s *KafkaStorage) getMessages(offset uint64) ([]Message, error) {
 		}
 
 		message.Offset = uint64(kafkaMessage.Offset)
+		message.LogTime = kafkaMessage.Time.UTC()
 		messages = append(messages, message)
 	}
 
 	return messages, nil
 }
 
+// Subscribe reads messages with a dedicated long-lived reader, which waits for new messages on the broker side
+func (s *KafkaStorage) Subscribe(ctx context.Context, offset uint64) (<-chan Message, error) {
+	reader, err := s.newSubscriptionReader(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+		defer func() { _ = reader.Close() }()
+
+		var failures int
+		for {
+			kafkaMessage, err := reader.ReadMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				failures++
+				log.Printf("failed to read a message (%v), trying to reconnect, %d retries left", err,
+					maxRetries-failures)
+				if failures >= maxRetries {
+					return
+				}
+				time.Sleep(reconnectInterval)
+
+				_ = reader.Close()
+				if reader, err = s.newSubscriptionReader(offset); err != nil {
+					log.Printf("failed to reconnect: %v", err)
+					return
+				}
+				continue
+			}
+			failures = 0
+
+			var message Message
+			if err = json.Unmarshal(kafkaMessage.Value, &message); err != nil {
+				log.Printf("failed to unmarshal a message %s: %v", string(kafkaMessage.Value), err)
+				return
+			}
+			message.Offset = uint64(kafkaMessage.Offset)
+			message.LogTime = kafkaMessage.Time.UTC()
+
+			select {
+			case messages <- message:
+				offset = message.Offset + 1
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return messages, nil
+}
+
+func (s *KafkaStorage) newSubscriptionReader(offset uint64) (*kafka.Reader, error) {
+	reader := s.newReader()
+	if err := reader.SetOffset(int64(offset)); err != nil {
+		_ = reader.Close()
+		return nil, fmt.Errorf("failed to SetOffset: %w", err)
+	}
+	return reader, nil
+}
+
+func (s *KafkaStorage) newReader() *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   []string{s.kafkaEndpoint},
+		Topic:     s.kafkaTopic,
+		Partition: kafkaPartition,
+		MaxWait:   time.Second,
+		Dialer: &kafka.Dialer{
+			Timeout:       10 * time.Second,
+			DualStack:     true,
+			TLS:           s.tlsConfig,
+			SASLMechanism: plain.Mechanism{Username: s.consumerCreds.Username, Password: s.consumerCreds.Password},
+		},
+	})
+}
+
 func (s *KafkaStorage) Close() error {
 	if s.writer != nil {
 		if err := s.writer.Close(); err != nil {
@@ -220,7 +301,6 @@ func (s *KafkaStorage) connect() error {
 	_ = s.Close()
 
 	mechanismProducer := plain.Mechanism{s.producerCreds.Username, s.producerCreds.Password}
-	mechanismConsumer := plain.Mechanism{s.consumerCreds.Username, s.consumerCreds.Password}
 
 	dialerProducer := &kafka.Dialer{
 		Timeout:       10 * time.Second,
@@ -228,27 +308,13 @@ func (s *KafkaStorage) connect() error {
 		TLS:           s.tlsConfig,
 		SASLMechanism: mechanismProducer,
 	}
-	dialerConsumer := &kafka.Dialer{
-		Timeout:       10 * time.Second,
-		DualStack:     true,
-		TLS:           s.tlsConfig,
-		SASLMechanism: mechanismConsumer,
-	}
 
 	conn, err := dialerProducer.DialLeader(s.ctx, "tcp", s.kafkaEndpoint, s.kafkaTopic, kafkaPartition)
 	if err != nil {
 		return fmt.Errorf("failed to init Kafka client: %w", err)
 	}
 
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:   []string{s.kafkaEndpoint},
-		Topic:     s.kafkaTopic,
-		Partition: kafkaPartition,
-		MaxWait:   time.Second,
-		Dialer:    dialerConsumer,
-	})
-
-	s.writer, s.reader = conn, reader
+	s.writer, s.reader = conn, s.newReader()
 
 	return nil
 }
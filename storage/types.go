@@ -2,7 +2,23 @@ package storage
 
 import (
 	"bytes"
+	"context"
 	"crypto/ed25519"
+	"encoding/binary"
+	"time"
+)
+
+const (
+	// MessageVersionLegacy is a version of messages built by older clients, whose signature covers only the data
+	MessageVersionLegacy uint8 = 0
+	// MessageVersionEnvelope is a version of messages, whose signature covers a canonical envelope
+	// of all fields set by a sender
+	MessageVersionEnvelope uint8 = 1
+	// CurrentMessageVersion is a version of messages built by clients
+	CurrentMessageVersion = MessageVersionEnvelope
+
+	// messageDomainTag separates signatures of messages from signatures of any other data made with the same key
+	messageDomainTag = "dc4bc/storage.Message"
 )
 
 type Message struct {
@@ -14,15 +30,50 @@ type Message struct {
 	Signature     []byte `json:"signature"`
 	SenderAddr    string `json:"sender"`
 	RecipientAddr string `json:"recipient"`
+	// Timestamp is set by the sender and signed with the data
+	Timestamp time.Time `json:"timestamp"`
+	// LogTime is assigned by the storage when the message is appended, so it's never signed either.
+	// Unlike Timestamp it can't be chosen by the sender, so it is the time of FSM transitions
+	// and the log replays to the same state
+	LogTime time.Time `json:"log_time"`
+	// Version defines which bytes of the message are signed, see Bytes
+	Version uint8 `json:"version,omitempty"`
 }
 
+// Bytes returns the signed bytes of the message. ID, Offset and LogTime are assigned by a storage, so they are never signed.
+// A legacy message signs the data only, exactly as older clients did, so its timestamp isn't signed either.
+// A message of any other version signs an envelope: the domain tag, the version, length-prefixed DkgRoundID, Event,
+// SenderAddr, RecipientAddr and Data, the timestamp
 func (m *Message) Bytes() []byte {
 	buf := bytes.NewBuffer(nil)
-	buf.Write(m.Data)
+
+	if m.Version == MessageVersionLegacy {
+		buf.Write(m.Data)
+		return buf.Bytes()
+	}
+
+	writeLengthPrefixed(buf, []byte(messageDomainTag))
+	buf.WriteByte(m.Version)
+	writeLengthPrefixed(buf, []byte(m.DkgRoundID))
+	writeLengthPrefixed(buf, []byte(m.Event))
+	writeLengthPrefixed(buf, []byte(m.SenderAddr))
+	writeLengthPrefixed(buf, []byte(m.RecipientAddr))
+	writeLengthPrefixed(buf, m.Data)
+
+	timestamp := make([]byte, 8)
+	binary.BigEndian.PutUint64(timestamp, uint64(m.Timestamp.UnixNano()))
+	buf.Write(timestamp)
 
 	return buf.Bytes()
 }
 
+func writeLengthPrefixed(buf *bytes.Buffer, data []byte) {
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(data)))
+	buf.Write(length)
+	buf.Write(data)
+}
+
 func (m *Message) Verify(pubKey ed25519.PublicKey) bool {
 	return ed25519.Verify(pubKey, m.Bytes(), m.Signature)
 }
@@ -31,5 +82,8 @@ type Storage interface {
 	Send(message Message) (Message, error)
 	SendBatch(messages ...Message) ([]Message, error) //expected to be an atomic operation
 	GetMessages(offset uint64) ([]Message, error)
+	// Subscribe streams messages starting from the given offset, including the ones sent later.
+	// The channel is closed when the context is done or the storage fails to read messages
+	Subscribe(ctx context.Context, offset uint64) (<-chan Message, error)
 	Close() error
 }
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestMessage_Bytes(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := Message{
+		ID:            "id",
+		DkgRoundID:    "dkg_round_id",
+		Offset:        1,
+		Event:         "event",
+		Data:          []byte("data"),
+		SenderAddr:    "sender",
+		RecipientAddr: "recipient",
+		Timestamp:     time.Now(),
+		Version:       CurrentMessageVersion,
+	}
+	message.Signature = ed25519.Sign(priv, message.Bytes())
+	if !message.Verify(pub) {
+		t.Fatal("expected the signature to be valid")
+	}
+
+	// fields assigned by a storage are not signed
+	assigned := message
+	assigned.ID, assigned.Offset = "another_id", 2
+	if !assigned.Verify(pub) {
+		t.Error("expected the signature to be valid after a storage assigned ID and offset")
+	}
+
+	for name, tamper := range map[string]func(m *Message){
+		"dkg_round_id": func(m *Message) { m.DkgRoundID = "another_dkg_round_id" },
+		"event":        func(m *Message) { m.Event = "another_event" },
+		"sender":       func(m *Message) { m.SenderAddr = "another_sender" },
+		"recipient":    func(m *Message) { m.RecipientAddr = "" },
+		"data":         func(m *Message) { m.Data = []byte("another_data") },
+		"timestamp":    func(m *Message) { m.Timestamp = m.Timestamp.Add(time.Second) },
+		"version":      func(m *Message) { m.Version = MessageVersionLegacy },
+		// moving bytes between fields must change the envelope too
+		"boundary": func(m *Message) { m.DkgRoundID, m.Event = "dkg_round_ide", "vent" },
+	} {
+		tampered := message
+		tamper(&tampered)
+		if tampered.Verify(pub) {
+			t.Errorf("expected the signature to be invalid after tampering with %s", name)
+		}
+	}
+
+	legacy := message
+	legacy.Version = MessageVersionLegacy
+	legacy.Event = "another_event"
+	if !bytes.Equal(legacy.Bytes(), []byte("data")) {
+		t.Error("expected a legacy message to sign the data only")
+	}
+}
+
+func TestMessage_VerifyLegacy(t *testing.T) {
+	// the message is signed by a client built before messages got versions
+	messageBz, err := ioutil.ReadFile("testdata/legacy_message.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message Message
+	if err = json.Unmarshal(messageBz, &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Version != MessageVersionLegacy || !message.Timestamp.IsZero() {
+		t.Fatalf("expected a legacy message without a timestamp, got version %d and timestamp %s",
+			message.Version, message.Timestamp)
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	copy(seed, "dc4bc legacy message test seed")
+	pub := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+	if !message.Verify(pub) {
+		t.Fatal("expected the signature of the legacy message to be valid")
+	}
+
+	message.Data = append(message.Data, ' ')
+	if message.Verify(pub) {
+		t.Error("expected the signature to be invalid after tampering with data")
+	}
+}